@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/dgunay/flag-exorcist/flagexorcist"
+	"github.com/ilyakaznacheev/cleanenv"
+)
+
+// doctor checks the setup and says how to fix whatever is wrong with it, so
+// that onboarding doesn't take trial and error, or end in a run that
+// silently checks nothing.
+func doctor(args []string) error {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: flag-exorcist doctor [packages]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	checks := []flagexorcist.Check{checkConfig()}
+	if checks[0].OK {
+		pkgs, err := loadPackages(fs.Args())
+		if err != nil {
+			checks = append(checks, flagexorcist.Check{
+				Name:   "packages",
+				Detail: err.Error(),
+				Fix:    "make sure `go build` works for the packages given",
+			})
+		} else {
+			checks = append(checks, flagexorcist.Doctor(pkgs)...)
+		}
+	}
+
+	failed := 0
+	for _, check := range checks {
+		mark := "ok  "
+		if !check.OK {
+			mark = "FAIL"
+			failed++
+		}
+		line := fmt.Sprintf("%s %s", mark, check.Name)
+		if check.Detail != "" {
+			line += ": " + check.Detail
+		}
+		fmt.Println(line)
+		if check.Fix != "" {
+			fmt.Printf("     fix: %s\n", check.Fix)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(checks))
+	}
+	return nil
+}
+
+// checkConfig checks that the config can be read from the environment and
+// initializes the analyzer with it.
+func checkConfig() flagexorcist.Check {
+	check := flagexorcist.Check{Name: "config"}
+	cfg := flagexorcist.Config{}
+	if err := cleanenv.ReadEnv(&cfg); err != nil {
+		check.Detail = err.Error()
+		check.Fix = "correct the environment variable named above"
+		return check
+	}
+
+	if err := flagexorcist.Initialize(cfg); err != nil {
+		check.Detail = err.Error()
+		check.Fix = "correct the setting named above"
+		return check
+	}
+
+	// Counted once initialized, since the flag registry adds to them
+	symbols, keys := flagexorcist.Tracked()
+	check.OK = true
+	check.Detail = fmt.Sprintf("%d symbols and %d keys tracked, cutoff %v",
+		len(symbols), len(keys), cfg.Cutoff,
+	)
+	return check
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Not parallel, since it sets the environment and configures the analyzer.
+func TestCheckConfig(t *testing.T) {
+	registry := filepath.Join(t.TempDir(), "flags.yaml")
+	contents := "flags:\n  - symbol: EnableX\n  - symbol: EnableSearch\n  - key: new-checkout\n"
+	if err := os.WriteFile(registry, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CUTOFF", "30d")
+	t.Setenv("FLAG_SYMBOLS", "EnableX")
+	t.Setenv("FLAG_REGISTRY", registry)
+
+	// The flags from the registry are counted, once each
+	check := checkConfig()
+	if !check.OK || !strings.HasPrefix(check.Detail, "2 symbols and 1 keys tracked") {
+		t.Errorf("checkConfig() = %+v, want 2 symbols and 1 key tracked", check)
+	}
+
+	t.Setenv("FLAG_SYMBOLS", "")
+	t.Setenv("FLAG_REGISTRY", "")
+	if check := checkConfig(); check.OK || check.Fix == "" {
+		t.Errorf("checkConfig() with no flags = %+v, want it to fail with a fix", check)
+	}
+}
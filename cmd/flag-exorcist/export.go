@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/constant"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/dgunay/flag-exorcist/flagexorcist"
+)
+
+const flagdSchema = "https://flagd.dev/schema/v0/flags.json"
+
+// flagdManifest is a flagd flag definition file, which OpenFeature's flagd
+// provider reads.
+type flagdManifest struct {
+	Schema string               `json:"$schema"`
+	Flags  map[string]flagdFlag `json:"flags"`
+}
+
+type flagdFlag struct {
+	State          string         `json:"state"`
+	Variants       map[string]any `json:"variants"`
+	DefaultVariant string         `json:"defaultVariant"`
+	Metadata       map[string]any `json:"metadata,omitempty"`
+}
+
+// export writes the inventory of tracked flags as a manifest for a managed
+// flag system.
+func export(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", "openfeature", "the manifest format; only openfeature (flagd) is supported")
+	out := fs.String("out", "", "the file to write the manifest to (defaults to stdout)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: flag-exorcist export [--format openfeature] [--out file] [packages]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != "openfeature" {
+		return fmt.Errorf("unknown export format %q", *format)
+	}
+
+	if _, err := initialize(); err != nil {
+		return err
+	}
+	pkgs, err := loadPackages(fs.Args())
+	if err != nil {
+		return err
+	}
+	manifest := openFeatureManifest(flagexorcist.Inventory(pkgs))
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(manifest)
+}
+
+// openFeatureManifest builds a flagd manifest skeleton from the inventory.
+// Flags whose type can't be told are assumed to be booleans, since most ad-hoc
+// flags are, and flags with no known default start out off.
+func openFeatureManifest(items []flagexorcist.InventoryItem) flagdManifest {
+	manifest := flagdManifest{Schema: flagdSchema, Flags: map[string]flagdFlag{}}
+	for _, item := range items {
+		key := item.Flag.Name
+		metadata := map[string]any{}
+		if item.Flag.Kind == flagexorcist.FlagKindSymbol {
+			key = kebabCase(item.Flag.Name)
+			metadata["goSymbol"] = item.Flag.String()
+		}
+
+		f := flagdFlag{State: "ENABLED", Metadata: metadata}
+		switch value := item.Default; item.Type {
+		case flagexorcist.FlagTypeString:
+			f.Variants = map[string]any{"default": stringValue(value)}
+			f.DefaultVariant = "default"
+		case flagexorcist.FlagTypeInteger:
+			i, _ := constant.Int64Val(numericValue(value, constant.Int))
+			f.Variants = map[string]any{"default": i}
+			f.DefaultVariant = "default"
+		case flagexorcist.FlagTypeFloat:
+			v, _ := constant.Float64Val(numericValue(value, constant.Float))
+			f.Variants = map[string]any{"default": v}
+			f.DefaultVariant = "default"
+		case flagexorcist.FlagTypeObject:
+			f.Variants = map[string]any{"default": map[string]any{}}
+			f.DefaultVariant = "default"
+		default:
+			f.Variants = map[string]any{"on": true, "off": false}
+			f.DefaultVariant = "off"
+			if value != nil && value.Kind() == constant.Bool && constant.BoolVal(value) {
+				f.DefaultVariant = "on"
+			}
+		}
+		if len(f.Metadata) == 0 {
+			f.Metadata = nil
+		}
+
+		manifest.Flags[key] = f
+	}
+	return manifest
+}
+
+func stringValue(v constant.Value) string {
+	if v == nil || v.Kind() != constant.String {
+		return ""
+	}
+	return constant.StringVal(v)
+}
+
+// numericValue converts v to kind, or returns zero if v isn't a number.
+func numericValue(v constant.Value, kind constant.Kind) constant.Value {
+	if v != nil {
+		switch kind {
+		case constant.Int:
+			if v := constant.ToInt(v); v.Kind() == constant.Int {
+				return v
+			}
+		case constant.Float:
+			if v := constant.ToFloat(v); v.Kind() == constant.Float {
+				return v
+			}
+		}
+	}
+	return constant.MakeInt64(0)
+}
+
+// kebabCase turns a Go identifier like EnableHTTPCheckout into a flag key
+// like enable-http-checkout.
+func kebabCase(name string) string {
+	runes := []rune(name)
+	b := &strings.Builder{}
+	for i, c := range runes {
+		if unicode.IsUpper(c) && i > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				b.WriteRune('-')
+			}
+		}
+		if c == '_' {
+			c = '-'
+		}
+		b.WriteRune(unicode.ToLower(c))
+	}
+	return b.String()
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"go/constant"
+	"reflect"
+	"testing"
+
+	"github.com/dgunay/flag-exorcist/flagexorcist"
+)
+
+func TestOpenFeatureManifest(t *testing.T) {
+	t.Parallel()
+
+	symbol := func(name string) flagexorcist.FlagID {
+		return flagexorcist.FlagID{Kind: flagexorcist.FlagKindSymbol, Namespace: "a", Name: name}
+	}
+	manifest := openFeatureManifest([]flagexorcist.InventoryItem{
+		{Flag: symbol("EnableCheckout"), Type: flagexorcist.FlagTypeBoolean, Default: constant.MakeBool(true)},
+		{Flag: symbol("CheckoutTheme"), Type: flagexorcist.FlagTypeString, Default: constant.MakeString("dark")},
+		{Flag: symbol("MaxRetries"), Type: flagexorcist.FlagTypeInteger, Default: constant.MakeInt64(3)},
+		{Flag: flagexorcist.KeyID("new-search")},
+	})
+
+	want := map[string]flagdFlag{
+		"enable-checkout": {
+			State:          "ENABLED",
+			Variants:       map[string]any{"on": true, "off": false},
+			DefaultVariant: "on",
+			Metadata:       map[string]any{"goSymbol": symbol("EnableCheckout").String()},
+		},
+		"checkout-theme": {
+			State:          "ENABLED",
+			Variants:       map[string]any{"default": "dark"},
+			DefaultVariant: "default",
+			Metadata:       map[string]any{"goSymbol": symbol("CheckoutTheme").String()},
+		},
+		"max-retries": {
+			State:          "ENABLED",
+			Variants:       map[string]any{"default": int64(3)},
+			DefaultVariant: "default",
+			Metadata:       map[string]any{"goSymbol": symbol("MaxRetries").String()},
+		},
+		// Keys are kept as they are, and start out off
+		"new-search": {
+			State:          "ENABLED",
+			Variants:       map[string]any{"on": true, "off": false},
+			DefaultVariant: "off",
+		},
+	}
+	if manifest.Schema != flagdSchema {
+		t.Errorf("openFeatureManifest() schema = %q, want %q", manifest.Schema, flagdSchema)
+	}
+	if !reflect.DeepEqual(manifest.Flags, want) {
+		t.Errorf("openFeatureManifest() flags = %+v, want %+v", manifest.Flags, want)
+	}
+}
+
+func TestKebabCase(t *testing.T) {
+	t.Parallel()
+
+	for name, want := range map[string]string{
+		"EnableX":            "enable-x",
+		"EnableHTTPCheckout": "enable-http-checkout",
+		"enableV2Search":     "enable-v2-search",
+		"ENABLE_SEARCH":      "enable-search",
+	} {
+		if got := kebabCase(name); got != want {
+			t.Errorf("kebabCase(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dgunay/flag-exorcist/flagexorcist"
+	"github.com/dgunay/flag-exorcist/flagexorcist/github"
+	"github.com/ilyakaznacheev/cleanenv"
+)
+
+const checkRunName = "Flag Exorcist"
+
+// Settings for the GitHub Checks integration. The defaults line up with the
+// environment GitHub Actions provides.
+type githubCheckConfig struct {
+	Token      string `env:"GITHUB_TOKEN" env-required:"true"`
+	Repository string `env:"GITHUB_REPOSITORY" env-required:"true"`
+	SHA        string `env:"GITHUB_SHA" env-required:"true"`
+	APIURL     string `env:"GITHUB_API_URL" env-default:"https://api.github.com"`
+}
+
+// githubCheck runs the analyzer and publishes the results as a check run.
+func githubCheck(args []string) error {
+	fs := flag.NewFlagSet("github-check", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: flag-exorcist github-check [packages]")
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ghCfg := githubCheckConfig{}
+	if err := cleanenv.ReadEnv(&ghCfg); err != nil {
+		return err
+	}
+
+	if _, err := initialize(); err != nil {
+		return err
+	}
+	findings, err := flagexorcist.Run(fs.Args()...)
+	if err != nil {
+		return err
+	}
+
+	run := checkRun(findings)
+	run.HeadSHA = ghCfg.SHA
+
+	// GitHub only takes so many annotations at a time, so the rest are added
+	// by updating the check run.
+	annotations := run.Output.Annotations
+	run.Output.Annotations, annotations = splitAnnotations(annotations)
+
+	ctx := context.Background()
+	client := github.NewClient(ghCfg.APIURL, ghCfg.Token)
+	created, err := client.CreateCheckRun(ctx, ghCfg.Repository, run)
+	if err != nil {
+		return err
+	}
+
+	for len(annotations) > 0 {
+		var batch []github.Annotation
+		batch, annotations = splitAnnotations(annotations)
+
+		output := *run.Output
+		output.Annotations = batch
+		err := client.UpdateCheckRun(ctx, ghCfg.Repository, created.ID, github.CheckRun{
+			Output: &output,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Created check run %q: %s\n", checkRunName, run.Conclusion)
+	return nil
+}
+
+// splitAnnotations splits off as many annotations as can be sent in one
+// request.
+func splitAnnotations(annotations []github.Annotation) (batch, rest []github.Annotation) {
+	if len(annotations) <= github.MaxAnnotations {
+		return annotations, nil
+	}
+	return annotations[:github.MaxAnnotations], annotations[github.MaxAnnotations:]
+}
+
+// checkRun builds a completed check run describing findings.
+func checkRun(findings []flagexorcist.Finding) github.CheckRun {
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.Position.Line < b.Position.Line
+	})
+
+	conclusion := "success"
+	byFlag := map[flagexorcist.FlagID][]flagexorcist.Finding{}
+	flags := []flagexorcist.FlagID{}
+	annotations := []github.Annotation{}
+	for _, f := range findings {
+		if !hasKey(byFlag, f.Flag) {
+			flags = append(flags, f.Flag)
+		}
+		byFlag[f.Flag] = append(byFlag[f.Flag], f)
+
+		// Findings about unenforced or snoozed flags are only notices, which
+		// leave the check passing
+		level := "notice"
+		if f.Enforced && f.Snoozed == nil {
+			switch f.Severity {
+			case flagexorcist.SeverityError:
+				level = "failure"
+				conclusion = "failure"
+			case flagexorcist.SeverityWarning:
+				level = "warning"
+				if conclusion == "success" {
+					conclusion = "neutral"
+				}
+			}
+		}
+
+		for _, u := range usages(f) {
+			annotations = append(annotations, github.Annotation{
+				Path:            u.Path,
+				StartLine:       u.Position.Line,
+				EndLine:         u.Position.Line,
+				AnnotationLevel: level,
+				Title:           f.Flag.String(),
+				Message:         f.Message,
+			})
+		}
+	}
+	sort.Slice(flags, func(i, j int) bool {
+		return flags[i].String() < flags[j].String()
+	})
+
+	summary := &strings.Builder{}
+	text := &strings.Builder{}
+	if len(flags) == 0 {
+		summary.WriteString("No stale flags found.\n")
+	} else {
+		summary.WriteString("| Flag | Introduced | Findings | Usages | Files | Severity |\n")
+		summary.WriteString("| --- | --- | --- | --- | --- | --- |\n")
+	}
+	for _, flag := range flags {
+		fs := byFlag[flag]
+		count, files := 0, map[string]bool{}
+		for _, f := range fs {
+			count += f.UsageCount
+			for _, u := range f.Usages {
+				files[u.Path] = true
+			}
+		}
+		severity := string(fs[0].Severity)
+		if fs[0].Snoozed != nil {
+			severity = fs[0].Snoozed.String()
+		}
+		fmt.Fprintf(summary, "| `%s` | %s | %d | %d | %d | %s |\n",
+			flag, fs[0].IntroducedAt.UTC().Format("2006-01-02"), len(fs), count, len(files),
+			severity,
+		)
+
+		fmt.Fprintf(text, "### `%s`\n\n", flag)
+		if fs[0].Description != "" {
+			fmt.Fprintf(text, "%s\n\n", fs[0].Description)
+		}
+		if owners := fs[0].CodeOwners; len(owners) > 0 {
+			fmt.Fprintf(text, "Owned by %s\n\n", strings.Join(owners, " "))
+		}
+		if tickets := fs[0].Tickets; len(tickets) > 0 {
+			fmt.Fprintf(text, "Tracked in %s\n\n", strings.Join(tickets, ", "))
+		}
+		if fs[0].Author != "" {
+			fmt.Fprintf(text, "Added by %s\n\n", fs[0].Author)
+		}
+		if fs[0].Commit != "" {
+			// GitHub links full commit hashes
+			fmt.Fprintf(text, "Added in %s %s\n\n", fs[0].Commit, fs[0].CommitSubject)
+		}
+		for _, f := range fs {
+			fmt.Fprintf(text, "- %s\n", f.Message)
+			for _, u := range usages(f) {
+				fmt.Fprintf(text, "  - `%s:%d`", u.Path, u.Position.Line)
+				if u.Source != "" {
+					fmt.Fprintf(text, " (%s)", u.Source)
+				}
+				text.WriteString("\n")
+			}
+		}
+		text.WriteString("\n")
+	}
+
+	return github.CheckRun{
+		Name:       checkRunName,
+		Status:     "completed",
+		Conclusion: conclusion,
+		Output: &github.CheckRunOutput{
+			Title:       fmt.Sprintf("%d stale flags, %d findings", len(flags), len(findings)),
+			Summary:     summary.String(),
+			Text:        text.String(),
+			Annotations: annotations,
+		},
+	}
+}
+
+// usages returns every usage a finding covers. Findings that aren't about
+// usages, like ones about missing history, cover only their own position.
+func usages(f flagexorcist.Finding) []flagexorcist.Usage {
+	if len(f.Usages) > 0 {
+		return f.Usages
+	}
+	return []flagexorcist.Usage{{Position: f.Position, Path: f.Path}}
+}
+
+func hasKey[K comparable, V any](m map[K]V, k K) bool {
+	_, ok := m[k]
+	return ok
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dgunay/flag-exorcist/flagexorcist"
+	"github.com/dgunay/flag-exorcist/flagexorcist/github"
+)
+
+func TestCheckRun(t *testing.T) {
+	t.Parallel()
+
+	finding := func(severity flagexorcist.Severity, enforced bool, snoozed bool) flagexorcist.Finding {
+		f := flagexorcist.Finding{
+			Flag:     flagexorcist.SymbolID("EnableX"),
+			Severity: severity,
+			Enforced: enforced,
+			Path:     "a/a.go",
+		}
+		if snoozed {
+			f.Snoozed = &flagexorcist.Snooze{Until: time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)}
+		}
+		return f
+	}
+
+	tests := []struct {
+		name           string
+		findings       []flagexorcist.Finding
+		wantConclusion string
+		wantLevels     []string
+	}{
+		{
+			name:           "no findings",
+			wantConclusion: "success",
+			wantLevels:     []string{},
+		},
+		{
+			name:           "error",
+			findings:       []flagexorcist.Finding{finding(flagexorcist.SeverityError, true, false)},
+			wantConclusion: "failure",
+			wantLevels:     []string{"failure"},
+		},
+		{
+			name:           "warning",
+			findings:       []flagexorcist.Finding{finding(flagexorcist.SeverityWarning, true, false)},
+			wantConclusion: "neutral",
+			wantLevels:     []string{"warning"},
+		},
+		{
+			name: "error and warning",
+			findings: []flagexorcist.Finding{
+				finding(flagexorcist.SeverityError, true, false),
+				finding(flagexorcist.SeverityWarning, true, false),
+			},
+			wantConclusion: "failure",
+			wantLevels:     []string{"failure", "warning"},
+		},
+		{
+			name:           "unenforced error",
+			findings:       []flagexorcist.Finding{finding(flagexorcist.SeverityError, false, false)},
+			wantConclusion: "success",
+			wantLevels:     []string{"notice"},
+		},
+		{
+			name:           "snoozed error",
+			findings:       []flagexorcist.Finding{finding(flagexorcist.SeverityError, true, true)},
+			wantConclusion: "success",
+			wantLevels:     []string{"notice"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			run := checkRun(tt.findings)
+			if run.Conclusion != tt.wantConclusion {
+				t.Errorf("checkRun() conclusion = %q, want %q", run.Conclusion, tt.wantConclusion)
+			}
+			levels := []string{}
+			for _, a := range run.Output.Annotations {
+				levels = append(levels, a.AnnotationLevel)
+			}
+			if len(levels) != len(tt.wantLevels) {
+				t.Fatalf("checkRun() annotation levels = %v, want %v", levels, tt.wantLevels)
+			}
+			for i := range levels {
+				if levels[i] != tt.wantLevels[i] {
+					t.Errorf("checkRun() annotation levels = %v, want %v", levels, tt.wantLevels)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestSplitAnnotations(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name                string
+		count               int
+		wantBatch, wantRest int
+	}{
+		{name: "none", count: 0, wantBatch: 0, wantRest: 0},
+		{name: "fewer than the limit", count: 3, wantBatch: 3, wantRest: 0},
+		{name: "exactly the limit", count: github.MaxAnnotations, wantBatch: github.MaxAnnotations},
+		{
+			name:      "over the limit",
+			count:     github.MaxAnnotations*2 + 1,
+			wantBatch: github.MaxAnnotations,
+			wantRest:  github.MaxAnnotations + 1,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			annotations := make([]github.Annotation, tt.count)
+			for i := range annotations {
+				annotations[i].StartLine = i
+			}
+			batch, rest := splitAnnotations(annotations)
+			if len(batch) != tt.wantBatch || len(rest) != tt.wantRest {
+				t.Fatalf("splitAnnotations() = %d, %d annotations, want %d, %d",
+					len(batch), len(rest), tt.wantBatch, tt.wantRest)
+			}
+			// Nothing is lost or reordered
+			for i, a := range append(batch, rest...) {
+				if a.StartLine != i {
+					t.Fatalf("splitAnnotations() put annotation %d at %d", a.StartLine, i)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dgunay/flag-exorcist/flagexorcist"
+	"github.com/dgunay/flag-exorcist/flagexorcist/github"
+	"github.com/ilyakaznacheev/cleanenv"
+)
+
+// Settings for filing issues. The defaults line up with the environment
+// GitHub Actions provides.
+type githubIssuesConfig struct {
+	Token      string `env:"GITHUB_TOKEN" env-required:"true"`
+	Repository string `env:"GITHUB_REPOSITORY" env-required:"true"`
+	APIURL     string `env:"GITHUB_API_URL" env-default:"https://api.github.com"`
+
+	// For linking to the code. Links point at SHA, or the default branch if
+	// it isn't set.
+	ServerURL string `env:"GITHUB_SERVER_URL" env-default:"https://github.com"`
+	SHA       string `env:"GITHUB_SHA"`
+
+	// The label that marks the issues flag-exorcist manages. Open issues with
+	// it are updated instead of opening duplicates.
+	Label string `env:"ISSUE_LABEL" env-default:"stale-flag"`
+}
+
+// githubIssues runs the analyzer and opens an issue for each flag past the
+// cutoff, or updates the one already open.
+func githubIssues(args []string) error {
+	fs := flag.NewFlagSet("github-issues", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "print the issues instead of filing them")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: flag-exorcist github-issues [--dry-run] [packages]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ghCfg := githubIssuesConfig{}
+	if err := cleanenv.ReadEnv(&ghCfg); err != nil {
+		return err
+	}
+
+	if _, err := initialize(); err != nil {
+		return err
+	}
+	findings, err := flagexorcist.Run(fs.Args()...)
+	if err != nil {
+		return err
+	}
+	wanted := staleFlagIssues(findings, ghCfg)
+
+	if *dryRun {
+		for _, issue := range wanted {
+			fmt.Printf("## %s\n\n%s\n", issue.Title, issue.Body)
+		}
+		return nil
+	}
+
+	ctx := context.Background()
+	client := github.NewClient(ghCfg.APIURL, ghCfg.Token)
+	open, err := client.ListIssues(ctx, ghCfg.Repository, ghCfg.Label)
+	if err != nil {
+		return err
+	}
+	byTitle := map[string]github.Issue{}
+	for _, issue := range open {
+		byTitle[issue.Title] = issue
+	}
+
+	for _, issue := range wanted {
+		if existing, ok := byTitle[issue.Title]; ok {
+			if existing.Body == issue.Body {
+				continue
+			}
+			issue.Labels = nil
+			updated, err := client.UpdateIssue(ctx, ghCfg.Repository, existing.Number, issue)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Updated %s\n", updated.HTMLURL)
+			continue
+		}
+
+		created, err := client.CreateIssue(ctx, ghCfg.Repository, issue)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Opened %s\n", created.HTMLURL)
+	}
+	return nil
+}
+
+// staleFlagIssues builds an issue for each flag past the cutoff that isn't
+// snoozed, sorted by title.
+func staleFlagIssues(
+	findings []flagexorcist.Finding, ghCfg githubIssuesConfig,
+) []github.IssueRequest {
+	byFlag := map[flagexorcist.FlagID][]flagexorcist.Finding{}
+	for _, f := range findings {
+		if flagexorcist.PastCutoff(f.Category) && f.Snoozed == nil {
+			byFlag[f.Flag] = append(byFlag[f.Flag], f)
+		}
+	}
+
+	issues := []github.IssueRequest{}
+	for flag, fs := range byFlag {
+		issues = append(issues, github.IssueRequest{
+			Title:  fmt.Sprintf("Remove stale flag %s", flag),
+			Body:   issueBody(flag, fs, ghCfg),
+			Labels: []string{ghCfg.Label},
+		})
+	}
+	sort.Slice(issues, func(i, j int) bool {
+		return issues[i].Title < issues[j].Title
+	})
+	return issues
+}
+
+// issueBody describes a stale flag: where it is declared, how old it is, who
+// owns it, and everywhere it is used.
+func issueBody(
+	flag flagexorcist.FlagID, fs []flagexorcist.Finding, ghCfg githubIssuesConfig,
+) string {
+	first := fs[0]
+	for _, f := range fs {
+		if f.IntroducedAt.Before(first.IntroducedAt) {
+			first = f
+		}
+	}
+
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "`%s` is past its cutoff and should be removed.\n\n", flag)
+	if first.Description != "" {
+		fmt.Fprintf(b, "%s\n\n", first.Description)
+	}
+	if first.DeclarationPath != "" {
+		fmt.Fprintf(b, "- **Declared:** %s\n",
+			codeLink(ghCfg, first.DeclarationPath, first.Declaration.Line),
+		)
+	}
+	// An absolute date, so the body doesn't change (and the issue isn't
+	// updated) just because a day went by
+	fmt.Fprintf(b, "- **Introduced:** %s\n", first.IntroducedAt.UTC().Format("2006-01-02"))
+	if len(first.CodeOwners) > 0 {
+		fmt.Fprintf(b, "- **Owners:** %s\n", strings.Join(first.CodeOwners, " "))
+	}
+	if first.Author != "" {
+		fmt.Fprintf(b, "- **Added by:** %s\n", first.Author)
+	}
+	if first.Commit != "" {
+		fmt.Fprintf(b, "- **Added in:** %s\n", commitLink(ghCfg, first))
+	}
+	if len(first.Tickets) > 0 {
+		fmt.Fprintf(b, "- **Tracked in:** %s\n", strings.Join(first.Tickets, ", "))
+	}
+
+	found := []flagexorcist.Usage{}
+	for _, f := range fs {
+		found = append(found, f.Usages...)
+	}
+	sort.SliceStable(found, func(i, j int) bool {
+		if found[i].Path != found[j].Path {
+			return found[i].Path < found[j].Path
+		}
+		return found[i].Position.Line < found[j].Position.Line
+	})
+	if len(found) == 0 {
+		b.WriteString("\nIt is no longer used anywhere, so it is safe to delete.\n")
+	} else {
+		fmt.Fprintf(b, "\n### Usages (%d)\n\n", len(found))
+		for _, u := range found {
+			fmt.Fprintf(b, "- %s (%s)\n", codeLink(ghCfg, u.Path, u.Position.Line), u.Source)
+		}
+	}
+
+	b.WriteString("\n<sub>Filed by flag-exorcist, which keeps this issue up to date.</sub>\n")
+	return b.String()
+}
+
+// commitLink links to the commit that added the flag of a finding, with its
+// subject line.
+func commitLink(ghCfg githubIssuesConfig, f flagexorcist.Finding) string {
+	link := fmt.Sprintf("[`%.8s`](%s/%s/commit/%s)",
+		f.Commit, strings.TrimSuffix(ghCfg.ServerURL, "/"), ghCfg.Repository, f.Commit,
+	)
+	if f.CommitSubject != "" {
+		link += " " + f.CommitSubject
+	}
+	return link
+}
+
+// codeLink links to a line of a file in the repo.
+func codeLink(ghCfg githubIssuesConfig, path string, line int) string {
+	ref := ghCfg.SHA
+	if ref == "" {
+		ref = "HEAD"
+	}
+	return fmt.Sprintf("[`%s:%d`](%s/%s/blob/%s/%s#L%d)",
+		path, line, strings.TrimSuffix(ghCfg.ServerURL, "/"), ghCfg.Repository, ref, path, line,
+	)
+}
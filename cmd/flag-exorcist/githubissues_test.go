@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgunay/flag-exorcist/flagexorcist"
+)
+
+func TestIssueBodyIsStable(t *testing.T) {
+	t.Parallel()
+
+	ghCfg := githubIssuesConfig{
+		ServerURL:  "https://github.com",
+		Repository: "dgunay/flag-exorcist",
+		Label:      "stale-flag",
+	}
+	flag := flagexorcist.FlagID{Kind: flagexorcist.FlagKindSymbol, Namespace: "a", Name: "EnableX"}
+	finding := func(ageDays int) flagexorcist.Finding {
+		return flagexorcist.Finding{
+			Flag:            flag,
+			Category:        flagexorcist.CategoryStale,
+			IntroducedAt:    time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC),
+			AgeDays:         ageDays,
+			DeclarationPath: "a/a.go",
+			Description:     "The new flow",
+		}
+	}
+
+	// A day later the flag is a day older, which mustn't change the issue
+	today := issueBody(flag, []flagexorcist.Finding{finding(100)}, ghCfg)
+	tomorrow := issueBody(flag, []flagexorcist.Finding{finding(101)}, ghCfg)
+	if today != tomorrow {
+		t.Errorf("issue body changed as the flag aged:\n%s\nvs\n%s", today, tomorrow)
+	}
+	if !strings.Contains(today, "- **Introduced:** 2023-01-02\n") {
+		t.Errorf("issue body has no introduction date:\n%s", today)
+	}
+	if !strings.Contains(today, "removed.\n\nThe new flow\n\n") {
+		t.Errorf("issue body has no description:\n%s", today)
+	}
+}
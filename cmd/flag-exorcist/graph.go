@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/dgunay/flag-exorcist/flagexorcist"
+)
+
+// usageGraph is the graph of which packages and files use each flag.
+type usageGraph struct {
+	Nodes []graphNode `json:"nodes"`
+	Edges []graphEdge `json:"edges"`
+}
+
+type graphNode struct {
+	ID    string `json:"id"`
+	Kind  string `json:"kind"`
+	Label string `json:"label"`
+}
+
+// graphEdge goes from a flag to a package that uses it, or from a package to
+// one of its files that uses a flag. Weight is how many usages it stands for.
+type graphEdge struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Weight int    `json:"weight"`
+}
+
+// graph writes the graph of flags to the packages and files that use them, to
+// show the blast radius of removing each.
+func graph(args []string) error {
+	fs := flag.NewFlagSet("graph", flag.ExitOnError)
+	format := fs.String("format", "dot", "the output format: dot (Graphviz) or json (an adjacency list)")
+	files := fs.Bool("files", true, "include the files each package uses flags in")
+	out := fs.String("out", "", "the file to write the graph to (defaults to stdout)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: flag-exorcist graph [--format dot|json] [--files=false] [--out file] [packages]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != "dot" && *format != "json" {
+		return fmt.Errorf("unknown graph format %q", *format)
+	}
+
+	if _, err := initialize(); err != nil {
+		return err
+	}
+	pkgs, err := loadPackages(fs.Args())
+	if err != nil {
+		return err
+	}
+	g := buildGraph(flagexorcist.Inventory(pkgs), *files)
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if *format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(g)
+	}
+	return writeDot(w, g)
+}
+
+// buildGraph builds the usage graph from the inventory, with nodes and edges
+// in a stable order. Files are shown relative to the working directory.
+func buildGraph(items []flagexorcist.InventoryItem, withFiles bool) usageGraph {
+	wd, _ := os.Getwd()
+	g := usageGraph{Nodes: []graphNode{}, Edges: []graphEdge{}}
+	nodes := map[string]bool{}
+	addNode := func(id, kind, label string) {
+		if !nodes[id] {
+			nodes[id] = true
+			g.Nodes = append(g.Nodes, graphNode{ID: id, Kind: kind, Label: label})
+		}
+	}
+	weights := map[[2]string]int{}
+	addEdge := func(from, to string) {
+		if weights[[2]string{from, to}] == 0 {
+			g.Edges = append(g.Edges, graphEdge{From: from, To: to})
+		}
+		weights[[2]string{from, to}]++
+	}
+
+	for _, item := range items {
+		flagNode := "flag:" + item.Flag.String()
+		addNode(flagNode, "flag", item.Flag.String())
+
+		uses := append([]flagexorcist.Use(nil), item.Uses...)
+		sort.Slice(uses, func(i, j int) bool {
+			a, b := uses[i], uses[j]
+			if a.Package != b.Package {
+				return a.Package < b.Package
+			}
+			if a.Position.Filename != b.Position.Filename {
+				return a.Position.Filename < b.Position.Filename
+			}
+			return a.Position.Offset < b.Position.Offset
+		})
+		for _, use := range uses {
+			pkgNode := "package:" + use.Package
+			addNode(pkgNode, "package", use.Package)
+			addEdge(flagNode, pkgNode)
+			if !withFiles {
+				continue
+			}
+
+			file := use.Position.Filename
+			if rel, err := filepath.Rel(wd, file); err == nil && wd != "" {
+				file = filepath.ToSlash(rel)
+			}
+			fileNode := "file:" + file
+			addNode(fileNode, "file", file)
+			addEdge(pkgNode, fileNode)
+		}
+	}
+
+	for i, e := range g.Edges {
+		g.Edges[i].Weight = weights[[2]string{e.From, e.To}]
+	}
+	return g
+}
+
+// writeDot writes the graph in Graphviz's DOT language, flowing from flags on
+// the left to files on the right.
+func writeDot(w io.Writer, g usageGraph) error {
+	shapes := map[string]string{"flag": "box", "package": "ellipse", "file": "note"}
+	fmt.Fprintln(w, "digraph flags {")
+	fmt.Fprintln(w, "\trankdir=LR;")
+	for _, n := range g.Nodes {
+		fmt.Fprintf(w, "\t%s [label=%s, shape=%s];\n",
+			strconv.Quote(n.ID), strconv.Quote(n.Label), shapes[n.Kind],
+		)
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(w, "\t%s -> %s [label=%s];\n",
+			strconv.Quote(e.From), strconv.Quote(e.To), strconv.Quote(strconv.Itoa(e.Weight)),
+		)
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
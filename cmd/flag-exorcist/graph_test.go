@@ -0,0 +1,79 @@
+package main
+
+import (
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dgunay/flag-exorcist/flagexorcist"
+)
+
+func TestBuildGraph(t *testing.T) {
+	t.Parallel()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	use := func(pkg, file string, offset int) flagexorcist.Use {
+		return flagexorcist.Use{
+			Package:  pkg,
+			Position: token.Position{Filename: filepath.Join(wd, file), Offset: offset},
+		}
+	}
+	items := []flagexorcist.InventoryItem{{
+		Flag: flagexorcist.SymbolID("EnableX"),
+		// Out of order, to be sorted
+		Uses: []flagexorcist.Use{
+			use("b", "b/b.go", 0),
+			use("a", "a/a.go", 20),
+			use("a", "a/a.go", 10),
+		},
+	}}
+
+	g := buildGraph(items, true)
+	wantNodes := []graphNode{
+		{ID: "flag:EnableX", Kind: "flag", Label: "EnableX"},
+		{ID: "package:a", Kind: "package", Label: "a"},
+		{ID: "file:a/a.go", Kind: "file", Label: "a/a.go"},
+		{ID: "package:b", Kind: "package", Label: "b"},
+		{ID: "file:b/b.go", Kind: "file", Label: "b/b.go"},
+	}
+	wantEdges := []graphEdge{
+		{From: "flag:EnableX", To: "package:a", Weight: 2},
+		{From: "package:a", To: "file:a/a.go", Weight: 2},
+		{From: "flag:EnableX", To: "package:b", Weight: 1},
+		{From: "package:b", To: "file:b/b.go", Weight: 1},
+	}
+	if !reflect.DeepEqual(g.Nodes, wantNodes) {
+		t.Errorf("buildGraph() nodes = %+v, want %+v", g.Nodes, wantNodes)
+	}
+	if !reflect.DeepEqual(g.Edges, wantEdges) {
+		t.Errorf("buildGraph() edges = %+v, want %+v", g.Edges, wantEdges)
+	}
+
+	// Without files, flags only go to packages
+	g = buildGraph(items, false)
+	if len(g.Nodes) != 3 || len(g.Edges) != 2 {
+		t.Errorf("buildGraph() without files = %+v, want flags and packages only", g)
+	}
+
+	dot := &strings.Builder{}
+	if err := writeDot(dot, g); err != nil {
+		t.Fatalf("writeDot() error = %v", err)
+	}
+	want := "digraph flags {\n" +
+		"\trankdir=LR;\n" +
+		"\t\"flag:EnableX\" [label=\"EnableX\", shape=box];\n" +
+		"\t\"package:a\" [label=\"a\", shape=ellipse];\n" +
+		"\t\"package:b\" [label=\"b\", shape=ellipse];\n" +
+		"\t\"flag:EnableX\" -> \"package:a\" [label=\"2\"];\n" +
+		"\t\"flag:EnableX\" -> \"package:b\" [label=\"1\"];\n" +
+		"}\n"
+	if dot.String() != want {
+		t.Errorf("writeDot() =\n%s\nwant\n%s", dot, want)
+	}
+}
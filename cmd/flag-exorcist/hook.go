@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"go/token"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dgunay/flag-exorcist/flagexorcist"
+)
+
+// hook is run as a git pre-commit hook. It only analyzes the packages of the
+// Go files staged for commit, and blocks the commit if the lines it adds
+// reference flags that are already past their cutoff, so that stale flags
+// don't spread while they wait to be removed. Findings on lines the commit
+// doesn't add are left to CI. Packages are loaded from the working tree, so
+// a file only partly staged is analyzed as it is on disk. Importing the cache
+// a CI run exported with --cache-import keeps it fast, since most flags are
+// then dated without reading the history.
+func hook(args []string) error {
+	fs := flag.NewFlagSet("hook", flag.ExitOnError)
+	cacheImport := fs.String("cache-import", "",
+		"read what earlier runs found in the history from this file, if it exists, "+
+			"so that flags don't have to be dated again",
+	)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: flag-exorcist hook [flags]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	top, err := git("rev-parse", "--show-toplevel")
+	if err != nil {
+		return err
+	}
+	top = strings.TrimSpace(top)
+	diff, err := git("diff", "--cached", "--unified=0", "--no-color", "--no-ext-diff",
+		"--diff-filter=ACMR", "--", "*.go",
+	)
+	if err != nil {
+		return err
+	}
+	added, err := addedLines(strings.NewReader(diff))
+	if err != nil {
+		return err
+	}
+	if len(added) == 0 {
+		return nil
+	}
+
+	if _, err := initialize(); err != nil {
+		return err
+	}
+	if *cacheImport != "" {
+		if err := flagexorcist.ImportCache(*cacheImport); err != nil {
+			return err
+		}
+	}
+	findings, err := flagexorcist.RunDir(context.Background(), top, stagedPackages(added)...)
+	if err != nil {
+		return err
+	}
+
+	blocked := newReferences(findings, top, added)
+	for _, f := range blocked {
+		fmt.Fprintf(os.Stderr, "%s:%d:%d: %s\n",
+			f.Path, f.Position.Line, f.Position.Column, f.Message,
+		)
+	}
+	if len(blocked) > 0 {
+		return fmt.Errorf(
+			"the staged changes add %d references to flags past their cutoff, "+
+				"remove them or commit with --no-verify", len(blocked),
+		)
+	}
+	return nil
+}
+
+// git runs git and returns its output.
+func git(args ...string) (string, error) {
+	out, err := exec.Command("git", args...).Output()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return "", fmt.Errorf("git %s: %s",
+			strings.Join(args, " "), strings.TrimSpace(string(exitErr.Stderr)),
+		)
+	}
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w", strings.Join(args, " "), err)
+	}
+	return string(out), nil
+}
+
+// addedLines returns the lines a diff with no context adds to each file, by
+// the file's path in the new tree.
+func addedLines(diff io.Reader) (map[string]map[int]bool, error) {
+	added := map[string]map[int]bool{}
+	file := ""
+	scanner := bufio.NewScanner(diff)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "+++ "):
+			name := strings.TrimPrefix(line, "+++ ")
+			if unquoted, err := strconv.Unquote(name); err == nil {
+				name = unquoted
+			}
+			file = strings.TrimPrefix(name, "b/")
+			if name == "/dev/null" {
+				file = ""
+			}
+		case strings.HasPrefix(line, "@@ ") && file != "":
+			// @@ -start[,count] +start[,count] @@
+			fields := strings.Fields(line)
+			if len(fields) < 3 || !strings.HasPrefix(fields[2], "+") {
+				return nil, fmt.Errorf("parse hunk header %q", line)
+			}
+			startStr, countStr, hasCount := strings.Cut(fields[2][1:], ",")
+			start, err := strconv.Atoi(startStr)
+			if err != nil {
+				return nil, fmt.Errorf("parse hunk header %q: %w", line, err)
+			}
+			count := 1
+			if hasCount {
+				if count, err = strconv.Atoi(countStr); err != nil {
+					return nil, fmt.Errorf("parse hunk header %q: %w", line, err)
+				}
+			}
+			if added[file] == nil {
+				added[file] = map[int]bool{}
+			}
+			for i := start; i < start+count; i++ {
+				added[file][i] = true
+			}
+		}
+	}
+	return added, scanner.Err()
+}
+
+// stagedPackages returns the patterns of the packages the files are in,
+// relative to the root of the checkout.
+func stagedPackages(added map[string]map[int]bool) []string {
+	dirs := map[string]bool{}
+	for file := range added {
+		if dir := path.Dir(file); dir == "." {
+			dirs["."] = true
+		} else {
+			dirs["./"+dir] = true
+		}
+	}
+	patterns := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		patterns = append(patterns, dir)
+	}
+	sort.Strings(patterns)
+	return patterns
+}
+
+// newReferences returns the findings that fail the run about flags past their
+// cutoff that have a usage on a line being added. A finding covers every
+// usage of its flag in the package, and is anchored at the first, so each of
+// them is checked. The findings returned are moved to the first added usage.
+// top is the root of the checkout the paths of the added lines are relative
+// to.
+func newReferences(
+	findings []flagexorcist.Finding, top string, added map[string]map[int]bool,
+) []flagexorcist.Finding {
+	isAdded := func(pos token.Position) bool {
+		rel, err := filepath.Rel(top, pos.Filename)
+		return err == nil && added[filepath.ToSlash(rel)][pos.Line]
+	}
+
+	blocked := []flagexorcist.Finding{}
+	for _, f := range findings {
+		if !f.Fails() || !flagexorcist.PastCutoff(f.Category) {
+			continue
+		}
+		if isAdded(f.Position) {
+			blocked = append(blocked, f)
+			continue
+		}
+		for _, u := range f.Usages {
+			if isAdded(u.Position) {
+				f.Position, f.Path = u.Position, u.Path
+				blocked = append(blocked, f)
+				break
+			}
+		}
+	}
+	return blocked
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"go/token"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dgunay/flag-exorcist/flagexorcist"
+)
+
+func TestAddedLines(t *testing.T) {
+	t.Parallel()
+
+	diff := `diff --git a/a/a.go b/a/a.go
+index 1111111..2222222 100644
+--- a/a/a.go
++++ b/a/a.go
+@@ -3,0 +4,2 @@ func a() {
++	if flags.EnableX {
++	}
+@@ -10 +12 @@ func b() {
+-	return 1
++	return 2
+@@ -20,2 +21,0 @@ func c() {
+-	x()
+-	y()
+diff --git a/main.go b/main.go
+new file mode 100644
+--- /dev/null
++++ "b/m\303\244in.go"
+@@ -0,0 +1 @@
++package main
+`
+	added, err := addedLines(strings.NewReader(diff))
+	if err != nil {
+		t.Fatalf("addedLines() error = %v", err)
+	}
+	want := map[string]map[int]bool{
+		"a/a.go":  {4: true, 5: true, 12: true},
+		"mäin.go": {1: true},
+	}
+	if !reflect.DeepEqual(added, want) {
+		t.Errorf("addedLines() = %v, want %v", added, want)
+	}
+
+	if got, want := stagedPackages(added), []string{".", "./a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("stagedPackages() = %q, want %q", got, want)
+	}
+
+	if _, err := addedLines(strings.NewReader("+++ b/a.go\n@@ -1 +x @@\n")); err == nil {
+		t.Error("addedLines() error = nil, want an error for a malformed hunk")
+	}
+}
+
+func TestNewReferences(t *testing.T) {
+	t.Parallel()
+
+	top := t.TempDir()
+	finding := func(category string, enforced bool, line int) flagexorcist.Finding {
+		return flagexorcist.Finding{
+			Flag:     flagexorcist.SymbolID("EnableX"),
+			Category: category,
+			Severity: flagexorcist.SeverityOf(category),
+			Enforced: enforced,
+			Position: token.Position{Filename: filepath.Join(top, "a", "a.go"), Line: line},
+		}
+	}
+	added := map[string]map[int]bool{"a/a.go": {4: true}}
+
+	// The flag was already used on line 2, which the finding is anchored at,
+	// and the commit adds another usage on line 4
+	usedBelow := finding(flagexorcist.CategoryStale, true, 2)
+	usedBelow.Usages = []flagexorcist.Usage{
+		{Source: flagexorcist.SourceSymbol, Position: usedBelow.Position},
+		{
+			Source:   flagexorcist.SourceSymbol,
+			Position: token.Position{Filename: filepath.Join(top, "a", "a.go"), Line: 4},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		finding flagexorcist.Finding
+		want    bool
+	}{
+		{name: "stale on added line", finding: finding(flagexorcist.CategoryStale, true, 4), want: true},
+		{name: "stale elsewhere", finding: finding(flagexorcist.CategoryStale, true, 5)},
+		{name: "usage on added line", finding: usedBelow, want: true},
+		{name: "report-only", finding: finding(flagexorcist.CategoryStale, false, 4)},
+		{name: "not past cutoff", finding: finding(flagexorcist.CategoryFlagBudget, true, 4)},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := newReferences([]flagexorcist.Finding{tt.finding}, top, added)
+			if (len(got) == 1) != tt.want {
+				t.Errorf("newReferences() = %+v, want blocked %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dgunay/flag-exorcist/flagexorcist"
+)
+
+// Statuses of a flag in the list.
+const (
+	statusFresh   = "fresh"
+	statusWarning = "warning"
+	statusStale   = "stale"
+	statusUndated = "undated"
+	statusSnoozed = "snoozed"
+)
+
+// listedFlag is a row of the list.
+type listedFlag struct {
+	flagexorcist.DatedFlag
+	Status string `json:"status"`
+}
+
+// list prints every tracked flag with how old it is and whether it is stale.
+// It never fails because of stale flags, so teams can see where they stand
+// before enforcing a cutoff.
+func list(args []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	warnBefore := fs.Duration("warn-before", 14*24*time.Hour,
+		"how long before reaching the cutoff a flag is shown as a warning")
+	asJSON := fs.Bool("json", false, "print the list as JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: flag-exorcist list [--warn-before 336h] [--json] [packages]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if _, err := initialize(); err != nil {
+		return err
+	}
+	if _, err := flagexorcist.Run(fs.Args()...); err != nil {
+		return err
+	}
+
+	flags := []listedFlag{}
+	for _, f := range flagexorcist.DatedFlags() {
+		flags = append(flags, listedFlag{DatedFlag: f, Status: status(f, *warnBefore)})
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(flags)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "FLAG\tINTRODUCED\tAGE\tUSAGES\tSTATUS\tDECLARED")
+	for _, f := range flags {
+		introduced, age := "-", "-"
+		if !f.Undated {
+			introduced = f.IntroducedAt.UTC().Format("2006-01-02")
+			age = fmt.Sprintf("%dd", f.AgeDays)
+			if f.BeforeWindow {
+				introduced = "before " + introduced
+				age = ">" + age
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s:%d\n",
+			f.Flag, introduced, age, f.Usages, f.Status, f.DeclarationPath, f.Declaration.Line,
+		)
+	}
+	return w.Flush()
+}
+
+// status says whether a flag is past its cutoff, or will be within
+// warnBefore, unless it is snoozed.
+func status(f flagexorcist.DatedFlag, warnBefore time.Duration) string {
+	switch {
+	case f.Undated:
+		return statusUndated
+	case f.Snoozed != nil:
+		return statusSnoozed
+	case f.Stale:
+		return statusStale
+	case time.Duration(f.AgeDays)*24*time.Hour >= f.Cutoff-warnBefore:
+		return statusWarning
+	}
+	return statusFresh
+}
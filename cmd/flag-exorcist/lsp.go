@@ -0,0 +1,481 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dgunay/flag-exorcist/flagexorcist"
+)
+
+// lsp serves the findings about the Go files open in an editor over the
+// Language Server Protocol on stdin and stdout, so that flags past their
+// cutoff show up inline rather than only in CI. The packages of open files
+// are analyzed when they are opened or saved, and again every interval, to
+// pick up edits made outside the editor, new commits and flags aging. What
+// the history says about each flag is kept between runs, so only the first
+// one walks it.
+func lsp(args []string) error {
+	fs := flag.NewFlagSet("lsp", flag.ExitOnError)
+	interval := fs.Duration("interval", time.Minute,
+		"how often to analyze the open packages again, or 0 to only do it when they're saved",
+	)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: flag-exorcist lsp [--interval duration]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if _, err := initialize(); err != nil {
+		return err
+	}
+	s := newLSPServer(os.Stdin, os.Stdout, func(dir string) ([]flagexorcist.Finding, error) {
+		return flagexorcist.RunDir(context.Background(), dir, ".")
+	})
+	return s.serve(*interval)
+}
+
+// lspServer is a language server with just enough of the protocol to publish
+// diagnostics. Messages are handled one at a time, since the analyzer can
+// only run once at a time anyway.
+type lspServer struct {
+	in      *bufio.Reader
+	out     io.Writer
+	closer  io.Closer
+	analyze func(dir string) ([]flagexorcist.Finding, error)
+
+	// How many files are open in each package directory, and the
+	// diagnostics last published for each file in it, by URI
+	open      map[string]int
+	published map[string]map[string][]lspDiagnostic
+
+	// Whether the client asked to shut down
+	shutdown bool
+}
+
+func newLSPServer(
+	in io.Reader, out io.Writer, analyze func(dir string) ([]flagexorcist.Finding, error),
+) *lspServer {
+	closer, _ := in.(io.Closer)
+	return &lspServer{
+		in:        bufio.NewReader(in),
+		closer:    closer,
+		out:       out,
+		analyze:   analyze,
+		open:      map[string]int{},
+		published: map[string]map[string][]lspDiagnostic{},
+	}
+}
+
+// rpcMessage is a JSON-RPC request, notification or response. Requests and
+// responses have an ID, notifications don't.
+type rpcMessage struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  any              `json:"params,omitempty"`
+	Result  any              `json:"result,omitempty"`
+	Error   *rpcError        `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// The JSON-RPC error for requests the server doesn't handle
+const rpcMethodNotFound = -32601
+
+// A result of null, which unlike a nil one is still sent
+var rpcNull = json.RawMessage("null")
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+// lspDiagnostic is a finding as the editor shows it.
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Code     string   `json:"code,omitempty"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+// Diagnostic severities
+const (
+	lspError       = 1
+	lspWarning     = 2
+	lspInformation = 3
+)
+
+// The params of the notifications about documents
+type lspDocumentParams struct {
+	TextDocument struct {
+		URI string `json:"uri"`
+	} `json:"textDocument"`
+}
+
+type lspPublishParams struct {
+	URI         string          `json:"uri"`
+	Diagnostics []lspDiagnostic `json:"diagnostics"`
+}
+
+// serve handles messages until the client exits, analyzing the open
+// packages again every interval, unless it is zero. Its input is closed when
+// it returns, if it can be, to stop reading it.
+func (s *lspServer) serve(interval time.Duration) error {
+	messages := make(chan rpcMessage)
+	failed := make(chan error, 1)
+	done := make(chan struct{})
+	defer func() {
+		close(done)
+		if s.closer != nil {
+			s.closer.Close()
+		}
+	}()
+	go func() {
+		for {
+			msg, err := readMessage(s.in)
+			if err != nil {
+				failed <- err
+				return
+			}
+			select {
+			case messages <- msg:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case msg := <-messages:
+			exit, err := s.handle(msg)
+			if exit || err != nil {
+				return err
+			}
+		case err := <-failed:
+			if err == io.EOF && s.shutdown {
+				return nil
+			}
+			return fmt.Errorf("read message: %w", err)
+		case <-tick:
+			dirs := make([]string, 0, len(s.open))
+			for dir := range s.open {
+				dirs = append(dirs, dir)
+			}
+			sort.Strings(dirs)
+			for _, dir := range dirs {
+				if err := s.check(dir); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// handle handles a message, and reports whether the client asked to exit.
+func (s *lspServer) handle(msg rpcMessage) (exit bool, err error) {
+	params, _ := json.Marshal(msg.Params)
+	doc := lspDocumentParams{}
+	if strings.HasPrefix(msg.Method, "textDocument/") {
+		if err := json.Unmarshal(params, &doc); err != nil {
+			return false, fmt.Errorf("parse %s params: %w", msg.Method, err)
+		}
+	}
+
+	switch msg.Method {
+	case "initialize":
+		return false, s.respond(msg.ID, map[string]any{
+			"capabilities": map[string]any{
+				"textDocumentSync": map[string]any{
+					"openClose": true,
+					// Only saved files are analyzed, so changes aren't sent
+					"change": 0,
+					"save":   map[string]any{},
+				},
+			},
+			"serverInfo": map[string]any{"name": "flag-exorcist"},
+		})
+	case "shutdown":
+		s.shutdown = true
+		return false, s.respond(msg.ID, rpcNull)
+	case "exit":
+		if !s.shutdown {
+			return true, fmt.Errorf("exited without being shut down")
+		}
+		return true, nil
+	case "textDocument/didOpen":
+		if dir, ok := packageDir(doc.TextDocument.URI); ok {
+			s.open[dir]++
+			return false, s.check(dir)
+		}
+	case "textDocument/didSave":
+		if dir, ok := packageDir(doc.TextDocument.URI); ok && s.open[dir] > 0 {
+			return false, s.check(dir)
+		}
+	case "textDocument/didClose":
+		if dir, ok := packageDir(doc.TextDocument.URI); ok && s.open[dir] > 0 {
+			if s.open[dir]--; s.open[dir] == 0 {
+				delete(s.open, dir)
+				return false, s.publish(dir, map[string][]lspDiagnostic{})
+			}
+		}
+	default:
+		if msg.ID != nil {
+			return false, s.write(rpcMessage{
+				JSONRPC: "2.0",
+				ID:      msg.ID,
+				Error: &rpcError{
+					Code:    rpcMethodNotFound,
+					Message: fmt.Sprintf("%s isn't supported", msg.Method),
+				},
+			})
+		}
+		// Other notifications, like initialized, need nothing done
+	}
+	return false, nil
+}
+
+// check analyzes the package in dir and publishes what it found. Failing to
+// analyze it is logged in the editor, rather than stopping the server, since
+// it is usually because the code doesn't compile yet.
+func (s *lspServer) check(dir string) error {
+	findings, err := s.analyze(dir)
+	if err != nil {
+		return s.write(rpcMessage{
+			JSONRPC: "2.0",
+			Method:  "window/logMessage",
+			Params: map[string]any{
+				"type":    lspError,
+				"message": fmt.Sprintf("analyze %s: %s", dir, err),
+			},
+		})
+	}
+
+	// The contents of each file, to count columns in as the protocol does
+	sources := map[string][]byte{}
+	diagnostics := map[string][]lspDiagnostic{}
+	for _, f := range findings {
+		filename := f.Position.Filename
+		if _, ok := sources[filename]; !ok {
+			// Columns are left as bytes if it can't be read
+			sources[filename], _ = os.ReadFile(filename)
+		}
+		uri := fileURI(filename)
+		diagnostics[uri] = append(diagnostics[uri], diagnostic(f, sources[filename]))
+	}
+	return s.publish(dir, diagnostics)
+}
+
+// publish sends the diagnostics of the files in dir that changed since they
+// were last published, clearing them from files that no longer have any.
+func (s *lspServer) publish(dir string, diagnostics map[string][]lspDiagnostic) error {
+	uris := []string{}
+	for uri := range diagnostics {
+		uris = append(uris, uri)
+	}
+	for uri := range s.published[dir] {
+		if !hasKey(diagnostics, uri) {
+			uris = append(uris, uri)
+		}
+	}
+	sort.Strings(uris)
+
+	for _, uri := range uris {
+		// Sent as an empty list rather than null, to clear them
+		diags := append([]lspDiagnostic{}, diagnostics[uri]...)
+		if previous, ok := s.published[dir][uri]; ok && reflect.DeepEqual(previous, diags) {
+			continue
+		}
+		err := s.write(rpcMessage{
+			JSONRPC: "2.0",
+			Method:  "textDocument/publishDiagnostics",
+			Params:  lspPublishParams{URI: uri, Diagnostics: diags},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if len(diagnostics) == 0 {
+		delete(s.published, dir)
+	} else {
+		s.published[dir] = diagnostics
+	}
+	return nil
+}
+
+func (s *lspServer) respond(id *json.RawMessage, result any) error {
+	return s.write(rpcMessage{JSONRPC: "2.0", ID: id, Result: result})
+}
+
+// write sends a message, framed by its length.
+func (s *lspServer) write(msg rpcMessage) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(body), body); err != nil {
+		return fmt.Errorf("write message: %w", err)
+	}
+	return nil
+}
+
+// readMessage reads a message framed by its length, skipping the other
+// headers.
+func readMessage(r *bufio.Reader) (rpcMessage, error) {
+	length := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return rpcMessage{}, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		name, value, _ := strings.Cut(line, ":")
+		if strings.EqualFold(name, "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+				return rpcMessage{}, fmt.Errorf("parse Content-Length %q: %w", value, err)
+			}
+		}
+	}
+	if length < 0 {
+		return rpcMessage{}, fmt.Errorf("message has no Content-Length")
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return rpcMessage{}, err
+	}
+	msg := rpcMessage{}
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return rpcMessage{}, fmt.Errorf("parse message: %w", err)
+	}
+	return msg, nil
+}
+
+// diagnostic converts a finding in the file with contents src to a diagnostic
+// at where it was found, over the code it covers if it covers a range. Only
+// findings that fail the run are errors, so that snoozed and report-only ones
+// don't look like they need fixing before merging.
+func diagnostic(f flagexorcist.Finding, src []byte) lspDiagnostic {
+	severity := lspInformation
+	switch {
+	case f.Fails():
+		severity = lspError
+	case f.Enforced && f.Snoozed == nil && f.Severity != flagexorcist.SeverityInfo:
+		severity = lspWarning
+	}
+
+	at := position(src, f.Position.Line, f.Position.Column)
+	end := at
+	if f.End != nil && f.End.Line > 0 {
+		end = position(src, f.End.Line, f.End.Column)
+	}
+	return lspDiagnostic{
+		Range:    lspRange{Start: at, End: end},
+		Severity: severity,
+		Code:     f.Category,
+		Source:   "flag-exorcist",
+		Message:  f.Message,
+	}
+}
+
+// position converts the 1-based line and byte column of a position in src to
+// the protocol's, which are 0-based and count columns in UTF-16 code units.
+// The column is left as bytes if src doesn't have the line.
+func position(src []byte, line, column int) lspPosition {
+	at := lspPosition{Line: line - 1, Character: column - 1}
+	if at.Line < 0 {
+		at.Line = 0
+	}
+	if at.Character < 0 {
+		at.Character = 0
+	}
+
+	lines := bytes.SplitN(src, []byte("\n"), at.Line+2)
+	if at.Line >= len(lines) || at.Character > len(lines[at.Line]) {
+		return at
+	}
+	units := 0
+	for _, r := range string(lines[at.Line][:at.Character]) {
+		if r >= 0x10000 {
+			// Outside the basic multilingual plane, so a surrogate pair
+			units += 2
+		} else {
+			units++
+		}
+	}
+	at.Character = units
+	return at
+}
+
+// packageDir returns the directory of the package a document is in, if it is
+// a Go file on disk.
+func packageDir(uri string) (string, bool) {
+	filename, ok := uriFilename(uri)
+	if !ok || !strings.HasSuffix(filename, ".go") {
+		return "", false
+	}
+	return filepath.Dir(filename), true
+}
+
+// uriFilename returns the name of the file on disk a file URI is of. The
+// paths of Windows ones start with the drive, like /C:/src/main.go.
+func uriFilename(uri string) (string, bool) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return "", false
+	}
+	path := u.Path
+	if isDrivePath(path) {
+		path = path[1:]
+	}
+	return filepath.FromSlash(path), true
+}
+
+// isDrivePath reports whether path starts with a Windows drive, like /C:/.
+func isDrivePath(path string) bool {
+	return len(path) >= 3 && path[0] == '/' && path[2] == ':' &&
+		('a' <= path[1] && path[1] <= 'z' || 'A' <= path[1] && path[1] <= 'Z')
+}
+
+// fileURI returns the URI of a file on disk.
+func fileURI(filename string) string {
+	path := filepath.ToSlash(filename)
+	if !strings.HasPrefix(path, "/") {
+		// Windows paths start with the drive
+		path = "/" + path
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"io"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/dgunay/flag-exorcist/flagexorcist"
+)
+
+func TestLSPServer(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.go"), filepath.Join(dir, "b.go")
+	stale := flagexorcist.Finding{
+		Flag:     flagexorcist.SymbolID("EnableX"),
+		Category: flagexorcist.CategoryStale,
+		Severity: flagexorcist.SeverityError,
+		Enforced: true,
+		Message:  "EnableX is 200 days old",
+		Position: token.Position{Filename: a, Line: 3, Column: 9},
+	}
+	// Each analysis finds less than the last
+	analyses := [][]flagexorcist.Finding{
+		{stale, {
+			Flag:     flagexorcist.SymbolID("EnableY"),
+			Category: flagexorcist.CategoryStale,
+			Severity: flagexorcist.SeverityError,
+			Message:  "EnableY is 100 days old",
+			Position: token.Position{Filename: b, Line: 1, Column: 1},
+		}},
+		{stale},
+	}
+	analyzed := []string{}
+	analyze := func(dir string) ([]flagexorcist.Finding, error) {
+		analyzed = append(analyzed, dir)
+		findings := analyses[0]
+		analyses = analyses[1:]
+		return findings, nil
+	}
+
+	in := &strings.Builder{}
+	send := func(id int, method string, params any) {
+		msg := map[string]any{"jsonrpc": "2.0", "method": method, "params": params}
+		if id > 0 {
+			msg["id"] = id
+		}
+		body, err := json.Marshal(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(in, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	}
+	doc := map[string]any{"textDocument": map[string]any{"uri": fileURI(a)}}
+	send(1, "initialize", map[string]any{})
+	send(0, "initialized", map[string]any{})
+	send(0, "textDocument/didOpen", doc)
+	send(0, "textDocument/didSave", doc)
+	send(2, "textDocument/hover", doc)
+	send(3, "shutdown", nil)
+	send(0, "exit", nil)
+
+	out := &strings.Builder{}
+	if err := newLSPServer(strings.NewReader(in.String()), out, analyze).serve(0); err != nil {
+		t.Fatalf("serve() error = %v", err)
+	}
+	if want := []string{dir, dir}; !reflect.DeepEqual(analyzed, want) {
+		t.Errorf("serve() analyzed %q, want %q", analyzed, want)
+	}
+
+	// The bodies of the messages sent
+	sent := regexp.MustCompile(`Content-Length: \d+\r\n\r\n`).Split(out.String(), -1)[1:]
+	diagnostic := `{"range":{"start":{"line":2,"character":8},"end":{"line":2,"character":8}},` +
+		`"severity":1,"code":"stale","source":"flag-exorcist","message":"EnableX is 200 days old"}`
+	publish := `{"jsonrpc":"2.0","method":"textDocument/publishDiagnostics","params":{"uri":`
+	want := []string{
+		`{"jsonrpc":"2.0","id":1,"result":{"capabilities":{"textDocumentSync":` +
+			`{"change":0,"openClose":true,"save":{}}},"serverInfo":{"name":"flag-exorcist"}}}`,
+		// Sorted by URI, and report-only findings are only informational
+		publish + `"` + fileURI(a) + `","diagnostics":[` + diagnostic + `]}}`,
+		publish + `"` + fileURI(b) + `","diagnostics":[` +
+			`{"range":{"start":{"line":0,"character":0},"end":{"line":0,"character":0}},` +
+			`"severity":3,"code":"stale","source":"flag-exorcist","message":"EnableY is 100 days old"}]}}`,
+		// Only the file whose diagnostics changed is published again
+		publish + `"` + fileURI(b) + `","diagnostics":[]}}`,
+		`{"jsonrpc":"2.0","id":2,"error":{"code":-32601,"message":"textDocument/hover isn't supported"}}`,
+		`{"jsonrpc":"2.0","id":3,"result":null}`,
+	}
+	if !reflect.DeepEqual(sent, want) {
+		t.Errorf("serve() sent\n%s\nwant\n%s", strings.Join(sent, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestLSPServerExit(t *testing.T) {
+	t.Parallel()
+
+	body := `{"jsonrpc":"2.0","method":"exit"}`
+	in := fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(body), body)
+	err := newLSPServer(strings.NewReader(in), io.Discard, nil).serve(0)
+	if err == nil {
+		t.Error("serve() error = nil, want an error for exiting without shutting down")
+	}
+
+	err = newLSPServer(strings.NewReader("Content-Type: x\r\n\r\n"), io.Discard, nil).serve(0)
+	if err == nil {
+		t.Error("serve() error = nil, want an error for a message without a length")
+	}
+
+	// The input is closed on exit, so it is no longer read
+	r, w := io.Pipe()
+	go func() {
+		for _, body := range []string{
+			`{"jsonrpc":"2.0","id":1,"method":"shutdown"}`, `{"jsonrpc":"2.0","method":"exit"}`,
+		} {
+			fmt.Fprintf(w, "Content-Length: %d\r\n\r\n%s", len(body), body)
+		}
+	}()
+	if err := newLSPServer(r, io.Discard, nil).serve(0); err != nil {
+		t.Fatalf("serve() error = %v", err)
+	}
+	if _, err := w.Write([]byte("Content-Length: 2\r\n\r\n{}")); err != io.ErrClosedPipe {
+		t.Errorf("Write() after exit error = %v, want %v", err, io.ErrClosedPipe)
+	}
+}
+
+func TestPosition(t *testing.T) {
+	t.Parallel()
+
+	src := []byte("package a\n\nvar s = \"héllo 🚩\" + EnableX\n")
+	tests := []struct {
+		name         string
+		line, column int
+		want         lspPosition
+	}{
+		{name: "ascii", line: 1, column: 9, want: lspPosition{Line: 0, Character: 8}},
+		// é is 2 bytes but 1 code unit, 🚩 is 4 bytes but 2 code units
+		{name: "after non-ascii", line: 3, column: 25, want: lspPosition{Line: 2, Character: 21}},
+		{name: "past the end", line: 5, column: 3, want: lspPosition{Line: 4, Character: 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := position(src, tt.line, tt.column); got != tt.want {
+				t.Errorf("position(%d, %d) = %+v, want %+v", tt.line, tt.column, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestURIFilename(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		uri  string
+		want string
+		ok   bool
+	}{
+		{uri: "file:///home/me/src/main.go", want: "/home/me/src/main.go", ok: true},
+		{uri: "file:///C:/src/main.go", want: "C:/src/main.go", ok: true},
+		{uri: "file:///c%3A/src/main.go", want: "c:/src/main.go", ok: true},
+		{uri: "untitled:Untitled-1"},
+	}
+	for _, tt := range tests {
+		got, ok := uriFilename(tt.uri)
+		if want := filepath.FromSlash(tt.want); got != want || ok != tt.ok {
+			t.Errorf("uriFilename(%q) = %q, %v, want %q, %v", tt.uri, got, ok, want, tt.ok)
+		}
+	}
+	if got := fileURI("C:/src/main.go"); got != "file:///C:/src/main.go" {
+		t.Errorf("fileURI() = %q, want file:///C:/src/main.go", got)
+	}
+}
@@ -1,19 +1,128 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
 	"github.com/dgunay/flag-exorcist/flagexorcist"
 	"github.com/ilyakaznacheev/cleanenv"
+	"go.opentelemetry.io/otel"
 	"golang.org/x/tools/go/analysis/singlechecker"
 )
 
+// Subcommands that run instead of the analyzer.
+var commands = map[string]func(args []string) error{
+	"simulate":      simulate,
+	"github-check":  githubCheck,
+	"github-issues": githubIssues,
+	"open":          open,
+	"export":        export,
+	"notify":        notify,
+	"list":          list,
+	"doctor":        doctor,
+	"graph":         graph,
+	"scan":          scan,
+	"lsp":           lsp,
+	"hook":          hook,
+	"stats":         stats,
+	"scan-repos":    scanRepos,
+}
+
 func main() {
-	cfg := flagexorcist.Config{}
-	if err := cleanenv.ReadEnv(&cfg); err != nil {
-		panic(err)
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			if err := cmd(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	cfg, err := initialize()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	shutdown, tracing, err := setupTracing()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if cfg.Progress || tracing || writesAfterRun(os.Args[1:]) {
+		// The checker parses the analyzer's flags itself
+		if err := flagexorcist.Analyzer.Flags.Parse(os.Args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		os.Exit(runInProcess(flagexorcist.Analyzer.Flags.Args(), cfg.Progress, shutdown))
 	}
 
-	flagexorcist.Initialize(cfg)
 	analyzer := flagexorcist.Analyzer
 
 	singlechecker.Main(analyzer)
 }
+
+// initialize configures the analyzer from the environment.
+func initialize() (flagexorcist.Config, error) {
+	cfg := flagexorcist.Config{}
+	if err := cleanenv.ReadEnv(&cfg); err != nil {
+		return cfg, err
+	}
+	return cfg, flagexorcist.Initialize(cfg)
+}
+
+// writesAfterRun reports whether the arguments ask for the cache to be
+// exported or a summary of the run to be written, which can only be done once
+// every package is analyzed, so the analyzer has to run in-process.
+func writesAfterRun(args []string) bool {
+	for _, arg := range args {
+		if arg == "--" {
+			return false
+		}
+		name, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if strings.HasPrefix(arg, "-") && (name == "cache-export" || name == "summary-out") {
+			return true
+		}
+	}
+	return false
+}
+
+// runInProcess runs the analyzer in-process, which unlike the vet-style
+// checker returns before exiting, so that progress and a timing summary can be
+// shown and traces flushed. Diagnostics are printed the way the checker prints
+// them, and the exit code is the same.
+func runInProcess(patterns []string, progress bool, shutdownTracing func()) int {
+	defer shutdownTracing()
+	ctx, span := otel.Tracer("github.com/dgunay/flag-exorcist").Start(
+		context.Background(), "flag-exorcist",
+	)
+	defer span.End()
+
+	start := time.Now()
+	stop := func() {}
+	if progress {
+		stop = flagexorcist.ReportProgress(os.Stderr, time.Second)
+	}
+	findings, err := flagexorcist.RunContext(ctx, patterns...)
+	stop()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	exit := 0
+	for _, f := range findings {
+		if f.Fails() {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", f.Position, f.Message)
+			exit = 3
+		}
+	}
+	if progress {
+		flagexorcist.WriteSummary(os.Stderr, time.Since(start))
+	}
+	return exit
+}
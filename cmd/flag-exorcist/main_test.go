@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func TestWritesAfterRun(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		args []string
+		want bool
+	}{
+		{name: "no flags", args: []string{"./..."}},
+		{name: "flag", args: []string{"--cache-export", "cache.bin", "./..."}, want: true},
+		{name: "flag with value", args: []string{"-cache-export=cache.bin"}, want: true},
+		{name: "import only", args: []string{"--cache-import=cache.bin", "./..."}},
+		{
+			name: "after another flag",
+			args: []string{"--since-ref", "main", "--cache-export=cache.bin"},
+			want: true,
+		},
+		{name: "after --", args: []string{"--", "--cache-export=cache.bin"}},
+		{name: "summary", args: []string{"--summary-out", "summary.json", "./..."}, want: true},
+		{name: "previous summary only", args: []string{"--summary-previous=summary.json"}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := writesAfterRun(tt.args); got != tt.want {
+				t.Errorf("writesAfterRun(%q) = %v, want %v", tt.args, got, tt.want)
+			}
+		})
+	}
+}
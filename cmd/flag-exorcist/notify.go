@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/dgunay/flag-exorcist/flagexorcist"
+	"github.com/ilyakaznacheev/cleanenv"
+)
+
+// maxNotifiedFlags is the most flags listed in each section of a notification,
+// to stay well under Slack's message size limit.
+const maxNotifiedFlags = 50
+
+type notifyConfig struct {
+	// A Slack incoming webhook, or anything that takes the same payload
+	WebhookURL string `env:"WEBHOOK_URL" env-required:"true"`
+
+	// Heading of the message
+	Title string `env:"NOTIFY_TITLE" env-default:"Stale feature flags"`
+
+	// File that remembers which flags were stale on the last run, so newly
+	// stale ones can be called out. Without it, every flag is listed as is.
+	StateFile string `env:"NOTIFY_STATE_FILE"`
+}
+
+// notifyState is what is remembered between runs.
+type notifyState struct {
+	Flags []string `json:"flags"`
+}
+
+// slackMessage is the payload of a Slack incoming webhook.
+type slackMessage struct {
+	Text string `json:"text"`
+}
+
+// notify runs the analyzer and posts a summary of the stale flags to a
+// webhook.
+func notify(args []string) error {
+	fs := flag.NewFlagSet("notify", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "print the message instead of posting it")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: flag-exorcist notify [--dry-run] [packages]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	nCfg := notifyConfig{}
+	if err := cleanenv.ReadEnv(&nCfg); err != nil {
+		return err
+	}
+
+	if _, err := initialize(); err != nil {
+		return err
+	}
+	findings, err := flagexorcist.Run(fs.Args()...)
+	if err != nil {
+		return err
+	}
+
+	var previous map[string]bool
+	if nCfg.StateFile != "" {
+		if previous, err = readNotifyState(nCfg.StateFile); err != nil {
+			return err
+		}
+	}
+	stale, snoozed := staleFlags(findings)
+	msg := slackMessage{Text: notification(nCfg.Title, stale, snoozed, previous)}
+
+	if *dryRun {
+		fmt.Println(msg.Text)
+		return nil
+	}
+	if err := postWebhook(nCfg.WebhookURL, msg); err != nil {
+		return err
+	}
+	if nCfg.StateFile != "" {
+		return writeNotifyState(nCfg.StateFile, stale)
+	}
+	return nil
+}
+
+// staleFlag is a flag past the cutoff, and its findings.
+type staleFlag struct {
+	flag     flagexorcist.FlagID
+	findings []flagexorcist.Finding
+}
+
+// staleFlags groups the findings about flags past the cutoff by flag, oldest
+// flag first, apart from the ones about snoozed flags.
+func staleFlags(findings []flagexorcist.Finding) (stale, snoozed []staleFlag) {
+	byFlag := map[flagexorcist.FlagID][]flagexorcist.Finding{}
+	snoozedByFlag := map[flagexorcist.FlagID][]flagexorcist.Finding{}
+	for _, f := range findings {
+		switch {
+		case !flagexorcist.PastCutoff(f.Category):
+		case f.Snoozed != nil:
+			snoozedByFlag[f.Flag] = append(snoozedByFlag[f.Flag], f)
+		default:
+			byFlag[f.Flag] = append(byFlag[f.Flag], f)
+		}
+	}
+	return oldestFirst(byFlag), oldestFirst(snoozedByFlag)
+}
+
+func oldestFirst(byFlag map[flagexorcist.FlagID][]flagexorcist.Finding) []staleFlag {
+	stale := []staleFlag{}
+	for flag, fs := range byFlag {
+		stale = append(stale, staleFlag{flag: flag, findings: fs})
+	}
+	sort.Slice(stale, func(i, j int) bool {
+		a, b := stale[i].findings[0].IntroducedAt, stale[j].findings[0].IntroducedAt
+		if !a.Equal(b) {
+			return a.Before(b)
+		}
+		return stale[i].flag.String() < stale[j].flag.String()
+	})
+	return stale
+}
+
+// notification renders the message. If previous is not nil, flags that
+// weren't in it are listed apart as newly stale. Snoozed flags are listed
+// last.
+func notification(title string, stale, snoozed []staleFlag, previous map[string]bool) string {
+	b := &strings.Builder{}
+	if len(stale) == 0 {
+		fmt.Fprintf(b, "*%s*: none :tada:\n", title)
+	} else {
+		fmt.Fprintf(b, "*%s*: %d\n", title, len(stale))
+		writeStale(b, stale, previous)
+	}
+	if len(snoozed) > 0 {
+		fmt.Fprintf(b, "\n*Snoozed (%d)*\n", len(snoozed))
+		writeFlagList(b, snoozed)
+	}
+	return b.String()
+}
+
+func writeStale(b *strings.Builder, stale []staleFlag, previous map[string]bool) {
+	if previous == nil {
+		writeFlagList(b, stale)
+		return
+	}
+
+	fresh, old := []staleFlag{}, []staleFlag{}
+	for _, s := range stale {
+		if previous[s.flag.String()] {
+			old = append(old, s)
+		} else {
+			fresh = append(fresh, s)
+		}
+	}
+	if len(fresh) > 0 {
+		fmt.Fprintf(b, "\n*Newly stale since the last run (%d)*\n", len(fresh))
+		writeFlagList(b, fresh)
+	}
+	if len(old) > 0 {
+		fmt.Fprintf(b, "\n*Still stale (%d)*\n", len(old))
+		writeFlagList(b, old)
+	}
+}
+
+func writeFlagList(b *strings.Builder, stale []staleFlag) {
+	for i, s := range stale {
+		if i == maxNotifiedFlags {
+			fmt.Fprintf(b, "• …and %d more\n", len(stale)-i)
+			return
+		}
+
+		f := s.findings[0]
+		count := 0
+		for _, f := range s.findings {
+			count += f.UsageCount
+		}
+		fmt.Fprintf(b, "• `%s`", s.flag)
+		if f.Description != "" {
+			fmt.Fprintf(b, " (%s)", f.Description)
+		}
+		fmt.Fprintf(b, ", added %s, %d usages", f.IntroducedAt.UTC().Format("2006-01-02"), count)
+		if len(f.CodeOwners) > 0 {
+			fmt.Fprintf(b, ", owned by %s", strings.Join(f.CodeOwners, " "))
+		}
+		if len(f.Tickets) > 0 {
+			fmt.Fprintf(b, ", tracked in %s", strings.Join(f.Tickets, ", "))
+		}
+		if f.Snoozed != nil {
+			fmt.Fprintf(b, ", %s", f.Snoozed)
+		}
+		b.WriteString("\n")
+	}
+}
+
+// postWebhook posts msg to a Slack-compatible webhook.
+func postWebhook(url string, msg slackMessage) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("encode message: %w", err)
+	}
+	resp, err := http.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("post to webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		text, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("post to webhook: %s: %s", resp.Status, bytes.TrimSpace(text))
+	}
+	return nil
+}
+
+// readNotifyState returns the flags that were stale on the last run. The
+// first run has no state, so every flag is newly stale.
+func readNotifyState(filename string) (map[string]bool, error) {
+	contents, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return map[string]bool{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("read notify state: %w", err)
+	}
+
+	state := notifyState{}
+	if err := json.Unmarshal(contents, &state); err != nil {
+		return nil, fmt.Errorf("parse notify state %s: %w", filename, err)
+	}
+	flags := map[string]bool{}
+	for _, flag := range state.Flags {
+		flags[flag] = true
+	}
+	return flags, nil
+}
+
+func writeNotifyState(filename string, stale []staleFlag) error {
+	state := notifyState{Flags: []string{}}
+	for _, s := range stale {
+		state.Flags = append(state.Flags, s.flag.String())
+	}
+	sort.Strings(state.Flags)
+
+	contents, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode notify state: %w", err)
+	}
+	if err := os.WriteFile(filename, append(contents, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write notify state: %w", err)
+	}
+	return nil
+}
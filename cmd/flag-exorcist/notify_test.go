@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dgunay/flag-exorcist/flagexorcist"
+)
+
+func TestNotification(t *testing.T) {
+	t.Parallel()
+
+	finding := func(name string, year int, snoozed bool) flagexorcist.Finding {
+		f := flagexorcist.Finding{
+			Flag:         flagexorcist.SymbolID(name),
+			Category:     flagexorcist.CategoryStale,
+			IntroducedAt: time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC),
+			UsageCount:   2,
+		}
+		if name == "EnableNew" {
+			f.Description = "The new flow"
+			f.Tickets = []string{"NEW-1", "NEW-2"}
+		}
+		if snoozed {
+			f.Snoozed = &flagexorcist.Snooze{Until: time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)}
+		}
+		return f
+	}
+	stale, snoozed := staleFlags([]flagexorcist.Finding{
+		finding("EnableNew", 2020, false),
+		finding("EnableOld", 2010, false),
+		// Counted once, with the usages of both packages
+		finding("EnableOld", 2010, false),
+		finding("EnableLater", 2015, true),
+	})
+
+	got := notification("Stale flags", stale, snoozed, map[string]bool{"EnableOld": true})
+	want := "*Stale flags*: 2\n" +
+		"\n*Newly stale since the last run (1)*\n" +
+		"• `EnableNew` (The new flow), added 2020-01-01, 2 usages, tracked in NEW-1, NEW-2\n" +
+		"\n*Still stale (1)*\n" +
+		"• `EnableOld`, added 2010-01-01, 4 usages\n" +
+		"\n*Snoozed (1)*\n" +
+		"• `EnableLater`, added 2015-01-01, 2 usages, " + fmt.Sprint(snoozed[0].findings[0].Snoozed) + "\n"
+	if got != want {
+		t.Errorf("notification() =\n%s\nwant\n%s", got, want)
+	}
+
+	// Without any state, flags aren't told apart
+	got = notification("Stale flags", stale[:1], nil, nil)
+	want = "*Stale flags*: 1\n• `EnableOld`, added 2010-01-01, 4 usages\n"
+	if got != want {
+		t.Errorf("notification() =\n%s\nwant\n%s", got, want)
+	}
+
+	if got := notification("Stale flags", nil, nil, nil); got != "*Stale flags*: none :tada:\n" {
+		t.Errorf("notification() = %q, want none", got)
+	}
+}
+
+func TestNotifyState(t *testing.T) {
+	t.Parallel()
+
+	filename := filepath.Join(t.TempDir(), "state.json")
+	previous, err := readNotifyState(filename)
+	if err != nil || len(previous) != 0 {
+		t.Fatalf("readNotifyState() = %v, %v before the first run, want nothing", previous, err)
+	}
+
+	stale := []staleFlag{
+		{flag: flagexorcist.SymbolID("EnableY")},
+		{flag: flagexorcist.SymbolID("EnableX")},
+	}
+	if err := writeNotifyState(filename, stale); err != nil {
+		t.Fatalf("writeNotifyState() error = %v", err)
+	}
+	previous, err = readNotifyState(filename)
+	if err != nil {
+		t.Fatalf("readNotifyState() error = %v", err)
+	}
+	if want := map[string]bool{"EnableX": true, "EnableY": true}; !reflect.DeepEqual(previous, want) {
+		t.Errorf("readNotifyState() = %v, want %v", previous, want)
+	}
+}
+
+func TestPostWebhook(t *testing.T) {
+	t.Parallel()
+
+	var posted slackMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if got := req.Header.Get("Content-Type"); got != "application/json" {
+			t.Errorf("Content-Type = %q, want JSON", got)
+		}
+		if err := json.NewDecoder(req.Body).Decode(&posted); err != nil {
+			t.Errorf("Failed to decode payload: %s", err)
+		}
+	}))
+	defer server.Close()
+
+	if err := postWebhook(server.URL, slackMessage{Text: "hi"}); err != nil {
+		t.Fatalf("postWebhook() error = %v", err)
+	}
+	if posted.Text != "hi" {
+		t.Errorf("postWebhook() posted %+v, want the message", posted)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "invalid_payload", http.StatusBadRequest)
+	}))
+	defer failing.Close()
+	if err := postWebhook(failing.URL, slackMessage{}); err == nil {
+		t.Error("postWebhook() error = nil, want the webhook's error")
+	}
+}
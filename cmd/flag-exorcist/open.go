@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/dgunay/flag-exorcist/flagexorcist"
+)
+
+// open opens the declaration and each usage of a flag in the user's editor,
+// one after another.
+func open(args []string) error {
+	fs := flag.NewFlagSet("open", flag.ExitOnError)
+	key := fs.Bool("key", false, "the flag is a string key rather than a Go symbol")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: flag-exorcist open [--key] <flag> [packages]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	id := flagexorcist.SymbolID(fs.Arg(0))
+	if *key {
+		id = flagexorcist.KeyID(fs.Arg(0))
+	}
+
+	pkgs, err := loadPackages(fs.Args()[1:])
+	if err != nil {
+		return err
+	}
+
+	// A const and the key it holds are on the same line, so only go to each
+	// line once.
+	locs := []flagexorcist.Location{}
+	lines := map[string]bool{}
+	for _, loc := range flagexorcist.Locate(pkgs, id) {
+		line := fmt.Sprintf("%s:%d", loc.Position.Filename, loc.Position.Line)
+		if !lines[line] {
+			lines[line] = true
+			locs = append(locs, loc)
+		}
+	}
+	if len(locs) == 0 {
+		return fmt.Errorf("flag %q is not used in the loaded packages", id)
+	}
+
+	editor := editor()
+	in := bufio.NewReader(os.Stdin)
+	for i, loc := range locs {
+		what := "usage"
+		if loc.Declaration {
+			what = "declaration"
+		}
+		fmt.Printf("[%d/%d] %s %s\n", i+1, len(locs), what, loc.Position)
+
+		cmd := editorCommand(editor, loc.Position)
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("run %s: %w", editor, err)
+		}
+
+		if i == len(locs)-1 {
+			break
+		}
+		fmt.Print("Enter for the next one, q to quit: ")
+		line, err := in.ReadString('\n')
+		if err != nil || strings.TrimSpace(line) == "q" {
+			break
+		}
+	}
+	return nil
+}
+
+// editor returns the user's editor: $VISUAL, then $EDITOR, then VS Code if it
+// is installed, then vi.
+func editor() string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if e := os.Getenv(env); e != "" {
+			return e
+		}
+	}
+	if _, err := exec.LookPath("code"); err == nil {
+		return "code"
+	}
+	return "vi"
+}
+
+// editorCommand builds the command that opens editor at pos. VS Code and its
+// forks take file:line:column with -g; everything else gets the +line
+// convention vi, emacs and nano understand.
+func editorCommand(editor string, pos token.Position) *exec.Cmd {
+	args := strings.Fields(editor)
+	switch filepath.Base(args[0]) {
+	case "code", "code-insiders", "codium":
+		args = append(args, "-g", fmt.Sprintf("%s:%d:%d", pos.Filename, pos.Line, pos.Column))
+	default:
+		args = append(args, fmt.Sprintf("+%d", pos.Line), pos.Filename)
+	}
+	return exec.Command(args[0], args[1:]...)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"go/token"
+	"reflect"
+	"testing"
+)
+
+func TestEditorCommand(t *testing.T) {
+	t.Parallel()
+
+	pos := token.Position{Filename: "/src/flags/flags.go", Line: 12, Column: 5}
+	tests := []struct {
+		editor   string
+		wantArgs []string
+	}{
+		{editor: "vi", wantArgs: []string{"vi", "+12", "/src/flags/flags.go"}},
+		{editor: "emacs -nw", wantArgs: []string{"emacs", "-nw", "+12", "/src/flags/flags.go"}},
+		{editor: "code", wantArgs: []string{"code", "-g", "/src/flags/flags.go:12:5"}},
+		{editor: "code --wait", wantArgs: []string{"code", "--wait", "-g", "/src/flags/flags.go:12:5"}},
+		{editor: "/usr/bin/codium", wantArgs: []string{"/usr/bin/codium", "-g", "/src/flags/flags.go:12:5"}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.editor, func(t *testing.T) {
+			t.Parallel()
+
+			cmd := editorCommand(tt.editor, pos)
+			if !reflect.DeepEqual(cmd.Args, tt.wantArgs) {
+				t.Errorf("editorCommand(%q) = %q, want %q", tt.editor, cmd.Args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+// Not parallel, since it sets the editor environment variables.
+func TestEditor(t *testing.T) {
+	t.Setenv("VISUAL", "nano")
+	t.Setenv("EDITOR", "vim")
+	if got := editor(); got != "nano" {
+		t.Errorf("editor() = %q, want $VISUAL", got)
+	}
+	t.Setenv("VISUAL", "")
+	if got := editor(); got != "vim" {
+		t.Errorf("editor() = %q, want $EDITOR", got)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dgunay/flag-exorcist/flagexorcist"
+)
+
+// repoTarget is a repo to scan, and the packages in it to analyze.
+type repoTarget struct {
+	Path     string
+	Patterns []string
+}
+
+// repoFlag is what was found about a flag in one repo.
+type repoFlag struct {
+	Repo     string                 `json:"repo"`
+	Flag     flagexorcist.FlagID    `json:"flag"`
+	AgeDays  int                    `json:"ageDays"`
+	Failing  bool                   `json:"failing"`
+	Findings []flagexorcist.Finding `json:"findings"`
+}
+
+// scanRepos runs the analyzer over several repos with the same config, and
+// reports on them together, by repo and flag. Repos are given as arguments,
+// or listed in a manifest. A repo that can't be analyzed doesn't stop the
+// others from being.
+func scanRepos(args []string) error {
+	fs := flag.NewFlagSet("scan-repos", flag.ExitOnError)
+	manifest := fs.String("manifest", "",
+		"a file listing a repo on each line, with the packages to analyze in it after its path",
+	)
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: flag-exorcist scan-repos [--manifest file] [--json] [repos]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	targets := []repoTarget{}
+	for _, path := range fs.Args() {
+		targets = append(targets, repoTarget{Path: path})
+	}
+	if *manifest != "" {
+		f, err := os.Open(*manifest)
+		if err != nil {
+			return fmt.Errorf("open manifest: %w", err)
+		}
+		listed, err := readManifest(f, filepath.Dir(*manifest))
+		f.Close()
+		if err != nil {
+			return err
+		}
+		targets = append(targets, listed...)
+	}
+	if len(targets) == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := initialize()
+	if err != nil {
+		return err
+	}
+	report := []repoFlag{}
+	failed := 0
+	for _, target := range targets {
+		cfg.RepoPath = target.Path
+		if err := flagexorcist.Initialize(cfg); err != nil {
+			return err
+		}
+		findings, err := flagexorcist.RunDir(context.Background(), target.Path, target.Patterns...)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", target.Path, err)
+			failed++
+			continue
+		}
+		report = append(report, mergeFindings(target.Path, findings)...)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	} else if err := writeMerged(os.Stdout, report); err != nil {
+		return err
+	}
+
+	failing := 0
+	for _, f := range report {
+		if f.Failing {
+			failing++
+		}
+	}
+	switch {
+	case failed > 0:
+		return fmt.Errorf("%d of %d repos couldn't be analyzed", failed, len(targets))
+	case failing > 0:
+		return fmt.Errorf("%d flags fail the run across %d repos", failing, len(targets))
+	}
+	return nil
+}
+
+// readManifest reads the repos listed in a manifest: a path on each line,
+// relative to dir, followed by the packages to analyze in it. Blank lines and
+// lines starting with # are skipped.
+func readManifest(r io.Reader, dir string) ([]repoTarget, error) {
+	targets := []repoTarget{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		path := fields[0]
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		targets = append(targets, repoTarget{Path: path, Patterns: fields[1:]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read manifest: %w", err)
+	}
+	return targets, nil
+}
+
+// mergeFindings groups the findings in a repo by flag, sorted by flag.
+func mergeFindings(repo string, findings []flagexorcist.Finding) []repoFlag {
+	byFlag := map[flagexorcist.FlagID]*repoFlag{}
+	merged := []*repoFlag{}
+	for _, f := range findings {
+		entry, ok := byFlag[f.Flag]
+		if !ok {
+			entry = &repoFlag{Repo: repo, Flag: f.Flag}
+			byFlag[f.Flag] = entry
+			merged = append(merged, entry)
+		}
+		entry.Findings = append(entry.Findings, f)
+		entry.Failing = entry.Failing || f.Fails()
+		if f.AgeDays > entry.AgeDays {
+			entry.AgeDays = f.AgeDays
+		}
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Flag.String() < merged[j].Flag.String()
+	})
+
+	flags := make([]repoFlag, 0, len(merged))
+	for _, entry := range merged {
+		flags = append(flags, *entry)
+	}
+	return flags
+}
+
+// writeMerged writes a line for each flag in each repo.
+func writeMerged(w io.Writer, report []repoFlag) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "REPO\tFLAG\tAGE\tFINDINGS\tFAILING")
+	for _, f := range report {
+		failing := "no"
+		if f.Failing {
+			failing = "yes"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%dd\t%d\t%s\n",
+			f.Repo, f.Flag, f.AgeDays, len(f.Findings), failing,
+		)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dgunay/flag-exorcist/flagexorcist"
+)
+
+func TestReadManifest(t *testing.T) {
+	t.Parallel()
+
+	manifest := "# Services\n" +
+		"billing\n" +
+		"\n" +
+		"/srv/checkout ./cmd/... ./internal/...\n"
+	got, err := readManifest(strings.NewReader(manifest), "repos")
+	if err != nil {
+		t.Fatalf("readManifest() error = %v", err)
+	}
+	want := []repoTarget{
+		{Path: filepath.Join("repos", "billing"), Patterns: []string{}},
+		{Path: "/srv/checkout", Patterns: []string{"./cmd/...", "./internal/..."}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readManifest() = %+v, want %+v", got, want)
+	}
+}
+
+func TestMergeFindings(t *testing.T) {
+	t.Parallel()
+
+	finding := func(name, category string, age int, enforced bool) flagexorcist.Finding {
+		return flagexorcist.Finding{
+			Flag:     flagexorcist.SymbolID(name),
+			Category: category,
+			Severity: flagexorcist.SeverityOf(category),
+			AgeDays:  age,
+			Enforced: enforced,
+		}
+	}
+	report := append(
+		mergeFindings("billing", []flagexorcist.Finding{
+			finding("EnableY", flagexorcist.CategoryStale, 100, false),
+			finding("EnableX", flagexorcist.CategoryStale, 200, true),
+			finding("EnableX", flagexorcist.CategoryUnused, 200, true),
+		}),
+		mergeFindings("checkout", []flagexorcist.Finding{
+			finding("EnableX", flagexorcist.CategoryInsufficientHistory, 0, true),
+		})...,
+	)
+	if len(report) != 3 || len(report[0].Findings) != 2 {
+		t.Fatalf("mergeFindings() = %+v, want EnableX and EnableY in billing, EnableX in checkout", report)
+	}
+
+	out := &strings.Builder{}
+	if err := writeMerged(out, report); err != nil {
+		t.Fatalf("writeMerged() error = %v", err)
+	}
+	want := "REPO      FLAG     AGE   FINDINGS  FAILING\n" +
+		"billing   EnableX  200d  2         yes\n" +
+		"billing   EnableY  100d  1         no\n" +
+		"checkout  EnableX  0d    1         no\n"
+	if out.String() != want {
+		t.Errorf("writeMerged() =\n%s\nwant\n%s", out, want)
+	}
+}
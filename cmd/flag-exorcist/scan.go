@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/dgunay/flag-exorcist/flagexorcist"
+)
+
+// scan loads packages itself and reports every finding about them, so the
+// analyzer can be run on a checkout without going through go vet. The first
+// argument, if it is a directory, is where packages are loaded from, which
+// can be another module; the rest are package patterns, which can also be
+// module paths.
+func scan(args []string) error {
+	fs := flag.NewFlagSet("scan", flag.ExitOnError)
+	asJSON := fs.Bool("json", false,
+		"print the findings as JSON, along with how many stale flags were left out with REPORT_TOP",
+	)
+	cacheImport := fs.String("cache-import", "",
+		"read what earlier runs found in the history from this file, if it exists",
+	)
+	cacheExport := fs.String("cache-export", "",
+		"write what was found in the history to this file, for later runs to import",
+	)
+	summaryOut := fs.String("summary-out", "", "write a summary of the run to this file as JSON")
+	summaryPrevious := fs.String("summary-previous", "",
+		"the summary of an earlier run, if it exists, to tell which flags are newly stale",
+	)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: flag-exorcist scan [flags] [dir] [packages]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	dir, patterns := scanArgs(fs.Args())
+
+	cfg, err := initialize()
+	if err != nil {
+		return err
+	}
+	if *cacheImport != "" {
+		if err := flagexorcist.ImportCache(*cacheImport); err != nil {
+			return err
+		}
+	}
+	start := time.Now()
+	stop := func() {}
+	if cfg.Progress {
+		stop = flagexorcist.ReportProgress(os.Stderr, time.Second)
+	}
+	findings, err := flagexorcist.RunDir(context.Background(), dir, patterns...)
+	stop()
+	if err != nil {
+		return err
+	}
+	unreported := flagexorcist.UnreportedFindings()
+	if cfg.Progress {
+		flagexorcist.WriteSummary(os.Stderr, time.Since(start))
+	}
+	if *cacheExport != "" {
+		if err := flagexorcist.ExportCache(*cacheExport); err != nil {
+			return err
+		}
+	}
+	if *summaryOut != "" {
+		err := writeRunSummary(*summaryOut, *summaryPrevious, findings, unreported, time.Since(start))
+		if err != nil {
+			return err
+		}
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		report := scanReport{Findings: findings, Unreported: unreportedFlags(unreported)}
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		writeReport(os.Stdout, findings, unreportedFlags(unreported))
+	}
+
+	all := append(append([]flagexorcist.Finding(nil), findings...), unreported...)
+	failed := 0
+	for _, f := range all {
+		if f.Fails() {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d findings fail the run", failed, len(all))
+	}
+	return nil
+}
+
+// scanArgs splits the arguments of scan into the directory to load packages
+// from and the patterns to load.
+func scanArgs(args []string) (dir string, patterns []string) {
+	if len(args) > 0 {
+		if info, err := os.Stat(args[0]); err == nil && info.IsDir() {
+			return args[0], args[1:]
+		}
+	}
+	return "", args
+}
+
+// scanReport is what scan prints with --json.
+type scanReport struct {
+	Findings []flagexorcist.Finding `json:"findings"`
+
+	// How many stale flags REPORT_TOP left out of the findings
+	Unreported int `json:"unreported"`
+}
+
+// unreportedFlags returns how many flags the findings REPORT_TOP left out are
+// about.
+func unreportedFlags(unreported []flagexorcist.Finding) int {
+	flags := map[flagexorcist.FlagID]bool{}
+	for _, f := range unreported {
+		flags[f.Flag] = true
+	}
+	return len(flags)
+}
+
+// writeReport writes a line for each finding, saying whether it fails the
+// run, and a count of them at the end, along with how many stale flags
+// REPORT_TOP left out.
+func writeReport(w io.Writer, findings []flagexorcist.Finding, unreported int) {
+	failed := 0
+	for _, f := range findings {
+		level := string(f.Severity)
+		switch {
+		case f.Snoozed != nil:
+			level = "snoozed"
+		case !f.Enforced:
+			level = "report-only"
+		}
+		if f.Fails() {
+			failed++
+		}
+		fmt.Fprintf(w, "%s:%d:%d: %s: %s\n",
+			f.Path, f.Position.Line, f.Position.Column, level, f.Message,
+		)
+	}
+	fmt.Fprintf(w, "%d findings, %d failing\n", len(findings), failed)
+	if unreported > 0 {
+		fmt.Fprintf(w, "%d more stale flags not reported, raise REPORT_TOP to see them\n", unreported)
+	}
+}
+
+// writeRunSummary writes a summary of a run that found findings in elapsed to
+// filename, compared to the summary in previous, if it is set and exists.
+func writeRunSummary(
+	filename, previous string, findings, unreported []flagexorcist.Finding, elapsed time.Duration,
+) error {
+	var prev *flagexorcist.RunSummary
+	if previous != "" {
+		var err error
+		if prev, err = flagexorcist.ReadRunSummary(previous); err != nil {
+			return err
+		}
+	}
+	summary := flagexorcist.Summarize(findings, unreported, flagexorcist.DatedFlags(), elapsed, prev)
+	return flagexorcist.WriteRunSummary(filename, summary)
+}
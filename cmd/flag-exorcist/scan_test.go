@@ -0,0 +1,83 @@
+package main
+
+import (
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgunay/flag-exorcist/flagexorcist"
+)
+
+func TestScanArgs(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	tests := []struct {
+		name         string
+		args         []string
+		wantDir      string
+		wantPatterns []string
+	}{
+		{name: "nothing", wantPatterns: nil},
+		{name: "directory", args: []string{dir}, wantDir: dir, wantPatterns: []string{}},
+		{
+			name:         "directory and patterns",
+			args:         []string{dir, "./internal/..."},
+			wantDir:      dir,
+			wantPatterns: []string{"./internal/..."},
+		},
+		{
+			name:         "module path",
+			args:         []string{"example.com/svc/..."},
+			wantPatterns: []string{"example.com/svc/..."},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir, patterns := scanArgs(tt.args)
+			if dir != tt.wantDir || !reflect.DeepEqual(patterns, tt.wantPatterns) {
+				t.Errorf("scanArgs(%q) = %q, %q, want %q, %q",
+					tt.args, dir, patterns, tt.wantDir, tt.wantPatterns)
+			}
+		})
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	t.Parallel()
+
+	finding := func(severity flagexorcist.Severity, enforced bool, message string) flagexorcist.Finding {
+		return flagexorcist.Finding{
+			Flag:     flagexorcist.SymbolID("EnableX"),
+			Severity: severity,
+			Enforced: enforced,
+			Message:  message,
+			Path:     "a/a.go",
+			Position: token.Position{Filename: "/src/a/a.go", Line: 3, Column: 9},
+		}
+	}
+	snoozed := finding(flagexorcist.SeverityError, true, "snoozed")
+	snoozed.Snoozed = &flagexorcist.Snooze{Until: time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	report := &strings.Builder{}
+	writeReport(report, []flagexorcist.Finding{
+		finding(flagexorcist.SeverityError, true, "stale"),
+		finding(flagexorcist.SeverityWarning, true, "shallow"),
+		finding(flagexorcist.SeverityError, false, "elsewhere"),
+		snoozed,
+	}, 2)
+	want := "a/a.go:3:9: error: stale\n" +
+		"a/a.go:3:9: warning: shallow\n" +
+		"a/a.go:3:9: report-only: elsewhere\n" +
+		"a/a.go:3:9: snoozed: snoozed\n" +
+		"4 findings, 1 failing\n" +
+		"2 more stale flags not reported, raise REPORT_TOP to see them\n"
+	if report.String() != want {
+		t.Errorf("writeReport() =\n%s\nwant\n%s", report, want)
+	}
+}
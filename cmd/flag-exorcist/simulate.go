@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/parser"
+	"os"
+
+	"github.com/dgunay/flag-exorcist/flagexorcist"
+	"golang.org/x/tools/go/packages"
+)
+
+// simulate prints a JSON description of what removing a flag would change.
+func simulate(args []string) error {
+	fs := flag.NewFlagSet("simulate", flag.ExitOnError)
+	symbol := fs.String("flag", "", "the flag symbol to simulate removing")
+	value := fs.String("value", "", "the value to assume the flag has (defaults to its declared value)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: flag-exorcist simulate --flag X [packages]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *symbol == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	pkgs, err := loadPackages(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	var assumed constant.Value
+	if *value != "" {
+		if assumed, err = parseValue(*value); err != nil {
+			return err
+		}
+	}
+
+	sim, err := flagexorcist.Simulate(pkgs, *symbol, assumed)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sim)
+}
+
+// parseValue parses a Go literal like true, 42 or "on".
+func parseValue(s string) (constant.Value, error) {
+	switch s {
+	case "true", "false":
+		return constant.MakeBool(s == "true"), nil
+	}
+
+	expr, err := parser.ParseExpr(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value %q: %w", s, err)
+	}
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok {
+		return nil, fmt.Errorf("invalid value %q: must be a literal", s)
+	}
+	return constant.MakeFromLiteral(lit.Value, lit.Kind, 0), nil
+}
+
+// loadPackages loads the packages matching patterns (./... by default) with
+// full syntax and type information.
+func loadPackages(patterns []string) ([]*packages.Package, error) {
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+
+	pkgs, err := packages.Load(&packages.Config{Mode: packages.LoadAllSyntax}, patterns...)
+	if err != nil {
+		return nil, err
+	}
+	if packages.PrintErrors(pkgs) > 0 {
+		return nil, fmt.Errorf("failed to load packages")
+	}
+	return pkgs, nil
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dgunay/flag-exorcist/flagexorcist"
+)
+
+// ageStats are the numbers the stats subcommand prints, and saves to compare
+// later runs to.
+type ageStats struct {
+	Date time.Time `json:"date"`
+
+	// Every flag tracked, and how many of them couldn't be dated
+	Flags   int `json:"flags"`
+	Undated int `json:"undated"`
+
+	// How many dated flags are in each age bucket
+	Buckets []ageBucket `json:"buckets"`
+
+	// Of the dated flags, in days
+	MeanAgeDays   float64 `json:"meanAgeDays"`
+	MedianAgeDays float64 `json:"medianAgeDays"`
+
+	// Flags past their cutoff, and what percentage of all flags they are
+	Stale        int     `json:"stale"`
+	StalePercent float64 `json:"stalePercent"`
+}
+
+type ageBucket struct {
+	Label string `json:"label"`
+	Flags int    `json:"flags"`
+}
+
+// The buckets flags are counted in, by the age in days they start at
+var bucketStarts = []struct {
+	label string
+	days  int
+}{
+	{"0-30d", 0},
+	{"30-90d", 30},
+	{"90d+", 90},
+}
+
+// stats prints how old the tracked flags are, all together, for tracking how
+// well a codebase keeps on top of them over time. Given the stats file of an
+// earlier run, it prints how each number changed since.
+func stats(args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	previous := fs.String("previous", "", "a stats file written by an earlier run, to compare to")
+	out := fs.String("out", "", "write the stats to this file, to compare later runs to")
+	asJSON := fs.Bool("json", false, "print the stats as JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(),
+			"usage: flag-exorcist stats [--previous file] [--out file] [--json] [packages]",
+		)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var prev *ageStats
+	if *previous != "" {
+		contents, err := os.ReadFile(*previous)
+		if err != nil {
+			return fmt.Errorf("read previous stats: %w", err)
+		}
+		prev = &ageStats{}
+		if err := json.Unmarshal(contents, prev); err != nil {
+			return fmt.Errorf("parse previous stats: %w", err)
+		}
+	}
+
+	if _, err := initialize(); err != nil {
+		return err
+	}
+	if _, err := flagexorcist.Run(fs.Args()...); err != nil {
+		return err
+	}
+	current := computeStats(flagexorcist.DatedFlags(), time.Now().UTC())
+
+	if *out != "" {
+		contents, err := json.MarshalIndent(current, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(*out, append(contents, '\n'), 0o644); err != nil {
+			return fmt.Errorf("write stats: %w", err)
+		}
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(current)
+	}
+	return writeStats(os.Stdout, current, prev)
+}
+
+// computeStats adds up the ages of the flags, as of date.
+func computeStats(flags []flagexorcist.DatedFlag, date time.Time) ageStats {
+	s := ageStats{Date: date, Flags: len(flags)}
+	for _, b := range bucketStarts {
+		s.Buckets = append(s.Buckets, ageBucket{Label: b.label})
+	}
+
+	ages := []int{}
+	for _, f := range flags {
+		if f.Stale {
+			s.Stale++
+		}
+		if f.Undated {
+			s.Undated++
+			continue
+		}
+		ages = append(ages, f.AgeDays)
+		for i := len(bucketStarts) - 1; i >= 0; i-- {
+			if f.AgeDays >= bucketStarts[i].days {
+				s.Buckets[i].Flags++
+				break
+			}
+		}
+	}
+
+	if len(flags) > 0 {
+		s.StalePercent = 100 * float64(s.Stale) / float64(len(flags))
+	}
+	if len(ages) > 0 {
+		sort.Ints(ages)
+		total := 0
+		for _, age := range ages {
+			total += age
+		}
+		s.MeanAgeDays = float64(total) / float64(len(ages))
+		mid := len(ages) / 2
+		s.MedianAgeDays = float64(ages[mid])
+		if len(ages)%2 == 0 {
+			s.MedianAgeDays = float64(ages[mid-1]+ages[mid]) / 2
+		}
+	}
+	return s
+}
+
+// writeStats writes the stats as a table, with how each number changed since
+// prev if it isn't nil.
+func writeStats(w io.Writer, s ageStats, prev *ageStats) error {
+	change := func(format string, get func(ageStats) float64) string {
+		if prev == nil {
+			return ""
+		}
+		return fmt.Sprintf(format, get(s)-get(*prev))
+	}
+	count := func(get func(ageStats) int) string {
+		return change("%+.0f", func(s ageStats) float64 { return float64(get(s)) })
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	row := func(label, value, change string) {
+		if prev == nil {
+			fmt.Fprintf(tw, "%s\t%s\n", label, value)
+		} else {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", label, value, change)
+		}
+	}
+	row("flags", fmt.Sprint(s.Flags), count(func(s ageStats) int { return s.Flags }))
+	row("undated", fmt.Sprint(s.Undated), count(func(s ageStats) int { return s.Undated }))
+	for _, b := range s.Buckets {
+		label := b.Label
+		row(label, fmt.Sprint(b.Flags), count(func(s ageStats) int { return bucketFlags(s, label) }))
+	}
+	row("mean age", fmt.Sprintf("%.1fd", s.MeanAgeDays),
+		change("%+.1fd", func(s ageStats) float64 { return s.MeanAgeDays }),
+	)
+	row("median age", fmt.Sprintf("%.1fd", s.MedianAgeDays),
+		change("%+.1fd", func(s ageStats) float64 { return s.MedianAgeDays }),
+	)
+	row("stale", fmt.Sprintf("%d (%.1f%%)", s.Stale, s.StalePercent),
+		change("%+.1f pts", func(s ageStats) float64 { return s.StalePercent }),
+	)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if prev != nil {
+		_, err := fmt.Fprintf(w, "Changes are since %s.\n", prev.Date.Format("2006-01-02"))
+		return err
+	}
+	return nil
+}
+
+// bucketFlags returns how many flags are in the bucket with label, or 0 if
+// the buckets were different when s was saved.
+func bucketFlags(s ageStats, label string) int {
+	for _, b := range s.Buckets {
+		if b.Label == label {
+			return b.Flags
+		}
+	}
+	return 0
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgunay/flag-exorcist/flagexorcist"
+)
+
+func TestComputeStats(t *testing.T) {
+	t.Parallel()
+
+	date := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	flag := func(age int, stale bool) flagexorcist.DatedFlag {
+		return flagexorcist.DatedFlag{AgeDays: age, Stale: stale}
+	}
+	got := computeStats([]flagexorcist.DatedFlag{
+		flag(10, false),
+		flag(30, false),
+		flag(60, false),
+		flag(200, true),
+		{Undated: true},
+	}, date)
+	want := ageStats{
+		Date:    date,
+		Flags:   5,
+		Undated: 1,
+		Buckets: []ageBucket{
+			{Label: "0-30d", Flags: 1},
+			{Label: "30-90d", Flags: 2},
+			{Label: "90d+", Flags: 1},
+		},
+		MeanAgeDays:   75,
+		MedianAgeDays: 45,
+		Stale:         1,
+		StalePercent:  20,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("computeStats() = %+v, want %+v", got, want)
+	}
+
+	if got := computeStats(nil, date); got.Flags != 0 || got.MeanAgeDays != 0 || got.StalePercent != 0 {
+		t.Errorf("computeStats(nil) = %+v, want zeroes", got)
+	}
+}
+
+func TestWriteStats(t *testing.T) {
+	t.Parallel()
+
+	current := ageStats{
+		Flags: 4,
+		Buckets: []ageBucket{
+			{Label: "0-30d", Flags: 1},
+			{Label: "30-90d", Flags: 2},
+			{Label: "90d+", Flags: 1},
+		},
+		MeanAgeDays:   75,
+		MedianAgeDays: 45,
+		Stale:         1,
+		StalePercent:  25,
+	}
+	out := &strings.Builder{}
+	if err := writeStats(out, current, nil); err != nil {
+		t.Fatalf("writeStats() error = %v", err)
+	}
+	want := "flags       4\n" +
+		"undated     0\n" +
+		"0-30d       1\n" +
+		"30-90d      2\n" +
+		"90d+        1\n" +
+		"mean age    75.0d\n" +
+		"median age  45.0d\n" +
+		"stale       1 (25.0%)\n"
+	if out.String() != want {
+		t.Errorf("writeStats() =\n%s\nwant\n%s", out, want)
+	}
+
+	// Buckets missing from the previous stats count as empty
+	previous := ageStats{
+		Date:          time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC),
+		Flags:         5,
+		Buckets:       []ageBucket{{Label: "0-30d", Flags: 3}},
+		MeanAgeDays:   80.5,
+		MedianAgeDays: 45,
+		Stale:         2,
+		StalePercent:  40,
+	}
+	out.Reset()
+	if err := writeStats(out, current, &previous); err != nil {
+		t.Fatalf("writeStats() error = %v", err)
+	}
+	want = "flags       4          -1\n" +
+		"undated     0          +0\n" +
+		"0-30d       1          -2\n" +
+		"30-90d      2          +2\n" +
+		"90d+        1          +1\n" +
+		"mean age    75.0d      -5.5d\n" +
+		"median age  45.0d      +0.0d\n" +
+		"stale       1 (25.0%)  -15.0 pts\n" +
+		"Changes are since 2024-10-01.\n"
+	if out.String() != want {
+		t.Errorf("writeStats() =\n%s\nwant\n%s", out, want)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+)
+
+// setupTracing exports traces of the analysis over OTLP/HTTP, if an endpoint
+// is configured with the standard OTEL_EXPORTER_OTLP_ENDPOINT or
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT variables. The exporter reads the rest of
+// its settings, like headers, from the environment too. shutdown flushes
+// whatever spans are left.
+func setupTracing() (shutdown func(), enabled bool, err error) {
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" &&
+		os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return func() {}, false, nil
+	}
+
+	ctx := context.Background()
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("create OTLP exporter: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL, semconv.ServiceName("flag-exorcist"),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func() {
+		if err := provider.Shutdown(ctx); err != nil {
+			fmt.Fprintln(os.Stderr, "failed to export traces:", err)
+		}
+	}, true, nil
+}
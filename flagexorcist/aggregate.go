@@ -0,0 +1,120 @@
+package flagexorcist
+
+import (
+	"fmt"
+	"go/types"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// flagUsesFact counts the usages of each flag in a package, by the flag's
+// string form. Run adds them up across every package it analyzes, since a
+// flag can be used nowhere in the package declaring it, but all over the
+// ones importing it.
+type flagUsesFact struct {
+	Uses map[string]int
+
+	// How many files that build constraints excluded mention each flag,
+	// which may be using it
+	Excluded map[string]int
+}
+
+func (*flagUsesFact) AFact() {}
+
+func (f *flagUsesFact) String() string {
+	flags := make([]string, 0, len(f.Uses))
+	for flag, n := range f.Uses {
+		flags = append(flags, fmt.Sprintf("%s=%d", flag, n))
+	}
+	for flag, n := range f.Excluded {
+		flags = append(flags, fmt.Sprintf("%s=%d excluded", flag, n))
+	}
+	sort.Strings(flags)
+	return "flag uses " + strings.Join(flags, " ")
+}
+
+// exportUses exports how often the package of pass uses each flag, if the
+// whole build graph is being analyzed together, so that usages can be added
+// up once it has been.
+func (r *runner) exportUses(
+	pass *analysis.Pass, usagesByFlag map[FlagID][]reference, excluded map[FlagID][]string,
+) {
+	if !r.aggregating || len(usagesByFlag) == 0 && len(excluded) == 0 {
+		return
+	}
+	uses := map[string]int{}
+	for flag, usages := range usagesByFlag {
+		uses[flag.String()] = len(usages)
+	}
+	mentions := map[string]int{}
+	for flag, files := range excluded {
+		mentions[flag.String()] = len(files)
+	}
+	pass.ExportPackageFact(&flagUsesFact{Uses: uses, Excluded: mentions})
+}
+
+// unusedExport is an exported flag that isn't used in the package declaring
+// it, which is only unused if no other package uses it either.
+type unusedExport struct {
+	pass    *analysis.Pass
+	flag    FlagID
+	obj     types.Object
+	intro   introduction
+	runDate time.Time
+}
+
+// unusedExports collects the exported flags unused in their own package.
+type unusedExports struct {
+	mu   sync.Mutex
+	list []unusedExport
+}
+
+func (u *unusedExports) add(e unusedExport) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.list = append(u.list, e)
+}
+
+// take returns the collected flags and resets the list.
+func (u *unusedExports) take() []unusedExport {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	list := u.list
+	u.list = nil
+	return list
+}
+
+// aggregateUses adds up the usages in every package analyzed. Exported flags
+// that none of them use are reported as unused, each dated flag's usages are
+// counted across all of them, and each finding is told how many of them use
+// its flag.
+func (r *runner) aggregateUses(facts packageFacts) {
+	totals := map[string]int{}
+	packages := map[string]int{}
+	excluded := map[string]int{}
+	for _, fs := range facts {
+		for _, fact := range fs {
+			if f, ok := fact.(*flagUsesFact); ok {
+				for flag, n := range f.Uses {
+					totals[flag] += n
+					packages[flag]++
+				}
+				for flag, n := range f.Excluded {
+					excluded[flag] += n
+				}
+			}
+		}
+	}
+
+	for _, e := range r.exported.take() {
+		if totals[e.flag.String()] == 0 && excluded[e.flag.String()] == 0 {
+			r.reportDead(e.pass, e.flag, e.obj, e.intro, e.runDate)
+		}
+	}
+	r.dated.countUses(totals)
+	r.findings.countPackages(packages)
+}
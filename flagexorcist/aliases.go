@@ -0,0 +1,81 @@
+package flagexorcist
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// parseAliases parses ALIASES, like EnableX=EnableXV2, into the names each
+// flag had before it was renamed, oldest last. Renames can chain, like
+// EnableX=EnableXV2 and EnableXV2=EnableXV3, and names as many renames ago
+// are sorted by name.
+func parseAliases(aliases []string) (map[string][]string, error) {
+	renamedTo := map[string]string{}
+	for _, alias := range aliases {
+		old, renamed, ok := strings.Cut(alias, "=")
+		old, renamed = strings.TrimSpace(old), strings.TrimSpace(renamed)
+		if !ok || old == "" || renamed == "" || old == renamed {
+			return nil, errors.Errorf("invalid alias %q, must be old=new", alias)
+		}
+		if prev, ok := renamedTo[old]; ok && prev != renamed {
+			return nil, errors.Errorf("%s is aliased to both %s and %s", old, prev, renamed)
+		}
+		renamedTo[old] = renamed
+	}
+
+	// How many renames ago each name was a flag's, to sort by
+	renames := map[string]map[string]int{}
+	for old := range renamedTo {
+		seen := map[string]bool{old: true}
+		hops := 1
+		for name := renamedTo[old]; name != ""; name = renamedTo[name] {
+			if seen[name] {
+				return nil, errors.Errorf("aliases of %s rename it in a cycle", old)
+			}
+			seen[name] = true
+			if renames[name] == nil {
+				renames[name] = map[string]int{}
+			}
+			renames[name][old] = hops
+			hops++
+		}
+	}
+
+	formerNames := map[string][]string{}
+	for name, olds := range renames {
+		list := make([]string, 0, len(olds))
+		for old := range olds {
+			list = append(list, old)
+		}
+		sort.Slice(list, func(i, j int) bool {
+			if olds[list[i]] != olds[list[j]] {
+				return olds[list[i]] < olds[list[j]]
+			}
+			return list[i] < list[j]
+		})
+		formerNames[name] = list
+	}
+	return formerNames, nil
+}
+
+// aliasJobs returns jobs dating ref by the names its flag had before it was
+// renamed, in the same file, so that its age survives the rename.
+func (r *runner) aliasJobs(ref reference, filename string) []datingJob {
+	name := ref.search
+	if ref.literal {
+		name = ref.id.Name
+	}
+	jobs := []datingJob{}
+	for _, old := range r.aliases[name] {
+		renamed := ref
+		renamed.search = old
+		if ref.literal {
+			renamed.search = strconv.Quote(old)
+		}
+		jobs = append(jobs, datingJob{ref: renamed, filename: filename})
+	}
+	return jobs
+}
@@ -0,0 +1,68 @@
+package flagexorcist
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/samber/mo"
+	"golang.org/x/tools/go/analysis"
+)
+
+// checkBudget reports the package if it declares more flags than
+// MaxFlagsPerPackage, at its newest flag, since that is the one that went over.
+// Flags whose declaration couldn't be dated count, but are assumed to be the
+// oldest.
+func (r *runner) checkBudget(
+	pass *analysis.Pass,
+	jobs []datingJob,
+	intros []mo.Option[introduction],
+	root string,
+	changed map[string]bool,
+	runDate time.Time,
+) {
+	type declared struct {
+		flag  FlagID
+		intro introduction
+		dated bool
+	}
+	decls := []declared{}
+	seen := map[FlagID]bool{}
+	for i, job := range jobs {
+		if !job.ref.declaration || job.imported || seen[job.ref.id] || r.ignored(job.filename) {
+			continue
+		}
+		seen[job.ref.id] = true
+		intro, ok := intros[i].Get()
+		intro.declaredAt = job.ref.pos
+		decls = append(decls, declared{flag: job.ref.id, intro: intro, dated: ok && !intro.shallow})
+	}
+	if len(decls) <= r.cfg.MaxFlagsPerPackage {
+		return
+	}
+
+	sort.Slice(decls, func(i, j int) bool {
+		a, b := decls[i], decls[j]
+		if a.dated != b.dated {
+			return !a.dated
+		}
+		if !a.intro.at.Equal(b.intro.at) {
+			return a.intro.at.Before(b.intro.at)
+		}
+		return a.intro.declaredAt < b.intro.declaredAt
+	})
+	newest := decls[len(decls)-1]
+	if !r.inChanged(changed, root, pass.Fset.Position(newest.intro.declaredAt).Filename) {
+		return
+	}
+
+	r.report(pass, newest.intro.declaredAt, runDate, r.annotated(pass, Finding{
+		Flag:     newest.flag,
+		Category: CategoryFlagBudget,
+		Message: fmt.Sprintf(
+			"Package '%v' declares %d flags, more than its budget of %d; '%v' is the newest",
+			pass.Pkg.Path(), len(decls), r.cfg.MaxFlagsPerPackage, newest.flag,
+		),
+		IntroducedAt: newest.intro.at,
+	}, newest.intro))
+}
@@ -0,0 +1,97 @@
+package flagexorcist
+
+import (
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/pkg/errors"
+)
+
+// changedFiles returns the paths, relative to root, of the files changed
+// between the merge base of the since ref and HEAD, or nil if every file is to
+// be reported on. They are cached per repo.
+func (r *runner) changedFiles(root string, repo *gitRepo) (map[string]bool, error) {
+	ref := r.cfg.SinceRef
+	if sinceRef != "" {
+		ref = sinceRef
+	}
+	if ref == "" {
+		return nil, nil
+	}
+
+	r.changedMu.Lock()
+	defer r.changedMu.Unlock()
+	if changed, ok := r.changed[root]; ok {
+		return changed, nil
+	}
+	if repo == nil {
+		return nil, errors.Errorf("SINCE_REF needs a git repo, but there is none at %s", root)
+	}
+
+	changed, err := diffSince(repo, ref)
+	if err != nil {
+		return nil, errors.Wrapf(err, "diff against %s", ref)
+	}
+	r.l.Debug().
+		Str("sinceRef", ref).
+		Int("files", len(changed)).
+		Msg("Only reporting on changed files")
+	r.changed[root] = changed
+	return changed, nil
+}
+
+// diffSince returns the paths of the files changed on HEAD since it branched
+// off ref, like `git diff --name-only ref...HEAD`.
+func diffSince(repo *gitRepo, ref string) (map[string]bool, error) {
+	baseHash, err := repo.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		return nil, errors.Wrapf(err, "resolve %s", ref)
+	}
+	headHash, err := repo.headHash()
+	if err != nil {
+		return nil, errors.Wrap(err, "resolve HEAD")
+	}
+	base, err := repo.CommitObject(*baseHash)
+	if err != nil {
+		return nil, err
+	}
+	head, err := repo.CommitObject(headHash)
+	if err != nil {
+		return nil, err
+	}
+
+	bases, err := base.MergeBase(head)
+	if err != nil {
+		return nil, errors.Wrap(err, "find merge base")
+	}
+	if len(bases) == 0 {
+		return nil, errors.Errorf("HEAD has no history in common with %s", ref)
+	}
+
+	from, err := bases[0].Tree()
+	if err != nil {
+		return nil, err
+	}
+	to, err := head.Tree()
+	if err != nil {
+		return nil, err
+	}
+	changes, err := object.DiffTree(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	changed := map[string]bool{}
+	for _, change := range changes {
+		// Deleted files have no usages left to report
+		if change.To.Name != "" {
+			changed[change.To.Name] = true
+		}
+	}
+	return changed, nil
+}
+
+// inChanged reports whether filename is among the changed files, or whether
+// every file is to be reported on.
+func (r *runner) inChanged(changed map[string]bool, root, filename string) bool {
+	return changed == nil || changed[r.relTo(root, filename)]
+}
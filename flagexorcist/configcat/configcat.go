@@ -0,0 +1,143 @@
+// Package configcat is a small client for the parts of the ConfigCat public
+// management API that flag-exorcist integrates with.
+package configcat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const DefaultBaseURL = "https://api.configcat.com"
+
+// DefaultTimeout is how long a request may take before it is given up on, so
+// that an outage of the API doesn't hang the run.
+const DefaultTimeout = 30 * time.Second
+
+type Client struct {
+	// Base URL of the API. Defaults to DefaultBaseURL.
+	BaseURL string
+
+	// Basic auth credentials of the management API
+	Username string
+	Password string
+
+	HTTPClient *http.Client
+}
+
+func NewClient(baseURL, username, password string) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		Username:   username,
+		Password:   password,
+		HTTPClient: &http.Client{Timeout: DefaultTimeout},
+	}
+}
+
+// SettingValue is a setting's targeting in one environment. Values are
+// whatever type the setting is, so they are left as JSON.
+type SettingValue struct {
+	Setting struct {
+		Key string `json:"key"`
+	} `json:"setting"`
+
+	// What is served to anyone no rule or percentage matches
+	Value json.RawMessage `json:"value"`
+
+	RolloutRules           []RolloutRule    `json:"rolloutRules"`
+	RolloutPercentageItems []PercentageItem `json:"rolloutPercentageItems"`
+}
+
+type RolloutRule struct {
+	Value json.RawMessage `json:"value"`
+}
+
+type PercentageItem struct {
+	Percentage int             `json:"percentage"`
+	Value      json.RawMessage `json:"value"`
+}
+
+// GetValue gets the targeting of the setting with key in an environment of a
+// config.
+func (c *Client) GetValue(
+	ctx context.Context, configID, environmentID, key string,
+) (SettingValue, error) {
+	var value SettingValue
+	path := fmt.Sprintf("/v1/configs/%s/environments/%s/settings/%s/value",
+		url.PathEscape(configID), url.PathEscape(environmentID), url.PathEscape(key),
+	)
+	err := c.get(ctx, path, &value)
+	return value, err
+}
+
+// ServedToAll returns the value everyone is served, if they are all served
+// the same one.
+func (v SettingValue) ServedToAll() (json.RawMessage, bool) {
+	values := []json.RawMessage{}
+	for _, rule := range v.RolloutRules {
+		values = append(values, rule.Value)
+	}
+	if len(v.RolloutPercentageItems) == 0 {
+		values = append(values, v.Value)
+	}
+	for _, item := range v.RolloutPercentageItems {
+		if item.Percentage > 0 {
+			values = append(values, item.Value)
+		}
+	}
+
+	if len(values) == 0 {
+		return nil, false
+	}
+	for _, value := range values[1:] {
+		if !equalJSON(value, values[0]) {
+			return nil, false
+		}
+	}
+	return values[0], true
+}
+
+// equalJSON reports whether a and b are the same JSON value.
+func equalJSON(a, b json.RawMessage) bool {
+	var x, y any
+	if json.Unmarshal(a, &x) != nil || json.Unmarshal(b, &y) != nil {
+		return bytes.Equal(a, b)
+	}
+	return reflect.DeepEqual(x, y)
+}
+
+// get sends a GET request and decodes the response into out.
+func (c *Client) get(ctx context.Context, path string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.Username != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("GET %s: %s: %s", path, resp.Status, bytes.TrimSpace(msg))
+	}
+	return errors.Wrap(json.NewDecoder(resp.Body).Decode(out), "decode response")
+}
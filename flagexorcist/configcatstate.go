@@ -0,0 +1,43 @@
+package flagexorcist
+
+import (
+	"context"
+
+	"github.com/dgunay/flag-exorcist/flagexorcist/configcat"
+)
+
+// configCatState looks up settings in an environment of a ConfigCat config.
+type configCatState struct {
+	client      *configcat.Client
+	config      string
+	environment string
+}
+
+func newConfigCatState(cfg Config) configCatState {
+	return configCatState{
+		client: configcat.NewClient(
+			cfg.ConfigCatAPIURL, cfg.ConfigCatUsername, cfg.ConfigCatPassword,
+		),
+		config:      cfg.ConfigCatConfigID,
+		environment: cfg.ConfigCatEnvironmentID,
+	}
+}
+
+func (s configCatState) Name() string {
+	return "configcat"
+}
+
+func (s configCatState) FlagState(ctx context.Context, key string) (FlagState, error) {
+	value, err := s.client.GetValue(ctx, s.config, s.environment, key)
+	if err != nil {
+		return FlagState{}, err
+	}
+
+	// Settings are always on, serving their value to anyone no rule matches
+	state := FlagState{Provider: s.Name(), On: true}
+	if served, ok := value.ServedToAll(); ok {
+		state.RolledOut = true
+		state.Serving = compactJSON(served)
+	}
+	return state, nil
+}
@@ -0,0 +1,73 @@
+package flagexorcist
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// excludedMentions returns the files of the package that build constraints
+// left out of the analysis, like ones for another platform or behind an
+// integration tag, that mention each flag. Usages in them can't be seen, so
+// a flag mentioned in one can't be said to be unused. Symbols are matched by
+// name and keys by their quoted value, since the files aren't type-checked.
+func (r *runner) excludedMentions(pass *analysis.Pass) map[FlagID][]string {
+	mentions := map[FlagID][]string{}
+	if len(pass.IgnoredFiles) == 0 {
+		return mentions
+	}
+	names := map[string]*regexp.Regexp{}
+	for name := range r.flags.symbols {
+		names[name] = regexp.MustCompile(`\b` + regexp.QuoteMeta(name) + `\b`)
+	}
+
+	for _, filename := range pass.IgnoredFiles {
+		if !strings.HasSuffix(filename, ".go") || r.ignored(filename) {
+			continue
+		}
+		contents, err := os.ReadFile(filename)
+		if err != nil {
+			r.l.Warn().Err(err).Str("file", filename).Msg("Failed to read file excluded by build constraints")
+			continue
+		}
+		src := string(contents)
+
+		for name, ids := range r.flags.symbols {
+			if !names[name].MatchString(src) {
+				continue
+			}
+			for _, id := range ids {
+				mentions[id] = append(mentions[id], filename)
+			}
+		}
+		for key, id := range r.flags.keys {
+			if strings.Contains(src, strconv.Quote(key)) {
+				mentions[id] = append(mentions[id], filename)
+			}
+		}
+	}
+
+	flags := make([]FlagID, 0, len(mentions))
+	for flag := range mentions {
+		flags = append(flags, flag)
+	}
+	sort.Slice(flags, func(i, j int) bool { return flags[i].less(flags[j]) })
+	for _, flag := range flags {
+		files := make([]string, 0, len(mentions[flag]))
+		for _, filename := range mentions[flag] {
+			files = append(files, filepath.Base(filename))
+		}
+		r.l.Info().
+			Stringer("flag", flag).
+			Str("package", pass.Pkg.Path()).
+			Strs("files", files).
+			Msg("Flag is mentioned in files excluded by build constraints, which aren't analyzed; " +
+				"pass their tags with BUILD_TAGS or -tags to analyze them")
+	}
+	return mentions
+}
@@ -0,0 +1,37 @@
+package flagexorcist
+
+import (
+	"go/token"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// importedConstJob returns the job dating a key from the declaration of the
+// const it is used through, if ref is such a usage and the const is declared
+// in another package of the checkout at root. Otherwise a key used only
+// through consts from other packages couldn't be dated in the package using
+// it, since none of its literals are there.
+func (r *runner) importedConstJob(
+	pass *analysis.Pass, root string, ref reference,
+) (datingJob, bool) {
+	if !ref.constAt.IsValid() || inPass(pass, ref.constAt) {
+		return datingJob{}, false
+	}
+	filename := pass.Fset.Position(ref.constAt).Filename
+	if filename == "" || r.vendored(filename) || r.topRoot(filename) != root {
+		return datingJob{}, false
+	}
+
+	decl := reference{id: ref.id, pos: ref.constAt, search: ref.search, declaration: true}
+	return datingJob{ref: decl, filename: filename, imported: true}, true
+}
+
+// inPass reports whether pos is in one of the files of the package of pass.
+func inPass(pass *analysis.Pass, pos token.Pos) bool {
+	for _, file := range pass.Files {
+		if file.FileStart <= pos && pos <= file.FileEnd {
+			return true
+		}
+	}
+	return false
+}
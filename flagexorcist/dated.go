@@ -0,0 +1,109 @@
+package flagexorcist
+
+import (
+	"go/token"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DatedFlag is a tracked flag the analyzer dated, whether or not it is stale.
+type DatedFlag struct {
+	Flag FlagID `json:"flag"`
+
+	// When the flag was introduced, and how many whole days old it is on the
+	// run date. Zero if the flag couldn't be dated because the repo is a
+	// shallow clone.
+	IntroducedAt time.Time `json:"introducedAt"`
+	AgeDays      int       `json:"ageDays"`
+
+	// Whether the flag was already present at the oldest commit searched, so
+	// it is at least as old as IntroducedAt
+	BeforeWindow bool `json:"beforeWindow,omitempty"`
+
+	// The flag's cutoff, and whether the flag is older than it
+	Cutoff time.Duration `json:"cutoff"`
+	Stale  bool          `json:"stale"`
+
+	// Whether the history was too shallow to date the flag
+	Undated bool `json:"undated,omitempty"`
+
+	// How many places the flag is used, across all packages
+	Usages int `json:"usages"`
+
+	// Where the flag is declared, or the key literal it was dated from, and
+	// its path relative to the repo root.
+	Declaration     token.Position `json:"declaration"`
+	DeclarationPath string         `json:"declarationPath"`
+
+	// Set if the flag is snoozed in the SNOOZE_FILE on the run date
+	Snoozed *Snooze `json:"snoozed,omitempty"`
+}
+
+// datedFlags collects every flag dated, merged across packages, for callers
+// that run the analyzer in-process.
+type datedFlags struct {
+	mu    sync.Mutex
+	flags map[FlagID]*DatedFlag
+}
+
+// add merges a flag dated in one package into what was found in the others.
+// The earliest introduction wins, and usages add up.
+func (d *datedFlags) add(flag DatedFlag) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.flags == nil {
+		d.flags = map[FlagID]*DatedFlag{}
+	}
+
+	prev, ok := d.flags[flag.Flag]
+	if !ok {
+		d.flags[flag.Flag] = &flag
+		return
+	}
+	usages := prev.Usages + flag.Usages
+	if prev.Undated || !flag.Undated && flag.IntroducedAt.Before(prev.IntroducedAt) {
+		*prev = flag
+	}
+	prev.Usages = usages
+}
+
+// countUses sets the usages of each flag to its total across every package,
+// by the flag's string form, since packages that use a flag without declaring
+// it don't date it.
+func (d *datedFlags) countUses(totals map[string]int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for flag, dated := range d.flags {
+		dated.Usages = totals[flag.String()]
+	}
+}
+
+// take returns the collected flags, sorted, and resets the list.
+func (d *datedFlags) take() []DatedFlag {
+	list := d.list()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.flags = nil
+	return list
+}
+
+// list returns the collected flags, sorted, keeping them.
+func (d *datedFlags) list() []DatedFlag {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	list := make([]DatedFlag, 0, len(d.flags))
+	for _, flag := range d.flags {
+		list = append(list, *flag)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Flag.less(list[j].Flag)
+	})
+	return list
+}
+
+// DatedFlags returns every flag dated since the last call, or since Run
+// started, sorted by flag.
+func DatedFlags() []DatedFlag {
+	return r.dated.take()
+}
@@ -0,0 +1,197 @@
+package flagexorcist
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/samber/mo"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// datingJob is a reference whose introduction needs looking up.
+type datingJob struct {
+	ref      reference
+	filename string
+
+	// The job dates the declaration of a const in another package, which the
+	// flag is used through
+	imported bool
+
+	// The checkout to date the job against, if not the one enclosing
+	// filename, like a dependency module's
+	root string
+}
+
+// dateAll looks up when each job's reference was added, running up to
+// GitConcurrency lookups at once. top is the repo of the checkout at root, or
+// nil if it isn't a git repo. Jobs whose history can't be read are left
+// undated, and returned as failures if OnGitError is report, unless it is
+// fail.
+func (r *runner) dateAll(
+	ctx context.Context, root string, top *gitRepo, jobs []datingJob,
+) ([]mo.Option[introduction], []gitFailure, error) {
+	var head string
+	if r.cfg.GitHubHistory && top != nil {
+		hash, err := top.headHash()
+		if err != nil {
+			return nil, nil, err
+		}
+		head = hash.String()
+	}
+
+	workers := r.cfg.GitConcurrency
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(jobs) {
+		workers = len(jobs)
+	}
+
+	// Each result is only written by the worker that took the job, so no
+	// locking is needed.
+	intros := make([]mo.Option[introduction], len(jobs))
+	errs := make([]error, len(jobs))
+	work := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < workers; w++ {
+		// go-git repos can't be shared between goroutines, so every worker
+		// but the first opens its own.
+		repo := top
+		if w > 0 && top != nil {
+			var err error
+			if repo, err = r.openRepo(root); err != nil {
+				close(work)
+				wg.Wait()
+				return nil, nil, err
+			}
+		}
+		historyFor := r.historyCache(root, repo)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				intros[i], errs[i] = r.date(ctx, historyFor, head, jobs[i])
+			}
+		}()
+	}
+	for i := range jobs {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	failures := []gitFailure{}
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		switch r.cfg.OnGitError {
+		case GitErrorSkip, GitErrorReport:
+			r.l.Warn().
+				Err(err).
+				Stringer("flag", jobs[i].ref.id).
+				Str("file", jobs[i].filename).
+				Msg("Failed to read the history of flag, leaving it undated")
+			if r.cfg.OnGitError == GitErrorReport {
+				failures = append(failures, gitFailure{job: jobs[i], err: err})
+			}
+		default:
+			return nil, nil, err
+		}
+	}
+	return intros, failures, nil
+}
+
+// date looks up when a job's reference was added. head is the commit to ask
+// GitHub about when the local history is cut short, if GitHubHistory is set.
+func (r *runner) date(
+	ctx context.Context,
+	historyFor func(root string) (HistoryProvider, error),
+	head string,
+	job datingJob,
+) (_ mo.Option[introduction], err error) {
+	top, root := r.topRoot(job.filename), r.repoRoot(job.filename)
+	if job.root != "" {
+		top, root = "", job.root
+	}
+	file := r.relTo(root, job.filename)
+	_, span := tracer.Start(ctx, "date flag", trace.WithAttributes(
+		attribute.Stringer("flag", job.ref.id),
+		attribute.String("search", job.ref.search),
+		attribute.String("file", file),
+		attribute.String("root", root),
+	))
+	defer func() { endSpan(span, err) }()
+
+	history, err := historyFor(root)
+	if err != nil {
+		return mo.None[introduction](), err
+	}
+
+	key, cacheable := cacheKey(history, root, file, job.ref.search)
+	found, cached := mo.None[introduction](), false
+	if cacheable {
+		found, cached = r.intros.get(key)
+	}
+	span.SetAttributes(attribute.Bool("cached", cached))
+	if !cached {
+		found, err = r.lookUp(history, file, job.ref.search)
+		if err != nil {
+			return mo.None[introduction](), errors.Wrapf(err, "date %v in %s", job.ref.id, file)
+		}
+		intro, ok := found.Get()
+		if ok && intro.shallow && head != "" && root == top &&
+			r.cfg.AgeMetric != AgeMetricLastModified {
+			// The commit that added it is on GitHub, if not here
+			if remote, ok := r.githubHistory(head).introduction(file, job.ref.search).Get(); ok {
+				found = mo.Some(remote)
+			}
+		}
+		if cacheable {
+			r.intros.put(key, found)
+		}
+	}
+
+	intro, ok := found.Get()
+	span.SetAttributes(attribute.Bool("found", ok))
+	if !ok {
+		return mo.None[introduction](), nil
+	}
+	span.SetAttributes(attribute.String("introduced_at", intro.at.Format(time.RFC3339)))
+	intro.declaredAt = job.ref.pos
+	return mo.Some(intro), nil
+}
+
+// historyCache returns the provider for each checkout root, opening it when
+// first needed. top is the repo of the checkout at topRoot, or nil if it isn't
+// a git repo.
+func (r *runner) historyCache(
+	topRoot string, top *gitRepo,
+) func(root string) (HistoryProvider, error) {
+	histories := map[string]HistoryProvider{}
+	return func(root string) (HistoryProvider, error) {
+		if history, ok := histories[root]; ok {
+			return history, nil
+		}
+		if top == nil && root == topRoot && isBuiltinBackend(r.cfg.GitBackend) {
+			histories[root] = r.nonGitHistory(root)
+			return histories[root], nil
+		}
+		history, err := r.historyFor(root, func() (*gitRepo, error) {
+			if root == topRoot {
+				return top, nil
+			}
+			return r.openRepo(root)
+		})
+		if err != nil {
+			return nil, err
+		}
+		histories[root] = history
+		return history, nil
+	}
+}
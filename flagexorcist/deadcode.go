@@ -0,0 +1,61 @@
+package flagexorcist
+
+import (
+	"fmt"
+	"go/constant"
+	"strconv"
+	"time"
+
+	"github.com/dgunay/flag-exorcist/flagexorcist/rewrite"
+	"golang.org/x/tools/go/analysis"
+)
+
+// resolvedValue returns the value a flag declared in the package always has:
+// the value it is hardcoded to, or else the value its flag system serves to
+// everyone, if it is a boolean.
+func resolvedValue(
+	pass *analysis.Pass, intro introduction, state FlagState, remote bool,
+) (constant.Value, bool) {
+	if value, ok := fixedValue(pass, definedAt(pass.TypesInfo, intro.declaredAt)); ok {
+		return value, true
+	}
+	if remote && state.RolledOut {
+		if b, err := strconv.ParseBool(state.Serving); err == nil {
+			return constant.MakeBool(b), true
+		}
+	}
+	return nil, false
+}
+
+// reportDeadBranches reports each piece of code in the package that can never
+// run because a flag past its cutoff always has value, like the else branch
+// of a condition on a flag hardcoded to true, as dead code covering the
+// whole of it. Only symbols declared in the package can be looked for.
+func (r *runner) reportDeadBranches(
+	pass *analysis.Pass, flag FlagID, intro introduction, value constant.Value,
+	runDate time.Time, changed map[string]bool, root string,
+) {
+	obj := definedAt(pass.TypesInfo, intro.declaredAt)
+	if obj == nil {
+		return
+	}
+	for _, file := range pass.Files {
+		filename := pass.Fset.Position(file.Pos()).Filename
+		if r.ignored(filename) || r.vendored(filename) || !r.inChanged(changed, root, filename) {
+			continue
+		}
+		for _, b := range rewrite.DeadBranches(file, pass.TypesInfo, obj, value) {
+			r.report(pass, b.Pos, runDate, r.annotated(pass, Finding{
+				Flag:     flag,
+				Category: CategoryDeadCode,
+				Message: fmt.Sprintf(
+					"Flag '%v', %v, is always %v, so this %s can never run and can be removed",
+					flag, r.describeAge(intro, runDate), value.ExactString(), b.Reason,
+				),
+				IntroducedAt: intro.at,
+				AgeDays:      ageDays(intro.at, runDate),
+				end:          b.End,
+			}, intro))
+		}
+	}
+}
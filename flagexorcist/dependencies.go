@@ -0,0 +1,108 @@
+package flagexorcist
+
+import (
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/tools/go/analysis"
+)
+
+// dependencyRepo is the checkout of a module that flags used here are
+// declared in.
+type dependencyRepo struct {
+	module string
+	dir    string
+}
+
+// parseDependencyRepos parses DEPENDENCY_REPOS, like
+// example.com/flags=../flags, resolving the checkouts to absolute paths. They
+// are sorted longest module first, so nested modules win over the modules
+// containing them.
+func parseDependencyRepos(repos []string) ([]dependencyRepo, error) {
+	parsed := []dependencyRepo{}
+	for _, repo := range repos {
+		module, dir, ok := strings.Cut(repo, "=")
+		module, dir = strings.TrimSuffix(strings.TrimSpace(module), "/"), strings.TrimSpace(dir)
+		if !ok || module == "" || dir == "" {
+			return nil, errors.Errorf("invalid dependency repo %q, must be module=path", repo)
+		}
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, errors.Wrapf(err, "resolve checkout of %s", module)
+		}
+		parsed = append(parsed, dependencyRepo{module: module, dir: abs})
+	}
+	sort.SliceStable(parsed, func(i, j int) bool {
+		return len(parsed[i].module) > len(parsed[j].module)
+	})
+	return parsed, nil
+}
+
+// dependencyDir returns the directory of the package at pkgPath in the
+// checkout of the dependency module it is in, if that module is in
+// DEPENDENCY_REPOS, along with the root of the repo it is checked out in,
+// which the module may be in a subdirectory of.
+func (r *runner) dependencyDir(pkgPath string) (dir, root string, ok bool) {
+	for _, dep := range r.dependencies {
+		if pkgPath != dep.module && !strings.HasPrefix(pkgPath, dep.module+"/") {
+			continue
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(pkgPath, dep.module), "/")
+		root := findCheckout(dep.dir)
+		if root == "" {
+			root = dep.dir
+		}
+		return filepath.Join(dep.dir, filepath.FromSlash(rel)), root, true
+	}
+	return "", "", false
+}
+
+// dependencyJob returns the job dating a symbol from its declaration in the
+// checkout of the dependency module declaring it, if ref is a usage of one
+// and the module is in DEPENDENCY_REPOS. Otherwise such flags are never
+// reported, since only the package declaring a symbol dates it, and the
+// dependency's packages are only analyzed for their facts.
+func (r *runner) dependencyJob(
+	pass *analysis.Pass, root string, ref reference,
+) (datingJob, bool) {
+	if len(r.dependencies) == 0 || ref.declaration || ref.literal ||
+		ref.id.Kind != FlagKindSymbol {
+		return datingJob{}, false
+	}
+	obj := usedAt(pass.TypesInfo, ref.pos)
+	if obj == nil || obj.Pkg() == nil || obj.Pkg() == pass.Pkg {
+		return datingJob{}, false
+	}
+	declared := pass.Fset.Position(obj.Pos()).Filename
+	if _, ok := r.within(root, declared); ok || declared == "" {
+		// Declared in this checkout after all, like through a replace
+		// directive, so dated where it is declared
+		return datingJob{}, false
+	}
+	dir, checkout, ok := r.dependencyDir(obj.Pkg().Path())
+	if !ok {
+		return datingJob{}, false
+	}
+
+	decl := reference{id: ref.id, pos: obj.Pos(), search: obj.Name(), declaration: true}
+	return datingJob{
+		ref:      decl,
+		filename: filepath.Join(dir, filepath.Base(declared)),
+		imported: true,
+		root:     checkout,
+	}, true
+}
+
+// usedAt returns the object used by the identifier at pos, if any.
+func usedAt(info *types.Info, pos token.Pos) types.Object {
+	for id, obj := range info.Uses {
+		if id.Pos() == pos {
+			return obj
+		}
+	}
+	return nil
+}
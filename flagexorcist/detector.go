@@ -0,0 +1,216 @@
+package flagexorcist
+
+import (
+	"go/token"
+	"go/types"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/packages"
+)
+
+// Detector finds the flags of a flag system in a package, so that flag
+// systems the analyzer doesn't know about can be tracked without changing
+// how it matches flags. Detectors are chosen with DETECTORS.
+type Detector interface {
+	// Detect returns every declaration and usage of a flag in the package of
+	// pass. Outside the analyzer, like when listing flags, pass only has its
+	// Fset, Files, Pkg, TypesInfo and the result of the inspect analyzer.
+	Detect(pass *analysis.Pass) []Detection
+}
+
+// Detection is a declaration or usage of a flag that a Detector found.
+type Detection struct {
+	Flag FlagID
+	Pos  token.Pos
+
+	// What to search the history of the file for to date the flag, usually
+	// the text at Pos
+	Search string
+
+	// It declares the flag, which dates it.
+	Declaration bool
+
+	// It is a string literal of a key. Keys usually have no declaration, so
+	// they are dated by the first commit any of their literals appear in.
+	Literal bool
+}
+
+// DetectorFactory makes a detector for the config.
+type DetectorFactory func(cfg Config) (Detector, error)
+
+var (
+	detectorsMu sync.Mutex
+	detectors   = map[string]DetectorFactory{}
+)
+
+// RegisterDetector makes a detector available to DETECTORS under name, which
+// is also the source of the usages it finds. It must be called before the
+// config is read, usually from an init function.
+func RegisterDetector(name string, factory DetectorFactory) {
+	detectorsMu.Lock()
+	defer detectorsMu.Unlock()
+	detectors[name] = factory
+}
+
+func detectorFactory(name string) (DetectorFactory, bool) {
+	detectorsMu.Lock()
+	defer detectorsMu.Unlock()
+	factory, ok := detectors[name]
+	return factory, ok
+}
+
+// The detectors run when DETECTORS is empty
+var builtinDetectors = []string{string(SourceSymbol), string(SourceKeyLiteral)}
+
+func init() {
+	RegisterDetector(string(SourceSymbol), func(Config) (Detector, error) {
+		return symbolDetector{}, nil
+	})
+	RegisterDetector(string(SourceKeyLiteral), func(Config) (Detector, error) {
+		return keyLiteralDetector{}, nil
+	})
+}
+
+// hasRegisteredDetector reports whether names has a detector other than the
+// built-in ones, which may find flags without any being configured.
+func hasRegisteredDetector(names []string) bool {
+	for _, name := range names {
+		if name != string(SourceSymbol) && name != string(SourceKeyLiteral) {
+			return true
+		}
+	}
+	return false
+}
+
+// namedDetector is a detector DETECTORS chose, and the name it was
+// registered under.
+type namedDetector struct {
+	Detector
+	name UsageSource
+}
+
+// newDetectors makes the detectors named by DETECTORS, or the built-in ones
+// if it is empty.
+func newDetectors(cfg Config) ([]namedDetector, error) {
+	names := cfg.Detectors
+	if len(names) == 0 {
+		names = builtinDetectors
+	}
+	list := []namedDetector{}
+	for _, name := range names {
+		factory, ok := detectorFactory(name)
+		if !ok {
+			return nil, errors.Errorf(
+				"no detector named %q, must be symbol, key-literal, or a registered detector", name,
+			)
+		}
+		d, err := factory(cfg)
+		if err != nil {
+			return nil, errors.Wrapf(err, "make detector %s", name)
+		}
+		list = append(list, namedDetector{Detector: d, name: UsageSource(name)})
+	}
+	return list, nil
+}
+
+// builtinDetector is implemented by the built-in detectors, which find
+// references in more detail than a Detection has, given the flags tracked in
+// the package.
+type builtinDetector interface {
+	references(ids flagIDs, in *inspector.Inspector, info *types.Info) []reference
+}
+
+// symbolDetector finds flags by identifier: symbols, consts and fields
+// holding keys, and consts passed to config lookup functions.
+type symbolDetector struct{}
+
+func (d symbolDetector) Detect(pass *analysis.Pass) []Detection {
+	in := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	return detections(d.references(r.passIDs(pass), in, pass.TypesInfo))
+}
+
+func (symbolDetector) references(
+	ids flagIDs, in *inspector.Inspector, info *types.Info,
+) []reference {
+	return ids.symbolReferences(in, info)
+}
+
+// keyLiteralDetector finds flags by the string literals of their keys.
+type keyLiteralDetector struct{}
+
+func (d keyLiteralDetector) Detect(pass *analysis.Pass) []Detection {
+	in := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	return detections(d.references(r.passIDs(pass), in, pass.TypesInfo))
+}
+
+func (keyLiteralDetector) references(
+	ids flagIDs, in *inspector.Inspector, info *types.Info,
+) []reference {
+	return ids.keyLiteralReferences(in, info)
+}
+
+// detections converts references to what a Detector returns.
+func detections(refs []reference) []Detection {
+	list := make([]Detection, 0, len(refs))
+	for _, ref := range refs {
+		list = append(list, Detection{
+			Flag: ref.id, Pos: ref.pos, Search: ref.search,
+			Declaration: ref.declaration, Literal: ref.literal,
+		})
+	}
+	return list
+}
+
+// references runs every detector over the package of pass, with ids being
+// the flags tracked in it. What several detectors found in the same place is
+// only returned once, and everything is in source order.
+func (r *runner) references(pass *analysis.Pass, ids flagIDs) []reference {
+	in := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	type place struct {
+		id  FlagID
+		pos token.Pos
+	}
+	seen := map[place]bool{}
+	refs := []reference{}
+	for _, d := range r.detectors {
+		var found []reference
+		if b, ok := d.Detector.(builtinDetector); ok {
+			found = b.references(ids, in, pass.TypesInfo)
+		} else {
+			for _, det := range d.Detect(pass) {
+				found = append(found, reference{
+					id: det.Flag, pos: det.Pos, search: det.Search,
+					declaration: det.Declaration, literal: det.Literal, detector: d.name,
+				})
+			}
+		}
+		for _, ref := range found {
+			if !seen[place{ref.id, ref.pos}] {
+				seen[place{ref.id, ref.pos}] = true
+				refs = append(refs, ref)
+			}
+		}
+	}
+	sort.SliceStable(refs, func(i, j int) bool { return refs[i].pos < refs[j].pos })
+	return refs
+}
+
+// packagePass is what detectors are given for a package loaded outside the
+// analyzer.
+func packagePass(pkg *packages.Package) *analysis.Pass {
+	return &analysis.Pass{
+		Analyzer:  Analyzer,
+		Fset:      pkg.Fset,
+		Files:     pkg.Syntax,
+		Pkg:       pkg.Types,
+		TypesInfo: pkg.TypesInfo,
+		ResultOf: map[*analysis.Analyzer]any{
+			inspect.Analyzer: inspector.New(pkg.Syntax),
+		},
+	}
+}
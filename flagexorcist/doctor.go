@@ -0,0 +1,231 @@
+package flagexorcist
+
+import (
+	"fmt"
+	"go/constant"
+	"go/types"
+	"path/filepath"
+	"sort"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/pkg/errors"
+	"golang.org/x/tools/go/packages"
+)
+
+// Check is the outcome of checking one part of the setup.
+type Check struct {
+	Name string
+	OK   bool
+
+	// What was found, or what is wrong if the check failed
+	Detail string
+
+	// How to fix it, if the check failed
+	Fix string
+}
+
+// Doctor checks that flags in pkgs can be found and dated with the current
+// config: that the repo can be opened, that its history is complete, that
+// every configured symbol is declared somewhere, and that every configured key
+// is used somewhere. Initialize must be called first.
+func Doctor(pkgs []*packages.Package) []Check {
+	root := r.cfg.WorkTree
+	if r.detectRoot {
+		root = ""
+		if filename := firstFile(pkgs); filename != "" {
+			root = r.topRoot(filename)
+		}
+	}
+
+	checks := []Check{r.checkRepo(root)}
+	if checks[0].OK {
+		checks = append(checks, r.checkHistory(root))
+	}
+	checks = append(checks, r.checkSymbols(pkgs)...)
+	return append(checks, r.checkKeys(pkgs)...)
+}
+
+// Tracked returns the symbols and keys the analyzer was configured with,
+// including the ones from the flag registry, sorted. Initialize must be
+// called first.
+func Tracked() (symbols, keys []FlagID) {
+	symbols, keys = []FlagID{}, []FlagID{}
+	seen := map[FlagID]bool{}
+	for _, ids := range r.flags.symbols {
+		for _, id := range ids {
+			if !seen[id] {
+				seen[id] = true
+				symbols = append(symbols, id)
+			}
+		}
+	}
+	for _, id := range r.flags.keys {
+		keys = append(keys, id)
+	}
+	sort.Slice(symbols, func(i, j int) bool { return symbols[i].less(symbols[j]) })
+	sort.Slice(keys, func(i, j int) bool { return keys[i].less(keys[j]) })
+	return symbols, keys
+}
+
+// checkRepo checks that there is a checkout to date flags with.
+func (r *runner) checkRepo(root string) Check {
+	check := Check{Name: "repo"}
+	if !isBuiltinBackend(r.cfg.GitBackend) {
+		check.OK = true
+		check.Detail = fmt.Sprintf("history comes from the %q provider", r.cfg.GitBackend)
+		return check
+	}
+	if root == "" {
+		check.Detail = "no checkout was found above any of the packages"
+		check.Fix = "run from inside a checkout, or set REPO_PATH to it"
+		return check
+	}
+	if isHgRepo(root) {
+		check.OK = true
+		check.Detail = fmt.Sprintf("mercurial checkout at %s", root)
+		return check
+	}
+
+	repo, err := r.open(root)
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		check.Detail = fmt.Sprintf("no git repo at %s, so flags can only be "+
+			"dated by file modification time", root)
+		check.Fix = "set REPO_PATH to the checkout, or GIT_DIR and GIT_WORK_TREE " +
+			"for bare repos and build sandboxes"
+		return check
+	}
+	if err != nil {
+		check.Detail = fmt.Sprintf("can't open the git repo at %s: %v", root, err)
+		check.Fix = "check that the repo isn't corrupt with `git fsck`"
+		return check
+	}
+	if _, err := repo.Head(); err != nil {
+		check.Detail = fmt.Sprintf("the git repo at %s has no HEAD: %v", root, err)
+		check.Fix = "commit something, or check out a branch"
+		return check
+	}
+
+	check.OK = true
+	check.Detail = fmt.Sprintf("git repo at %s", root)
+	return check
+}
+
+// checkHistory checks that the history is deep enough to date every flag.
+func (r *runner) checkHistory(root string) Check {
+	check := Check{Name: "history", OK: true}
+	if !isBuiltinBackend(r.cfg.GitBackend) || isHgRepo(root) {
+		return check
+	}
+	repo, err := r.open(root)
+	if err != nil {
+		return check
+	}
+	shallow, err := repo.Storer.Shallow()
+	if err != nil {
+		check.OK = false
+		check.Detail = fmt.Sprintf("can't read the shallow commits: %v", err)
+		return check
+	}
+	if len(shallow) == 0 {
+		return check
+	}
+
+	check.Detail = fmt.Sprintf(
+		"the repo is a shallow clone with %d boundary commits", len(shallow),
+	)
+	if r.cfg.OnShallow == ShallowDeepen {
+		check.Detail += "; it will be deepened when analyzed"
+		return check
+	}
+	check.OK = false
+	check.Detail += ", so flags older than the clone can't be dated"
+	check.Fix = "fetch the full history with `git fetch --unshallow` " +
+		"(fetch-depth: 0 with actions/checkout), or set ON_SHALLOW=deepen"
+	return check
+}
+
+// checkSymbols checks that every configured symbol is declared in pkgs.
+func (r *runner) checkSymbols(pkgs []*packages.Package) []Check {
+	declared := map[FlagID]bool{}
+	names := map[string]bool{}
+	for _, pkg := range pkgs {
+		for ident, obj := range pkg.TypesInfo.Defs {
+			if obj == nil {
+				continue
+			}
+			names[ident.Name] = true
+			for _, id := range r.flags.symbols[ident.Name] {
+				if declares(obj, id) {
+					declared[id] = true
+				}
+			}
+		}
+	}
+
+	checks := []Check{}
+	symbols, _ := Tracked()
+	for _, id := range symbols {
+		symbol := id.String()
+		check := Check{Name: "symbol " + symbol, OK: declared[id]}
+		if !check.OK {
+			check.Detail = "not declared in any of the packages analyzed"
+			check.Fix = "check the spelling in FLAG_SYMBOLS or the flag registry, " +
+				"and that the package declaring it is analyzed"
+			if name, ok := closest(id.Name, names); ok {
+				id.Name = name
+				check.Fix = fmt.Sprintf("did you mean %s? ", id) + check.Fix
+			}
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// checkKeys checks that every configured key is used in pkgs, as a string
+// literal or a const holding one.
+func (r *runner) checkKeys(pkgs []*packages.Package) []Check {
+	used := map[string]bool{}
+	for _, pkg := range pkgs {
+		for _, tv := range pkg.TypesInfo.Types {
+			if tv.Value != nil && tv.Value.Kind() == constant.String {
+				used[constant.StringVal(tv.Value)] = true
+			}
+		}
+	}
+
+	checks := []Check{}
+	_, keys := Tracked()
+	for _, id := range keys {
+		check := Check{Name: "key " + id.Name, OK: used[id.Name]}
+		if !check.OK {
+			check.Detail = "not used in any of the packages analyzed"
+			check.Fix = "check the spelling in FLAG_KEYS or the flag registry, " +
+				"and that a package using it is analyzed"
+			if name, ok := closest(id.Name, used); ok {
+				check.Fix = fmt.Sprintf("did you mean %q? ", name) + check.Fix
+			}
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// declares reports whether obj is the declaration of the symbol id.
+func declares(obj types.Object, id FlagID) bool {
+	if id.Namespace == "" {
+		return true
+	}
+	return obj.Pkg() != nil && obj.Pkg().Path() == id.Namespace
+}
+
+// firstFile returns the absolute path of the first Go file in pkgs, if any.
+func firstFile(pkgs []*packages.Package) string {
+	for _, pkg := range pkgs {
+		for _, filename := range pkg.GoFiles {
+			if abs, err := filepath.Abs(filename); err == nil {
+				return abs
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,211 @@
+package flagexorcist
+
+import (
+	"context"
+	"go/types"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/packages"
+)
+
+// Run loads the packages matching patterns and runs the analyzer over them
+// in-process, returning everything it found. Unlike the vet-style checker,
+// this gives callers structured findings to do their own reporting with.
+// Configured symbols that never matched anything are warned about once every
+// package has been analyzed. What is found in the history is imported from
+// and exported to the files named by the --cache-import and --cache-export
+// flags, if set, and a summary of the run is written to the one named by
+// --summary-out. With REPORT_TOP, only the findings about the oldest stale
+// flags are returned, and UnreportedFindings returns the rest. Initialize
+// must be called first.
+func Run(patterns ...string) ([]Finding, error) {
+	return RunContext(context.Background(), patterns...)
+}
+
+// RunContext is Run, with the analysis traced as part of the span in ctx.
+func RunContext(ctx context.Context, patterns ...string) ([]Finding, error) {
+	return RunDir(ctx, "", patterns...)
+}
+
+// RunDir is RunContext, with packages loaded as if from dir, so that patterns
+// are relative to it and resolved in its module. An empty dir is the current
+// directory.
+func RunDir(ctx context.Context, dir string, patterns ...string) (_ []Finding, err error) {
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+
+	start := time.Now()
+	_, span := tracer.Start(ctx, "load packages", trace.WithAttributes(attribute.String("dir", dir)))
+	cfg := &packages.Config{Mode: packages.LoadAllSyntax, Dir: dir}
+	if len(r.cfg.BuildTags) > 0 {
+		cfg.BuildFlags = []string{"-tags=" + strings.Join(r.cfg.BuildTags, ",")}
+	}
+	pkgs, err := packages.Load(cfg, patterns...)
+	if err == nil && packages.PrintErrors(pkgs) > 0 {
+		err = errors.New("failed to load packages")
+	}
+	endSpan(span, errors.Wrap(err, "load packages"))
+	if err != nil {
+		return nil, errors.Wrap(err, "load packages")
+	}
+
+	if cacheImport != "" {
+		if err := ImportCache(cacheImport); err != nil {
+			return nil, err
+		}
+	}
+
+	r.traceCtx = ctx
+	r.aggregating = true
+	defer func() { r.traceCtx, r.aggregating = nil, false }()
+	r.findings.take()
+	r.dated.take()
+	r.unreported.take()
+	r.exported.take()
+
+	// Dependencies are visited first, so that their facts are there for the
+	// packages importing them. They are only analyzed for their facts.
+	roots := map[*packages.Package]bool{}
+	for _, pkg := range pkgs {
+		roots[pkg] = true
+	}
+	facts := packageFacts{}
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		switch {
+		case err != nil:
+		case roots[pkg]:
+			_, err = runAnalyzer(Analyzer, pkg, map[*analysis.Analyzer]any{}, facts)
+		case len(r.flags.maps) > 0:
+			_, err = runAnalyzer(factsAnalyzer, pkg, map[*analysis.Analyzer]any{}, facts)
+		}
+		if err != nil {
+			err = errors.Wrapf(err, "analyze %s", pkg.PkgPath)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	r.aggregateUses(facts)
+	r.warnUnfound()
+	if cacheExport != "" {
+		if err := ExportCache(cacheExport); err != nil {
+			return nil, err
+		}
+	}
+	findings, unreported := r.findings.take(), []Finding(nil)
+	if r.cfg.ReportTop > 0 {
+		findings, unreported = r.topFindings(findings, r.cfg.ReportTop)
+		for _, f := range unreported {
+			r.unreported.add(f)
+		}
+	}
+	if summaryOut != "" {
+		if err := r.writeRunSummary(findings, unreported, time.Since(start)); err != nil {
+			return nil, err
+		}
+	}
+	return findings, nil
+}
+
+// factsAnalyzer only exports the facts of a package, for packages that are
+// only analyzed because the ones being analyzed import them.
+var factsAnalyzer = &analysis.Analyzer{
+	Name: "flagexorcistfacts",
+	Doc:  "Exports the facts of a package",
+	Run: func(pass *analysis.Pass) (any, error) {
+		r.passIDs(pass)
+		return nil, nil
+	},
+	Requires:  Analyzer.Requires,
+	FactTypes: Analyzer.FactTypes,
+}
+
+// packageFacts are the facts exported by each package analyzed.
+type packageFacts map[*types.Package][]analysis.Fact
+
+// importFact copies the fact about pkg of the same type as fact into it.
+func (facts packageFacts) importFact(pkg *types.Package, fact analysis.Fact) bool {
+	for _, f := range facts[pkg] {
+		if reflect.TypeOf(f) == reflect.TypeOf(fact) {
+			reflect.ValueOf(fact).Elem().Set(reflect.ValueOf(f).Elem())
+			return true
+		}
+	}
+	return false
+}
+
+// all returns the facts about pkg and every package it imports, directly or
+// not.
+func (facts packageFacts) all(pkg *packages.Package) []analysis.PackageFact {
+	all := []analysis.PackageFact{}
+	packages.Visit([]*packages.Package{pkg}, nil, func(p *packages.Package) {
+		for _, f := range facts[p.Types] {
+			all = append(all, analysis.PackageFact{Package: p.Types, Fact: f})
+		}
+	})
+	return all
+}
+
+// runAnalyzer runs a on pkg after running everything it requires. Results are
+// memoized in results, and facts are kept in facts.
+func runAnalyzer(
+	a *analysis.Analyzer,
+	pkg *packages.Package,
+	results map[*analysis.Analyzer]any,
+	facts packageFacts,
+) (any, error) {
+	if result, ok := results[a]; ok {
+		return result, nil
+	}
+
+	resultOf := map[*analysis.Analyzer]any{}
+	for _, req := range a.Requires {
+		result, err := runAnalyzer(req, pkg, results, facts)
+		if err != nil {
+			return nil, err
+		}
+		resultOf[req] = result
+	}
+
+	pass := &analysis.Pass{
+		Analyzer:          a,
+		Fset:              pkg.Fset,
+		Files:             pkg.Syntax,
+		OtherFiles:        pkg.OtherFiles,
+		IgnoredFiles:      pkg.IgnoredFiles,
+		Pkg:               pkg.Types,
+		TypesInfo:         pkg.TypesInfo,
+		TypesSizes:        pkg.TypesSizes,
+		ResultOf:          resultOf,
+		Report:            func(analysis.Diagnostic) {},
+		ImportObjectFact:  func(types.Object, analysis.Fact) bool { return false },
+		ExportObjectFact:  func(types.Object, analysis.Fact) {},
+		ImportPackageFact: facts.importFact,
+		ExportPackageFact: func(fact analysis.Fact) {
+			facts[pkg.Types] = append(facts[pkg.Types], fact)
+		},
+		AllObjectFacts:  func() []analysis.ObjectFact { return nil },
+		AllPackageFacts: func() []analysis.PackageFact { return facts.all(pkg) },
+	}
+
+	result, err := a.Run(pass)
+	if err != nil {
+		return nil, err
+	}
+	if a.ResultType != nil && result != nil && reflect.TypeOf(result) != a.ResultType {
+		return nil, errors.Errorf(
+			"%s returned a %T, want %v", a.Name, result, a.ResultType,
+		)
+	}
+
+	results[a] = result
+	return result, nil
+}
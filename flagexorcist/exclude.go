@@ -0,0 +1,70 @@
+package flagexorcist
+
+import (
+	"path"
+	"strings"
+)
+
+// ignored reports whether nothing found in filename should be reported or
+// counted, because it is excluded or is an ignored test.
+func (r *runner) ignored(filename string) bool {
+	if r.cfg.IgnoreTests && isTestFile(filename) {
+		return true
+	}
+	return r.excluded(filename)
+}
+
+func isTestFile(filename string) bool {
+	return strings.HasSuffix(filename, "_test.go")
+}
+
+// excluded reports whether filename is matched by one of ExcludePaths, so
+// that nothing found in it is reported or counted.
+func (r *runner) excluded(filename string) bool {
+	if len(r.cfg.ExcludePaths) == 0 {
+		return false
+	}
+	rel := r.relPath(filename)
+	if r.cfg.FoldPathCase {
+		rel = strings.ToLower(rel)
+	}
+	for _, glob := range r.cfg.ExcludePaths {
+		if r.cfg.FoldPathCase {
+			glob = strings.ToLower(glob)
+		}
+		if matchGlob(strings.Split(glob, "/"), strings.Split(rel, "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob matches a slash-separated path against a glob, both split into
+// segments. A ** segment matches any number of segments, including none, and
+// other segments are matched with path.Match.
+func matchGlob(glob, segments []string) bool {
+	for len(glob) > 0 {
+		if glob[0] == "**" {
+			for i := len(segments); i >= 0; i-- {
+				if matchGlob(glob[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, err := path.Match(glob[0], segments[0]); err != nil || !ok {
+			return false
+		}
+		glob, segments = glob[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
+
+// vendored reports whether filename is in a vendor directory.
+func (r *runner) vendored(filename string) bool {
+	rel := r.relPath(filename)
+	return strings.HasPrefix(rel, "vendor/") || strings.Contains(rel, "/vendor/")
+}
@@ -0,0 +1,72 @@
+package flagexorcist
+
+import (
+	"fmt"
+	"go/token"
+	"regexp"
+	"time"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// expiryAnnotation is a comment on a flag's declaration saying when it is due
+// to be removed, like
+//
+//	// flagexorcist:expires 2023-04-01
+var expiryAnnotation = regexp.MustCompile(`flagexorcist:expires\s+(\S+)`)
+
+// expiry is when a flag is due to be removed, and what says so.
+type expiry struct {
+	at     time.Time
+	source string
+}
+
+// expiryOf returns when flag is due to be removed: the date annotated on its
+// declaration at pos, or else its expiry in the flag registry. It is zero if
+// neither says.
+func (r *runner) expiryOf(pass *analysis.Pass, flag FlagID, pos token.Pos) expiry {
+	for _, group := range declarationComments(pass, pos) {
+		match := expiryAnnotation.FindStringSubmatch(group.Text())
+		if match == nil {
+			continue
+		}
+		at, err := time.Parse("2006-01-02", match[1])
+		if err != nil {
+			r.l.Warn().
+				Str("pos", pass.Fset.Position(pos).String()).
+				Msgf("Ignoring invalid expiry %q of flag %v, must be YYYY-MM-DD", match[1], flag)
+			continue
+		}
+		return expiry{at: at, source: "its annotation"}
+	}
+	if at := r.registry[flag].expiry; !at.IsZero() {
+		return expiry{at: at, source: "the flag registry"}
+	}
+	return expiry{}
+}
+
+// expired reports whether the flag was due to be removed before runDate.
+func (r *runner) expired(e expiry, runDate time.Time) bool {
+	return !e.at.IsZero() && e.at.Before(runDate)
+}
+
+// inGracePeriod reports whether the flag expired less than ExpiryGracePeriod
+// before runDate, so it is only warned about.
+func (r *runner) inGracePeriod(e expiry, runDate time.Time) bool {
+	return r.expired(e, runDate) && !e.at.Add(r.cfg.ExpiryGracePeriod).Before(runDate)
+}
+
+// describeExpiry says when a flag was due to be removed, and when its grace
+// period ends, if there is one.
+func (r *runner) describeExpiry(e expiry, runDate time.Time) string {
+	if r.cfg.ExpiryGracePeriod <= 0 {
+		return fmt.Sprintf("expired %s according to %s", r.formatDate(e.at), e.source)
+	}
+	ends := "ended"
+	if r.inGracePeriod(e, runDate) {
+		ends = "ends"
+	}
+	return fmt.Sprintf("expired %s according to %s and its grace period %s %s,",
+		r.formatDate(e.at), e.source, ends, r.formatDate(e.at.Add(r.cfg.ExpiryGracePeriod)),
+	)
+}
@@ -0,0 +1,359 @@
+package flagexorcist
+
+import (
+	"fmt"
+	"go/token"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// Severity is how serious a finding is.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityInfo    Severity = "info"
+)
+
+// Diagnostic categories reported by the analyzer.
+const (
+	// A flag that is older than the cutoff.
+	CategoryStale = "stale"
+	// A flag that can't be dated because the git history is incomplete.
+	CategoryInsufficientHistory = "insufficient-history"
+	// A flag that can't be dated because reading the git history failed,
+	// with ON_GIT_ERROR=report.
+	CategoryGitError = "git-error"
+	// A flag older than the cutoff that is declared but never used.
+	CategoryUnused = "unused"
+	// A flag older than the cutoff that is hardcoded to true or false, so
+	// the code it gates is dead.
+	CategoryHardcoded = "hardcoded"
+	// A flag older than the cutoff that is only used in tests.
+	CategoryTestOnly = "test-only"
+	// A package that declares more flags than MaxFlagsPerPackage.
+	CategoryFlagBudget = "flag-budget"
+	// A flag that is still in the code after its expiry date in the flag
+	// registry or its annotation.
+	CategoryExpired = "expired"
+	// A flag that is still in the code after its expiry date, but within
+	// EXPIRY_GRACE_PERIOD of it.
+	CategoryGracePeriod = "grace-period"
+	// A flag younger than the cutoff that its flag system says is archived
+	// or serving one value to everyone, so its rollout is over.
+	CategoryRolledOut = "rolled-out"
+	// A flag younger than the cutoff that will be older than it within
+	// WARN_BEFORE.
+	CategoryExpiringSoon = "expiring-soon"
+	// Code that can never run because a flag older than the cutoff always
+	// has the same value, with REPORT_DEAD_CODE.
+	CategoryDeadCode = "dead-code"
+)
+
+var categorySeverities = map[string]Severity{
+	CategoryStale:               SeverityError,
+	CategoryInsufficientHistory: SeverityWarning,
+	CategoryGitError:            SeverityWarning,
+	CategoryUnused:              SeverityError,
+	CategoryHardcoded:           SeverityError,
+	CategoryTestOnly:            SeverityError,
+	CategoryFlagBudget:          SeverityError,
+	CategoryExpired:             SeverityError,
+	CategoryGracePeriod:         SeverityWarning,
+	CategoryRolledOut:           SeverityError,
+	CategoryExpiringSoon:        SeverityInfo,
+	CategoryDeadCode:            SeverityError,
+}
+
+// SeverityOf returns the severity of findings in a diagnostic category.
+// Unknown categories are errors.
+func SeverityOf(category string) Severity {
+	if s, ok := categorySeverities[category]; ok {
+		return s
+	}
+	return SeverityError
+}
+
+// PastCutoff reports whether findings in a diagnostic category are about
+// flags older than the cutoff or past their expiry, which should be removed.
+func PastCutoff(category string) bool {
+	switch category {
+	case CategoryStale, CategoryUnused, CategoryHardcoded, CategoryTestOnly, CategoryExpired,
+		CategoryGracePeriod, CategoryDeadCode:
+		return true
+	}
+	return false
+}
+
+// Finding is a single problem found with a flag.
+type Finding struct {
+	Flag     FlagID         `json:"flag"`
+	Category string         `json:"category"`
+	Severity Severity       `json:"severity"`
+	Message  string         `json:"message"`
+	Position token.Position `json:"position"`
+
+	// Where the code the finding covers ends, for findings about a range of
+	// code, like dead code.
+	End *token.Position `json:"end,omitempty"`
+
+	// Path of the file relative to the repo root.
+	Path string `json:"path"`
+
+	// When the flag was introduced.
+	IntroducedAt time.Time `json:"introducedAt"`
+
+	// How many whole days old the flag is on the run date
+	AgeDays int `json:"ageDays"`
+
+	// Where the flag is declared, or the key literal it was dated from, and
+	// its path relative to the repo root.
+	Declaration     token.Position `json:"declaration"`
+	DeclarationPath string         `json:"declarationPath"`
+
+	// False if the finding is in a file that is not onboarded, and so was
+	// only logged rather than reported.
+	Enforced bool `json:"enforced"`
+
+	// Every usage of the flag the finding covers, across all detectors.
+	Usages []Usage `json:"usages,omitempty"`
+
+	// How many usages there are, and how many files they are spread across,
+	// to tell quick cleanups from big ones. Like Usages, these only cover
+	// the package the finding is in.
+	UsageCount int `json:"usageCount"`
+	FileCount  int `json:"fileCount"`
+
+	// How many of the packages analyzed use the flag. Only Run counts it,
+	// since the vet-style checker analyzes one package at a time, so it is
+	// zero there.
+	PackageCount int `json:"packageCount,omitempty"`
+
+	// Who to route the cleanup to: the author of the commit that added the
+	// flag, and its owners in the flag registry, or else the CODEOWNERS of
+	// the file it is declared in.
+	Author     string   `json:"author,omitempty"`
+	CodeOwners []string `json:"codeOwners,omitempty"`
+
+	// The hash and subject line of the commit that added the flag, to get
+	// from the finding to the change that introduced it
+	Commit        string `json:"commit,omitempty"`
+	CommitSubject string `json:"commitSubject,omitempty"`
+
+	// What the flag is for, according to the flag registry
+	Description string `json:"description,omitempty"`
+
+	// Tickets the flag registry gives the flag, and the ones mentioned in the
+	// comments on its declaration
+	Tickets []string `json:"tickets,omitempty"`
+
+	// The state of the flag in the flag system that serves it, if it was
+	// looked up there
+	State *FlagState `json:"state,omitempty"`
+
+	// Set if the flag is snoozed in the SNOOZE_FILE, in which case the
+	// finding is only logged rather than reported
+	Snoozed *Snooze `json:"snoozed,omitempty"`
+
+	fixes []analysis.SuggestedFix
+	end   token.Pos
+}
+
+// Fails reports whether the finding fails the run: it is an error, in a file
+// where findings are enforced, about a flag that isn't snoozed.
+func (f Finding) Fails() bool {
+	return f.Enforced && f.Snoozed == nil && f.Severity == SeverityError
+}
+
+// UsageSource is the detector that found a usage of a flag.
+type UsageSource string
+
+const (
+	// A reference to the flag's Go symbol.
+	SourceSymbol UsageSource = "symbol"
+	// A string literal of the flag's key.
+	SourceKeyLiteral UsageSource = "key-literal"
+	// The name of an environment variable read with os.Getenv or
+	// os.LookupEnv.
+	SourceEnv UsageSource = "env"
+)
+
+// Usage is a single place a flag is used.
+type Usage struct {
+	Source   UsageSource    `json:"source"`
+	Position token.Position `json:"position"`
+
+	// Path of the file relative to the repo root.
+	Path string `json:"path"`
+
+	pos token.Pos
+}
+
+// usageSummary describes how many usages there are across how many files, and
+// how many each detector found, or nothing if there is only one usage.
+func usageSummary(usages []Usage) string {
+	if len(usages) < 2 {
+		return ""
+	}
+
+	counts := map[UsageSource]int{}
+	sources := []UsageSource{}
+	for _, u := range usages {
+		if counts[u.Source] == 0 {
+			sources = append(sources, u.Source)
+		}
+		counts[u.Source]++
+	}
+
+	parts := []string{}
+	for _, source := range sources {
+		parts = append(parts, fmt.Sprintf("%d %s", counts[source], source))
+	}
+	files := fileCount(usages)
+	filesWord := "files"
+	if files == 1 {
+		filesWord = "file"
+	}
+	return fmt.Sprintf(" (used %d times across %d %s: %s)",
+		len(usages), files, filesWord, strings.Join(parts, ", "),
+	)
+}
+
+// fileCount returns how many files usages are in.
+func fileCount(usages []Usage) int {
+	files := map[string]bool{}
+	for _, u := range usages {
+		files[u.Position.Filename] = true
+	}
+	return len(files)
+}
+
+// findings collects every finding reported, for callers that run the
+// analyzer in-process.
+type findings struct {
+	mu   sync.Mutex
+	list []Finding
+}
+
+func (f *findings) add(finding Finding) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.list = append(f.list, finding)
+}
+
+// countPackages sets how many packages use the flag of each finding, by the
+// flag's string form.
+func (f *findings) countPackages(packages map[string]int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, finding := range f.list {
+		f.list[i].PackageCount = packages[finding.Flag.String()]
+	}
+}
+
+// take returns the collected findings, sorted, and resets the list.
+func (f *findings) take() []Finding {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	list := f.list
+	f.list = nil
+	sortFindings(list)
+	return list
+}
+
+// sortFindings sorts findings by file, position, flag and category, so that
+// output is the same for the same code no matter what order packages were
+// analyzed in.
+func sortFindings(list []Finding) {
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		switch {
+		case a.Path != b.Path:
+			return a.Path < b.Path
+		case a.Position.Line != b.Position.Line:
+			return a.Position.Line < b.Position.Line
+		case a.Position.Column != b.Position.Column:
+			return a.Position.Column < b.Position.Column
+		case a.Flag != b.Flag:
+			return a.Flag.less(b.Flag)
+		}
+		return a.Category < b.Category
+	})
+}
+
+// report reports a finding as a diagnostic at pos, or only logs it if it is a
+// warning or informational, the file it is in is not onboarded, or its flag
+// is snoozed on runDate.
+func (r *runner) report(pass *analysis.Pass, pos token.Pos, runDate time.Time, f Finding) {
+	f.Position = pass.Fset.Position(pos)
+	if f.end.IsValid() {
+		end := pass.Fset.Position(f.end)
+		f.End = &end
+	}
+	f.Path = r.relPath(f.Position.Filename)
+	f.Severity = SeverityOf(f.Category)
+	f.Enforced = r.enforced(f.Position.Filename)
+	if f.Declaration.IsValid() {
+		f.DeclarationPath = r.relPath(f.Declaration.Filename)
+	}
+	f.UsageCount = len(f.Usages)
+	f.FileCount = fileCount(f.Usages)
+	f.Snoozed, _ = r.snoozed(f.Flag, runDate)
+	if f.Snoozed == nil {
+		f.Message += r.snoozeNote(f.Flag, runDate)
+	}
+	f.Message = r.templatedMessage(f)
+
+	related := []analysis.RelatedInformation{}
+	for i, u := range f.Usages {
+		f.Usages[i].Path = r.relPath(u.Position.Filename)
+		if u.pos != pos {
+			related = append(related, analysis.RelatedInformation{
+				Pos:     u.pos,
+				Message: fmt.Sprintf("also used here (%s)", u.Source),
+			})
+		}
+	}
+	r.findings.add(f)
+
+	if f.Snoozed != nil {
+		r.l.Info().
+			Str("pos", f.Position.String()).
+			Msg(f.Snoozed.String() + ": " + f.Message)
+		return
+	}
+	if !f.Enforced {
+		r.l.Warn().
+			Str("pos", f.Position.String()).
+			Msg("report-only: " + f.Message)
+		return
+	}
+	if f.Severity == SeverityInfo {
+		r.l.Info().
+			Str("pos", f.Position.String()).
+			Msg(f.Message)
+		return
+	}
+	if f.Severity != SeverityError {
+		// Any diagnostic fails the vet-style checker
+		r.l.Warn().
+			Str("pos", f.Position.String()).
+			Msg(f.Message)
+		return
+	}
+
+	pass.Report(analysis.Diagnostic{
+		Pos:      pos,
+		End:      f.end,
+		Category: f.Category,
+		Message:  f.Message,
+		Related:  related,
+
+		SuggestedFixes: f.fixes,
+	})
+}
@@ -1,36 +1,363 @@
 package flagexorcist
 
 import (
+	"context"
+	"fmt"
 	"go/ast"
 	"go/token"
-	"io"
+	"os"
+	"os/exec"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
+	"text/template"
 	"time"
 
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
 	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
-	"github.com/rs/zerolog/log"
 	"github.com/samber/mo"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/tools/go/analysis"
 	"golang.org/x/tools/go/analysis/passes/inspect"
-	"golang.org/x/tools/go/ast/inspector"
 )
 
 type Config struct {
-	// The symbols that the user wants to look at
-	FlagSymbols []string `env:"FLAG_SYMBOLS" env-required:"true"`
+	// The symbols that the user wants to look at. A symbol can be qualified
+	// with the import path of its package, like example.com/flags.MyFlag, to
+	// tell apart symbols with the same name.
+	FlagSymbols []string `env:"FLAG_SYMBOLS"`
 
-	// Cutoff duration for how old a flag can be before we complain about it
-	Cutoff time.Duration `env:"CUTOFF" env-required:"true"`
+	// String keys of flags, like the ones passed to feature flag SDKs. A key
+	// can be prefixed with the flag system it belongs to, like
+	// launchdarkly:new-checkout. Consts whose value is a tracked key are
+	// treated as the same flag as the key.
+	FlagKeys []string `env:"FLAG_KEYS"`
+
+	// Vars initialized to a map literal whose string keys are flags, like
+	// example.com/flags.Defaults, qualified like FLAG_SYMBOLS. Every key in
+	// the literal is tracked like the ones in FLAG_KEYS, declared and dated
+	// by its entry.
+	FlagMaps []string `env:"FLAG_MAPS"`
+
+	// Config lookup functions whose key argument is a flag, like
+	// github.com/spf13/viper.GetBool, qualified like FLAG_SYMBOLS. Methods
+	// are qualified by the package of their type. Every literal key passed to
+	// one is tracked like the ones in FLAG_KEYS, for teams whose flags live in
+	// their config.
+	ConfigFuncs []string `env:"CONFIG_FUNCS"`
+
+	// Only track keys passed to CONFIG_FUNCS that start with this, like
+	// features.
+	ConfigKeyPrefix string `env:"CONFIG_KEY_PREFIX"`
+
+	// Track the environment variables read with os.Getenv or os.LookupEnv
+	// whose names start with this, like FEATURE_, as flag keys, for services
+	// that gate features on their environment.
+	EnvFlagPrefix string `env:"ENV_FLAG_PREFIX"`
+
+	// What finds flags in the code: symbol, which matches identifiers,
+	// key-literal, which matches string literals of keys, or the name of a
+	// detector registered with RegisterDetector, for flag systems of your
+	// own. Empty runs symbol and key-literal.
+	Detectors []string `env:"DETECTORS"`
+
+	// A struct tag key, like flag, whose value on a field names the flag key
+	// the field holds, like `flag:"new-checkout"`. Fields tagged with a
+	// tracked key are the same flag as the key, like consts holding one.
+	// Anything after a comma in the value is ignored.
+	FlagTag string `env:"FLAG_TAG"`
+
+	// Path to a YAML or JSON file snoozing flags until a date, with a reason.
+	// Findings about a snoozed flag are only logged until then, and its
+	// findings say it was snoozed once it is reported again.
+	SnoozeFile string `env:"SNOOZE_FILE"`
+
+	// How old a flag can be before we complain about it, like 90d, 3mo or
+	// 1y. See Period for every form it can take.
+	Cutoff Period `env:"CUTOFF" env-required:"true"`
+
+	// How long before a flag reaches its cutoff to start saying so, like 14d,
+	// so that its removal can be planned before it fails the run. Flags that
+	// will be past their cutoff within it are reported as informational
+	// findings, which don't fail the run. Takes the same forms as CUTOFF.
+	WarnBefore Period `env:"WARN_BEFORE"`
+
+	// Path to a YAML or JSON registry of flags, whose symbols and keys are
+	// tracked along with FLAG_SYMBOLS and FLAG_KEYS. Each entry can name the
+	// flag's owners, the tickets tracking it, what it is for, a cutoff of its
+	// own, and an expiry date after which it is reported if it is still in
+	// the code. All of it is included in the flag's findings.
+	FlagRegistry string `env:"FLAG_REGISTRY"`
+
+	// How long after its expiry a flag is only warned about, before it is an
+	// error. Besides the flag registry, a flag's expiry can be annotated in a
+	// comment on its declaration, like "flagexorcist:expires 2023-04-01",
+	// which wins over the registry. Warnings are logged and included in
+	// structured output, but don't fail the run.
+	ExpiryGracePeriod time.Duration `env:"EXPIRY_GRACE_PERIOD" env-default:"0"`
+
+	// Names flags had before they were renamed, as old=new, like
+	// EnableX=EnableXV2. A renamed flag is dated by the earliest of its
+	// names in the file it is declared or used in, so its age survives the
+	// rename. Works for both symbols and keys.
+	Aliases []string `env:"ALIASES"`
+
+	// Checkouts of the dependency modules flags are declared in, as
+	// module=path, like example.com/flags=../flags. Flags are otherwise only
+	// reported in the package declaring them, so flags from dependencies
+	// never would be. With their module's checkout, their usages here are
+	// reported, dated by the commit that added their declaration to the
+	// dependency's history.
+	DependencyRepos []string `env:"DEPENDENCY_REPOS"`
 
 	// Log level to log at
 	LogLevel LogLevel `env:"LOG_LEVEL" env-default:"info"`
 
-	// Path to the git repo. Defaults to the current directory.
-	RepoPath string `env:"REPO_PATH" env-default:"."`
+	// Path to also write logs to as JSON lines, at LOG_FILE_LEVEL. At debug,
+	// they include how long each phase of analyzing each package took, and
+	// each package as a whole, to profile slow runs with.
+	LogFile      string   `env:"LOG_FILE"`
+	LogFileLevel LogLevel `env:"LOG_FILE_LEVEL" env-default:"debug"`
+
+	// Path to the git repo. If unset, along with GIT_DIR and GIT_WORK_TREE,
+	// each file is dated against the checkout enclosing it, found by walking
+	// up from the file, or else the current directory.
+	RepoPath string `env:"REPO_PATH"`
+
+	// Only follow the first parent of merge commits when searching for the
+	// commit that introduced a flag, like `git log --first-parent`.
+	FirstParent bool `env:"FIRST_PARENT" env-default:"false"`
+
+	// Path to a file listing the directories, relative to the repo, where
+	// findings are enforced. Findings anywhere else are only logged. If unset,
+	// findings are enforced everywhere.
+	OnboardingFile string `env:"ONBOARDING_FILE"`
+
+	// Which commit date to date flags by: "author" or "committer". Rebased
+	// branches keep their original author dates, so teams that rebase onto
+	// main usually want "committer".
+	DateSource DateSource `env:"DATE_SOURCE" env-default:"author"`
+
+	// What to do when reading a flag's history fails: "fail" the analysis
+	// with the error, "skip" the flag, leaving it undated, or "report" it with
+	// a warning saying why it couldn't be dated
+	OnGitError GitErrorPolicy `env:"ON_GIT_ERROR" env-default:"fail"`
+
+	// What a flag's age is measured from: "introduced" for the commit that
+	// added it, or "last_modified" for the most recent commit that changed a
+	// line referencing it, for teams that care whether its gating logic is
+	// still being worked on.
+	AgeMetric AgeMetric `env:"AGE_METRIC" env-default:"introduced"`
+
+	// The date that flag ages are measured from: "head" for the date of the
+	// HEAD commit, "now" for today, or a date like 2023-04-01. Ages are
+	// counted in whole UTC days, so anchoring to HEAD means the same commit
+	// always produces the same findings no matter where or when it is checked.
+	RunDate string `env:"RUN_DATE" env-default:"head"`
+
+	// The time to treat as now, like 2023-04-01T00:00:00Z, so runs can be
+	// reproduced later. Unset means the current time. If set, ages are
+	// measured from its day instead of HEAD's, as if RUN_DATE were "now";
+	// only a date in RUN_DATE wins over it.
+	ReferenceTime time.Time `env:"REFERENCE_TIME" env-layout:"2006-01-02T15:04:05Z07:00"`
+
+	// Only report flags used in files changed since HEAD branched off this
+	// ref, like origin/main, so pull requests are only held up by code they
+	// touch. Long-standing usages elsewhere are left alone.
+	SinceRef string `env:"SINCE_REF"`
+
+	// Returns the current time. Overrides ReferenceTime, and like it is what
+	// ages are measured from unless RUN_DATE is a date; tests use it to pin
+	// the clock.
+	Now func() time.Time `env:"-"`
+
+	// The most commits to search for the commit that introduced a flag. 0
+	// means no limit.
+	MaxHistoryDepth int `env:"MAX_HISTORY_DEPTH" env-default:"0"`
+
+	// Don't search commits older than this date. Flags that are already
+	// present at the oldest commit searched are assumed to be older than the
+	// cutoff.
+	HistorySince time.Time `env:"HISTORY_SINCE" env-layout:"2006-01-02"`
+
+	// What to do when the repo is a shallow clone: "report" a warning for
+	// flags that can't be dated, "fail" the run, or "deepen" the clone by
+	// fetching the full history.
+	OnShallow ShallowPolicy `env:"ON_SHALLOW" env-default:"report"`
+
+	// Path to the git directory, for bare repos or build systems that keep
+	// git metadata apart from the source checkout. Defaults to the repo at
+	// RepoPath.
+	GitDir string `env:"GIT_DIR"`
+
+	// Root of the source checkout, which file paths are taken relative to
+	// when looking them up in history. Defaults to RepoPath.
+	WorkTree string `env:"GIT_WORK_TREE"`
+
+	// How to read git history: "go-git" to read it in-process, or "exec" to
+	// run the git binary, which is much faster on large repos. Falls back to
+	// go-git if git isn't installed.
+	GitBackend GitBackend `env:"GIT_BACKEND" env-default:"go-git"`
+
+	// Look up flags whose history is cut off by a shallow clone with the
+	// GitHub API, so depth-1 CI jobs still get accurate dates. Needs
+	// GITHUB_REPOSITORY, and GITHUB_TOKEN for private repos.
+	GitHubHistory bool `env:"GITHUB_HISTORY" env-default:"false"`
+
+	// The owner/name slug of the repo on GitHub
+	GitHubRepository string `env:"GITHUB_REPOSITORY"`
+
+	// Token to authenticate to the GitHub API with
+	GitHubToken string `env:"GITHUB_TOKEN"`
+
+	// Base URL of the GitHub API, for GitHub Enterprise
+	GitHubAPIURL string `env:"GITHUB_API_URL" env-default:"https://api.github.com"`
+
+	// Token to authenticate to the LaunchDarkly REST API with. If set, each
+	// tracked key without a namespace or in the launchdarkly namespace is
+	// looked up there, its state is included in findings, and flags that are
+	// archived or serving one variation to everyone are reported even before
+	// they are older than the cutoff.
+	LaunchDarklyAPIToken string `env:"LAUNCHDARKLY_API_TOKEN"`
+
+	// The LaunchDarkly project and environment to look flags up in
+	LaunchDarklyProject     string `env:"LAUNCHDARKLY_PROJECT" env-default:"default"`
+	LaunchDarklyEnvironment string `env:"LAUNCHDARKLY_ENVIRONMENT" env-default:"production"`
+
+	// Base URL of the LaunchDarkly API
+	LaunchDarklyAPIURL string `env:"LAUNCHDARKLY_API_URL" env-default:"https://app.launchdarkly.com"`
+
+	// Base URL of an Unleash server, like https://unleash.example.com. If
+	// set, tracked keys without a namespace or in the unleash namespace are
+	// looked up there like in LaunchDarkly, and toggles that are archived or
+	// enabled for everyone are reported.
+	UnleashAPIURL string `env:"UNLEASH_API_URL"`
+
+	// Admin API token to authenticate to Unleash with
+	UnleashAPIToken string `env:"UNLEASH_API_TOKEN"`
+
+	// The Unleash project and environment to look toggles up in
+	UnleashProject     string `env:"UNLEASH_PROJECT" env-default:"default"`
+	UnleashEnvironment string `env:"UNLEASH_ENVIRONMENT" env-default:"production"`
+
+	// Admin API key to authenticate to Split with. If set, tracked keys
+	// without a namespace or in the split namespace are looked up in the
+	// workspace and environment below like in LaunchDarkly.
+	SplitAPIKey      string `env:"SPLIT_API_KEY"`
+	SplitWorkspaceID string `env:"SPLIT_WORKSPACE_ID"`
+	SplitEnvironment string `env:"SPLIT_ENVIRONMENT" env-default:"Production"`
+	SplitAPIURL      string `env:"SPLIT_API_URL" env-default:"https://api.split.io"`
+
+	// Basic auth credentials of the ConfigCat management API. If set, tracked
+	// keys without a namespace or in the configcat namespace are looked up as
+	// settings of the config and environment below like in LaunchDarkly.
+	ConfigCatUsername      string `env:"CONFIGCAT_USERNAME"`
+	ConfigCatPassword      string `env:"CONFIGCAT_PASSWORD"`
+	ConfigCatConfigID      string `env:"CONFIGCAT_CONFIG_ID"`
+	ConfigCatEnvironmentID string `env:"CONFIGCAT_ENVIRONMENT_ID"`
+	ConfigCatAPIURL        string `env:"CONFIGCAT_API_URL" env-default:"https://api.configcat.com"`
+
+	// Other flag systems to look flags up in, after the built-in ones, for
+	// callers that run the analyzer in-process.
+	FlagStateProviders []FlagStateProvider `env:"-"`
+
+	// How many flags to look up in history at once in each package. 0 means
+	// one per CPU.
+	GitConcurrency int `env:"GIT_CONCURRENCY" env-default:"0"`
+
+	// Show progress on stderr while analyzing, and how long each phase took
+	// at the end.
+	Progress bool `env:"PROGRESS" env-default:"false"`
+
+	// Compare file paths without regard to case, for checkouts on
+	// case-insensitive filesystems like the defaults on macOS and Windows,
+	// where the path a file is opened by can differ in case from the path
+	// recorded in history.
+	FoldPathCase bool `env:"FOLD_PATH_CASE" env-default:"false"`
+
+	// Globs of paths relative to the repo, like vendor/** or **/mocks/**,
+	// where flags are ignored: usages there are neither reported nor counted.
+	// * matches within one directory and ** across any number of them.
+	ExcludePaths []string `env:"EXCLUDE_PATHS"`
+
+	// Ignore usages in _test.go files, so that tests alone don't keep an
+	// expired flag alive.
+	IgnoreTests bool `env:"IGNORE_TESTS" env-default:"false"`
+
+	// Count usages in vendor directories as keeping a flag in use. Flags are
+	// never dated or reported from vendored code, since vendored copies of a
+	// flag package are not where it is maintained, but usages there can
+	// still count.
+	CountVendorUsages bool `env:"COUNT_VENDOR_USAGES" env-default:"false"`
+
+	// Also report old flags that are declared but no longer used, at their
+	// declaration, with a fix that deletes it. Exported flags outside package
+	// main are only reported by Run, which analyzes every package that could
+	// use them together. The vet-style checker sees one package at a time,
+	// so it never reports them. Flags mentioned in files that build
+	// constraints excluded aren't reported either, since those may use them.
+	ReportUnused bool `env:"REPORT_UNUSED" env-default:"false"`
+
+	// Build tags to load packages with in Run, like linux,integration, so
+	// that usages in files behind them are analyzed too. The vet-style
+	// checker takes -tags instead.
+	BuildTags []string `env:"BUILD_TAGS"`
+
+	// Also report old flags that are only used in tests, which are as dead
+	// as unused ones and should be removed along with their tests. Test
+	// usages are looked at for this even if IgnoreTests is set.
+	ReportTestOnly bool `env:"REPORT_TEST_ONLY" env-default:"false"`
+
+	// Also report the code that can never run because a flag older than the
+	// cutoff always has the same value, covering the whole of it: branches
+	// of conditions on the flag that are never taken, code after early
+	// returns that always happen, and cases of switches on it that never
+	// match. The value is the one the flag is hardcoded to, or that its flag
+	// system serves to everyone. Only flags declared in the package are
+	// looked for.
+	ReportDeadCode bool `env:"REPORT_DEAD_CODE" env-default:"false"`
+
+	// The most tracked flags a package may declare, no matter how old they
+	// are. Packages over it are reported at their newest flag. 0 means no
+	// limit.
+	MaxFlagsPerPackage int `env:"MAX_FLAGS_PER_PACKAGE" env-default:"0"`
+
+	// Only report the findings about this many of the oldest flags past
+	// their cutoff, and count the rest. Only Run applies it, since the
+	// vet-style checker analyzes one package at a time. 0 means no limit.
+	ReportTop int `env:"REPORT_TOP" env-default:"0"`
+
+	// Name who owns each flag in diagnostics: the CODEOWNERS of the file it
+	// is declared in, and the author of the commit that added it. Structured
+	// output always includes them.
+	ReportOwners bool `env:"REPORT_OWNERS" env-default:"false"`
+
+	// Ticket ids to pick out of the comments on a flag's declaration, like
+	// `\bPROJ-[0-9]+\b` for PROJ-1234 in "Rollout tracked in PROJ-1234",
+	// and attach to its findings. Empty, the default, to not look for
+	// tickets: a pattern loose enough to fit every tracker also matches
+	// UTF-8, SHA-256 and the like.
+	TicketPattern string `env:"TICKET_PATTERN"`
+
+	// A Go template for diagnostic messages, executed with a MessageData,
+	// like "{{.Symbol}} is {{.AgeDays}} days old, see https://wiki/flags".
+	// Empty for the built-in messages.
+	MessageTemplate string `env:"MESSAGE_TEMPLATE"`
+
+	// The Go time layout dates are shown in
+	DateFormat string `env:"DATE_FORMAT" env-default:"2006-01-02"`
+
+	// The unit ages and the cutoff are shown in: "days", "weeks", "months",
+	// "years", or "auto" to pick whichever reads best for each.
+	AgeUnit AgeUnit `env:"AGE_UNIT" env-default:"days"`
 }
 
 type LogLevel zerolog.Level
@@ -44,9 +371,130 @@ func (l *LogLevel) SetValue(s string) error {
 	return nil
 }
 
+type DateSource string
+
+const (
+	DateSourceAuthor    DateSource = "author"
+	DateSourceCommitter DateSource = "committer"
+)
+
+func (d *DateSource) SetValue(s string) error {
+	switch src := DateSource(s); src {
+	case DateSourceAuthor, DateSourceCommitter:
+		*d = src
+		return nil
+	}
+	return errors.Errorf("invalid date source %q, must be author or committer", s)
+}
+
+// AgeMetric is what a flag's age is measured from.
+type AgeMetric string
+
+const (
+	AgeMetricIntroduced   AgeMetric = "introduced"
+	AgeMetricLastModified AgeMetric = "last_modified"
+)
+
+func (m *AgeMetric) SetValue(s string) error {
+	switch metric := AgeMetric(s); metric {
+	case AgeMetricIntroduced, AgeMetricLastModified:
+		*m = metric
+		return nil
+	}
+	return errors.Errorf("invalid age metric %q, must be introduced or last_modified", s)
+}
+
 type runner struct {
 	cfg Config
 	l   zerolog.Logger
+
+	// The flags being tracked
+	flags flagIDs
+
+	// Everything reported so far, every flag dated, and the findings
+	// REPORT_TOP left out of what the last Run returned
+	findings   findings
+	dated      datedFlags
+	unreported findings
+
+	// What has been done so far
+	progress progress
+
+	// What each lookup of history found, kept until the analyzer is
+	// configured again
+	intros introCache
+
+	// Warns once that there is no repo, and once that the history can't
+	// tell when flags were last modified
+	noRepo         sync.Once
+	noLastModified sync.Once
+
+	// Directories where findings are enforced, or nil if they are enforced
+	// everywhere.
+	onboarded []string
+
+	// Whether the checkout is found from each file, since no repo was
+	// configured, and the checkout found for each directory.
+	detectRoot bool
+	rootsMu    sync.Mutex
+	roots      map[string]string
+
+	// The roots whose shallow clones have been deepened, or tried to be,
+	// guarded by deepenMu
+	deepened map[string]bool
+
+	// What the flag registry says about each flag in it
+	registry map[FlagID]registryEntry
+
+	// Parsed SnoozeFile
+	snoozes map[FlagID]Snooze
+
+	// Parsed Aliases: the names each flag had before it was renamed
+	aliases map[string][]string
+
+	// Parsed DependencyRepos
+	dependencies []dependencyRepo
+
+	// The detectors named by Detectors
+	detectors []namedDetector
+
+	// Compiled TicketPattern
+	tickets *regexp.Regexp
+
+	// Parsed MessageTemplate
+	message *template.Template
+
+	// Parsed Cutoff and WarnBefore
+	cutoffSpan, warnBefore span
+
+	// What spans are started under, if set by RunContext
+	traceCtx context.Context
+
+	// The CODEOWNERS rules of each repo root
+	ownersMu sync.Mutex
+	owners   map[string][]ownerRule
+
+	// Which symbols have matched, to warn about ones that never do
+	sightings sightings
+
+	// The files changed since SinceRef in each repo root
+	changedMu sync.Mutex
+	changed   map[string]map[string]bool
+
+	// The flag systems to look up the state of flags in, and what they said
+	providers []FlagStateProvider
+	remote    remoteStates
+
+	// Set while Run analyzes the whole build graph, so that usages can be
+	// added up across packages, and the exported flags waiting on that
+	aggregating bool
+	exported    unusedExports
+
+	// Why the repo's paths couldn't be resolved, if they couldn't
+	pathErr error
+
+	// The LogFile being logged to
+	logFile *os.File
 }
 
 var r runner
@@ -58,98 +506,672 @@ var Analyzer *analysis.Analyzer = &analysis.Analyzer{
 	Requires: []*analysis.Analyzer{
 		inspect.Analyzer,
 	},
+	FactTypes: []analysis.Fact{new(mapKeysFact), new(flagUsesFact)},
+}
+
+// Set by the --since-ref flag, which overrides SinceRef
+var sinceRef string
+
+func init() {
+	Analyzer.Flags.StringVar(&sinceRef, "since-ref", "",
+		"only report flags used in files changed since HEAD branched off this ref (overrides SINCE_REF)",
+	)
 }
 
-func Initialize(cfg Config) {
-	// Get the full path to the repo
+// Initialize configures the analyzer. It returns an error if the config is
+// invalid, or a file it names can't be read.
+func Initialize(cfg Config) error {
+	r.registry = nil
+	if cfg.FlagRegistry != "" {
+		symbols, keys, registry, err := loadRegistry(cfg.FlagRegistry)
+		if err != nil {
+			return err
+		}
+		cfg.FlagSymbols = append(append([]string(nil), cfg.FlagSymbols...), symbols...)
+		cfg.FlagKeys = append(append([]string(nil), cfg.FlagKeys...), keys...)
+		r.registry = registry
+	}
+	if len(cfg.FlagSymbols) == 0 && len(cfg.FlagKeys) == 0 && len(cfg.FlagMaps) == 0 &&
+		len(cfg.ConfigFuncs) == 0 && cfg.EnvFlagPrefix == "" && !hasRegisteredDetector(cfg.Detectors) {
+		return errors.New(
+			"at least one of FLAG_SYMBOLS, FLAG_KEYS, FLAG_MAPS, CONFIG_FUNCS, ENV_FLAG_PREFIX or FLAG_REGISTRY " +
+				"must be set, or DETECTORS must name a registered detector",
+		)
+	}
+	if cfg.GitHubHistory && cfg.GitHubRepository == "" {
+		return errors.New("GITHUB_HISTORY needs GITHUB_REPOSITORY to be set")
+	}
+	if cfg.SplitAPIKey != "" && cfg.SplitWorkspaceID == "" {
+		return errors.New("SPLIT_API_KEY needs SPLIT_WORKSPACE_ID to be set")
+	}
+	if cfg.ConfigCatUsername != "" &&
+		(cfg.ConfigCatConfigID == "" || cfg.ConfigCatEnvironmentID == "") {
+		return errors.New(
+			"CONFIGCAT_USERNAME needs CONFIGCAT_CONFIG_ID and CONFIGCAT_ENVIRONMENT_ID to be set",
+		)
+	}
+
+	r.detectRoot = cfg.RepoPath == "" && cfg.GitDir == "" && cfg.WorkTree == ""
+	r.roots = map[string]string{}
+	r.deepened = map[string]bool{}
+	r.owners = map[string][]ownerRule{}
+	r.changed = map[string]map[string]bool{}
+	if cfg.RepoPath == "" {
+		cfg.RepoPath = "."
+	}
+
+	// Get the full path to the repo. Failing to is an error from every
+	// package analyzed, rather than a panic.
+	r.pathErr = nil
 	repoPath, err := filepath.Abs(cfg.RepoPath)
 	if err != nil {
-		panic(err)
+		r.pathErr = errors.Wrap(err, "resolve REPO_PATH")
+	} else {
+		cfg.RepoPath = repoPath
+	}
+
+	if cfg.GitDir != "" {
+		if gitDir, err := filepath.Abs(cfg.GitDir); err != nil {
+			r.pathErr = errors.Wrap(err, "resolve GIT_DIR")
+		} else {
+			cfg.GitDir = gitDir
+		}
+	}
+	if cfg.WorkTree == "" {
+		cfg.WorkTree = cfg.RepoPath
+	} else if workTree, err := filepath.Abs(cfg.WorkTree); err != nil {
+		r.pathErr = errors.Wrap(err, "resolve GIT_WORK_TREE")
+	} else {
+		cfg.WorkTree = workTree
 	}
-	cfg.RepoPath = repoPath
 	r.cfg = cfg
+	r.flags = newFlagIDs(cfg)
+	r.progress.reset()
+	r.sightings.reset()
+	r.dated.take()
+	r.exported.take()
+	r.intros.reset()
+	r.providers = remoteProviders(cfg)
+	r.remote.reset()
 
-	r.l = log.Logger.Level(zerolog.Level(cfg.LogLevel))
+	if r.logFile != nil {
+		r.logFile.Close()
+	}
+	if r.l, r.logFile, err = newLogger(cfg); err != nil {
+		return err
+	}
+
+	switch cfg.GitBackend {
+	case "", GitBackendGoGit:
+	case GitBackendExec:
+		if _, err := exec.LookPath("git"); err != nil {
+			r.l.Warn().Err(err).Msg("git isn't installed, falling back to the go-git backend")
+			r.cfg.GitBackend = GitBackendGoGit
+		}
+	default:
+		if _, ok := historyProvider(string(cfg.GitBackend)); !ok {
+			return errors.Errorf("no history provider named %q", cfg.GitBackend)
+		}
+	}
+
+	if _, err := parseRunDate(cfg.RunDate, r.now()); err != nil {
+		return err
+	}
+	if r.cutoffSpan, err = parsePeriod(string(cfg.Cutoff)); err != nil {
+		return errors.Wrap(err, "invalid CUTOFF")
+	}
+	if r.warnBefore, err = parsePeriod(string(cfg.WarnBefore)); err != nil {
+		return errors.Wrap(err, "invalid WARN_BEFORE")
+	}
+	if r.tickets, err = compileTicketPattern(cfg.TicketPattern); err != nil {
+		return err
+	}
+	if r.message, err = parseMessageTemplate(cfg.MessageTemplate); err != nil {
+		return err
+	}
+
+	if r.aliases, err = parseAliases(cfg.Aliases); err != nil {
+		return err
+	}
+	if r.dependencies, err = parseDependencyRepos(cfg.DependencyRepos); err != nil {
+		return err
+	}
+	if r.detectors, err = newDetectors(cfg); err != nil {
+		return err
+	}
+
+	r.snoozes = nil
+	if cfg.SnoozeFile != "" {
+		if r.snoozes, err = loadSnoozes(cfg.SnoozeFile); err != nil {
+			return err
+		}
+	}
+
+	r.onboarded = nil
+	if cfg.OnboardingFile != "" {
+		r.onboarded, err = loadOnboarding(cfg.OnboardingFile)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (r *runner) run(pass *analysis.Pass) (any, error) {
+func (r *runner) run(pass *analysis.Pass) (result any, err error) {
 	r.l.Debug().Str("package", pass.Pkg.Name()).Msg("Running flagexorcist on package")
+	defer r.progress.packages.Add(1)
+	start := time.Now()
+	defer func() {
+		r.l.Debug().
+			Err(err).
+			Str("package", pass.Pkg.Path()).
+			Dur("duration", time.Since(start)).
+			Msg("Analyzed package")
+	}()
+	ctx, span := tracer.Start(r.traceContext(), "analyze package", trace.WithAttributes(
+		attribute.String("package", pass.Pkg.Path()),
+		attribute.Int("files", len(pass.Files)),
+	))
+	defer func() { endSpan(span, err) }()
+	if r.pathErr != nil {
+		return nil, r.pathErr
+	}
 
-	// Get the git repo
-	r.l.Debug().Str("path", r.cfg.RepoPath).Msg("Opening git repo")
-	repo, err := git.PlainOpen(r.cfg.RepoPath)
+	_, find := r.startPhase(ctx, pass, PhaseFindRefs)
+	refs := r.findFlagRefs(pass)
+	r.sightings.record(pass.TypesInfo)
+	find.span.SetAttributes(attribute.Int("references", len(refs)))
+	excluded := r.excludedMentions(pass)
+	find.end(nil)
+	if len(refs) == 0 {
+		// Nothing to date, like in the dependencies that are analyzed for
+		// their facts
+		r.exportUses(pass, nil, excluded)
+		return nil, nil
+	}
+
+	// Get the git repo. Files in submodules are dated against the
+	// submodule's own history, which is opened when first needed.
+	root := r.cfg.WorkTree
+	if len(pass.Files) > 0 {
+		root = r.topRoot(pass.Fset.File(pass.Files[0].Pos()).Name())
+	}
+	_, open := r.startPhase(ctx, pass, PhaseOpenRepo, attribute.String("root", root))
+	repo, err := r.openRepo(root)
+	if errors.Is(err, git.ErrRepositoryNotExists) && r.cfg.GitDir == "" {
+		// Dated some other way below
+		repo = nil
+	} else if err != nil {
+		return nil, open.end(err)
+	}
+	topHistory, err := r.historyCache(root, repo)(root)
+	if err != nil {
+		return nil, open.end(err)
+	}
+	runDate, err := r.runDate(repo, topHistory)
+	if err != nil {
+		return nil, open.end(errors.Wrap(err, "get run date"))
+	}
+	changed, err := r.changedFiles(root, repo)
 	if err != nil {
-		return nil, errors.Wrap(err, "open git repo")
+		return nil, open.end(err)
 	}
+	open.end(nil)
 
-	identifiers := r.findFlagIdents(pass)
+	dateCtx, date := r.startPhase(ctx, pass, PhaseDate)
 
-	// sort these into declarations and usages
-	declarationCommitTimes := map[string]time.Time{}
-	usagesByFlag := map[string][]*ast.Ident{}
-	for _, id := range identifiers {
-		if isDeclaration(id) && !hasKey(declarationCommitTimes, id.Name) {
-			timeCommitted := r.timeCommitted(repo, id.Name, pass.Fset.Position(id.NamePos))
-			if t := timeCommitted.OrEmpty(); !t.IsZero() {
-				declarationCommitTimes[id.Name] = t
+	// sort these into declarations and usages. Symbols are dated by their
+	// declaration, keys by the first commit any of their literals appear in.
+	// When measuring from the last modification, usages of symbols are dated
+	// too, since changing them changes the flag's gating logic.
+	lastModified := r.cfg.AgeMetric == AgeMetricLastModified
+	usagesByFlag := map[FlagID][]reference{}
+	testUsages := map[FlagID][]reference{}
+	jobs := []datingJob{}
+	dated := map[string]bool{}
+	for _, ref := range refs {
+		// Declarations in ignored files still date the flag for its usages
+		// elsewhere.
+		filename := pass.Fset.Position(ref.pos).Filename
+		if !ref.declaration && isTestFile(filename) && !r.excluded(filename) {
+			testUsages[ref.id] = append(testUsages[ref.id], ref)
+		}
+		if !ref.declaration && r.ignored(filename) {
+			continue
+		}
+		vendored := r.vendored(filename)
+		if vendored && (ref.declaration || !r.cfg.CountVendorUsages) {
+			continue
+		}
+		if !ref.declaration {
+			usagesByFlag[ref.id] = append(usagesByFlag[ref.id], ref)
+		}
+		if job, ok := r.importedConstJob(pass, root, ref); ok {
+			if !dated[job.ref.search+"\x00"+job.filename] {
+				dated[job.ref.search+"\x00"+job.filename] = true
+				jobs = append(jobs, job)
 			}
-		} else {
-			usagesByFlag[id.Name] = append(usagesByFlag[id.Name], id)
+		}
+		if job, ok := r.dependencyJob(pass, root, ref); ok {
+			if !dated[job.ref.search+"\x00"+job.filename] {
+				dated[job.ref.search+"\x00"+job.filename] = true
+				jobs = append(jobs, job)
+			}
+		}
+		if !ref.declaration && !ref.literal && !lastModified || vendored {
+			continue
+		}
+
+		if dated[ref.search+"\x00"+filename] {
+			continue
+		}
+		dated[ref.search+"\x00"+filename] = true
+		jobs = append(jobs, datingJob{ref: ref, filename: filename})
+		for _, job := range r.aliasJobs(ref, filename) {
+			if !dated[job.ref.search+"\x00"+filename] {
+				dated[job.ref.search+"\x00"+filename] = true
+				jobs = append(jobs, job)
+			}
+		}
+	}
+
+	r.exportUses(pass, usagesByFlag, excluded)
+
+	date.span.SetAttributes(attribute.Int("jobs", len(jobs)))
+	intros, failures, err := r.dateAll(dateCtx, root, repo, jobs)
+	if err != nil {
+		return nil, date.end(err)
+	}
+	r.progress.symbols.Add(int64(len(jobs)))
+	date.end(nil)
+
+	_, report := r.startPhase(ctx, pass, PhaseReport)
+	defer report.end(nil)
+	r.reportGitFailures(pass, failures, runDate, root, changed)
+	declarationCommitTimes := map[FlagID]introduction{}
+	declaredAt := map[FlagID]token.Pos{}
+	for i, job := range jobs {
+		intro, ok := intros[i].Get()
+		if !ok {
+			continue
+		}
+		if job.ref.declaration && lastModified {
+			declaredAt[job.ref.id] = job.ref.pos
+		}
+		prev, ok := declarationCommitTimes[job.ref.id]
+		switch {
+		case !ok:
+			declarationCommitTimes[job.ref.id] = intro
+		case lastModified:
+			if intro.at.After(prev.at) {
+				declarationCommitTimes[job.ref.id] = intro
+			}
+		case intro.at.Before(prev.at):
+			declarationCommitTimes[job.ref.id] = intro
 		}
 	}
+	// The most recent change may be to a usage, but the flag is still
+	// declared where it is declared
+	for flag, pos := range declaredAt {
+		intro := declarationCommitTimes[flag]
+		intro.declaredAt = pos
+		declarationCommitTimes[flag] = intro
+	}
+
+	// Report in source order, so the output is the same from run to run
+	flags := make([]FlagID, 0, len(declarationCommitTimes))
+	for flag := range declarationCommitTimes {
+		flags = append(flags, flag)
+	}
+	sort.Slice(flags, func(i, j int) bool {
+		a, b := declarationCommitTimes[flags[i]], declarationCommitTimes[flags[j]]
+		if a.declaredAt != b.declaredAt {
+			return a.declaredAt < b.declaredAt
+		}
+		return flags[i].less(flags[j])
+	})
 
 	// We complain if any used symbol is very old
-	for symbol, committedAt := range declarationCommitTimes {
-		usages, ok := usagesByFlag[symbol]
+	for _, flag := range flags {
+		intro := declarationCommitTimes[flag]
+		declaration := pass.Fset.Position(intro.declaredAt)
+		dated := DatedFlag{
+			Flag:            flag,
+			Cutoff:          r.cutoff(flag).approx(),
+			Undated:         intro.shallow,
+			Usages:          len(usagesByFlag[flag]),
+			Declaration:     declaration,
+			DeclarationPath: r.relPath(declaration.Filename),
+		}
+		dated.Snoozed, _ = r.snoozed(flag, runDate)
+		if !intro.shallow {
+			dated.IntroducedAt = intro.at
+			dated.AgeDays = ageDays(intro.at, runDate)
+			dated.BeforeWindow = intro.beforeWindow
+			dated.Stale = r.isOld(flag, intro, runDate)
+		}
+		if !intro.shallow || !r.ignored(declaration.Filename) {
+			r.dated.add(dated)
+		}
+
+		if intro.shallow {
+			if r.ignored(declaration.Filename) || !r.inChanged(changed, root, declaration.Filename) {
+				continue
+			}
+			r.report(pass, intro.declaredAt, runDate, Finding{
+				Flag:     flag,
+				Category: CategoryInsufficientHistory,
+				Message: fmt.Sprintf(
+					"Can't tell when flag '%v' was added because the repo is a shallow clone; "+
+						"fetch the full history with `git fetch --unshallow`",
+					flag,
+				),
+			})
+			continue
+		}
+
+		usages, ok := usagesByFlag[flag]
+		tests := testUsages[flag]
+		if r.cfg.ReportTestOnly && len(tests) > 0 && onlyTests(pass, usages) {
+			if r.inChanged(changed, root, declaration.Filename) {
+				r.reportTestOnly(pass, flag, intro, runDate, tests)
+			}
+			continue
+		}
+		if !ok && r.cfg.ReportTestOnly && flag.Kind == FlagKindSymbol &&
+			usedInTests(pass, flag.Name) {
+			if r.inChanged(changed, root, declaration.Filename) {
+				r.reportTestOnly(pass, flag, intro, runDate, nil)
+			}
+			continue
+		}
 		if !ok {
+			// Files for other platforms or tags may still use it
+			if r.cfg.ReportUnused && len(excluded[flag]) == 0 &&
+				r.inChanged(changed, root, declaration.Filename) {
+				r.reportUnused(pass, flag, intro, runDate)
+			}
 			continue
 		}
 
+		committedAt := intro.at
 		r.l.Debug().
 			Time("committedAt", committedAt).
-			Dur("cutoff", r.cfg.Cutoff).
-			Str("symbol", symbol).
+			Bool("beforeWindow", intro.beforeWindow).
+			Stringer("cutoff", r.cutoff(flag)).
+			Time("runDate", runDate).
+			Stringer("flag", flag).
 			Msg("Checking if flag is old")
-		if committedAt.Before(time.Now().Add(-r.cfg.Cutoff)) {
-			for _, usage := range usages {
-				pass.Reportf(
-					usage.Pos(),
-					"Flag '%v', added on %v, is more than %v days old",
-					symbol, committedAt.Format("2006-01-02"),
-					r.cfg.Cutoff.Hours()/24,
-				)
+		old := r.isOld(flag, intro, runDate)
+		expiry := r.expiryOf(pass, flag, intro.declaredAt)
+		expired := r.expired(expiry, runDate)
+		state, remote := r.remoteState(ctx, flag).Get()
+		done := remote && state.Done()
+		soon := !old && !expired && !done && r.expiringSoon(flag, intro, runDate)
+		if !old && !expired && !done && !soon {
+			continue
+		}
+
+		// Every usage is folded into one finding, so a flag found by several
+		// detectors isn't reported several times. It is reported at the first
+		// usage that is enforced, if any, and never in vendored code or files
+		// that haven't changed since SinceRef.
+		found := []Usage{}
+		for _, usage := range usages {
+			found = append(found, Usage{
+				Source:   usage.source(),
+				Position: pass.Fset.Position(usage.pos),
+				pos:      usage.pos,
+			})
+		}
+		anchor := token.NoPos
+		for _, usage := range found {
+			if r.vendored(usage.Position.Filename) ||
+				!r.inChanged(changed, root, usage.Position.Filename) {
+				continue
+			}
+			if anchor == token.NoPos {
+				anchor = usage.pos
+			}
+			if r.enforced(usage.Position.Filename) {
+				anchor = usage.pos
+				break
+			}
+		}
+		if anchor == token.NoPos {
+			// Only used in vendored or unchanged code
+			continue
+		}
+
+		note := ""
+		if intro.byMtime {
+			note = " (dated by file modification time, since there is no git history)"
+		}
+		if remote {
+			note += fmt.Sprintf(" (%v)", state)
+		}
+
+		// Flags hardcoded to a value are the cheapest to delete, since the
+		// rollout is over and one side of every conditional is dead. Flags
+		// past their expiry, and flags their flag system says are done, are
+		// due for removal however young they are.
+		category := CategoryStale
+		message := fmt.Sprintf(
+			"Flag '%v', %v; cutoff is %v%v%v",
+			flag, r.describeAge(intro, runDate), r.formatCutoff(flag),
+			usageSummary(found), note,
+		)
+		value, hardcoded := false, false
+		if flag.Kind == FlagKindSymbol && old {
+			value, hardcoded = hardcodedValue(pass, intro.declaredAt)
+		}
+		switch {
+		case hardcoded:
+			category = CategoryHardcoded
+			message = fmt.Sprintf(
+				"Flag '%v', %v, is hardcoded to %v, so its rollout is complete and "+
+					"the code it turns off is dead; cutoff is %v%v%v",
+				flag, r.describeAge(intro, runDate), value, r.formatCutoff(flag),
+				usageSummary(found), note,
+			)
+		case expired && !(old && r.inGracePeriod(expiry, runDate)):
+			category = CategoryExpired
+			if r.inGracePeriod(expiry, runDate) {
+				category = CategoryGracePeriod
 			}
+			message = fmt.Sprintf(
+				"Flag '%v', %v, %v but is still in the code%v%v",
+				flag, r.describeAge(intro, runDate), r.describeExpiry(expiry, runDate),
+				usageSummary(found), note,
+			)
+		case soon:
+			category = CategoryExpiringSoon
+			message = fmt.Sprintf(
+				"Flag '%v', %v, %v; plan its removal%v%v",
+				flag, r.describeAge(intro, runDate), r.describeStaleOn(flag, intro, runDate),
+				usageSummary(found), note,
+			)
+		case !old:
+			category = CategoryRolledOut
+			message = fmt.Sprintf(
+				"Flag '%v', %v, is %v, so its rollout is complete and the code gating "+
+					"on it can be removed%v",
+				flag, r.describeAge(intro, runDate), state, usageSummary(found),
+			)
 		}
 
+		f := Finding{
+			Flag:         flag,
+			Category:     category,
+			Message:      message,
+			IntroducedAt: committedAt,
+			AgeDays:      ageDays(intro.at, runDate),
+			Usages:       found,
+		}
+		if remote {
+			f.State = &state
+		}
+		if !soon {
+			if fix, ok := r.inlineFix(pass, flag, usages); ok {
+				f.fixes = []analysis.SuggestedFix{fix}
+			}
+		}
+		r.report(pass, anchor, runDate, r.annotated(pass, f, intro))
+
+		if r.cfg.ReportDeadCode && !soon && flag.Kind == FlagKindSymbol {
+			if value, ok := resolvedValue(pass, intro, state, remote); ok {
+				r.reportDeadBranches(pass, flag, intro, value, runDate, changed, root)
+			}
+		}
 	}
 
+	if r.cfg.MaxFlagsPerPackage > 0 {
+		r.checkBudget(pass, jobs, intros, root, changed, runDate)
+	}
 	return nil, nil
 }
 
-func (r *runner) findFlagIdents(pass *analysis.Pass) []*ast.Ident {
-	idents := []*ast.Ident{}
+// ageDays returns how many whole days old something from at is on runDate.
+func ageDays(at, runDate time.Time) int {
+	return int(runDate.Sub(truncateToDay(at)).Hours() / 24)
+}
+
+// isOld reports whether a flag introduced at intro is older than its cutoff
+// on runDate.
+func (r *runner) isOld(flag FlagID, intro introduction, runDate time.Time) bool {
+	return intro.beforeWindow || truncateToDay(intro.at).Before(r.cutoff(flag).before(runDate))
+}
+
+// staleOn returns the first day a flag introduced at intro is older than its
+// cutoff.
+func (r *runner) staleOn(flag FlagID, intro introduction) time.Time {
+	return r.cutoff(flag).after(truncateToDay(intro.at)).AddDate(0, 0, 1)
+}
 
-	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
-	nodeFilter := []ast.Node{
-		(*ast.Ident)(nil),
+// expiringSoon reports whether a flag introduced at intro isn't older than
+// its cutoff on runDate, but will be within WarnBefore of it.
+func (r *runner) expiringSoon(flag FlagID, intro introduction, runDate time.Time) bool {
+	if r.warnBefore.isZero() || intro.beforeWindow || r.isOld(flag, intro, runDate) {
+		return false
 	}
-	inspect.Preorder(nodeFilter, func(node ast.Node) {
-		id := node.(*ast.Ident)
-		for _, symbol := range r.cfg.FlagSymbols {
-			// TODO: disambiguate between packages
+	return !r.staleOn(flag, intro).After(r.warnBefore.after(runDate))
+}
 
-			if id.Name == symbol {
-				r.l.Debug().
-					Str("symbol", symbol).
-					Any("pos", pass.Fset.Position(id.NamePos)).
-					Msg("Found usage or declaration of flag symbol")
+// runDate returns the UTC day that flag ages are measured from.
+func (r *runner) runDate(repo *gitRepo, history HistoryProvider) (time.Time, error) {
+	date, err := parseRunDate(r.cfg.RunDate, r.now())
+	if err != nil {
+		return time.Time{}, err
+	}
+	if date.IsPresent() {
+		return date.MustGet(), nil
+	}
+	if r.clockPinned() {
+		return truncateToDay(r.now()), nil
+	}
+	if h, ok := history.(headDater); ok && repo == nil {
+		at, err := h.headDate()
+		return truncateToDay(at), err
+	}
+	if repo == nil {
+		// No HEAD to anchor to
+		return truncateToDay(r.now()), nil
+	}
 
-				idents = append(idents, id)
-			}
-		}
-	})
+	head, err := repo.headHash()
+	if err != nil {
+		return time.Time{}, err
+	}
+	commit, err := repo.CommitObject(head)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return truncateToDay(r.commitTime(commit)), nil
+}
 
-	return idents
+// now returns the current time according to the configured clock.
+func (r *runner) now() time.Time {
+	switch {
+	case r.cfg.Now != nil:
+		return r.cfg.Now()
+	case !r.cfg.ReferenceTime.IsZero():
+		return r.cfg.ReferenceTime
+	}
+	return time.Now()
+}
+
+// clockPinned reports whether the clock is pinned by Now or ReferenceTime.
+func (r *runner) clockPinned() bool {
+	return r.cfg.Now != nil || !r.cfg.ReferenceTime.IsZero()
+}
+
+// parseRunDate parses the RunDate option, with "now" meaning the day of now.
+// It returns None if the date should be taken from the HEAD commit.
+func parseRunDate(s string, now time.Time) (mo.Option[time.Time], error) {
+	switch s {
+	case "", "head":
+		return mo.None[time.Time](), nil
+	case "now":
+		return mo.Some(truncateToDay(now)), nil
+	}
+
+	date, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return mo.None[time.Time](), errors.Errorf(
+			"invalid run date %q, must be head, now, or YYYY-MM-DD", s,
+		)
+	}
+	return mo.Some(date), nil
+}
+
+// truncateToDay returns midnight UTC of the day t falls on.
+func truncateToDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
+
+// reference is a place a tracked flag appears in the code.
+type reference struct {
+	id  FlagID
+	pos token.Pos
+
+	// What to search file contents for to find when the reference was added
+	search string
+
+	// The reference declares the flag, either as a symbol or as the value of
+	// a const or var.
+	declaration bool
+
+	// The reference is a string literal of a key
+	literal bool
+
+	// Where the const the key is used through is declared, if it is
+	constAt token.Pos
+
+	// The detector that found it, if not the symbol or key-literal one: a
+	// registered detector, or SourceEnv for a key read from the environment
+	detector UsageSource
+}
+
+// source returns the detector that found the reference.
+func (ref reference) source() UsageSource {
+	if ref.detector != "" {
+		return ref.detector
+	}
+	if ref.literal {
+		return SourceKeyLiteral
+	}
+	return SourceSymbol
+}
+
+func (r *runner) findFlagRefs(pass *analysis.Pass) []reference {
+	refs := r.references(pass, r.passIDs(pass))
+	for _, ref := range refs {
+		r.l.Debug().
+			Stringer("flag", ref.id).
+			Any("pos", pass.Fset.Position(ref.pos)).
+			Msg("Found usage or declaration of flag")
+	}
+	return refs
 }
 
 func isDeclaration(ident *ast.Ident) bool {
@@ -175,63 +1197,456 @@ func isDeclaration(ident *ast.Ident) bool {
 	return false
 }
 
+// introduction is when a flag was added.
+type introduction struct {
+	at time.Time
+
+	// The flag was already present in the oldest commit searched, so it was
+	// really added some time before at.
+	beforeWindow bool
+
+	// The flag was already present at the boundary of a shallow clone, so we
+	// don't know when it was added.
+	shallow bool
+
+	// The flag was dated by when its file was last modified, because there
+	// is no history to search.
+	byMtime bool
+
+	// Where the flag is declared
+	declaredAt token.Pos
+
+	// Who made the commit that added the flag, as "Name <email>", and its
+	// hash and subject line, if known
+	author  string
+	commit  string
+	subject string
+}
+
 // Given some symbol, find the commit where it was added and return the Time of
 // the commit.
 func (r *runner) timeCommitted(
-	repo *git.Repository, symbol string, pos token.Position,
-) mo.Option[time.Time] {
-	iter, err := repo.Log(&git.LogOptions{
-		// Until: &r.cfg.Cutoff, // TODO: reinstate this
+	repo *gitRepo, symbol, searchFileName string,
+) (mo.Option[introduction], error) {
+	// Reading files is what's slow, so the commits are listed first and only
+	// read as the search needs them
+	commits := []*object.Commit{}
+	truncated, err := r.walkHistory(repo, func(commit *object.Commit) error {
+		commits = append(commits, commit)
+		return nil
 	})
 	if err != nil {
-		panic(err) // TODO:
-		return mo.None[time.Time]()
+		return mo.None[introduction](), errors.Wrap(err, "walk history")
 	}
 
-	timestamp := mo.None[time.Time]()
+	// Commits that didn't change the file have the same blob of it as the
+	// commit before, so each version of the file is only read once
+	found := map[plumbing.Hash]bool{}
+	contains := func(i int) (bool, error) {
+		tree, entry, err := r.entryIn(commits[i], searchFileName)
+		if err != nil || entry == nil {
+			return false, err
+		}
+		if in, ok := found[entry.Hash]; ok {
+			return in, nil
+		}
+		file, err := tree.TreeEntryFile(entry)
+		if err != nil {
+			return false, err
+		}
+		contents, err := file.Contents()
+		if err != nil {
+			return false, err
+		}
+		r.progress.reads.Add(1)
+		found[entry.Hash] = strings.Contains(contents, symbol)
+		return found[entry.Hash], nil
+	}
+	oldest, err := oldestContaining(commits, contains)
+	if err != nil {
+		return mo.None[introduction](), errors.Wrap(err, "walk history")
+	}
+	if oldest < 0 {
+		return mo.None[introduction](), nil
+	}
+
+	commit := commits[oldest]
+	r.l.Debug().
+		Str("symbol", symbol).
+		Str("file", searchFileName).
+		Str("commit", commit.Hash.String()).
+		Str("when", r.commitTime(commit).String()).
+		Int("versions", len(found)).
+		Int("commits", len(commits)).
+		Msg("Symbol found in commit")
+	return mo.Some(introduction{
+		at:           r.commitTime(commit),
+		beforeWindow: truncated && parentsMissing(commit, commits),
+		shallow:      repo.shallow[commit.Hash],
+		author:       signature(commit.Author.Name, commit.Author.Email),
+		commit:       commit.Hash.String(),
+		subject:      firstLine(commit.Message),
+	}), nil
+}
 
-	iter.ForEach(func(commit *object.Commit) error {
-		var file *object.File
-		iter, err := commit.Files()
+// oldestContaining returns the index of the oldest of commits, which are
+// newest first, whose file contains the symbol, or -1 if none does. Once
+// added, a symbol stays in its file until the flag is removed, so along the
+// first parents from the newest commit, the commits having it are the newest
+// ones, and the oldest is found by binary search. If that is a merge whose
+// first parent doesn't have it, the symbol came from a merged branch, which
+// is searched the same way. Only if the newest commit doesn't have it, which
+// contradicts the search, is every commit checked.
+//
+// A symbol removed and added back is dated from when it was first added if it
+// was already there in the oldest commit searched, and otherwise may be dated
+// from when it was added back.
+func oldestContaining(
+	commits []*object.Commit, contains func(i int) (bool, error),
+) (int, error) {
+	if len(commits) == 0 {
+		return -1, nil
+	}
+	in, err := contains(0)
+	if err != nil {
+		return -1, err
+	}
+	if !in {
+		return scanContaining(commits, contains)
+	}
+
+	index := make(map[plumbing.Hash]int, len(commits))
+	for i, commit := range commits {
+		index[commit.Hash] = i
+	}
+	from := 0
+	for {
+		chain := firstParents(commits, index, from)
+		oldest, err := bisectChain(chain, contains)
 		if err != nil {
-			return err
+			return -1, err
 		}
 
-		// Chop off everything before the base of the repo path to compare just
-		// the relative path.
-		searchFileName := strings.TrimPrefix(pos.Filename, r.cfg.RepoPath+"/")
-		err = iter.ForEach(func(f *object.File) error {
-			if f.Name == searchFileName {
-				file = f
-				return io.EOF
+		merged := -1
+		for n, parent := range commits[oldest].ParentHashes {
+			i, ok := index[parent]
+			if n == 0 || !ok {
+				continue
 			}
-			return nil
-		})
-
-		// If the file is found, search for the symbol within the file
-		if file != nil {
-			// TODO: we should only check changes to the file, not the whole file
-			contents, err := file.Contents()
+			in, err := contains(i)
 			if err != nil {
-				return err
+				return -1, err
 			}
-			if strings.Contains(contents, symbol) {
-				// The symbol was found in this commit, so return the commit timestamp
-				r.l.Debug().
-					Str("symbol", symbol).
-					Str("file", pos.Filename).
-					Str("commit", commit.Hash.String()).
-					Str("when", commit.Author.When.String()).
-					Msg("Symbol found in commit")
-				timestamp = mo.Some[time.Time](commit.Author.When)
-				return nil
+			if in {
+				merged = i
+				break
 			}
 		}
+		if merged < 0 {
+			return oldest, nil
+		}
+		from = merged
+	}
+}
+
+// parentsMissing reports whether a parent of commit isn't in commits, so
+// what it changed is unknown.
+func parentsMissing(commit *object.Commit, commits []*object.Commit) bool {
+	listed := map[plumbing.Hash]bool{}
+	for _, c := range commits {
+		listed[c.Hash] = true
+	}
+	for _, parent := range commit.ParentHashes {
+		if !listed[parent] {
+			return true
+		}
+	}
+	return false
+}
+
+// firstParents returns the indexes of the commits reached from commits[from]
+// by following first parents, as far as commits goes.
+func firstParents(commits []*object.Commit, index map[plumbing.Hash]int, from int) []int {
+	chain := []int{from}
+	for {
+		parents := commits[chain[len(chain)-1]].ParentHashes
+		if len(parents) == 0 {
+			return chain
+		}
+		i, ok := index[parents[0]]
+		if !ok {
+			return chain
+		}
+		chain = append(chain, i)
+	}
+}
+
+// bisectChain returns the index of the oldest commit of chain, a line of
+// first parents whose newest commit has the symbol, that has it, assuming the
+// ones having it are the newest.
+func bisectChain(chain []int, contains func(i int) (bool, error)) (int, error) {
+	last := chain[len(chain)-1]
+	in, err := contains(last)
+	if err != nil || in {
+		return last, err
+	}
 
+	// The newest commit has it, and the oldest doesn't
+	has, hasNot := 0, len(chain)-1
+	for hasNot-has > 1 {
+		mid := has + (hasNot-has)/2
+		in, err := contains(chain[mid])
+		if err != nil {
+			return -1, err
+		}
+		if in {
+			has = mid
+		} else {
+			hasNot = mid
+		}
+	}
+	return chain[has], nil
+}
+
+// scanContaining returns the index of the oldest of commits whose file
+// contains the symbol, or -1 if none does, checking every one.
+func scanContaining(commits []*object.Commit, contains func(i int) (bool, error)) (int, error) {
+	oldest := -1
+	for i := range commits {
+		in, err := contains(i)
+		if err != nil {
+			return -1, err
+		}
+		if in {
+			oldest = i
+		}
+	}
+	return oldest, nil
+}
+
+// timeModified finds the newest commit that changed a line of searchFileName
+// containing symbol, by walking back until the lines containing it differ
+// from the ones in the commit after.
+func (r *runner) timeModified(
+	repo *gitRepo, symbol, searchFileName string,
+) (mo.Option[introduction], error) {
+	var newer *object.Commit
+	var newerLines []string
+	var newerBlob plumbing.Hash
+	changed := false
+	truncated, err := r.walkHistory(repo, func(commit *object.Commit) error {
+		_, entry, err := r.entryIn(commit, searchFileName)
+		if err != nil {
+			return err
+		}
+		blob := plumbing.ZeroHash
+		if entry != nil {
+			blob = entry.Hash
+		}
+		if newer != nil && blob == newerBlob {
+			// The commit didn't change the file, so its lines are the same
+			newer = commit
+			return nil
+		}
+		newerBlob = blob
+
+		lines, err := r.linesWith(commit, searchFileName, symbol)
+		if err != nil {
+			return err
+		}
+		if newer != nil && !equalLines(lines, newerLines) {
+			changed = true
+			return storer.ErrStop
+		}
+		newer, newerLines = commit, lines
 		return nil
 	})
+	if err != nil {
+		return mo.None[introduction](), errors.Wrap(err, "walk history")
+	}
+	if newer == nil || !changed && len(newerLines) == 0 {
+		return mo.None[introduction](), nil
+	}
+
+	r.l.Debug().
+		Str("symbol", symbol).
+		Str("file", searchFileName).
+		Str("commit", newer.Hash.String()).
+		Str("when", r.commitTime(newer).String()).
+		Msg("Symbol last changed in commit")
+	return mo.Some(introduction{
+		at:           r.commitTime(newer),
+		beforeWindow: !changed && truncated,
+		shallow:      repo.shallow[newer.Hash],
+		author:       signature(newer.Author.Name, newer.Author.Email),
+		commit:       newer.Hash.String(),
+		subject:      firstLine(newer.Message),
+	}), nil
+}
+
+// linesWith returns the lines of the file at path in commit that contain
+// symbol, or nil if the file isn't in the commit.
+func (r *runner) linesWith(commit *object.Commit, path, symbol string) ([]string, error) {
+	file, err := r.fileIn(commit, path)
+	if err != nil || file == nil {
+		return nil, err
+	}
+	lines, err := file.Lines()
+	if err != nil {
+		return nil, err
+	}
+	r.progress.reads.Add(1)
+
+	found := []string{}
+	for _, line := range lines {
+		if strings.Contains(line, symbol) {
+			found = append(found, line)
+		}
+	}
+	return found, nil
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// fileIn returns the file at path in commit, or nil if there isn't one.
+func (r *runner) fileIn(commit *object.Commit, path string) (*object.File, error) {
+	tree, entry, err := r.entryIn(commit, path)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	return tree.TreeEntryFile(entry)
+}
+
+// entryIn returns the entry of the file at path in commit, and the tree of
+// the directory it is in, or nil if there isn't one. Only the trees on the
+// way to it are read, not the contents of any file, so telling whether
+// commits changed a file by its hash is cheap.
+func (r *runner) entryIn(
+	commit *object.Commit, path string,
+) (*object.Tree, *object.TreeEntry, error) {
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, nil, err
+	}
+	names := strings.Split(path, "/")
+	for i, name := range names {
+		var entry *object.TreeEntry
+		for j := range tree.Entries {
+			if r.samePath(tree.Entries[j].Name, name) {
+				entry = &tree.Entries[j]
+				break
+			}
+		}
+		switch {
+		case entry == nil:
+			return nil, nil, nil
+		case i == len(names)-1:
+			if !entry.Mode.IsFile() {
+				return nil, nil, nil
+			}
+			return tree, entry, nil
+		case entry.Mode != filemode.Dir:
+			return nil, nil, nil
+		}
+		if tree, err = tree.Tree(entry.Name); err != nil {
+			return nil, nil, err
+		}
+	}
+	return nil, nil, nil
+}
+
+// commitTime returns the date of the commit according to the configured
+// DateSource.
+func (r *runner) commitTime(commit *object.Commit) time.Time {
+	if r.cfg.DateSource == DateSourceCommitter {
+		return commit.Committer.When
+	}
+	return commit.Author.When
+}
+
+// walkHistory calls fn for every commit reachable from HEAD, newest first. If
+// FirstParent is set, only the first parent of each merge commit is followed.
+// The walk stops early at MaxHistoryDepth commits or when it reaches commits
+// older than HistorySince, in which case truncated is true.
+func (r *runner) walkHistory(
+	repo *gitRepo, fn func(*object.Commit) error,
+) (truncated bool, err error) {
+	visited := 0
+	bounded := func(commit *object.Commit) error {
+		if r.cfg.MaxHistoryDepth > 0 && visited >= r.cfg.MaxHistoryDepth {
+			truncated = true
+			return storer.ErrStop
+		}
+		if !r.cfg.HistorySince.IsZero() && commit.Committer.When.Before(r.cfg.HistorySince) {
+			truncated = true
+			return storer.ErrStop
+		}
+		visited++
+		r.progress.commits.Add(1)
+		return fn(commit)
+	}
+
+	if !r.cfg.FirstParent {
+		opts := &git.LogOptions{From: repo.head}
+		if !r.cfg.HistorySince.IsZero() {
+			// Stopping at the first commit older than HistorySince is only
+			// right if we see commits in date order.
+			opts.Order = git.LogOrderCommitterTime
+		}
+		iter, err := repo.Log(opts)
+		if err != nil {
+			return false, err
+		}
+		err = iter.ForEach(bounded)
+		if errors.Is(err, plumbing.ErrObjectNotFound) && len(repo.shallow) > 0 {
+			// Shallow clones are missing the parents of their oldest commits
+			err = nil
+		}
+		return truncated, err
+	}
+
+	head, err := repo.headHash()
+	if err != nil {
+		return false, err
+	}
+	commit, err := repo.CommitObject(head)
+	if err != nil {
+		return false, err
+	}
+
+	for {
+		if err := bounded(commit); err != nil {
+			if err == storer.ErrStop {
+				return truncated, nil
+			}
+			return truncated, err
+		}
+		if commit.NumParents() == 0 {
+			return truncated, nil
+		}
+		commit, err = commit.Parent(0)
+		if err == plumbing.ErrObjectNotFound {
+			// Shallow clones are missing the parents of their oldest commits
+			return truncated, nil
+		} else if err != nil {
+			return truncated, err
+		}
+	}
+}
 
-	return timestamp
+// relPath returns the path of a file relative to the checkout it is in.
+func (r *runner) relPath(filename string) string {
+	return r.relTo(r.topRoot(filename), filename)
 }
 
 func hasKey[K comparable, V any](m map[K]V, k K) bool {
@@ -1,30 +1,2342 @@
 package flagexorcist_test
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/dgunay/flag-exorcist/flagexorcist"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"golang.org/x/tools/go/analysis"
 	"golang.org/x/tools/go/analysis/analysistest"
+	"golang.org/x/tools/go/packages"
 )
 
+// testdataDir returns the path of elem in the repo's testdata directory.
+func testdataDir(t *testing.T, elem ...string) string {
+	t.Helper()
+	workDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get wd: %s", err)
+	}
+	return filepath.Join(append([]string{filepath.Dir(workDir), "testdata"}, elem...)...)
+}
+
+// initialize configures the analyzer with cfg, dating flags by this repo's
+// history unless it sets a RepoPath. Its zero LogLevel logs everything.
+//
+// The analyzer is configured globally, so tests that call this, directly or
+// through analyze, aren't parallel: they run one at a time, before TestAll,
+// the only parallel test that configures it. Those that use run also set
+// GOPATH, which parallel tests can't do.
+func initialize(t *testing.T, cfg flagexorcist.Config) {
+	t.Helper()
+	if cfg.RepoPath == "" {
+		cfg.RepoPath = ".."
+	}
+	if err := flagexorcist.Initialize(cfg); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+}
+
+// analyze configures the analyzer with cfg and checks its diagnostics for
+// every package in testdata/dir against their want comments.
+func analyze(t *testing.T, dir string, cfg flagexorcist.Config) {
+	t.Helper()
+	initialize(t, cfg)
+	analysistest.Run(t, testdataDir(t, dir), flagexorcist.Analyzer, "./src/...")
+}
+
+// run runs the analyzer in-process over the packages matching patterns in
+// the GOPATH at gopath.
+func run(t *testing.T, gopath string, patterns ...string) []flagexorcist.Finding {
+	t.Helper()
+	t.Setenv("GO111MODULE", "off")
+	t.Setenv("GOPATH", gopath)
+	findings, err := flagexorcist.Run(patterns...)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	return findings
+}
+
+// failing returns whether the findings about each flag fail the run.
+func failing(findings []flagexorcist.Finding) map[flagexorcist.FlagID]bool {
+	fails := map[flagexorcist.FlagID]bool{}
+	for _, f := range findings {
+		fails[f.Flag] = fails[f.Flag] || f.Fails()
+	}
+	return fails
+}
+
+// allConfig is how TestAll configures the analyzer for the packages in
+// testdata/src.
+func allConfig() flagexorcist.Config {
+	return flagexorcist.Config{
+		Cutoff:         "0",
+		FlagSymbols:    []string{"MyFlag"},
+		FlagKeys:       []string{"new-checkout"},
+		ExcludePaths:   []string{"**/mocks/**"},
+		IgnoreTests:    true,
+		ReportUnused:   true,
+		ReportTestOnly: true,
+		TicketPattern:  `[A-Z]+-[0-9]+`,
+		RunDate:        "2100-01-01",
+	}
+}
+
 func TestAll(t *testing.T) {
 	t.Parallel()
 
-	workDir, err := os.Getwd()
+	cfg := allConfig()
+	cfg.GitConcurrency = 4
+	initialize(t, cfg)
+
+	analysistest.RunWithSuggestedFixes(
+		t, testdataDir(t), flagexorcist.Analyzer, "./src/...", "vendored/vendor/flags",
+	)
+}
+
+func TestExecBackend(t *testing.T) {
+	cfg := allConfig()
+	cfg.GitBackend = flagexorcist.GitBackendExec
+	initialize(t, cfg)
+
+	analysistest.Run(t, testdataDir(t), flagexorcist.Analyzer, "./src/...")
+}
+
+func TestFixtures(t *testing.T) {
+	tests := []struct {
+		name string
+		// The directory of testdata the packages are in, and the patterns
+		// of the ones to check, ./src/... if empty
+		dir      string
+		patterns []string
+		cfg      flagexorcist.Config
+		// Check the suggested fixes against the golden files too
+		fixes bool
+	}{
+		{
+			name: "message template",
+			dir:  "templates",
+			cfg: flagexorcist.Config{
+				Cutoff:        "0",
+				FlagSymbols:   []string{"MyFlag"},
+				RunDate:       "2100-01-01",
+				TicketPattern: `[A-Z]+-[0-9]+`,
+				MessageTemplate: "{{.Symbol}} ({{.Flag.Kind}}) is {{.AgeDays}} days old, " +
+					"past the {{.CutoffDays}} day cutoff; remove it ({{.Ticket}})",
+			},
+		},
+		{
+			// Stale, but nothing has changed since HEAD so it isn't reported
+			name: "since ref",
+			dir:  "typos",
+			cfg: flagexorcist.Config{
+				Cutoff:      "0",
+				FlagSymbols: []string{"MyFlag"},
+				RunDate:     "2100-01-01",
+				SinceRef:    "HEAD",
+			},
+		},
+		{
+			name: "last modified, go-git",
+			dir:  "modified",
+			cfg: flagexorcist.Config{
+				Cutoff:      "0",
+				FlagSymbols: []string{"MyFlag"},
+				RunDate:     "2100-01-01",
+				GitBackend:  flagexorcist.GitBackendGoGit,
+				AgeMetric:   flagexorcist.AgeMetricLastModified,
+			},
+		},
+		{
+			name: "last modified, exec",
+			dir:  "modified",
+			cfg: flagexorcist.Config{
+				Cutoff:      "0",
+				FlagSymbols: []string{"MyFlag"},
+				RunDate:     "2100-01-01",
+				GitBackend:  flagexorcist.GitBackendExec,
+				AgeMetric:   flagexorcist.AgeMetricLastModified,
+			},
+		},
+		{
+			// None of the flags are stale, but there are too many of them
+			name: "flag budget",
+			dir:  "budget",
+			cfg: flagexorcist.Config{
+				Cutoff:             "36500d",
+				FlagSymbols:        []string{"EnableSearch", "EnableCheckout", "EnableReviews"},
+				MaxFlagsPerPackage: 2,
+			},
+		},
+		{
+			name: "flag tag",
+			dir:  "tags",
+			cfg: flagexorcist.Config{
+				Cutoff:   "0",
+				FlagKeys: []string{"new-checkout"},
+				FlagTag:  "flag",
+				RunDate:  "2100-01-01",
+			},
+		},
+		{
+			name:     "inline fix",
+			dir:      "inline",
+			patterns: []string{"checkout"},
+			cfg: flagexorcist.Config{
+				Cutoff:      "0",
+				FlagSymbols: []string{"EnableCheckout", "CheckoutName", "EnableSearch"},
+				FlagKeys:    []string{"new-reviews"},
+				FlagTag:     "flag",
+				RunDate:     "2100-01-01",
+			},
+			fixes: true,
+		},
+		{
+			name: "flag maps",
+			dir:  "maps",
+			cfg: flagexorcist.Config{
+				Cutoff:   "0",
+				FlagMaps: []string{"flags.Defaults"},
+				RunDate:  "2100-01-01",
+			},
+		},
+		{
+			name: "config funcs",
+			dir:  "config",
+			cfg: flagexorcist.Config{
+				Cutoff:          "0",
+				ConfigFuncs:     []string{"viper.GetBool"},
+				ConfigKeyPrefix: "features.",
+				RunDate:         "2100-01-01",
+			},
+		},
+		{
+			name: "env flags",
+			dir:  "env",
+			cfg: flagexorcist.Config{
+				Cutoff:        "0",
+				EnvFlagPrefix: "FEATURE_",
+				RunDate:       "2100-01-01",
+			},
+		},
+		{
+			// Without ENV_FLAG_PREFIX, environment lookups listed in
+			// CONFIG_FUNCS are matched by CONFIG_KEY_PREFIX instead, and are
+			// still env usages
+			name: "env lookups as config funcs",
+			dir:  "env",
+			cfg: flagexorcist.Config{
+				Cutoff:          "0",
+				ConfigFuncs:     []string{"os.Getenv", "os.LookupEnv"},
+				ConfigKeyPrefix: "FEATURE_",
+				RunDate:         "2100-01-01",
+			},
+		},
+		{
+			// The keys are only declared in another package than the one
+			// using them
+			name:     "const keys",
+			dir:      "consts",
+			patterns: []string{"./src/app"},
+			cfg: flagexorcist.Config{
+				Cutoff:          "0",
+				FlagKeys:        []string{"new-checkout"},
+				ConfigFuncs:     []string{"config.GetBool"},
+				ConfigKeyPrefix: "features.",
+				RunDate:         "2100-01-01",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			initialize(t, tt.cfg)
+			patterns := tt.patterns
+			if len(patterns) == 0 {
+				patterns = []string{"./src/..."}
+			}
+			if tt.fixes {
+				analysistest.RunWithSuggestedFixes(t, testdataDir(t, tt.dir), flagexorcist.Analyzer, patterns...)
+			} else {
+				analysistest.Run(t, testdataDir(t, tt.dir), flagexorcist.Analyzer, patterns...)
+			}
+		})
+	}
+}
+
+// TestFailingFlags checks which flags fail the run when some of their
+// findings are only logged.
+func TestFailingFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		// The directory of testdata the packages are in, and the ones to run
+		// the analyzer over
+		dir      string
+		patterns []string
+		cfg      flagexorcist.Config
+		// Whether the findings about each flag fail the run
+		want map[flagexorcist.FlagID]bool
+		// Checks anything else about the findings
+		check func(t *testing.T, findings []flagexorcist.Finding)
+	}{
+		{
+			// Flags in their grace period are still found, but don't fail
+			// the run
+			name:     "expiry grace period",
+			dir:      "grace",
+			patterns: []string{"grace"},
+			cfg: flagexorcist.Config{
+				Cutoff:            "36500d",
+				FlagSymbols:       []string{"EnableWishlist"},
+				FlagRegistry:      testdataDir(t, "grace", "flags.yaml"),
+				ExpiryGracePeriod: 30 * 24 * time.Hour,
+				RunDate:           "2100-01-01",
+			},
+			want: map[flagexorcist.FlagID]bool{
+				flagexorcist.SymbolID("EnableSearch"):   false,
+				flagexorcist.SymbolID("EnableReviews"):  true,
+				flagexorcist.SymbolID("EnableWishlist"): true,
+			},
+			check: func(t *testing.T, findings []flagexorcist.Finding) {
+				for _, f := range findings {
+					if f.Flag == flagexorcist.SymbolID("EnableSearch") &&
+						f.Category != flagexorcist.CategoryGracePeriod {
+						t.Errorf("EnableSearch is %s, want it in its grace period", f.Category)
+					}
+				}
+			},
+		},
+		{
+			// Snoozed findings are still found, but don't fail the run
+			name:     "snooze file",
+			dir:      "snooze",
+			patterns: []string{"snooze"},
+			cfg: flagexorcist.Config{
+				Cutoff:      "0",
+				FlagSymbols: []string{"EnableSearch", "EnableReviews"},
+				SnoozeFile:  testdataDir(t, "snooze", "snooze.yaml"),
+				RunDate:     "2100-01-01",
+			},
+			want: map[flagexorcist.FlagID]bool{
+				flagexorcist.SymbolID("EnableSearch"):  false,
+				flagexorcist.SymbolID("EnableReviews"): true,
+			},
+			check: func(t *testing.T, findings []flagexorcist.Finding) {
+				for _, f := range flagexorcist.DatedFlags() {
+					snoozed := f.Flag == flagexorcist.SymbolID("EnableSearch")
+					if (f.Snoozed != nil) != snoozed {
+						t.Errorf("%v snoozed = %v, want %v", f.Flag, f.Snoozed, snoozed)
+					}
+				}
+			},
+		},
+		{
+			// Only the flags in the onboarded directory and the one nested
+			// in it fail the run. The others are report-only.
+			name:     "onboarding file",
+			dir:      "onboarding",
+			patterns: []string{"payments/...", "paymentsold", "checkout"},
+			cfg: flagexorcist.Config{
+				Cutoff:         "0",
+				FlagSymbols:    []string{"EnablePayments", "EnableLegacy", "EnableOld", "EnableCheckout"},
+				OnboardingFile: testdataDir(t, "onboarding", "onboarded.txt"),
+				RunDate:        "2100-01-01",
+			},
+			want: map[flagexorcist.FlagID]bool{
+				flagexorcist.SymbolID("EnablePayments"): true,
+				flagexorcist.SymbolID("EnableLegacy"):   true,
+				flagexorcist.SymbolID("EnableOld"):      false,
+				flagexorcist.SymbolID("EnableCheckout"): false,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Only the findings that fail the run are diagnostics
+			analyze(t, tt.dir, tt.cfg)
+
+			findings := run(t, testdataDir(t, tt.dir), tt.patterns...)
+			if fails := failing(findings); !reflect.DeepEqual(fails, tt.want) {
+				t.Errorf("Run() failing flags = %v, want %v", fails, tt.want)
+			}
+			if tt.check != nil {
+				tt.check(t, findings)
+			}
+		})
+	}
+}
+
+// fixedHistory dates every symbol to the same day.
+type fixedHistory time.Time
+
+func (h fixedHistory) IntroducedAt(file, symbol string) (time.Time, bool) {
+	return time.Time(h), true
+}
+
+func TestHistoryProvider(t *testing.T) {
+	day := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	flagexorcist.RegisterHistoryProvider("fixed",
+		func(flagexorcist.Config, string) (flagexorcist.HistoryProvider, error) {
+			return fixedHistory(day), nil
+		},
+	)
+
+	// Everything in the repo is far newer than the run date, so findings
+	// only turn up if the provider is used.
+	cfg := allConfig()
+	cfg.RunDate = "2000-01-05"
+	cfg.GitBackend = "fixed"
+	initialize(t, cfg)
+
+	analysistest.Run(t, testdataDir(t), flagexorcist.Analyzer, "./src/...")
+}
+
+func TestReferenceTime(t *testing.T) {
+	// Only stale because the clock is pinned decades ahead, which wins over
+	// the date of HEAD
+	future := time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+	for name, cfg := range map[string]flagexorcist.Config{
+		"Now":           {RunDate: "now", Now: func() time.Time { return future }},
+		"ReferenceTime": {RunDate: "head", ReferenceTime: future},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg.Cutoff = "18250d"
+			cfg.FlagSymbols = []string{"MyFlag"}
+			analyze(t, "clock", cfg)
+
+			dated := flagexorcist.DatedFlags()
+			if len(dated) != 1 {
+				t.Fatalf("DatedFlags() = %v, want just MyFlag", dated)
+			}
+			if f := dated[0]; f.Flag != flagexorcist.SymbolID("MyFlag") || !f.Stale || f.Usages != 1 {
+				t.Errorf("DatedFlags() = %+v, want MyFlag stale with 1 usage", f)
+			}
+		})
+	}
+}
+
+func TestUnfoundSymbols(t *testing.T) {
+	analyze(t, "typos", flagexorcist.Config{
+		Cutoff:      "36500d",
+		FlagSymbols: []string{"MyFlag", "MyFlg", "typos.MyFlag", "Unrelated"},
+	})
+
+	want := []flagexorcist.UnfoundSymbol{
+		{
+			Symbol:     flagexorcist.SymbolID("MyFlg"),
+			Suggestion: flagexorcist.SymbolID("MyFlag"),
+		},
+		{Symbol: flagexorcist.SymbolID("Unrelated")},
+	}
+	if got := flagexorcist.UnfoundSymbols(); !reflect.DeepEqual(got, want) {
+		t.Errorf("UnfoundSymbols() = %v, want %v", got, want)
+	}
+}
+
+func TestBuildConstraints(t *testing.T) {
+	// enableSearch is only used in a file behind the integration tag, so it
+	// may not be unused
+	cfg := flagexorcist.Config{
+		Cutoff:       "0",
+		FlagSymbols:  []string{"enableSearch", "enableReviews"},
+		ReportUnused: true,
+		RunDate:      "2100-01-01",
+	}
+	analyze(t, "constraints", cfg)
+
+	// With the tag, that usage is found
+	cfg.BuildTags = []string{"integration"}
+	initialize(t, cfg)
+	run(t, testdataDir(t, "constraints"), "constraints")
+	usages := map[flagexorcist.FlagID]int{}
+	for _, f := range flagexorcist.DatedFlags() {
+		usages[f.Flag] = f.Usages
+	}
+	if n := usages[flagexorcist.SymbolID("enableSearch")]; n != 1 {
+		t.Errorf("enableSearch has %d usages with the integration tag, want 1", n)
+	}
+}
+
+// toggleDetector finds the keys passed to Toggle methods, standing in for a
+// flag system the analyzer doesn't know about.
+type toggleDetector struct{}
+
+func (toggleDetector) Detect(pass *analysis.Pass) []flagexorcist.Detection {
+	found := []flagexorcist.Detection{}
+	for _, file := range pass.Files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok || len(call.Args) != 1 {
+				return true
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			lit, isLit := call.Args[0].(*ast.BasicLit)
+			if !ok || sel.Sel.Name != "Toggle" || !isLit {
+				return true
+			}
+			key, _ := strconv.Unquote(lit.Value)
+			found = append(found, flagexorcist.Detection{
+				Flag: flagexorcist.KeyID(key), Pos: lit.Pos(), Search: lit.Value, Literal: true,
+			})
+			return true
+		})
+	}
+	return found
+}
+
+func TestRegisterDetector(t *testing.T) {
+	flagexorcist.RegisterDetector("toggle", func(flagexorcist.Config) (flagexorcist.Detector, error) {
+		return toggleDetector{}, nil
+	})
+
+	// No flags are configured, the detector finds them
+	cfg := flagexorcist.Config{
+		Cutoff:    "0",
+		Detectors: []string{"toggle"},
+		RunDate:   "2100-01-01",
+	}
+	analyze(t, "detector", cfg)
+
+	findings := run(t, testdataDir(t, "detector"), "detector")
+	if len(findings) != 1 || len(findings[0].Usages) != 1 ||
+		findings[0].Usages[0].Source != "toggle" {
+		t.Errorf("Run() = %+v, want a usage of new-checkout found by toggle", findings)
+	}
+}
+
+func TestLaunchDarkly(t *testing.T) {
+	flags := map[string]string{
+		"checkout-v2": `{
+			"key": "checkout-v2",
+			"variations": [{"value": true}, {"value": false}],
+			"environments": {"production": {
+				"on": true,
+				"fallthrough": {"rollout": {"variations": [
+					{"variation": 0, "weight": 100000}, {"variation": 1, "weight": 0}
+				]}},
+				"targets": [{"variation": 0, "values": ["alice"]}]
+			}}
+		}`,
+		"search-v2": `{
+			"key": "search-v2",
+			"archived": true,
+			"variations": [{"value": true}, {"value": false}],
+			"environments": {"production": {"on": false, "offVariation": 1}}
+		}`,
+		"reviews-v2": `{
+			"key": "reviews-v2",
+			"variations": [{"value": true}, {"value": false}],
+			"environments": {"production": {
+				"on": true,
+				"fallthrough": {"variation": 1},
+				"rules": [{"variation": 0}]
+			}}
+		}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if got := req.Header.Get("Authorization"); got != "api-token" {
+			t.Errorf("Authorization = %q, want the token", got)
+		}
+		if got := req.URL.Query().Get("env"); got != "production" {
+			t.Errorf("env = %q, want production", got)
+		}
+		key := strings.TrimPrefix(req.URL.Path, "/api/v2/flags/default/")
+		if key == "elsewhere" {
+			t.Errorf("looked up %q, which belongs to another flag system", key)
+		}
+		body, ok := flags[key]
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		fmt.Fprint(w, body)
+	}))
+	defer server.Close()
+
+	// None of the flags are stale, but some are done rolling out
+	analyze(t, "launchdarkly", flagexorcist.Config{
+		Cutoff: "36500d",
+		FlagKeys: []string{
+			"checkout-v2", "launchdarkly:search-v2", "reviews-v2", "missing", "unleash:elsewhere",
+		},
+		LaunchDarklyAPIToken:    "api-token",
+		LaunchDarklyProject:     "default",
+		LaunchDarklyEnvironment: "production",
+		LaunchDarklyAPIURL:      server.URL,
+	})
+}
+
+func TestUnleash(t *testing.T) {
+	features := map[string]string{
+		"checkout-v2": `{"name": "checkout-v2", "environments": [
+			{"name": "development", "enabled": false},
+			{"name": "production", "enabled": true, "strategies": [
+				{"name": "userWithId", "parameters": {"userIds": "alice"}},
+				{"name": "flexibleRollout", "parameters": {"rollout": "100"}}
+			]}
+		]}`,
+		"reviews-v2": `{"name": "reviews-v2", "environments": [
+			{"name": "production", "enabled": true, "strategies": [
+				{"name": "flexibleRollout", "parameters": {"rollout": "50"}},
+				{"name": "default", "constraints": [{"contextName": "region"}]}
+			]}
+		]}`,
+		"wishlist": `{"name": "wishlist", "environments": [
+			{"name": "production", "enabled": false, "strategies": [{"name": "default"}]}
+		]}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if got := req.Header.Get("Authorization"); got != "api-token" {
+			t.Errorf("Authorization = %q, want the token", got)
+		}
+		if req.URL.Path == "/api/admin/archive/features/default" {
+			fmt.Fprint(w, `{"features": [{"name": "search-v2", "archived": true}]}`)
+			return
+		}
+		name := strings.TrimPrefix(req.URL.Path, "/api/admin/projects/default/features/")
+		body, ok := features[name]
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		fmt.Fprint(w, body)
+	}))
+	defer server.Close()
+
+	// None of the flags are stale, but some are done rolling out
+	analyze(t, "unleash", flagexorcist.Config{
+		Cutoff:             "36500d",
+		FlagKeys:           []string{"checkout-v2", "unleash:search-v2", "reviews-v2", "wishlist"},
+		UnleashAPIURL:      server.URL,
+		UnleashAPIToken:    "api-token",
+		UnleashProject:     "default",
+		UnleashEnvironment: "production",
+	})
+}
+
+// customState is a FlagStateProvider for a homegrown flag system.
+type customState map[string]flagexorcist.FlagState
+
+func (customState) Name() string {
+	return "custom"
+}
+
+func (s customState) FlagState(ctx context.Context, key string) (flagexorcist.FlagState, error) {
+	state, ok := s[key]
+	if !ok {
+		return state, fmt.Errorf("no flag %s", key)
+	}
+	return state, nil
+}
+
+func TestFlagStateProviders(t *testing.T) {
+	responses := map[string]string{
+		"/internal/api/v2/splits/ws/ws-1/checkout-v2/environments/Production": `{
+			"name": "checkout-v2",
+			"treatments": [{"name": "on", "keys": ["alice"]}, {"name": "off"}],
+			"rules": [{"buckets": [{"treatment": "on", "size": 100}]}],
+			"defaultRule": [{"treatment": "on", "size": 100}, {"treatment": "off", "size": 0}]
+		}`,
+		"/internal/api/v2/splits/ws/ws-1/wishlist/environments/Production": `{
+			"name": "wishlist",
+			"treatments": [{"name": "on"}, {"name": "off"}],
+			"defaultRule": [{"treatment": "on", "size": 10}, {"treatment": "off", "size": 90}]
+		}`,
+		"/v1/configs/config-1/environments/env-1/settings/search-v2/value": `{
+			"setting": {"key": "search-v2"},
+			"value": true,
+			"rolloutRules": [{"value": true}]
+		}`,
+		"/v1/configs/config-1/environments/env-1/settings/ramping/value": `{
+			"setting": {"key": "ramping"},
+			"value": false,
+			"rolloutPercentageItems": [{"percentage": 20, "value": true}, {"percentage": 80, "value": false}]
+		}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if strings.HasPrefix(req.URL.Path, "/internal/") {
+			if got := req.Header.Get("Authorization"); got != "Bearer split-key" {
+				t.Errorf("Authorization = %q, want the Split key", got)
+			}
+		} else if user, password, _ := req.BasicAuth(); user != "user" || password != "pass" {
+			t.Errorf("basic auth = %q:%q, want the ConfigCat credentials", user, password)
+		}
+		body, ok := responses[req.URL.Path]
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		fmt.Fprint(w, body)
+	}))
+	defer server.Close()
+
+	// None of the flags are stale, but some are done rolling out
+	analyze(t, "providers", flagexorcist.Config{
+		Cutoff: "36500d",
+		FlagKeys: []string{
+			"split:checkout-v2", "configcat:search-v2", "custom:reviews-v2",
+			"split:wishlist", "configcat:ramping",
+		},
+		SplitAPIKey:            "split-key",
+		SplitWorkspaceID:       "ws-1",
+		SplitEnvironment:       "Production",
+		SplitAPIURL:            server.URL,
+		ConfigCatUsername:      "user",
+		ConfigCatPassword:      "pass",
+		ConfigCatConfigID:      "config-1",
+		ConfigCatEnvironmentID: "env-1",
+		ConfigCatAPIURL:        server.URL,
+		FlagStateProviders: []flagexorcist.FlagStateProvider{customState{
+			"reviews-v2": {Provider: "custom", Archived: true},
+		}},
+	})
+}
+
+func TestFlagRegistry(t *testing.T) {
+	// Every flag comes from the registry, which gives new-checkout a cutoff
+	// of its own
+	analyze(t, "registry", flagexorcist.Config{
+		Cutoff:       "36500d",
+		FlagRegistry: testdataDir(t, "registry", "flags.yaml"),
+		RunDate:      "2100-01-01",
+		ReportOwners: true,
+	})
+
+	// What the registry says about a flag is in its findings
+	for _, f := range run(t, testdataDir(t, "registry"), "reg") {
+		if f.Flag != flagexorcist.KeyID("new-checkout") {
+			continue
+		}
+		if f.Description != "The one-page checkout" ||
+			!reflect.DeepEqual(f.Tickets, []string{"CHK-12", "CHK-15"}) {
+			t.Errorf("Run() new-checkout = %+v, want its description and tickets", f)
+		}
+	}
+}
+
+func TestDeadCode(t *testing.T) {
+	analyze(t, "dead", flagexorcist.Config{
+		Cutoff:         "0",
+		FlagSymbols:    []string{"EnableCheckout"},
+		ReportDeadCode: true,
+		RunDate:        "2100-01-01",
+	})
+
+	// Dead code findings cover the whole of it
+	found := false
+	for _, f := range run(t, testdataDir(t, "dead"), "dead") {
+		if f.Category != flagexorcist.CategoryDeadCode || f.Position.Line != 10 {
+			continue
+		}
+		found = true
+		if f.End == nil || f.End.Line != 12 {
+			t.Errorf("Run() else branch ends at %v, want line 12", f.End)
+		}
+	}
+	if !found {
+		t.Error("Run() found no dead else branch")
+	}
+}
+
+// commitFile writes contents to the file at path in the worktree of repo, and
+// commits it at a fixed time. It returns the hash of the file's blob.
+func commitFile(
+	t *testing.T, repo *git.Repository, path, contents, message string, at time.Time,
+) plumbing.Hash {
+	t.Helper()
+	tree, err := repo.Worktree()
 	if err != nil {
-		t.Fatalf("Failed to get wd: %s", err)
+		t.Fatal(err)
 	}
+	filename := filepath.Join(tree.Filesystem.Root(), filepath.FromSlash(path))
+	if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filename, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	blob, err := tree.Add(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := &object.Signature{Name: "test", Email: "test@example.com", When: at}
+	if _, err := tree.Commit(message, &git.CommitOptions{Author: sig, Committer: sig}); err != nil {
+		t.Fatal(err)
+	}
+	return blob
+}
+
+// flagsSource is a package declaring and using the flag EnableX.
+const flagsSource = "package flags\n\nvar EnableX = true\n\nfunc Use() bool { return EnableX }\n"
 
-	flagexorcist.Initialize(flagexorcist.Config{
-		Cutoff:      0,
-		FlagSymbols: []string{"MyFlag"},
-		LogLevel:    flagexorcist.LogLevel(zerolog.DebugLevel),
-		RepoPath:    "..",
+// initFlagRepo creates a repo at dir whose only commit, in 2001, adds
+// flagsSource as the flags package.
+func initFlagRepo(t *testing.T, dir string) *git.Repository {
+	t.Helper()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatalf("Failed to init repo: %s", err)
+	}
+	commitFile(t, repo, "src/flags/flags.go", flagsSource,
+		"Add EnableX", time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC))
+	return repo
+}
+
+func TestAliases(t *testing.T) {
+	// EnableXV3 was renamed from EnableXV2, which was renamed from EnableX,
+	// which was added long before
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatalf("Failed to init repo: %s", err)
+	}
+	commit := func(contents, message string, at time.Time) {
+		t.Helper()
+		commitFile(t, repo, "src/flags/flags.go", contents, message, at)
+	}
+	commit(flagsSource,
+		"Add EnableX", time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC))
+	commit("package flags\n\nvar EnableXV2 = true\n\nfunc Use() bool { return EnableXV2 }\n",
+		"Rename EnableX", time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC))
+	commit("package flags\n\n"+
+		"var EnableXV3 = true\n\n"+
+		"func Use() bool { return EnableXV3 } // want "+
+		"`Flag 'EnableXV3', introduced 2001-01-01.* "+
+		"\\[added by test <test@example\\.com> in [0-9a-f]{8} \\(\"Add EnableX\"\\)\\]`\n",
+		"Rename EnableXV2", time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	// Only old enough by the date of its oldest name, and added by the
+	// commit that added that
+	for _, backend := range []flagexorcist.GitBackend{
+		flagexorcist.GitBackendGoGit, flagexorcist.GitBackendExec,
+	} {
+		initialize(t, flagexorcist.Config{
+			Cutoff:       "32850d",
+			FlagSymbols:  []string{"EnableXV3"},
+			Aliases:      []string{"EnableXV2=EnableXV3", "EnableX=EnableXV2"},
+			RepoPath:     dir,
+			RunDate:      "2100-01-01",
+			ReportOwners: true,
+			GitBackend:   backend,
+		})
+
+		analysistest.Run(t, dir, flagexorcist.Analyzer, "flags")
+	}
+}
+
+func TestDependencyRepos(t *testing.T) {
+	// The app uses EnableX from the flags module, which is in the GOPATH the
+	// way it would be in the module cache, without its history. Its own
+	// checkout is elsewhere.
+	gopath := t.TempDir()
+	app, err := git.PlainInit(filepath.Join(gopath, "src", "app"), false)
+	if err != nil {
+		t.Fatalf("Failed to init repo: %s", err)
+	}
+	commitFile(t, app, "app.go",
+		"package app\n\nimport \"flags\"\n\nfunc Checkout() bool { return flags.EnableX }\n",
+		"Use EnableX", time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err := os.MkdirAll(filepath.Join(gopath, "src", "flags"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(gopath, "src", "flags", "flags.go"), []byte(flagsSource), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deps := t.TempDir()
+	initFlagRepo(t, deps)
+
+	cfg := flagexorcist.Config{
+		Cutoff:      "3650d",
+		FlagSymbols: []string{"flags.EnableX"},
+		RepoPath:    filepath.Join(gopath, "src", "app"),
+		RunDate:     "2021-01-01",
+	}
+	initialize(t, cfg)
+	if findings := run(t, gopath, "app"); len(findings) != 0 {
+		t.Errorf("Run() = %v without its checkout, want no findings", findings)
+	}
+
+	// Dated by the dependency's history, and reported where it is used
+	cfg.DependencyRepos = []string{"flags=" + filepath.Join(deps, "src", "flags")}
+	initialize(t, cfg)
+	findings := run(t, gopath, "app")
+	if len(findings) != 1 {
+		t.Fatalf("Run() = %v, want one finding", findings)
+	}
+	f := findings[0]
+	if f.Flag != flagexorcist.SymbolID("flags.EnableX") || f.Category != flagexorcist.CategoryStale {
+		t.Errorf("Run() = %v, want flags.EnableX stale", f)
+	}
+	if want := time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC); !f.IntroducedAt.Equal(want) {
+		t.Errorf("IntroducedAt = %v, want %v", f.IntroducedAt, want)
+	}
+	if filepath.Base(f.Position.Filename) != "app.go" {
+		t.Errorf("Reported at %v, want in app.go", f.Position)
+	}
+}
+
+func TestAggregateUses(t *testing.T) {
+	initialize(t, flagexorcist.Config{
+		Cutoff: "0",
+		FlagSymbols: []string{
+			"flags.EnableSearch", "flags.EnableLegacy", "flags.EnableCheckout",
+		},
+		RunDate:      "2100-01-01",
+		ReportUnused: true,
 	})
 
-	testdata := filepath.Join(filepath.Dir(workDir), "testdata")
-	analysistest.Run(t, testdata, flagexorcist.Analyzer, "./src/...")
+	// EnableSearch and EnableLegacy are exported and unused in their
+	// package, but only EnableLegacy is unused anywhere. EnableCheckout is
+	// past its cutoff, and used in both packages.
+	findings := run(t, testdataDir(t, "aggregate"), "flags", "app")
+	type counts struct {
+		category         string
+		usages, packages int
+	}
+	got := map[flagexorcist.FlagID]counts{}
+	for _, f := range findings {
+		got[f.Flag] = counts{f.Category, f.UsageCount, f.PackageCount}
+	}
+	wantFindings := map[flagexorcist.FlagID]counts{
+		flagexorcist.SymbolID("flags.EnableLegacy"):   {flagexorcist.CategoryUnused, 0, 0},
+		flagexorcist.SymbolID("flags.EnableCheckout"): {flagexorcist.CategoryHardcoded, 1, 2},
+	}
+	if !reflect.DeepEqual(got, wantFindings) {
+		t.Errorf("Run() = %+v, want %+v", got, wantFindings)
+	}
+
+	usages := map[flagexorcist.FlagID]int{}
+	for _, f := range flagexorcist.DatedFlags() {
+		usages[f.Flag] = f.Usages
+	}
+	want := map[flagexorcist.FlagID]int{
+		flagexorcist.SymbolID("flags.EnableSearch"):   2,
+		flagexorcist.SymbolID("flags.EnableLegacy"):   0,
+		flagexorcist.SymbolID("flags.EnableCheckout"): 2,
+	}
+	if !reflect.DeepEqual(usages, want) {
+		t.Errorf("DatedFlags() usages = %v, want %v", usages, want)
+	}
+
+	// Analyzing one package at a time, EnableLegacy might be used anywhere
+	analysistest.Run(t, testdataDir(t, "aggregate"), flagexorcist.Analyzer, "flags")
+}
+
+func TestOnGitError(t *testing.T) {
+	// The history of EnableX can't be read, since its file's blob is gone
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatalf("Failed to init repo: %s", err)
+	}
+	blob := commitFile(t, repo, "src/flags/flags.go",
+		flagsSource,
+		"Add EnableX", time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC))
+	hash := blob.String()
+	if err := os.Remove(filepath.Join(dir, ".git", "objects", hash[:2], hash[2:])); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GO111MODULE", "off")
+	t.Setenv("GOPATH", dir)
+
+	for _, policy := range []flagexorcist.GitErrorPolicy{
+		flagexorcist.GitErrorFail, flagexorcist.GitErrorSkip, flagexorcist.GitErrorReport,
+	} {
+		initialize(t, flagexorcist.Config{
+			Cutoff:      "0",
+			FlagSymbols: []string{"EnableX"},
+			RepoPath:    dir,
+			RunDate:     "2100-01-01",
+			OnGitError:  policy,
+		})
+
+		findings, err := flagexorcist.Run("flags")
+		if policy == flagexorcist.GitErrorReport {
+			// Reported as a warning, which doesn't fail the run
+			if err != nil || len(findings) != 1 ||
+				findings[0].Category != flagexorcist.CategoryGitError || findings[0].Fails() {
+				t.Errorf("With ON_GIT_ERROR=%s, Run() = %v, %v", policy, findings, err)
+			}
+			continue
+		}
+		if (err != nil) != (policy == flagexorcist.GitErrorFail) || len(findings) > 0 {
+			t.Errorf("With ON_GIT_ERROR=%s, Run() = %v, %v", policy, findings, err)
+		}
+	}
+}
+
+func TestLogFile(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "flagexorcist.log")
+	analyze(t, "clock", flagexorcist.Config{
+		Cutoff:       "18250d",
+		FlagSymbols:  []string{"MyFlag"},
+		LogLevel:     flagexorcist.LogLevel(zerolog.ErrorLevel),
+		LogFile:      logFile,
+		LogFileLevel: flagexorcist.LogLevel(zerolog.DebugLevel),
+		RunDate:      "2100-01-01",
+	})
+
+	contents, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %s", err)
+	}
+
+	// Every phase is timed, and so is the package
+	timed := map[string]bool{}
+	for _, line := range strings.Split(strings.TrimSpace(string(contents)), "\n") {
+		var entry struct {
+			Message  string   `json:"message"`
+			Package  string   `json:"package"`
+			Phase    string   `json:"phase"`
+			Duration *float64 `json:"duration"`
+		}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Log line %q isn't JSON: %s", line, err)
+		}
+		if entry.Duration != nil && entry.Package == "clock" {
+			timed[entry.Phase] = true
+		}
+	}
+	for _, phase := range []string{
+		"", flagexorcist.PhaseFindRefs, flagexorcist.PhaseOpenRepo,
+		flagexorcist.PhaseDate, flagexorcist.PhaseReport,
+	} {
+		if !timed[phase] {
+			t.Errorf("No timing logged for phase %q in %s", phase, contents)
+		}
+	}
+}
+
+func TestInitializeErrors(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing.yaml")
+	for name, cfg := range map[string]flagexorcist.Config{
+		"no flags":         {},
+		"missing registry": {FlagRegistry: missing},
+		"missing snoozes":  {FlagSymbols: []string{"MyFlag"}, SnoozeFile: missing},
+		"bad run date":     {FlagSymbols: []string{"MyFlag"}, RunDate: "tomorrow"},
+		"bad cutoff":       {FlagSymbols: []string{"MyFlag"}, Cutoff: "3 months"},
+		"bad warn before":  {FlagSymbols: []string{"MyFlag"}, Cutoff: "90d", WarnBefore: "soon"},
+		"bad dependency":   {FlagSymbols: []string{"MyFlag"}, DependencyRepos: []string{"example.com/flags"}},
+		"unknown detector": {FlagSymbols: []string{"MyFlag"}, Detectors: []string{"launchdarkly"}},
+		"bad log file": {
+			FlagSymbols: []string{"MyFlag"},
+			LogFile:     filepath.Join(missing, "flagexorcist.log"),
+		},
+	} {
+		if err := flagexorcist.Initialize(cfg); err == nil {
+			t.Errorf("Initialize() with %s succeeded, want an error", name)
+		}
+	}
+}
+
+func TestPeriod(t *testing.T) {
+	t.Parallel()
+
+	for _, s := range []string{
+		"", "0", "2160h", "90d", "2w", "3mo", "1y", "1y6mo", "1d12h", "P3M", "P1Y2M10DT2H30M", "pt36h",
+	} {
+		var p flagexorcist.Period
+		if err := p.SetValue(s); err != nil || p != flagexorcist.Period(s) {
+			t.Errorf("SetValue(%q) = %q, %v, want it accepted", s, p, err)
+		}
+	}
+	for _, s := range []string{"90", "1.5d", "3 months", "-1d", "P", "PT", "P1H"} {
+		var p flagexorcist.Period
+		if err := p.SetValue(s); err == nil {
+			t.Errorf("SetValue(%q) succeeded, want an error", s)
+		}
+	}
+}
+
+func TestCalendarCutoff(t *testing.T) {
+	dir := t.TempDir()
+	initFlagRepo(t, dir)
+
+	// EnableX was added on 2001-01-01, and 2004 is a leap year, so four years
+	// later is a day more than 4*365 days later
+	for cutoff, stale := range map[flagexorcist.Period]bool{
+		"4y":    false,
+		"48mo":  false,
+		"P4Y":   false,
+		"1460d": true,
+	} {
+		initialize(t, flagexorcist.Config{
+			Cutoff:      cutoff,
+			FlagSymbols: []string{"EnableX"},
+			RepoPath:    dir,
+			RunDate:     "2005-01-01",
+		})
+		findings := run(t, dir, "flags")
+		if got := failing(findings)[flagexorcist.SymbolID("EnableX")]; got != stale {
+			t.Errorf("Run() with a cutoff of %s = %+v, want stale %v", cutoff, findings, stale)
+		}
+	}
+}
+
+func TestWarnBefore(t *testing.T) {
+	dir := t.TempDir()
+	initFlagRepo(t, dir)
+
+	// EnableX was added on 2001-01-01, so with a cutoff of 4y it is stale
+	// from 2005-01-02
+	tests := []struct {
+		warnBefore flagexorcist.Period
+		runDate    string
+		want       string
+	}{
+		{warnBefore: "2w", runDate: "2004-12-20", want: flagexorcist.CategoryExpiringSoon},
+		{warnBefore: "1w", runDate: "2004-12-20"},
+		{warnBefore: "", runDate: "2004-12-31"},
+		{warnBefore: "2w", runDate: "2005-01-02", want: flagexorcist.CategoryStale},
+	}
+	for _, tt := range tests {
+		initialize(t, flagexorcist.Config{
+			Cutoff:      "4y",
+			WarnBefore:  tt.warnBefore,
+			FlagSymbols: []string{"EnableX"},
+			RepoPath:    dir,
+			RunDate:     tt.runDate,
+		})
+		findings := run(t, dir, "flags")
+		got := ""
+		for _, f := range findings {
+			got = f.Category
+		}
+		if got != tt.want || len(findings) > 1 {
+			t.Errorf("Run() warning %s before on %s = %+v, want a finding of %q",
+				tt.warnBefore, tt.runDate, findings, tt.want)
+			continue
+		}
+		if got != flagexorcist.CategoryExpiringSoon {
+			continue
+		}
+
+		// Informational, so it doesn't fail the run
+		f := findings[0]
+		if f.Fails() || f.Severity != flagexorcist.SeverityInfo {
+			t.Errorf("Run() expiring soon = %+v, want it informational", f)
+		}
+		if !strings.Contains(f.Message, "will be past its cutoff of 1460 days on 2005-01-02, in 13 days") {
+			t.Errorf("Run() expiring soon message = %q, want when it will be stale", f.Message)
+		}
+	}
+}
+
+func TestDeepenShallowRemote(t *testing.T) {
+	// A shallow clone of a shallow clone stays shallow when deepened
+	dir := t.TempDir()
+	origin := filepath.Join(dir, "origin")
+	repo := initFlagRepo(t, origin)
+	commitFile(t, repo, "README", "flags\n",
+		"Add README", time.Date(2002, 1, 1, 0, 0, 0, 0, time.UTC))
+	for _, clone := range [][2]string{{"origin", "mirror"}, {"mirror", "checkout"}} {
+		out, err := exec.Command("git", "clone", "--depth=1",
+			"file://"+filepath.Join(dir, clone[0]), filepath.Join(dir, clone[1]),
+		).CombinedOutput()
+		if err != nil {
+			t.Fatalf("Failed to clone %s: %s: %s", clone[0], err, out)
+		}
+	}
+	checkout := filepath.Join(dir, "checkout")
+
+	initialize(t, flagexorcist.Config{
+		Cutoff:      "0",
+		FlagSymbols: []string{"EnableX"},
+		RepoPath:    checkout,
+		RunDate:     "2100-01-01",
+		OnShallow:   flagexorcist.ShallowDeepen,
+	})
+
+	// Deepened once, and then reported like by default
+	findings := run(t, checkout, "flags")
+	if len(findings) != 1 || findings[0].Category != flagexorcist.CategoryInsufficientHistory {
+		t.Errorf("Run() = %+v, want EnableX with insufficient history", findings)
+	}
+}
+
+func TestGitHubHistory(t *testing.T) {
+	// A depth-1 clone, whose only commit came long after EnableX
+	dir := t.TempDir()
+	origin := filepath.Join(dir, "origin")
+	repo := initFlagRepo(t, origin)
+	commitFile(t, repo, "README", "flags\n",
+		"Add README", time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	checkout := filepath.Join(dir, "checkout")
+	out, err := exec.Command("git", "clone", "-q", "--depth=1", "file://"+origin, checkout).CombinedOutput()
+	if err != nil {
+		t.Fatalf("Failed to clone origin: %s: %s", err, out)
+	}
+	head, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// GitHub has the whole history
+	commits := `[
+		{"sha": "2222", "commit": {"message": "Add README",
+			"author": {"name": "test", "email": "test@example.com", "date": "2020-01-01T00:00:00Z"},
+			"committer": {"name": "test", "email": "test@example.com", "date": "2020-01-01T00:00:00Z"}}},
+		{"sha": "1111", "commit": {"message": "Add EnableX\n\nBehind a flag for now.",
+			"author": {"name": "test", "email": "test@example.com", "date": "2001-01-01T00:00:00Z"},
+			"committer": {"name": "test", "email": "test@example.com", "date": "2001-01-01T00:00:00Z"}}}
+	]`
+	contents := fmt.Sprintf(`{"encoding": "base64", "content": %q}`,
+		base64.StdEncoding.EncodeToString([]byte(flagsSource)))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if got := req.Header.Get("Authorization"); got != "Bearer gh-token" {
+			t.Errorf("Authorization = %q, want the token", got)
+		}
+		switch req.URL.Path {
+		case "/repos/acme/flags/commits":
+			if got := req.URL.Query().Get("sha"); got != head.Hash().String() {
+				t.Errorf("sha = %q, want the checkout's HEAD", got)
+			}
+			if got := req.URL.Query().Get("path"); got != "src/flags/flags.go" {
+				t.Errorf("path = %q, want the declaring file", got)
+			}
+			fmt.Fprint(w, commits)
+		case "/repos/acme/flags/contents/src/flags/flags.go":
+			fmt.Fprint(w, contents)
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	defer server.Close()
+
+	initialize(t, flagexorcist.Config{
+		Cutoff:           "3650d",
+		FlagSymbols:      []string{"EnableX"},
+		RepoPath:         checkout,
+		RunDate:          "2025-01-01",
+		GitHubHistory:    true,
+		GitHubRepository: "acme/flags",
+		GitHubToken:      "gh-token",
+		GitHubAPIURL:     server.URL,
+	})
+
+	findings := run(t, checkout, "flags")
+	if len(findings) != 1 || findings[0].Category != flagexorcist.CategoryStale ||
+		findings[0].Commit != "1111" || findings[0].CommitSubject != "Add EnableX" {
+		t.Errorf("Run() = %+v, want EnableX stale since commit 1111", findings)
+	}
+}
+
+func TestGitDir(t *testing.T) {
+	// The history is in a bare repo, apart from the checkout analyzed
+	dir := t.TempDir()
+	initFlagRepo(t, filepath.Join(dir, "origin"))
+	gitDir := filepath.Join(dir, "flags.git")
+	out, err := exec.Command("git", "clone", "-q", "--bare",
+		filepath.Join(dir, "origin"), gitDir).CombinedOutput()
+	if err != nil {
+		t.Fatalf("Failed to clone origin: %s: %s", err, out)
+	}
+	workTree := filepath.Join(dir, "checkout")
+	filename := filepath.Join(workTree, "src", "flags", "flags.go")
+	if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filename, []byte(flagsSource), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, backend := range []flagexorcist.GitBackend{
+		flagexorcist.GitBackendGoGit, flagexorcist.GitBackendExec,
+	} {
+		initialize(t, flagexorcist.Config{
+			Cutoff:      "3650d",
+			FlagSymbols: []string{"EnableX"},
+			RepoPath:    workTree,
+			GitDir:      gitDir,
+			WorkTree:    workTree,
+			RunDate:     "2025-01-01",
+			GitBackend:  backend,
+		})
+
+		findings := run(t, workTree, "flags")
+		if len(findings) != 1 || findings[0].Category != flagexorcist.CategoryStale ||
+			findings[0].IntroducedAt.Year() != 2001 {
+			t.Errorf("With %s, Run() = %+v, want EnableX stale since 2001", backend, findings)
+		}
+	}
+}
+
+func TestLinkedWorktree(t *testing.T) {
+	// The checkout analyzed is a linked worktree, whose .git is a file
+	dir := t.TempDir()
+	initFlagRepo(t, filepath.Join(dir, "main"))
+	linked := filepath.Join(dir, "linked")
+	out, err := exec.Command("git", "-C", filepath.Join(dir, "main"),
+		"worktree", "add", "-q", "--detach", linked).CombinedOutput()
+	if err != nil {
+		t.Fatalf("Failed to add worktree: %s: %s", err, out)
+	}
+
+	for _, backend := range []flagexorcist.GitBackend{
+		flagexorcist.GitBackendGoGit, flagexorcist.GitBackendExec,
+	} {
+		initialize(t, flagexorcist.Config{
+			Cutoff:      "3650d",
+			FlagSymbols: []string{"EnableX"},
+			RepoPath:    linked,
+			RunDate:     "2025-01-01",
+			GitBackend:  backend,
+		})
+
+		findings := run(t, linked, "flags")
+		if len(findings) != 1 || findings[0].Category != flagexorcist.CategoryStale ||
+			findings[0].IntroducedAt.Year() != 2001 {
+			t.Errorf("With %s, Run() = %+v, want EnableX stale since 2001", backend, findings)
+		}
+	}
+}
+
+func TestSubmodule(t *testing.T) {
+	// EnableX is old in the submodule, which was added to the superproject
+	// much later
+	dir := t.TempDir()
+	initFlagRepo(t, filepath.Join(dir, "flags"))
+	super := filepath.Join(dir, "super")
+	added := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	gitDated(t, dir, added, added, "init", "-q", super)
+	gitDated(t, super, added, added, "-c", "protocol.file.allow=always",
+		"submodule", "add", "-q", filepath.Join(dir, "flags"), "src/flags")
+	gitDated(t, super, added, added, "commit", "-q", "-m", "Add flags")
+	// The package lives in the submodule's src dir
+	pkg := filepath.Join(super, "src", "flags")
+
+	for _, backend := range []flagexorcist.GitBackend{
+		flagexorcist.GitBackendGoGit, flagexorcist.GitBackendExec,
+	} {
+		initialize(t, flagexorcist.Config{
+			Cutoff:      "3650d",
+			FlagSymbols: []string{"EnableX"},
+			RepoPath:    super,
+			RunDate:     "2025-01-01",
+			GitBackend:  backend,
+		})
+
+		findings := run(t, pkg, "flags")
+		if len(findings) != 1 || findings[0].Category != flagexorcist.CategoryStale ||
+			findings[0].IntroducedAt.Year() != 2001 {
+			t.Errorf("With %s, Run() = %+v, want EnableX stale since 2001", backend, findings)
+		}
+	}
+}
+
+func TestNoRepo(t *testing.T) {
+	// An exported snapshot, whose file was last modified long ago
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "src", "flags", "flags.go")
+	if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filename, []byte(flagsSource), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	modified := time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(filename, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+
+	initialize(t, flagexorcist.Config{
+		Cutoff:      "3650d",
+		FlagSymbols: []string{"EnableX"},
+		RepoPath:    dir,
+		RunDate:     "2025-01-01",
+	})
+
+	findings := run(t, dir, "flags")
+	if len(findings) != 1 || !findings[0].IntroducedAt.Equal(modified) ||
+		!strings.Contains(findings[0].Message, "dated by file modification time") {
+		t.Errorf("Run() = %+v, want EnableX dated by its file's mtime", findings)
+	}
+}
+
+func TestMercurial(t *testing.T) {
+	if _, err := exec.LookPath("hg"); err != nil {
+		t.Skip("Mercurial isn't installed")
+	}
+
+	dir := t.TempDir()
+	hg := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("hg", append([]string{"-R", dir}, args...)...)
+		cmd.Env = append(os.Environ(), "HGUSER=test", "HGPLAIN=1")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("hg %s failed: %s: %s", strings.Join(args, " "), err, out)
+		}
+	}
+	if out, err := exec.Command("hg", "init", dir).CombinedOutput(); err != nil {
+		t.Fatalf("Failed to init repo: %s: %s", err, out)
+	}
+	filename := filepath.Join(dir, "src", "flags", "flags.go")
+	if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filename, []byte(flagsSource), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	hg("commit", "-A", "-d", "2001-01-01 00:00:00 +0000", "-m", "Add EnableX")
+
+	initialize(t, flagexorcist.Config{
+		Cutoff:      "3650d",
+		FlagSymbols: []string{"EnableX"},
+		RepoPath:    dir,
+		RunDate:     "2025-01-01",
+	})
+
+	findings := run(t, dir, "flags")
+	if len(findings) != 1 || findings[0].Category != flagexorcist.CategoryStale ||
+		findings[0].IntroducedAt.UTC().Year() != 2001 || findings[0].Author != "test" {
+		t.Errorf("Run() = %+v, want EnableX stale since 2001, by test", findings)
+	}
+}
+
+func TestJujutsu(t *testing.T) {
+	if _, err := exec.LookPath("jj"); err != nil {
+		t.Skip("Jujutsu isn't installed")
+	}
+
+	// A workspace that isn't colocated, so its history is only in jj's git
+	// store
+	dir := t.TempDir()
+	jj := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("jj", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"JJ_USER=test", "JJ_EMAIL=test@example.com",
+			"JJ_TIMESTAMP=2001-01-01T00:00:00+00:00",
+		)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("jj %s failed: %s: %s", strings.Join(args, " "), err, out)
+		}
+	}
+	jj("git", "init")
+	filename := filepath.Join(dir, "src", "flags", "flags.go")
+	if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filename, []byte(flagsSource), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	jj("commit", "-m", "Add EnableX")
+
+	for _, backend := range []flagexorcist.GitBackend{
+		flagexorcist.GitBackendGoGit, flagexorcist.GitBackendExec,
+	} {
+		initialize(t, flagexorcist.Config{
+			Cutoff:      "3650d",
+			FlagSymbols: []string{"EnableX"},
+			RepoPath:    dir,
+			RunDate:     "2025-01-01",
+			GitBackend:  backend,
+		})
+
+		findings := run(t, dir, "flags")
+		if len(findings) != 1 || findings[0].Category != flagexorcist.CategoryStale ||
+			findings[0].IntroducedAt.Year() != 2001 {
+			t.Errorf("With %s, Run() = %+v, want EnableX stale since 2001", backend, findings)
+		}
+	}
+}
+
+func TestStats(t *testing.T) {
+	dir := t.TempDir()
+	repo := initFlagRepo(t, dir)
+	commitFile(t, repo, "README", "flags\n",
+		"Add README", time.Date(2002, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	initialize(t, flagexorcist.Config{
+		Cutoff:      "3650d",
+		FlagSymbols: []string{"EnableX"},
+		RepoPath:    dir,
+		RunDate:     "2025-01-01",
+	})
+	if stats := flagexorcist.CurrentStats(); stats.Packages != 0 || stats.Symbols != 0 {
+		t.Errorf("CurrentStats() = %+v before running, want nothing done", stats)
+	}
+	run(t, dir, "flags")
+
+	stats := flagexorcist.CurrentStats()
+	if stats.Packages != 1 || stats.Symbols != 1 || stats.Commits == 0 {
+		t.Errorf("CurrentStats() = %+v, want 1 package and 1 flag dated from some commits", stats)
+	}
+	phases := []string{}
+	for _, phase := range stats.Phases {
+		phases = append(phases, phase.Phase)
+	}
+	want := []string{
+		flagexorcist.PhaseOpenRepo, flagexorcist.PhaseFindRefs,
+		flagexorcist.PhaseDate, flagexorcist.PhaseReport,
+	}
+	if !reflect.DeepEqual(phases, want) {
+		t.Errorf("CurrentStats() phases = %v, want %v", phases, want)
+	}
+
+	summary := &strings.Builder{}
+	flagexorcist.WriteSummary(summary, time.Second)
+	if !strings.HasPrefix(summary.String(), "1 packages analyzed, 1 flags dated, ") ||
+		!strings.Contains(summary.String(), "  "+flagexorcist.PhaseDate+" ") {
+		t.Errorf("WriteSummary() wrote:\n%s", summary)
+	}
+}
+
+func TestFoldPathCase(t *testing.T) {
+	// The package was committed as Flags, but is checked out as flags, like
+	// on a case-insensitive filesystem
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatalf("Failed to init repo: %s", err)
+	}
+	commitFile(t, repo, "src/Flags/flags.go", flagsSource,
+		"Add EnableX", time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err := os.Rename(filepath.Join(dir, "src", "Flags"), filepath.Join(dir, "src", "flags")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, backend := range []flagexorcist.GitBackend{
+		flagexorcist.GitBackendGoGit, flagexorcist.GitBackendExec,
+	} {
+		for _, fold := range []bool{false, true} {
+			initialize(t, flagexorcist.Config{
+				Cutoff:       "3650d",
+				FlagSymbols:  []string{"EnableX"},
+				RepoPath:     dir,
+				RunDate:      "2025-01-01",
+				GitBackend:   backend,
+				FoldPathCase: fold,
+			})
+
+			findings := run(t, dir, "flags")
+			dated := len(findings) == 1 && findings[0].IntroducedAt.Year() == 2001
+			if dated != fold {
+				t.Errorf("With %s and FoldPathCase=%v, Run() = %+v, want dated = %v",
+					backend, fold, findings, fold)
+			}
+		}
+	}
+}
+
+func TestDetectRoot(t *testing.T) {
+	// Run from this repo, on packages in another one
+	dir := t.TempDir()
+	initFlagRepo(t, dir)
+
+	for _, backend := range []flagexorcist.GitBackend{
+		flagexorcist.GitBackendGoGit, flagexorcist.GitBackendExec,
+	} {
+		// Not through initialize, which would set REPO_PATH
+		err := flagexorcist.Initialize(flagexorcist.Config{
+			Cutoff:      "3650d",
+			FlagSymbols: []string{"EnableX"},
+			RunDate:     "2025-01-01",
+			GitBackend:  backend,
+		})
+		if err != nil {
+			t.Fatalf("Initialize() error = %v", err)
+		}
+
+		findings := run(t, dir, "flags")
+		if len(findings) != 1 || findings[0].Category != flagexorcist.CategoryStale ||
+			findings[0].IntroducedAt.Year() != 2001 || findings[0].Path != "src/flags/flags.go" {
+			t.Errorf("With %s, Run() = %+v, want EnableX stale since 2001", backend, findings)
+		}
+	}
+}
+
+func TestOwners(t *testing.T) {
+	dir := t.TempDir()
+	repo := initFlagRepo(t, dir)
+	// The last matching rule wins
+	commitFile(t, repo, ".github/CODEOWNERS", "* @acme/everyone\nsrc/flags/ @acme/flags # flags team\n",
+		"Add CODEOWNERS", time.Date(2002, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	initialize(t, flagexorcist.Config{
+		Cutoff:       "3650d",
+		FlagSymbols:  []string{"EnableX"},
+		RepoPath:     dir,
+		RunDate:      "2025-01-01",
+		ReportOwners: true,
+	})
+
+	findings := run(t, dir, "flags")
+	if len(findings) != 1 {
+		t.Fatalf("Run() = %+v, want EnableX", findings)
+	}
+	f := findings[0]
+	if !reflect.DeepEqual(f.CodeOwners, []string{"@acme/flags"}) ||
+		f.Author != "test <test@example.com>" || f.CommitSubject != "Add EnableX" {
+		t.Errorf("Run() = %+v, want EnableX owned by @acme/flags and added by test", f)
+	}
+	want := fmt.Sprintf(`[owned by @acme/flags, added by test <test@example.com> in %s ("Add EnableX")]`,
+		f.Commit[:8])
+	if !strings.HasSuffix(f.Message, want) {
+		t.Errorf("Run() message = %q, want it to end with %q", f.Message, want)
+	}
+}
+
+// Not parallel, since it installs a tracer provider.
+func TestTracing(t *testing.T) {
+	dir := t.TempDir()
+	initFlagRepo(t, dir)
+	initialize(t, flagexorcist.Config{
+		Cutoff:      "3650d",
+		FlagSymbols: []string{"EnableX"},
+		RepoPath:    dir,
+		RunDate:     "2025-01-01",
+	})
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	// The analyzer's tracer stays delegated to the first provider installed,
+	// so it is shut down rather than replaced afterwards
+	otel.SetTracerProvider(provider)
+	defer provider.Shutdown(context.Background())
+
+	t.Setenv("GO111MODULE", "off")
+	t.Setenv("GOPATH", dir)
+	ctx, root := provider.Tracer("test").Start(context.Background(), "test")
+	if _, err := flagexorcist.RunContext(ctx, "flags"); err != nil {
+		t.Fatalf("RunContext() error = %v", err)
+	}
+	root.End()
+
+	// Every span is part of the caller's trace
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		if span.SpanContext().TraceID() != root.SpanContext().TraceID() {
+			t.Errorf("Span %q is in trace %s, want the caller's", span.Name(), span.SpanContext().TraceID())
+		}
+		spans[span.Name()] = span
+	}
+	for _, name := range []string{
+		"load packages", "analyze package", flagexorcist.PhaseFindRefs,
+		flagexorcist.PhaseOpenRepo, flagexorcist.PhaseDate, "date flag", flagexorcist.PhaseReport,
+	} {
+		if _, ok := spans[name]; !ok {
+			t.Errorf("No %q span was recorded", name)
+		}
+	}
+	if span, ok := spans["date flag"]; ok {
+		attrs := attribute.NewSet(span.Attributes()...)
+		if flag, _ := attrs.Value("flag"); flag.AsString() != "EnableX" {
+			t.Errorf("date flag span has flag = %q, want EnableX", flag.AsString())
+		}
+		if at, _ := attrs.Value("introduced_at"); at.AsString() != "2001-01-01T00:00:00Z" {
+			t.Errorf("date flag span has introduced_at = %q, want 2001-01-01", at.AsString())
+		}
+	}
+}
+
+func TestStableOutput(t *testing.T) {
+	// Several flags, used in several places in several packages
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatalf("Failed to init repo: %s", err)
+	}
+	at := time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+	commitFile(t, repo, "src/flags/flags.go",
+		"package flags\n\nvar EnableZ, EnableY, EnableX = true, false, true\n\n"+
+			"func Use() bool { return EnableZ && EnableY || EnableX }\n",
+		"Add flags", at)
+	commitFile(t, repo, "src/app/app.go",
+		"package app\n\nimport \"flags\"\n\nfunc Run() bool { return flags.EnableX || flags.EnableZ }\n",
+		"Add app", at)
+
+	outputs := []string{}
+	for _, concurrency := range []int{1, 8, 8} {
+		initialize(t, flagexorcist.Config{
+			Cutoff:         "3650d",
+			FlagSymbols:    []string{"EnableX", "EnableY", "EnableZ"},
+			RepoPath:       dir,
+			RunDate:        "2025-01-01",
+			GitConcurrency: concurrency,
+		})
+		findings := run(t, dir, "app", "flags")
+		if len(findings) == 0 {
+			t.Fatal("Run() found nothing")
+		}
+		for i := 1; i < len(findings); i++ {
+			a, b := findings[i-1], findings[i]
+			if a.Path > b.Path || a.Path == b.Path && a.Position.Offset > b.Position.Offset {
+				t.Errorf("Run() put %s:%d before %s:%d",
+					a.Path, a.Position.Line, b.Path, b.Position.Line)
+			}
+		}
+
+		out, err := json.Marshal(findings)
+		if err != nil {
+			t.Fatal(err)
+		}
+		outputs = append(outputs, string(out))
+	}
+	for i := 1; i < len(outputs); i++ {
+		if outputs[i] != outputs[0] {
+			t.Errorf("Run() output changed between runs:\n%s\nvs\n%s", outputs[0], outputs[i])
+		}
+	}
+}
+
+func TestRunDir(t *testing.T) {
+	dir := t.TempDir()
+	initFlagRepo(t, dir)
+	initialize(t, flagexorcist.Config{
+		Cutoff:      "3650d",
+		FlagSymbols: []string{"EnableX"},
+		RepoPath:    dir,
+		RunDate:     "2025-01-01",
+	})
+
+	// Patterns are relative to the directory, not to this one
+	t.Setenv("GO111MODULE", "off")
+	t.Setenv("GOPATH", dir)
+	findings, err := flagexorcist.RunDir(context.Background(), filepath.Join(dir, "src", "flags"), ".")
+	if err != nil {
+		t.Fatalf("RunDir() error = %v", err)
+	}
+	if len(findings) != 1 || findings[0].Path != "src/flags/flags.go" {
+		t.Errorf("RunDir() = %+v, want EnableX", findings)
+	}
+}
+
+func TestHistoryKept(t *testing.T) {
+	dir := t.TempDir()
+	repo := initFlagRepo(t, dir)
+	initialize(t, flagexorcist.Config{
+		Cutoff:      "3650d",
+		FlagSymbols: []string{"EnableX"},
+		RepoPath:    dir,
+		RunDate:     "2025-01-01",
+	})
+
+	run(t, dir, "flags")
+	walked := flagexorcist.CurrentStats().Commits
+	if walked == 0 {
+		t.Fatal("CurrentStats() = no commits walked, want the history dated from")
+	}
+
+	// Analyzing it again doesn't walk the history again
+	findings := run(t, dir, "flags")
+	if got := flagexorcist.CurrentStats().Commits; got != walked {
+		t.Errorf("CurrentStats() = %d commits walked, want %d from the first run only", got, walked)
+	}
+	if !failing(findings)[flagexorcist.SymbolID("EnableX")] {
+		t.Errorf("Run() = %+v, want EnableX still stale", findings)
+	}
+
+	// Nor after commits that leave the file alone
+	commitFile(t, repo, "README", "flags\n",
+		"Add README", time.Date(2002, 1, 1, 0, 0, 0, 0, time.UTC))
+	run(t, dir, "flags")
+	if got := flagexorcist.CurrentStats().Commits; got != walked {
+		t.Errorf("CurrentStats() = %d commits walked, want %d after changing another file", got, walked)
+	}
+
+	// Until the file changes
+	commitFile(t, repo, "src/flags/flags.go", flagsSource+"\n// EnableX is old\n",
+		"Document EnableX", time.Date(2003, 1, 1, 0, 0, 0, 0, time.UTC))
+	run(t, dir, "flags")
+	if got := flagexorcist.CurrentStats().Commits; got == walked {
+		t.Errorf("CurrentStats() = %d commits walked, want more after the file changed", got)
+	}
+}
+
+func TestCacheFile(t *testing.T) {
+	dir := t.TempDir()
+	initFlagRepo(t, dir)
+	cfg := flagexorcist.Config{
+		Cutoff:      "3650d",
+		FlagSymbols: []string{"EnableX"},
+		RepoPath:    dir,
+		RunDate:     "2025-01-01",
+	}
+	cache := filepath.Join(t.TempDir(), "cache.bin")
+	setFlag := func(name, value string) {
+		t.Helper()
+		if err := flagexorcist.Analyzer.Flags.Set(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() {
+		setFlag("cache-import", "")
+		setFlag("cache-export", "")
+	})
+
+	// Importing a cache that isn't there yet is fine
+	initialize(t, cfg)
+	setFlag("cache-import", cache)
+	setFlag("cache-export", cache)
+	want := run(t, dir, "flags")
+	if flagexorcist.CurrentStats().Commits == 0 {
+		t.Fatal("CurrentStats() = no commits walked, want the history dated from")
+	}
+
+	// A later run finds the same without walking any history
+	initialize(t, cfg)
+	setFlag("cache-export", "")
+	got := run(t, dir, "flags")
+	if commits := flagexorcist.CurrentStats().Commits; commits != 0 {
+		t.Errorf("CurrentStats() = %d commits walked, want none with the cache imported", commits)
+	}
+	// Compared as JSON, since times lose their location in the cache
+	gotJSON, _ := json.Marshal(got)
+	wantJSON, _ := json.Marshal(want)
+	if string(gotJSON) != string(wantJSON) {
+		t.Errorf("Run() = %s with the cache imported, want %s", gotJSON, wantJSON)
+	}
+
+	// Unless the history is read differently
+	cfg.FirstParent = true
+	initialize(t, cfg)
+	run(t, dir, "flags")
+	if flagexorcist.CurrentStats().Commits == 0 {
+		t.Error("CurrentStats() = no commits walked, want the cache ignored for other settings")
+	}
+}
+
+// gitDated runs git in dir, with any commit it makes authored at author and
+// committed at committer.
+func gitDated(t *testing.T, dir string, author, committer time.Time, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+		"GIT_AUTHOR_DATE="+author.Format(time.RFC3339),
+		"GIT_COMMITTER_DATE="+committer.Format(time.RFC3339),
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %s failed: %s: %s", strings.Join(args, " "), err, out)
+	}
+}
+
+func TestFirstParent(t *testing.T) {
+	// EnableX was added on a branch long before the branch was merged
+	dir := t.TempDir()
+	at := func(year int) time.Time { return time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC) }
+	gitAt := func(year int, args ...string) {
+		t.Helper()
+		gitDated(t, dir, at(year), at(year), args...)
+	}
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatalf("Failed to init repo: %s", err)
+	}
+	commitFile(t, repo, "README", "flags\n", "Add README", at(2000))
+	gitAt(2001, "checkout", "-q", "-b", "feature")
+	commitFile(t, repo, "src/flags/flags.go",
+		flagsSource,
+		"Add EnableX", at(2001))
+	gitAt(2020, "checkout", "-q", "master")
+	gitAt(2020, "merge", "-q", "--no-ff", "-m", "Merge feature", "feature")
+
+	// Only the merge brought EnableX to main, which wasn't long ago
+	for _, backend := range []flagexorcist.GitBackend{
+		flagexorcist.GitBackendGoGit, flagexorcist.GitBackendExec,
+	} {
+		for firstParent, wantStale := range map[bool]bool{false: true, true: false} {
+			initialize(t, flagexorcist.Config{
+				Cutoff:      "3650d",
+				FlagSymbols: []string{"EnableX"},
+				RepoPath:    dir,
+				RunDate:     "2025-01-01",
+				FirstParent: firstParent,
+				GitBackend:  backend,
+			})
+
+			if stale := len(run(t, dir, "flags")) > 0; stale != wantStale {
+				t.Errorf("With %s and FirstParent=%v, stale = %v, want %v",
+					backend, firstParent, stale, wantStale)
+			}
+		}
+	}
+}
+
+func TestBisectHistory(t *testing.T) {
+	at := func(year int) time.Time { return time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC) }
+	// version is the flags package in year, with EnableX or not, so that
+	// every commit changes it
+	version := func(year int, enabled bool) string {
+		if enabled {
+			return flagsSource + fmt.Sprintf("\n// %d\n", year)
+		}
+		return fmt.Sprintf("package flags\n\n// %d\n", year)
+	}
+
+	tests := []struct {
+		name string
+		// Makes the history of the repo at dir
+		history func(t *testing.T, dir string, repo *git.Repository)
+		want    int
+	}{
+		{
+			name: "linear",
+			history: func(t *testing.T, dir string, repo *git.Repository) {
+				for year := 1990; year <= 2020; year++ {
+					commitFile(t, repo, "src/flags/flags.go", version(year, year >= 2013),
+						"Change flags", at(year))
+				}
+			},
+			want: 2013,
+		},
+		{
+			name: "merged branch",
+			history: func(t *testing.T, dir string, repo *git.Repository) {
+				for year := 1990; year <= 2009; year++ {
+					commitFile(t, repo, "src/flags/flags.go", version(year, false),
+						"Change flags", at(year))
+				}
+				gitDated(t, dir, at(2010), at(2010), "checkout", "-q", "-b", "feature")
+				commitFile(t, repo, "src/flags/flags.go", version(2010, true), "Add EnableX", at(2010))
+				gitDated(t, dir, at(2011), at(2011), "checkout", "-q", "master")
+				for year := 2011; year <= 2019; year++ {
+					commitFile(t, repo, "README", fmt.Sprintf("%d\n", year), "Change README", at(year))
+				}
+				gitDated(t, dir, at(2020), at(2020), "merge", "-q", "--no-ff", "-m", "Merge feature", "feature")
+			},
+			want: 2010,
+		},
+		{
+			name: "removed and added back",
+			history: func(t *testing.T, dir string, repo *git.Repository) {
+				for year := 2001; year <= 2020; year++ {
+					commitFile(t, repo, "src/flags/flags.go",
+						version(year, year < 2005 || year >= 2010), "Change flags", at(year))
+				}
+			},
+			want: 2001,
+		},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		repo, err := git.PlainInit(dir, false)
+		if err != nil {
+			t.Fatalf("Failed to init repo: %s", err)
+		}
+		tt.history(t, dir, repo)
+		initialize(t, flagexorcist.Config{
+			Cutoff:      "0",
+			FlagSymbols: []string{"EnableX"},
+			RepoPath:    dir,
+			RunDate:     "2025-01-01",
+		})
+
+		run(t, dir, "flags")
+		dated := flagexorcist.DatedFlags()
+		if len(dated) != 1 || !dated[0].IntroducedAt.Equal(at(tt.want)) {
+			t.Errorf("%s: DatedFlags() = %+v, want EnableX introduced in %d", tt.name, dated, tt.want)
+		}
+		// Bisecting reads a handful of the versions of the file, not each of
+		// them
+		if reads := flagexorcist.CurrentStats().Reads; reads > 10 {
+			t.Errorf("%s: read %d versions of the file, want at most 10", tt.name, reads)
+		}
+	}
+}
+
+func TestUnchangedCommits(t *testing.T) {
+	at := func(year int) time.Time { return time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC) }
+	tests := []struct {
+		name string
+		// Makes the history after EnableX was added in 2001
+		history func(t *testing.T, dir string, repo *git.Repository)
+		metric  flagexorcist.AgeMetric
+	}{
+		{
+			// Only the README changes after 2001, so the commits are told
+			// apart from the one adding the flag by the file's blob
+			name: "untouched",
+			history: func(t *testing.T, dir string, repo *git.Repository) {
+				for year := 2002; year <= 2020; year++ {
+					commitFile(t, repo, "README", fmt.Sprintf("%d\n", year), "Change README", at(year))
+				}
+			},
+		},
+		{
+			name: "untouched, last modified",
+			history: func(t *testing.T, dir string, repo *git.Repository) {
+				for year := 2002; year <= 2020; year++ {
+					commitFile(t, repo, "README", fmt.Sprintf("%d\n", year), "Change README", at(year))
+				}
+			},
+			metric: flagexorcist.AgeMetricLastModified,
+		},
+		{
+			// The file is renamed away and back unchanged, so the version
+			// at HEAD is the one read for the commit adding the flag
+			name: "renamed back",
+			history: func(t *testing.T, dir string, repo *git.Repository) {
+				gitDated(t, dir, at(2005), at(2005), "mv", "src/flags/flags.go", "src/flags/old.go")
+				gitDated(t, dir, at(2005), at(2005), "commit", "-q", "-m", "Rename flags")
+				commitFile(t, repo, "README", "renamed\n", "Change README", at(2010))
+				gitDated(t, dir, at(2015), at(2015), "mv", "src/flags/old.go", "src/flags/flags.go")
+				gitDated(t, dir, at(2015), at(2015), "commit", "-q", "-m", "Rename flags back")
+			},
+		},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		tt.history(t, dir, initFlagRepo(t, dir))
+		initialize(t, flagexorcist.Config{
+			Cutoff:      "0",
+			FlagSymbols: []string{"EnableX"},
+			RepoPath:    dir,
+			RunDate:     "2025-01-01",
+			AgeMetric:   tt.metric,
+		})
+
+		run(t, dir, "flags")
+		dated := flagexorcist.DatedFlags()
+		if len(dated) != 1 || !dated[0].IntroducedAt.Equal(at(2001)) {
+			t.Errorf("%s: DatedFlags() = %+v, want EnableX dated 2001", tt.name, dated)
+		}
+		if reads := flagexorcist.CurrentStats().Reads; reads != 1 {
+			t.Errorf("%s: read %d versions of the file, want only the one version there is", tt.name, reads)
+		}
+	}
+}
+
+func TestDateSource(t *testing.T) {
+	// EnableX was written long before it was rebased onto the branch
+	dir := t.TempDir()
+	if _, err := git.PlainInit(dir, false); err != nil {
+		t.Fatalf("Failed to init repo: %s", err)
+	}
+	filename := filepath.Join(dir, "src", "flags", "flags.go")
+	if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	err := os.WriteFile(filename,
+		[]byte(flagsSource), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	written := time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+	rebased := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	gitDated(t, dir, written, rebased, "add", ".")
+	gitDated(t, dir, written, rebased, "commit", "-q", "-m", "Add EnableX")
+
+	for _, backend := range []flagexorcist.GitBackend{
+		flagexorcist.GitBackendGoGit, flagexorcist.GitBackendExec,
+	} {
+		for source, want := range map[flagexorcist.DateSource]time.Time{
+			flagexorcist.DateSourceAuthor:    written,
+			flagexorcist.DateSourceCommitter: rebased,
+		} {
+			initialize(t, flagexorcist.Config{
+				Cutoff:      "3650d",
+				FlagSymbols: []string{"EnableX"},
+				RepoPath:    dir,
+				RunDate:     "2025-01-01",
+				DateSource:  source,
+				GitBackend:  backend,
+			})
+
+			run(t, dir, "flags")
+			dated := flagexorcist.DatedFlags()
+			if len(dated) != 1 || !dated[0].IntroducedAt.Equal(want) {
+				t.Errorf("With %s and DATE_SOURCE=%s, DatedFlags() = %+v, want EnableX introduced %s",
+					backend, source, dated, want)
+			}
+		}
+	}
+}
+
+func TestHistoryWindow(t *testing.T) {
+	dir := t.TempDir()
+	repo := initFlagRepo(t, dir)
+	commitFile(t, repo, "README", "flags\n",
+		"Add README", time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC))
+	commitFile(t, repo, "README", "flags!\n",
+		"Update README", time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	// EnableX isn't old enough, unless the search stops before reaching the
+	// commit that added it, when it is assumed to be
+	since := time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)
+	for name, cfg := range map[string]flagexorcist.Config{
+		"unbounded":    {},
+		"depth":        {MaxHistoryDepth: 1},
+		"since":        {HistorySince: since},
+		"depth (exec)": {MaxHistoryDepth: 1, GitBackend: flagexorcist.GitBackendExec},
+		"since (exec)": {HistorySince: since, GitBackend: flagexorcist.GitBackendExec},
+	} {
+		cfg.Cutoff = "18250d"
+		cfg.FlagSymbols = []string{"EnableX"}
+		cfg.RepoPath = dir
+		cfg.RunDate = "2021-01-01"
+		initialize(t, cfg)
+
+		bounded := name != "unbounded"
+		findings := run(t, dir, "flags")
+		dated := flagexorcist.DatedFlags()
+		if len(dated) != 1 || dated[0].BeforeWindow != bounded || dated[0].Stale != bounded ||
+			(len(findings) > 0) != bounded {
+			t.Errorf("%s: Run() = %+v, DatedFlags() = %+v, want stale = %v",
+				name, findings, dated, bounded)
+		}
+	}
+}
+
+func TestRunSummary(t *testing.T) {
+	t.Parallel()
+
+	at := func(year int) time.Time { return time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC) }
+	search, checkout, reviews := flagexorcist.SymbolID("EnableSearch"),
+		flagexorcist.SymbolID("EnableCheckout"), flagexorcist.SymbolID("EnableReviews")
+	findings := []flagexorcist.Finding{
+		{Flag: search, Severity: flagexorcist.SeverityError, Enforced: true},
+		{Flag: checkout, Severity: flagexorcist.SeverityError},
+		{Flag: reviews, Severity: flagexorcist.SeverityInfo, Enforced: true},
+	}
+	flags := []flagexorcist.DatedFlag{
+		{Flag: checkout, IntroducedAt: at(2010), Stale: true},
+		{Flag: reviews, IntroducedAt: at(2020)},
+		{Flag: search, IntroducedAt: at(2005), Stale: true},
+		{Flag: flagexorcist.SymbolID("EnableWishlist"), Undated: true},
+	}
+
+	// Only EnableCheckout was stale last time
+	filename := filepath.Join(t.TempDir(), "summary.json")
+	previous, err := flagexorcist.ReadRunSummary(filename)
+	if err != nil || previous != nil {
+		t.Fatalf("ReadRunSummary() of a missing file = %v, %v, want nil", previous, err)
+	}
+	first := flagexorcist.Summarize(findings, nil, flags[:2], time.Second, nil)
+	if err := flagexorcist.WriteRunSummary(filename, first); err != nil {
+		t.Fatal(err)
+	}
+	if previous, err = flagexorcist.ReadRunSummary(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	// REPORT_TOP left out the finding about EnableSearch, which still counts
+	s := flagexorcist.Summarize(findings[1:], findings[:1], flags, time.Minute, previous)
+	if s.Findings != 3 || s.Failing != 1 || s.Unreported != 1 || s.Flags != 4 || s.Stale != 2 {
+		t.Errorf("Summarize() = %+v, want 3 findings, 1 failing, 1 unreported, 4 flags, 2 stale", s)
+	}
+	wantSeverities := map[flagexorcist.Severity]int{
+		flagexorcist.SeverityError: 2, flagexorcist.SeverityInfo: 1,
+	}
+	if !reflect.DeepEqual(s.BySeverity, wantSeverities) {
+		t.Errorf("BySeverity = %v, want %v", s.BySeverity, wantSeverities)
+	}
+	if s.Oldest == nil || s.Oldest.Flag != search {
+		t.Errorf("Oldest = %+v, want EnableSearch", s.Oldest)
+	}
+	if want := []flagexorcist.FlagID{checkout, search}; !reflect.DeepEqual(s.StaleFlags, want) {
+		t.Errorf("StaleFlags = %v, want %v", s.StaleFlags, want)
+	}
+	if want := []flagexorcist.FlagID{search}; !reflect.DeepEqual(s.NewlyStale, want) {
+		t.Errorf("NewlyStale = %v, want %v", s.NewlyStale, want)
+	}
+	if s.Duration != time.Minute {
+		t.Errorf("Duration = %v, want %v", s.Duration, time.Minute)
+	}
+}
+
+func TestSummaryOut(t *testing.T) {
+	dir := t.TempDir()
+	initFlagRepo(t, dir)
+	initialize(t, flagexorcist.Config{
+		Cutoff:      "3650d",
+		FlagSymbols: []string{"EnableX"},
+		RepoPath:    dir,
+		RunDate:     "2025-01-01",
+	})
+	summary := filepath.Join(t.TempDir(), "summary.json")
+	setFlag := func(name, value string) {
+		t.Helper()
+		if err := flagexorcist.Analyzer.Flags.Set(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() {
+		setFlag("summary-out", "")
+		setFlag("summary-previous", "")
+	})
+
+	// Compared to a run before EnableX was stale, it is newly stale, but
+	// not compared to the run after that
+	before := filepath.Join(t.TempDir(), "before.json")
+	if err := flagexorcist.WriteRunSummary(before, flagexorcist.RunSummary{}); err != nil {
+		t.Fatal(err)
+	}
+	setFlag("summary-out", summary)
+	for _, tt := range []struct {
+		previous string
+		wantNew  []flagexorcist.FlagID
+	}{
+		{previous: before, wantNew: []flagexorcist.FlagID{flagexorcist.SymbolID("EnableX")}},
+		{previous: summary, wantNew: []flagexorcist.FlagID{}},
+	} {
+		setFlag("summary-previous", tt.previous)
+		findings := run(t, dir, "flags")
+		s, err := flagexorcist.ReadRunSummary(summary)
+		if err != nil || s == nil {
+			t.Fatalf("ReadRunSummary() = %v, %v, want the run's summary", s, err)
+		}
+		if s.Findings != len(findings) || s.Stale != 1 || s.Packages == 0 {
+			t.Errorf("Summary = %+v, want %d findings and EnableX stale", s, len(findings))
+		}
+		if !reflect.DeepEqual(s.NewlyStale, tt.wantNew) {
+			t.Errorf("Compared to %s, NewlyStale = %v, want %v",
+				filepath.Base(tt.previous), s.NewlyStale, tt.wantNew)
+		}
+	}
+}
+
+func TestReportTop(t *testing.T) {
+	// Three stale flags, added two years apart, of which only the two oldest
+	// are reported
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatalf("Failed to init repo: %s", err)
+	}
+	for i, name := range []string{"EnableC", "EnableA", "EnableB"} {
+		source := fmt.Sprintf("package flags\n\nvar %s = true\n\nfunc Use%s() bool { return %s }\n",
+			name, name, name)
+		commitFile(t, repo, "src/flags/"+strings.ToLower(name)+".go", source,
+			"Add "+name, time.Date(2005-2*i, 1, 1, 0, 0, 0, 0, time.UTC))
+	}
+	initialize(t, flagexorcist.Config{
+		Cutoff:      "0",
+		FlagSymbols: []string{"EnableA", "EnableB", "EnableC"},
+		RepoPath:    dir,
+		RunDate:     "2025-01-01",
+		ReportTop:   2,
+	})
+
+	got := failing(run(t, dir, "flags"))
+	want := map[flagexorcist.FlagID]bool{
+		flagexorcist.SymbolID("EnableB"): true,
+		flagexorcist.SymbolID("EnableA"): true,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Failing flags = %v, want %v", got, want)
+	}
+	unreported := flagexorcist.UnreportedFindings()
+	if len(unreported) != 1 || unreported[0].Flag != flagexorcist.SymbolID("EnableC") {
+		t.Errorf("UnreportedFindings() = %+v, want the finding about EnableC", unreported)
+	}
+}
+
+func TestDoctor(t *testing.T) {
+	at := func(year int) time.Time { return time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC) }
+	origin := t.TempDir()
+	repo := initFlagRepo(t, origin)
+	commitFile(t, repo, "src/flags/keys.go", "package flags\n\nconst Checkout = \"new-checkout\"\n",
+		"Add new-checkout", at(2002))
+	shallow := t.TempDir()
+	out, err := exec.Command("git", "clone", "-q", "--depth=1", "file://"+origin, shallow).CombinedOutput()
+	if err != nil {
+		t.Fatalf("git clone failed: %s: %s", err, out)
+	}
+	noRepo := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(noRepo, "src", "flags"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"flags.go", "keys.go"} {
+		contents, err := os.ReadFile(filepath.Join(origin, "src", "flags", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(noRepo, "src", "flags", name), contents, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		dir  string
+		// Whether each check passed, by name
+		want map[string]bool
+	}{
+		{
+			name: "full clone",
+			dir:  origin,
+			want: map[string]bool{
+				"repo": true, "history": true,
+				"symbol EnableX": true, "symbol EnableY": false,
+				"key new-checkout": true, "key new-chekout": false,
+			},
+		},
+		{
+			name: "shallow clone",
+			dir:  shallow,
+			want: map[string]bool{
+				"repo": true, "history": false,
+				"symbol EnableX": true, "symbol EnableY": false,
+				"key new-checkout": true, "key new-chekout": false,
+			},
+		},
+		{
+			name: "no repo",
+			dir:  noRepo,
+			want: map[string]bool{
+				"repo":           false,
+				"symbol EnableX": true, "symbol EnableY": false,
+				"key new-checkout": true, "key new-chekout": false,
+			},
+		},
+	}
+	for _, tt := range tests {
+		initialize(t, flagexorcist.Config{
+			FlagSymbols: []string{"EnableX", "EnableY"},
+			FlagKeys:    []string{"new-checkout", "new-chekout"},
+			RepoPath:    tt.dir,
+		})
+		pkgs, err := packages.Load(&packages.Config{
+			Mode: packages.LoadAllSyntax,
+			Dir:  tt.dir,
+			Env:  append(os.Environ(), "GO111MODULE=off", "GOPATH="+tt.dir),
+		}, "flags")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := map[string]bool{}
+		fixes := map[string]string{}
+		for _, check := range flagexorcist.Doctor(pkgs) {
+			got[check.Name] = check.OK
+			fixes[check.Name] = check.Fix
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Doctor() = %v, want %v", tt.name, got, tt.want)
+		}
+		for name, suggestion := range map[string]string{
+			"symbol EnableY":  "did you mean EnableX?",
+			"key new-chekout": `did you mean "new-checkout"?`,
+		} {
+			if !strings.HasPrefix(fixes[name], suggestion) {
+				t.Errorf("%s: fix for %s = %q, want it to start with %q", tt.name, name, fixes[name], suggestion)
+			}
+		}
+	}
 }
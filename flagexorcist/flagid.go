@@ -0,0 +1,375 @@
+package flagexorcist
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// FlagKind is how a flag is referred to in code.
+type FlagKind string
+
+const (
+	// A Go identifier, like a const, var, or struct field.
+	FlagKindSymbol FlagKind = "symbol"
+	// A string key, like the ones passed to feature flag SDKs.
+	FlagKindKey FlagKind = "key"
+)
+
+// FlagID identifies a flag. A Go const whose value is a tracked key is the
+// same flag as the key, so it is identified by the key.
+type FlagID struct {
+	Kind FlagKind `json:"kind"`
+
+	// For symbols, the import path of the package declaring the symbol. For
+	// keys, the flag system the key belongs to, like launchdarkly. Empty
+	// matches any.
+	Namespace string `json:"namespace,omitempty"`
+
+	Name string `json:"name"`
+}
+
+// less orders flags by name, then namespace, then kind.
+func (id FlagID) less(other FlagID) bool {
+	if id.Name != other.Name {
+		return id.Name < other.Name
+	}
+	if id.Namespace != other.Namespace {
+		return id.Namespace < other.Namespace
+	}
+	return id.Kind < other.Kind
+}
+
+// SymbolID parses a symbol from FLAG_SYMBOLS. It can be qualified with the
+// import path of its package, like example.com/flags.EnableCheckout.
+func SymbolID(s string) FlagID {
+	if i := strings.LastIndex(s, "."); i >= 0 {
+		return FlagID{Kind: FlagKindSymbol, Namespace: s[:i], Name: s[i+1:]}
+	}
+	return FlagID{Kind: FlagKindSymbol, Name: s}
+}
+
+// KeyID parses a key from FLAG_KEYS. It can be prefixed with the flag system
+// it belongs to, like launchdarkly:new-checkout.
+func KeyID(s string) FlagID {
+	if i := strings.Index(s, ":"); i >= 0 {
+		return FlagID{Kind: FlagKindKey, Namespace: s[:i], Name: s[i+1:]}
+	}
+	return FlagID{Kind: FlagKindKey, Name: s}
+}
+
+// String returns the flag as it would be written in the config.
+func (id FlagID) String() string {
+	switch {
+	case id.Namespace == "":
+		return id.Name
+	case id.Kind == FlagKindKey:
+		return id.Namespace + ":" + id.Name
+	default:
+		return id.Namespace + "." + id.Name
+	}
+}
+
+// flagIDs indexes the flags being tracked.
+type flagIDs struct {
+	symbols map[string][]FlagID
+	keys    map[string]FlagID
+
+	// The vars holding maps whose keys are flags, by name
+	maps map[string][]FlagID
+
+	// The struct tag key naming the flag key of a field, or empty to not
+	// look at tags
+	tag string
+
+	// The config lookup functions whose keys are flags, by name, and the
+	// prefix the keys must have
+	configFuncs  map[string][]FlagID
+	configPrefix string
+
+	// The prefix of the environment variables that are flags, or empty to
+	// not look at environment variables
+	envPrefix string
+}
+
+func newFlagIDs(cfg Config) flagIDs {
+	ids := flagIDs{
+		symbols:      map[string][]FlagID{},
+		keys:         map[string]FlagID{},
+		maps:         map[string][]FlagID{},
+		tag:          cfg.FlagTag,
+		configFuncs:  map[string][]FlagID{},
+		configPrefix: cfg.ConfigKeyPrefix,
+		envPrefix:    cfg.EnvFlagPrefix,
+	}
+	for _, m := range cfg.FlagMaps {
+		id := SymbolID(m)
+		ids.maps[id.Name] = append(ids.maps[id.Name], id)
+	}
+	for _, f := range cfg.ConfigFuncs {
+		id := SymbolID(f)
+		ids.configFuncs[id.Name] = append(ids.configFuncs[id.Name], id)
+	}
+	for _, s := range cfg.FlagSymbols {
+		id := SymbolID(s)
+		ids.symbols[id.Name] = append(ids.symbols[id.Name], id)
+	}
+	for _, k := range cfg.FlagKeys {
+		id := KeyID(k)
+		ids.keys[id.Name] = id
+	}
+	return ids
+}
+
+// symbol returns the flag ident refers to, if any. tags are the flag keys
+// of struct fields, from fieldTags.
+func (ids flagIDs) symbol(
+	info *types.Info, ident *ast.Ident, tags map[*types.Var]string,
+) (FlagID, bool) {
+	obj := info.ObjectOf(ident)
+
+	// Consts holding a tracked key are the key, and so are fields tagged
+	// with one
+	if c, ok := obj.(*types.Const); ok && c.Val().Kind() == constant.String {
+		if id, ok := ids.keys[constant.StringVal(c.Val())]; ok {
+			return id, true
+		}
+	}
+	if v, ok := obj.(*types.Var); ok && v.IsField() {
+		if id, ok := ids.keys[tags[v]]; ok && tags[v] != "" {
+			return id, true
+		}
+	}
+
+	return match(ids.symbols[ident.Name], obj)
+}
+
+// match returns the first of ids that obj is, going by the package it is
+// declared in, if any.
+func match(ids []FlagID, obj types.Object) (FlagID, bool) {
+	for _, id := range ids {
+		if id.Namespace == "" {
+			return id, true
+		}
+		if obj != nil && obj.Pkg() != nil && obj.Pkg().Path() == id.Namespace {
+			return id, true
+		}
+	}
+	return FlagID{}, false
+}
+
+// key returns the flag lit refers to, if any.
+func (ids flagIDs) key(lit *ast.BasicLit) (FlagID, bool) {
+	if lit.Kind != token.STRING {
+		return FlagID{}, false
+	}
+	s, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		return FlagID{}, false
+	}
+	id, ok := ids.keys[s]
+	return id, ok
+}
+
+// configKey returns the key passed to one of the config lookup functions, if
+// the innermost node of stack is that argument or the name of a const passed
+// as it, like features.new_checkout in
+//
+//	viper.GetBool("features.new_checkout")
+//
+// and it has the config key prefix. Such keys are flags whether or not they
+// are in FLAG_KEYS. So are the names of environment variables with the env
+// flag prefix read with os.Getenv or os.LookupEnv, for which env is true.
+func (ids flagIDs) configKey(info *types.Info, stack []ast.Node) (_ FlagID, env, ok bool) {
+	if len(ids.configFuncs) == 0 && ids.envPrefix == "" || len(stack) < 2 {
+		return FlagID{}, false, false
+	}
+	arg := stack[len(stack)-1].(ast.Expr)
+	parent := stack[len(stack)-2]
+	if sel, ok := parent.(*ast.SelectorExpr); ok && sel.Sel == arg && len(stack) > 2 {
+		arg, parent = sel, stack[len(stack)-3]
+	}
+	call, ok := parent.(*ast.CallExpr)
+	if !ok || len(call.Args) == 0 || call.Args[0] != arg {
+		return FlagID{}, false, false
+	}
+	fn, ok := typeutil.Callee(info, call).(*types.Func)
+	if !ok {
+		return FlagID{}, false, false
+	}
+	// Without ENV_FLAG_PREFIX, environment lookups are only tracked if they
+	// are listed in CONFIG_FUNCS, by CONFIG_KEY_PREFIX
+	prefix := ids.configPrefix
+	if env = isEnvLookup(fn); env && ids.envPrefix != "" {
+		prefix = ids.envPrefix
+	} else if _, ok := match(ids.configFuncs[fn.Name()], fn); !ok {
+		return FlagID{}, false, false
+	}
+
+	value := info.Types[arg].Value
+	if value == nil || value.Kind() != constant.String {
+		return FlagID{}, false, false
+	}
+	key := constant.StringVal(value)
+	if !strings.HasPrefix(key, prefix) {
+		return FlagID{}, false, false
+	}
+	return FlagID{Kind: FlagKindKey, Name: key}, env, true
+}
+
+// isEnvLookup reports whether fn reads an environment variable by name.
+func isEnvLookup(fn *types.Func) bool {
+	return fn.Pkg() != nil && fn.Pkg().Path() == "os" &&
+		(fn.Name() == "Getenv" || fn.Name() == "LookupEnv")
+}
+
+// symbolReferences finds every reference to the tracked flags by an
+// identifier: symbols, consts and fields holding keys, and consts passed to
+// config lookup functions.
+func (ids flagIDs) symbolReferences(in *inspector.Inspector, info *types.Info) []reference {
+	refs := []reference{}
+	tags := ids.fieldTags(in, info)
+	_, entries := ids.mapEntries(in, info)
+
+	nodeFilter := []ast.Node{
+		(*ast.Ident)(nil),
+	}
+	in.WithStack(nodeFilter, func(node ast.Node, push bool, stack []ast.Node) bool {
+		if !push {
+			return true
+		}
+		n := node.(*ast.Ident)
+		env := false
+		id, ok := ids.symbol(info, n, tags)
+		if !ok {
+			id, env, ok = ids.configKey(info, stack)
+		}
+		if !ok {
+			return true
+		}
+		ref := reference{
+			id: id, pos: n.Pos(), search: n.Name,
+			declaration: isDeclaration(n) || isMapEntry(stack, entries),
+		}
+		if env {
+			ref.detector = SourceEnv
+		}
+		// A key used through a const is dated from where the const is
+		// declared, too
+		if c, ok := info.Uses[n].(*types.Const); ok && id.Kind == FlagKindKey {
+			ref.constAt = c.Pos()
+		}
+		refs = append(refs, ref)
+		return true
+	})
+
+	return refs
+}
+
+// keyLiteralReferences finds every string literal of a tracked key, and of a
+// key passed to a config lookup function.
+func (ids flagIDs) keyLiteralReferences(in *inspector.Inspector, info *types.Info) []reference {
+	refs := []reference{}
+	_, entries := ids.mapEntries(in, info)
+
+	nodeFilter := []ast.Node{
+		(*ast.BasicLit)(nil),
+	}
+	in.WithStack(nodeFilter, func(node ast.Node, push bool, stack []ast.Node) bool {
+		if !push {
+			return true
+		}
+		n := node.(*ast.BasicLit)
+		env := false
+		id, ok := ids.key(n)
+		if !ok {
+			id, env, ok = ids.configKey(info, stack)
+		}
+		if !ok {
+			return true
+		}
+		// Keys are declared as consts or vars, or as entries of FLAG_MAPS
+		_, inSpec := stack[len(stack)-2].(*ast.ValueSpec)
+		ref := reference{
+			id: id, pos: n.Pos(), search: n.Value,
+			declaration: inSpec || isMapEntry(stack, entries), literal: true,
+		}
+		if env {
+			ref.detector = SourceEnv
+		}
+		refs = append(refs, ref)
+		return true
+	})
+
+	return refs
+}
+
+// fieldTags returns the flag keys in the struct tags of the fields declared
+// or used in the package, like enable-checkout in
+//
+//	EnableCheckout bool `flag:"enable-checkout,default=false"`
+//
+// Fields of structs from other packages are found through the selectors and
+// composite literals that use them.
+func (ids flagIDs) fieldTags(in *inspector.Inspector, info *types.Info) map[*types.Var]string {
+	tags := map[*types.Var]string{}
+	if ids.tag == "" {
+		return tags
+	}
+	addStruct := func(t types.Type) {
+		if ptr, ok := t.(*types.Pointer); ok {
+			t = ptr.Elem()
+		}
+		s, ok := t.Underlying().(*types.Struct)
+		if !ok {
+			return
+		}
+		for i := 0; i < s.NumFields(); i++ {
+			value, _, _ := strings.Cut(reflect.StructTag(s.Tag(i)).Get(ids.tag), ",")
+			if value != "" {
+				tags[s.Field(i)] = value
+			}
+		}
+	}
+
+	nodeFilter := []ast.Node{
+		(*ast.StructType)(nil),
+		(*ast.CompositeLit)(nil),
+		(*ast.SelectorExpr)(nil),
+	}
+	in.Preorder(nodeFilter, func(node ast.Node) {
+		switch n := node.(type) {
+		case *ast.StructType, *ast.CompositeLit:
+			if t := info.TypeOf(n.(ast.Expr)); t != nil {
+				addStruct(t)
+			}
+		case *ast.SelectorExpr:
+			sel, ok := info.Selections[n]
+			if !ok || sel.Kind() != types.FieldVal {
+				return
+			}
+			// Walk down through embedded fields to the struct declaring
+			// the field
+			t := sel.Recv()
+			for _, i := range sel.Index() {
+				addStruct(t)
+				if ptr, ok := t.(*types.Pointer); ok {
+					t = ptr.Elem()
+				}
+				s, ok := t.Underlying().(*types.Struct)
+				if !ok {
+					return
+				}
+				t = s.Field(i).Type()
+			}
+		}
+	})
+	return tags
+}
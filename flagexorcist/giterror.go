@@ -0,0 +1,65 @@
+package flagexorcist
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/tools/go/analysis"
+)
+
+// GitErrorPolicy is what to do when reading a flag's history fails.
+type GitErrorPolicy string
+
+const (
+	// Fail the analysis of the package with the error.
+	GitErrorFail GitErrorPolicy = "fail"
+	// Log the error and leave the flag undated.
+	GitErrorSkip GitErrorPolicy = "skip"
+	// Report the flag as undated with a diagnostic saying why.
+	GitErrorReport GitErrorPolicy = "report"
+)
+
+func (p *GitErrorPolicy) SetValue(s string) error {
+	switch policy := GitErrorPolicy(s); policy {
+	case GitErrorFail, GitErrorSkip, GitErrorReport:
+		*p = policy
+		return nil
+	}
+	return errors.Errorf("invalid git error policy %q, must be fail, skip or report", s)
+}
+
+// gitFailure is a job whose reference couldn't be dated because reading the
+// history failed.
+type gitFailure struct {
+	job datingJob
+	err error
+}
+
+// reportGitFailures reports each flag that couldn't be dated because reading
+// its history failed, once, at the first reference that failed.
+func (r *runner) reportGitFailures(
+	pass *analysis.Pass,
+	failures []gitFailure,
+	runDate time.Time,
+	root string,
+	changed map[string]bool,
+) {
+	reported := map[FlagID]bool{}
+	for _, failure := range failures {
+		flag := failure.job.ref.id
+		if reported[flag] || r.ignored(failure.job.filename) ||
+			!r.inChanged(changed, root, failure.job.filename) {
+			continue
+		}
+		reported[flag] = true
+		r.report(pass, failure.job.ref.pos, runDate, Finding{
+			Flag:     flag,
+			Category: CategoryGitError,
+			Message: fmt.Sprintf(
+				"Can't tell when flag '%v' was added because reading its history failed: %v",
+				flag, failure.err,
+			),
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// MaxAnnotations is the most annotations GitHub accepts in a single check run
+// request. More can be added by updating the check run.
+const MaxAnnotations = 50
+
+type CheckRun struct {
+	ID         int64           `json:"id,omitempty"`
+	Name       string          `json:"name,omitempty"`
+	HeadSHA    string          `json:"head_sha,omitempty"`
+	Status     string          `json:"status,omitempty"`
+	Conclusion string          `json:"conclusion,omitempty"`
+	Output     *CheckRunOutput `json:"output,omitempty"`
+}
+
+type CheckRunOutput struct {
+	Title       string       `json:"title"`
+	Summary     string       `json:"summary"`
+	Text        string       `json:"text,omitempty"`
+	Annotations []Annotation `json:"annotations,omitempty"`
+}
+
+type Annotation struct {
+	Path      string `json:"path"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+
+	// One of notice, warning or failure
+	AnnotationLevel string `json:"annotation_level"`
+
+	Title   string `json:"title,omitempty"`
+	Message string `json:"message"`
+}
+
+// CreateCheckRun creates a check run on repo, which is an owner/name slug.
+func (c *Client) CreateCheckRun(ctx context.Context, repo string, run CheckRun) (CheckRun, error) {
+	var created CheckRun
+	err := c.do(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/check-runs", repo), run, &created)
+	return created, err
+}
+
+// UpdateCheckRun updates an existing check run. Annotations are appended to
+// the ones already on the check run.
+func (c *Client) UpdateCheckRun(ctx context.Context, repo string, id int64, run CheckRun) error {
+	return c.do(ctx, http.MethodPatch, fmt.Sprintf("/repos/%s/check-runs/%d", repo, id), run, nil)
+}
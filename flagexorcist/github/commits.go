@@ -0,0 +1,90 @@
+package github
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// commitsPerPage is the most commits GitHub returns per page.
+const commitsPerPage = 100
+
+type Commit struct {
+	SHA    string        `json:"sha"`
+	Commit CommitDetails `json:"commit"`
+}
+
+type CommitDetails struct {
+	Author    Signature `json:"author"`
+	Committer Signature `json:"committer"`
+	Message   string    `json:"message"`
+}
+
+type Signature struct {
+	Name  string    `json:"name"`
+	Email string    `json:"email"`
+	Date  time.Time `json:"date"`
+}
+
+// ListCommits lists the commits reachable from sha that changed path, newest
+// first. repo is the owner/name slug.
+func (c *Client) ListCommits(ctx context.Context, repo, sha, path string) ([]Commit, error) {
+	commits := []Commit{}
+	for page := 1; ; page++ {
+		query := url.Values{
+			"sha":      {sha},
+			"path":     {path},
+			"per_page": {fmt.Sprint(commitsPerPage)},
+			"page":     {fmt.Sprint(page)},
+		}
+
+		batch := []Commit{}
+		err := c.do(ctx, http.MethodGet, "/repos/"+repo+"/commits?"+query.Encode(), nil, &batch)
+		if err != nil {
+			return nil, err
+		}
+		commits = append(commits, batch...)
+		if len(batch) < commitsPerPage {
+			return commits, nil
+		}
+	}
+}
+
+type fileContents struct {
+	Content  string `json:"content"`
+	Encoding string `json:"encoding"`
+}
+
+// FileContents returns the contents of path at ref.
+func (c *Client) FileContents(ctx context.Context, repo, path, ref string) ([]byte, error) {
+	query := url.Values{"ref": {ref}}
+	contents := fileContents{}
+	err := c.do(ctx, http.MethodGet,
+		"/repos/"+repo+"/contents/"+escapePath(path)+"?"+query.Encode(), nil, &contents,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if contents.Encoding != "base64" {
+		return nil, errors.Errorf("unsupported encoding %q for %s", contents.Encoding, path)
+	}
+
+	// GitHub wraps the encoded content in lines
+	b, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(contents.Content, "\n", ""))
+	return b, errors.Wrapf(err, "decode %s", path)
+}
+
+// escapePath escapes each segment of a slash-separated path.
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
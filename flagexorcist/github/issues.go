@@ -0,0 +1,74 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// issuesPerPage is the most issues GitHub returns per page.
+const issuesPerPage = 100
+
+type Issue struct {
+	Number  int    `json:"number"`
+	Title   string `json:"title"`
+	Body    string `json:"body"`
+	HTMLURL string `json:"html_url"`
+
+	// Set if the issue is a pull request, which the issues API also lists
+	PullRequest *struct{} `json:"pull_request,omitempty"`
+}
+
+// IssueRequest creates or edits an issue. Labels replace the issue's labels
+// when editing, so they are left out if nil.
+type IssueRequest struct {
+	Title  string   `json:"title,omitempty"`
+	Body   string   `json:"body,omitempty"`
+	Labels []string `json:"labels,omitempty"`
+}
+
+// ListIssues lists the open issues on repo with label, leaving out pull
+// requests.
+func (c *Client) ListIssues(ctx context.Context, repo, label string) ([]Issue, error) {
+	issues := []Issue{}
+	for page := 1; ; page++ {
+		query := url.Values{
+			"labels":   {label},
+			"state":    {"open"},
+			"per_page": {fmt.Sprint(issuesPerPage)},
+			"page":     {fmt.Sprint(page)},
+		}
+
+		batch := []Issue{}
+		err := c.do(ctx, http.MethodGet, "/repos/"+repo+"/issues?"+query.Encode(), nil, &batch)
+		if err != nil {
+			return nil, err
+		}
+		for _, issue := range batch {
+			if issue.PullRequest == nil {
+				issues = append(issues, issue)
+			}
+		}
+		if len(batch) < issuesPerPage {
+			return issues, nil
+		}
+	}
+}
+
+// CreateIssue opens an issue on repo.
+func (c *Client) CreateIssue(ctx context.Context, repo string, issue IssueRequest) (Issue, error) {
+	var created Issue
+	err := c.do(ctx, http.MethodPost, "/repos/"+repo+"/issues", issue, &created)
+	return created, err
+}
+
+// UpdateIssue edits an existing issue.
+func (c *Client) UpdateIssue(
+	ctx context.Context, repo string, number int, issue IssueRequest,
+) (Issue, error) {
+	var updated Issue
+	path := fmt.Sprintf("/repos/%s/issues/%d", repo, number)
+	err := c.do(ctx, http.MethodPatch, path, issue, &updated)
+	return updated, err
+}
@@ -0,0 +1,77 @@
+package flagexorcist
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/dgunay/flag-exorcist/flagexorcist/github"
+	"github.com/samber/mo"
+)
+
+// githubHistory dates symbols through the GitHub API, for when a shallow
+// clone doesn't have the commits locally.
+type githubHistory struct {
+	r      *runner
+	client *github.Client
+
+	// The owner/name slug of the repo
+	repo string
+	// The commit to search the history of
+	ref string
+}
+
+func (r *runner) githubHistory(ref string) githubHistory {
+	return githubHistory{
+		r:      r,
+		client: github.NewClient(r.cfg.GitHubAPIURL, r.cfg.GitHubToken),
+		repo:   r.cfg.GitHubRepository,
+		ref:    ref,
+	}
+}
+
+func (h githubHistory) IntroducedAt(file, symbol string) (time.Time, bool) {
+	intro, ok := h.introduction(file, symbol).Get()
+	return intro.at, ok
+}
+
+func (h githubHistory) introduction(file, symbol string) mo.Option[introduction] {
+	ctx := context.Background()
+	commits, err := h.client.ListCommits(ctx, h.repo, h.ref, file)
+	if err != nil {
+		h.r.l.Warn().Err(err).Str("file", file).Msg("Failed to list commits from GitHub")
+		return mo.None[introduction]()
+	}
+
+	// Oldest first, so the first commit with the symbol added it
+	for i := len(commits) - 1; i >= 0; i-- {
+		commit := commits[i]
+		contents, err := h.client.FileContents(ctx, h.repo, file, commit.SHA)
+		if err != nil {
+			// The file may not have existed at this path yet
+			h.r.l.Debug().Err(err).Str("commit", commit.SHA).Msg("Failed to get file from GitHub")
+			continue
+		}
+		if !strings.Contains(string(contents), symbol) {
+			continue
+		}
+
+		at := commit.Commit.Author.Date
+		if h.r.cfg.DateSource == DateSourceCommitter {
+			at = commit.Commit.Committer.Date
+		}
+		h.r.l.Debug().
+			Str("symbol", symbol).
+			Str("file", file).
+			Str("commit", commit.SHA).
+			Str("when", at.String()).
+			Msg("Symbol found in commit on GitHub")
+		return mo.Some(introduction{
+			at:      at,
+			author:  signature(commit.Commit.Author.Name, commit.Commit.Author.Email),
+			commit:  commit.SHA,
+			subject: firstLine(commit.Commit.Message),
+		})
+	}
+	return mo.None[introduction]()
+}
@@ -0,0 +1,93 @@
+package flagexorcist
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+// hardcodedValue returns the value of the flag declared at pos, if it is
+// hardcoded: a boolean const, or a var initialized to a constant boolean that
+// is never assigned again. Exported vars outside of package main could be
+// assigned by other packages, so they are never hardcoded.
+func hardcodedValue(pass *analysis.Pass, pos token.Pos) (bool, bool) {
+	value, ok := fixedValue(pass, definedAt(pass.TypesInfo, pos))
+	if !ok || value.Kind() != constant.Bool {
+		return false, false
+	}
+	return constant.BoolVal(value), true
+}
+
+// fixedValue returns the value of a const, or of a var in the package that
+// is initialized to a constant and never assigned again, like
+// hardcodedValue.
+func fixedValue(pass *analysis.Pass, obj types.Object) (constant.Value, bool) {
+	switch obj := obj.(type) {
+	case *types.Const:
+		return obj.Val(), true
+	case *types.Var:
+		if obj.IsField() || obj.Pkg() != pass.Pkg || obj.Exported() && pass.Pkg.Name() != "main" {
+			return nil, false
+		}
+	default:
+		return nil, false
+	}
+
+	value, ok := initialValue(pass, obj)
+	if !ok || isAssigned(pass, obj) {
+		return nil, false
+	}
+	return value, true
+}
+
+// initialValue returns the constant a var is initialized to in its
+// declaration, if any.
+func initialValue(pass *analysis.Pass, obj types.Object) (constant.Value, bool) {
+	in := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	var value constant.Value
+	in.Preorder([]ast.Node{(*ast.ValueSpec)(nil)}, func(node ast.Node) {
+		spec := node.(*ast.ValueSpec)
+		for i, name := range spec.Names {
+			if name.Pos() == obj.Pos() && i < len(spec.Values) {
+				value = pass.TypesInfo.Types[spec.Values[i]].Value
+			}
+		}
+	})
+	return value, value != nil
+}
+
+// isAssigned reports whether obj is assigned anywhere after its declaration,
+// or has its address taken so that it could be.
+func isAssigned(pass *analysis.Pass, obj types.Object) bool {
+	in := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	refersTo := func(expr ast.Expr) bool {
+		ident, ok := astutil.Unparen(expr).(*ast.Ident)
+		return ok && pass.TypesInfo.Uses[ident] == obj
+	}
+
+	assigned := false
+	nodeFilter := []ast.Node{
+		(*ast.AssignStmt)(nil),
+		(*ast.IncDecStmt)(nil),
+		(*ast.UnaryExpr)(nil),
+	}
+	in.Preorder(nodeFilter, func(node ast.Node) {
+		switch node := node.(type) {
+		case *ast.AssignStmt:
+			for _, lhs := range node.Lhs {
+				assigned = assigned || refersTo(lhs)
+			}
+		case *ast.IncDecStmt:
+			assigned = assigned || refersTo(node.X)
+		case *ast.UnaryExpr:
+			assigned = assigned || node.Op == token.AND && refersTo(node.X)
+		}
+	})
+	return assigned
+}
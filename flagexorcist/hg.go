@@ -0,0 +1,112 @@
+package flagexorcist
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/samber/mo"
+)
+
+func init() {
+	RegisterHistoryProvider("hg", func(cfg Config, root string) (HistoryProvider, error) {
+		if _, err := exec.LookPath("hg"); err != nil {
+			return nil, errors.Wrap(err, "the hg backend needs Mercurial installed")
+		}
+		return hgHistory{r: &r, root: root}, nil
+	})
+}
+
+// isHgRepo reports whether root is a Mercurial checkout.
+func isHgRepo(root string) bool {
+	info, err := os.Stat(filepath.Join(root, ".hg"))
+	return err == nil && info.IsDir()
+}
+
+// hgHistory reads Mercurial history with the hg binary. Mercurial has no
+// separate committer date, so DATE_SOURCE makes no difference, and history
+// is always searched in full.
+type hgHistory struct {
+	r    *runner
+	root string
+}
+
+func (h hgHistory) IntroducedAt(file, symbol string) (time.Time, bool) {
+	return introducedAt(h, file, symbol)
+}
+
+func (h hgHistory) introduction(file, symbol string) (mo.Option[introduction], error) {
+	// The ancestors of the working copy that touched the file, oldest first
+	revset := "::. and file('path:" + file + "')"
+	if h.r.cfg.FirstParent {
+		revset = "_firstancestors(.) and file('path:" + file + "')"
+	}
+	out, err := h.hg("log", "-r", revset, "--template", "{node} {author}\t{date|hgdate}\t{desc|firstline}\n")
+	if err != nil {
+		return mo.None[introduction](), err
+	}
+
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		node, rest, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		author, rest, _ := strings.Cut(rest, "\t")
+		date, desc, _ := strings.Cut(rest, "\t")
+		contents, err := h.hg("cat", "-r", node, "path:"+file)
+		if err != nil || !strings.Contains(string(contents), symbol) {
+			continue
+		}
+
+		at, err := parseHgDate(date)
+		if err != nil {
+			return mo.None[introduction](), err
+		}
+		h.r.l.Debug().
+			Str("symbol", symbol).
+			Str("file", file).
+			Str("commit", node).
+			Str("when", at.String()).
+			Msg("Symbol found in commit")
+		return mo.Some(introduction{at: at, author: author, commit: node, subject: desc}), nil
+	}
+	return mo.None[introduction](), nil
+}
+
+// headDate returns the date of the working copy's parent revision.
+func (h hgHistory) headDate() (time.Time, error) {
+	out, err := h.hg("log", "-r", ".", "--template", "{date|hgdate}")
+	if err != nil {
+		return time.Time{}, err
+	}
+	return parseHgDate(string(out))
+}
+
+// hg runs hg on the repo and returns its output.
+func (h hgHistory) hg(args ...string) ([]byte, error) {
+	args = append([]string{"-R", h.root}, args...)
+	cmd := exec.Command("hg", args...)
+	// Keep user config like aliases and pagers out of the output
+	cmd.Env = append(os.Environ(), "HGPLAIN=1")
+	out, err := cmd.Output()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return nil, errors.Wrapf(err, "hg %s: %s",
+			strings.Join(args, " "), strings.TrimSpace(string(exitErr.Stderr)),
+		)
+	}
+	return out, errors.Wrapf(err, "hg %s", strings.Join(args, " "))
+}
+
+// parseHgDate parses an hgdate, which is a unix time and a timezone offset.
+func parseHgDate(s string) (time.Time, error) {
+	unix, _, _ := strings.Cut(strings.TrimSpace(s), " ")
+	secs, err := strconv.ParseInt(unix, 10, 64)
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "parse hg date %q", s)
+	}
+	return time.Unix(secs, 0), nil
+}
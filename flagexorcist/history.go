@@ -0,0 +1,424 @@
+package flagexorcist
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/pkg/errors"
+	"github.com/samber/mo"
+)
+
+// GitBackend is how history is read. Besides the built-in backends, it can be
+// the name of any registered HistoryProvider.
+type GitBackend string
+
+const (
+	// Read history in-process with go-git.
+	GitBackendGoGit GitBackend = "go-git"
+	// Shell out to the git binary, which is much faster on big repos.
+	GitBackendExec GitBackend = "exec"
+)
+
+func (b *GitBackend) SetValue(s string) error {
+	switch backend := GitBackend(s); backend {
+	case GitBackendGoGit, GitBackendExec:
+		*b = backend
+		return nil
+	}
+	if _, ok := historyProvider(s); ok {
+		*b = GitBackend(s)
+		return nil
+	}
+	return errors.Errorf(
+		"invalid git backend %q, must be go-git, exec, or a registered history provider", s,
+	)
+}
+
+// HistoryProvider finds when symbols were added to files, so that flags can
+// be dated from something other than the built-in git backends.
+type HistoryProvider interface {
+	// IntroducedAt returns when symbol first appeared in file, which is
+	// relative to the root of the checkout. False if it never did.
+	IntroducedAt(file, symbol string) (time.Time, bool)
+}
+
+// HistoryProviderFactory makes the provider for the checkout at root.
+type HistoryProviderFactory func(cfg Config, root string) (HistoryProvider, error)
+
+var (
+	providersMu      sync.Mutex
+	historyProviders = map[string]HistoryProviderFactory{}
+)
+
+// RegisterHistoryProvider makes a provider available to GIT_BACKEND under
+// name. It must be called before the config is read, usually from an init
+// function.
+func RegisterHistoryProvider(name string, factory HistoryProviderFactory) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	historyProviders[name] = factory
+}
+
+func historyProvider(name string) (HistoryProviderFactory, bool) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	factory, ok := historyProviders[name]
+	return factory, ok
+}
+
+// detailedHistory is implemented by providers that can tell when a date is
+// only a bound, because the history searched was cut short.
+type detailedHistory interface {
+	introduction(file, symbol string) (mo.Option[introduction], error)
+}
+
+// introducedIn asks p when symbol was added to file.
+func introducedIn(p HistoryProvider, file, symbol string) (mo.Option[introduction], error) {
+	if p, ok := p.(detailedHistory); ok {
+		return p.introduction(file, symbol)
+	}
+	at, ok := p.IntroducedAt(file, symbol)
+	if !ok {
+		return mo.None[introduction](), nil
+	}
+	return mo.Some(introduction{at: at}), nil
+}
+
+// introducedAt answers IntroducedAt for a detailed provider. Reading the
+// history failing is the same as not finding the symbol.
+func introducedAt(p detailedHistory, file, symbol string) (time.Time, bool) {
+	found, err := p.introduction(file, symbol)
+	intro, ok := found.Get()
+	return intro.at, ok && err == nil
+}
+
+// modificationHistory is implemented by providers that can tell when a line
+// referencing a symbol last changed.
+type modificationHistory interface {
+	lastModified(file, symbol string) (mo.Option[introduction], error)
+}
+
+// lookUp asks p when symbol was added to file or, if AgeMetric is
+// last_modified, when a line of file referencing it last changed. Providers
+// that can't tell the latter fall back to the former.
+func (r *runner) lookUp(
+	p HistoryProvider, file, symbol string,
+) (mo.Option[introduction], error) {
+	if r.cfg.AgeMetric == AgeMetricLastModified {
+		if p, ok := p.(modificationHistory); ok {
+			return p.lastModified(file, symbol)
+		}
+		r.noLastModified.Do(func() {
+			r.l.Warn().Msg("The history provider can't tell when flags were last modified, " +
+				"measuring from when they were introduced instead")
+		})
+	}
+	return introducedIn(p, file, symbol)
+}
+
+// historyFor returns the provider for the checkout at root. repo opens the
+// checkout's git repo, which the built-in backends read.
+func (r *runner) historyFor(root string, repo func() (*gitRepo, error)) (HistoryProvider, error) {
+	if isBuiltinBackend(r.cfg.GitBackend) {
+		opened, err := repo()
+		if err != nil {
+			return nil, err
+		}
+		if r.cfg.GitBackend == GitBackendExec {
+			return execHistory{r: r, repo: opened}, nil
+		}
+		return goGitHistory{r: r, repo: opened}, nil
+	}
+
+	factory, ok := historyProvider(string(r.cfg.GitBackend))
+	if !ok {
+		return nil, errors.Errorf("no history provider named %q", r.cfg.GitBackend)
+	}
+	return factory(r.cfg, root)
+}
+
+// headDater is implemented by providers for checkouts without a git repo
+// that can still tell the date of the checked out revision.
+type headDater interface {
+	headDate() (time.Time, error)
+}
+
+// nonGitHistory returns the provider for a checkout at root that isn't a git
+// repo: Mercurial if it is a Mercurial checkout, or else file modification
+// times, for exported tarballs and vendored snapshots.
+func (r *runner) nonGitHistory(root string) HistoryProvider {
+	if isHgRepo(root) {
+		if _, err := exec.LookPath("hg"); err == nil {
+			return hgHistory{r: r, root: root}
+		}
+	}
+
+	r.noRepo.Do(func() {
+		r.l.Warn().
+			Str("path", root).
+			Msg("Not a git repo, dating flags by file modification time")
+	})
+	return mtimeHistory{root: root}
+}
+
+func isBuiltinBackend(b GitBackend) bool {
+	return b == "" || b == GitBackendGoGit || b == GitBackendExec
+}
+
+// mtimeHistory dates symbols by when their file was last modified, for code
+// that isn't in a repo. It is only a rough guess, since any edit to the file
+// makes its flags look new.
+type mtimeHistory struct {
+	root string
+}
+
+func (h mtimeHistory) IntroducedAt(file, symbol string) (time.Time, bool) {
+	return introducedAt(h, file, symbol)
+}
+
+func (h mtimeHistory) introduction(file, symbol string) (mo.Option[introduction], error) {
+	info, err := os.Stat(filepath.Join(h.root, file))
+	if err != nil {
+		return mo.None[introduction](), nil
+	}
+	return mo.Some(introduction{at: info.ModTime(), byMtime: true}), nil
+}
+
+// lastModified is the file's modification time too, which is if anything a
+// better guess at when the flag last changed.
+func (h mtimeHistory) lastModified(file, symbol string) (mo.Option[introduction], error) {
+	return h.introduction(file, symbol)
+}
+
+// goGitHistory walks history in-process.
+type goGitHistory struct {
+	r    *runner
+	repo *gitRepo
+}
+
+func (h goGitHistory) IntroducedAt(file, symbol string) (time.Time, bool) {
+	return introducedAt(h, file, symbol)
+}
+
+func (h goGitHistory) introduction(file, symbol string) (mo.Option[introduction], error) {
+	return h.r.timeCommitted(h.repo, symbol, file)
+}
+
+func (h goGitHistory) lastModified(file, symbol string) (mo.Option[introduction], error) {
+	return h.r.timeModified(h.repo, symbol, file)
+}
+
+// execHistory asks the git binary, using its pickaxe search (git log -S) to
+// find the commits that changed how often the symbol appears in the file.
+type execHistory struct {
+	r    *runner
+	repo *gitRepo
+}
+
+func (h execHistory) IntroducedAt(file, symbol string) (time.Time, bool) {
+	return introducedAt(h, file, symbol)
+}
+
+func (h execHistory) introduction(file, symbol string) (mo.Option[introduction], error) {
+	repo := h.repo
+
+	// If the search is cut short, the symbol may already have been there at
+	// the oldest commit searched.
+	window := h.window()
+	rev, oldest, ok, err := h.searchRange(window)
+	if err != nil || !ok {
+		return mo.None[introduction](), err
+	}
+	if boundary, ok := h.boundary(oldest, file, symbol).Get(); ok {
+		return mo.Some(boundary), nil
+	}
+
+	args := append([]string{"log", "-S" + symbol}, window...)
+	commits, err := h.log(repo, append(args, rev, "--", h.pathspec(file))...)
+	if err != nil {
+		return mo.None[introduction](), err
+	}
+	h.r.progress.commits.Add(int64(len(commits)))
+	if len(commits) == 0 {
+		return mo.None[introduction](), nil
+	}
+
+	added := commits[len(commits)-1]
+	h.r.l.Debug().
+		Str("symbol", symbol).
+		Str("file", file).
+		Str("commit", added.hash).
+		Str("when", added.at.String()).
+		Msg("Symbol found in commit")
+	return mo.Some(introduction{
+		at:      added.at,
+		shallow: repo.shallow[plumbing.NewHash(added.hash)],
+		author:  added.author,
+		commit:  added.hash,
+		subject: added.subject,
+	}), nil
+}
+
+// lastModified finds the newest commit whose diff adds or removes a line of
+// file containing symbol, with git log -G.
+func (h execHistory) lastModified(file, symbol string) (mo.Option[introduction], error) {
+	repo := h.repo
+
+	window := h.window()
+	rev, oldest, ok, err := h.searchRange(window)
+	if err != nil || !ok {
+		return mo.None[introduction](), err
+	}
+
+	args := append([]string{"log", "-G" + regexp.QuoteMeta(symbol), "--max-count=1"}, window...)
+	commits, err := h.log(repo, append(args, rev, "--", h.pathspec(file))...)
+	if err != nil {
+		return mo.None[introduction](), err
+	}
+	h.r.progress.commits.Add(int64(len(commits)))
+	if len(commits) == 0 {
+		// Untouched since before the oldest commit searched
+		return h.boundary(oldest, file, symbol), nil
+	}
+
+	changed := commits[0]
+	return mo.Some(introduction{
+		at:      changed.at,
+		shallow: repo.shallow[plumbing.NewHash(changed.hash)],
+		author:  changed.author,
+		commit:  changed.hash,
+		subject: changed.subject,
+	}), nil
+}
+
+// window returns the git log options that format commits for log and limit
+// them to the history that is to be searched.
+func (h execHistory) window() []string {
+	cfg := h.r.cfg
+	format := "--format=%H %at %an <%ae>%x09%s"
+	if cfg.DateSource == DateSourceCommitter {
+		format = "--format=%H %ct %an <%ae>%x09%s"
+	}
+	window := []string{format}
+	if cfg.FirstParent {
+		window = append(window, "--first-parent")
+	}
+	if !cfg.HistorySince.IsZero() {
+		window = append(window, "--since="+cfg.HistorySince.Format(time.RFC3339))
+	}
+	return window
+}
+
+// searchRange returns the revisions to search and, if the search is cut
+// short by MaxHistoryDepth or HistorySince, the oldest commit in it. ok is
+// false if there are no commits to search.
+func (h execHistory) searchRange(
+	window []string,
+) (rev string, oldest mo.Option[loggedCommit], ok bool, err error) {
+	cfg := h.r.cfg
+	repo := h.repo
+	rev = repo.headRev()
+	if cfg.MaxHistoryDepth <= 0 && cfg.HistorySince.IsZero() {
+		return rev, mo.None[loggedCommit](), true, nil
+	}
+
+	args := append([]string{"log"}, window...)
+	if cfg.MaxHistoryDepth > 0 {
+		args = append(args, "--max-count="+strconv.Itoa(cfg.MaxHistoryDepth))
+	}
+	commits, err := h.log(repo, append(args, repo.headRev())...)
+	if err != nil {
+		return "", mo.None[loggedCommit](), false, err
+	}
+	if len(commits) == 0 {
+		return "", mo.None[loggedCommit](), false, nil
+	}
+
+	last := commits[len(commits)-1]
+	if _, err := h.git(repo, "rev-parse", "--verify", "--quiet", last.hash+"^"); err != nil {
+		// The whole history is searched after all
+		return rev, mo.None[loggedCommit](), true, nil
+	}
+	return last.hash + ".." + repo.headRev(), mo.Some(last), true, nil
+}
+
+// pathspec returns the pathspec git matches file by, which ignores case if
+// FoldPathCase is set.
+func (h execHistory) pathspec(file string) string {
+	if h.r.cfg.FoldPathCase {
+		return ":(icase)" + file
+	}
+	return file
+}
+
+// boundary returns the oldest commit searched as a bound on when symbol was
+// added to file, if the search was cut short there and symbol was already
+// in file.
+func (h execHistory) boundary(
+	oldest mo.Option[loggedCommit], file, symbol string,
+) mo.Option[introduction] {
+	commit, ok := oldest.Get()
+	if !ok {
+		return mo.None[introduction]()
+	}
+	contents, err := h.git(h.repo, "show", commit.hash+":"+file)
+	if err != nil || !bytes.Contains(contents, []byte(symbol)) {
+		return mo.None[introduction]()
+	}
+	return mo.Some(introduction{at: commit.at, beforeWindow: true})
+}
+
+// loggedCommit is a line of `git log --format="%H %at %an <%ae>%x09%s"`.
+type loggedCommit struct {
+	hash    string
+	at      time.Time
+	author  string
+	subject string
+}
+
+// log runs git log, which must be formatted as hash, unix time, author, a tab
+// and subject, newest first.
+func (h execHistory) log(repo *gitRepo, args ...string) ([]loggedCommit, error) {
+	out, err := h.git(repo, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	commits := []loggedCommit{}
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		hash, rest, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		unix, rest, _ := strings.Cut(rest, " ")
+		author, subject, _ := strings.Cut(rest, "\t")
+		secs, err := strconv.ParseInt(unix, 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse commit time %q", unix)
+		}
+		commits = append(commits, loggedCommit{
+			hash: hash, at: time.Unix(secs, 0), author: author, subject: subject,
+		})
+	}
+	return commits, nil
+}
+
+// git runs git on the repo and returns its output.
+func (h execHistory) git(repo *gitRepo, args ...string) ([]byte, error) {
+	args = append(h.r.gitArgs(repo.root), args...)
+	out, err := exec.Command("git", args...).Output()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return nil, errors.Wrapf(err, "git %s: %s",
+			strings.Join(args, " "), strings.TrimSpace(string(exitErr.Stderr)),
+		)
+	}
+	return out, errors.Wrapf(err, "git %s", strings.Join(args, " "))
+}
@@ -0,0 +1,186 @@
+package flagexorcist
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/dgunay/flag-exorcist/flagexorcist/rewrite"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// inlineFix returns a fix replacing the simple usages of a flag, like
+// `if flags.EnableX {`, with the value it is declared with: its value if it
+// is a const or a var that is never assigned, or the default in its struct
+// tag if it is a field, like `flag:"enable-x,default=false"`. The code that
+// is dead once it is replaced is left for other tools to clean up. Imports
+// left unused are deleted. Usages in vendored code are left alone.
+func (r *runner) inlineFix(
+	pass *analysis.Pass, flag FlagID, usages []reference,
+) (analysis.SuggestedFix, bool) {
+	edits := []analysis.TextEdit{}
+
+	// How many usages through each imported package were replaced in each
+	// file, to tell when the import is no longer used
+	qualified := map[*ast.File]map[*types.PkgName]int{}
+	for _, usage := range usages {
+		if usage.literal || r.vendored(pass.Fset.Position(usage.pos).Filename) {
+			continue
+		}
+		file := fileAt(pass, usage.pos)
+		if file == nil {
+			continue
+		}
+		path, _ := astutil.PathEnclosingInterval(file, usage.pos, usage.pos)
+		id, ok := path[0].(*ast.Ident)
+		if !ok {
+			continue
+		}
+		var sel *ast.SelectorExpr
+		if len(path) > 1 {
+			if s, ok := path[1].(*ast.SelectorExpr); ok && s.Sel == id {
+				sel = s
+			}
+		}
+
+		value, ok := r.inlineValue(pass, id, sel)
+		if !ok {
+			continue
+		}
+		edit, ok := rewrite.InlineEdit(file, id, value)
+		if !ok {
+			continue
+		}
+		edits = append(edits, edit.TextEdit())
+
+		if sel == nil {
+			continue
+		}
+		if name, ok := pass.TypesInfo.Uses[sel.X.(*ast.Ident)].(*types.PkgName); ok {
+			if qualified[file] == nil {
+				qualified[file] = map[*types.PkgName]int{}
+			}
+			qualified[file][name]++
+		}
+	}
+	if len(edits) == 0 {
+		return analysis.SuggestedFix{}, false
+	}
+
+	for file, names := range qualified {
+		for name, replaced := range names {
+			if uses(pass.TypesInfo, file, name) > replaced {
+				continue
+			}
+			if del, ok := rewrite.ImportEdit(file, name); ok {
+				edits = append(edits, del.TextEdit())
+			}
+		}
+	}
+	return analysis.SuggestedFix{
+		Message:   fmt.Sprintf("Inline the declared value of '%v'", flag),
+		TextEdits: edits,
+	}, true
+}
+
+// inlineValue returns the value to replace the usage of a flag at id with,
+// if it is known at compile time and a literal of it has the same type.
+// sel is the selector id is the field or qualified name in, if any.
+func (r *runner) inlineValue(
+	pass *analysis.Pass, id *ast.Ident, sel *ast.SelectorExpr,
+) (constant.Value, bool) {
+	obj := pass.TypesInfo.Uses[id]
+	if obj == nil {
+		return nil, false
+	}
+	// A literal is untyped, so it would change the type of a variable
+	// declared from it unless the flag has the type the literal defaults to
+	switch types.Default(obj.Type()) {
+	case types.Typ[types.Bool], types.Typ[types.String], types.Typ[types.Int]:
+	default:
+		return nil, false
+	}
+
+	if v, ok := obj.(*types.Var); ok && v.IsField() {
+		if sel == nil {
+			return nil, false
+		}
+		return r.tagDefault(pass, sel, v)
+	}
+	return fixedValue(pass, obj)
+}
+
+// tagDefault returns the default in the FLAG_TAG struct tag of the field sel
+// selects, like false in `flag:"enable-x,default=false"`.
+func (r *runner) tagDefault(
+	pass *analysis.Pass, sel *ast.SelectorExpr, field *types.Var,
+) (constant.Value, bool) {
+	if r.cfg.FlagTag == "" {
+		return nil, false
+	}
+	selection, ok := pass.TypesInfo.Selections[sel]
+	if !ok || selection.Kind() != types.FieldVal {
+		return nil, false
+	}
+
+	// Walk down through embedded fields to the struct declaring the field
+	t := selection.Recv()
+	tag := ""
+	for _, i := range selection.Index() {
+		if ptr, ok := t.(*types.Pointer); ok {
+			t = ptr.Elem()
+		}
+		s, ok := t.Underlying().(*types.Struct)
+		if !ok {
+			return nil, false
+		}
+		t, tag = s.Field(i).Type(), s.Tag(i)
+	}
+
+	_, options, _ := strings.Cut(reflect.StructTag(tag).Get(r.cfg.FlagTag), ",")
+	for _, option := range strings.Split(options, ",") {
+		text, ok := strings.CutPrefix(option, "default=")
+		if !ok {
+			continue
+		}
+		switch types.Default(field.Type()) {
+		case types.Typ[types.Bool]:
+			b, err := strconv.ParseBool(text)
+			return constant.MakeBool(b), err == nil
+		case types.Typ[types.Int]:
+			n, err := strconv.ParseInt(text, 10, 0)
+			return constant.MakeInt64(n), err == nil
+		case types.Typ[types.String]:
+			return constant.MakeString(text), true
+		}
+	}
+	return nil, false
+}
+
+// fileAt returns the file in the package that pos is in, if any.
+func fileAt(pass *analysis.Pass, pos token.Pos) *ast.File {
+	for _, file := range pass.Files {
+		if file.Pos() <= pos && pos <= file.End() {
+			return file
+		}
+	}
+	return nil
+}
+
+// uses counts the usages of obj in file.
+func uses(info *types.Info, file *ast.File, obj types.Object) int {
+	n := 0
+	ast.Inspect(file, func(node ast.Node) bool {
+		if id, ok := node.(*ast.Ident); ok && info.Uses[id] == obj {
+			n++
+		}
+		return true
+	})
+	return n
+}
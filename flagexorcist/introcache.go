@@ -0,0 +1,278 @@
+package flagexorcist
+
+import (
+	"encoding/gob"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/samber/mo"
+)
+
+// introKey is a lookup whose answer can't change until the file's history
+// does: a symbol searched for in a file of the checkout at root, at a version
+// of the file.
+type introKey struct {
+	root, version, file, search string
+}
+
+// introCache keeps what each lookup found, so that a process analyzing the
+// same code again, like an editor's language server, doesn't walk history it
+// has already walked. It is emptied when the analyzer is configured, since
+// what a lookup finds depends on the config, and can be exported to a file to
+// be imported by later runs.
+type introCache struct {
+	mu     sync.Mutex
+	intros map[introKey]mo.Option[introduction]
+}
+
+func (c *introCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.intros = map[introKey]mo.Option[introduction]{}
+}
+
+func (c *introCache) get(key introKey) (mo.Option[introduction], bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	intro, ok := c.intros[key]
+	return intro, ok
+}
+
+func (c *introCache) put(key introKey, intro mo.Option[introduction]) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.intros == nil {
+		c.intros = map[introKey]mo.Option[introduction]{}
+	}
+	c.intros[key] = intro
+}
+
+// versionedHistory is implemented by providers that can tell which version
+// of a file they would search the history of, whose lookups can be cached.
+type versionedHistory interface {
+	version(file string) (string, error)
+}
+
+func (h goGitHistory) version(file string) (string, error) {
+	return h.r.fileVersion(h.repo, file)
+}
+
+func (h execHistory) version(file string) (string, error) {
+	return h.r.fileVersion(h.repo, file)
+}
+
+// fileVersion returns the hash of the contents of file in the commit history
+// is read from. A file with the same contents has the same history, unless it
+// was changed and changed back since, so the hash stands for it. With
+// MaxHistoryDepth set, the history searched moves with every commit, so the
+// commit is part of the version too.
+func (r *runner) fileVersion(repo *gitRepo, file string) (string, error) {
+	head, err := repo.headHash()
+	if err != nil {
+		return "", err
+	}
+	commit, err := repo.CommitObject(head)
+	if err != nil {
+		return "", err
+	}
+	_, found, err := r.entryIn(commit, file)
+	if err != nil {
+		return "", err
+	}
+	if found == nil {
+		return "", errors.Errorf("%s isn't in %s", file, head)
+	}
+
+	if r.cfg.MaxHistoryDepth > 0 {
+		return head.String() + ":" + found.Hash.String(), nil
+	}
+	return found.Hash.String(), nil
+}
+
+// cacheKey returns the key a lookup in history is cached under, if it can
+// be.
+func cacheKey(history HistoryProvider, root, file, search string) (introKey, bool) {
+	h, ok := history.(versionedHistory)
+	if !ok {
+		return introKey{}, false
+	}
+	version, err := h.version(file)
+	if err != nil {
+		return introKey{}, false
+	}
+	return introKey{root: root, version: version, file: file, search: search}, true
+}
+
+// Set by the --cache-import and --cache-export flags
+var cacheImport, cacheExport string
+
+func init() {
+	Analyzer.Flags.StringVar(&cacheImport, "cache-import", "",
+		"read what earlier runs found in the history from this file, if it exists",
+	)
+	Analyzer.Flags.StringVar(&cacheExport, "cache-export", "",
+		"write what was found in the history to this file, for later runs to import",
+	)
+}
+
+// The version of the cache file format
+const cacheFormat = 1
+
+// cacheFile is what ExportCache writes.
+type cacheFile struct {
+	Format int
+
+	// The settings the lookups were made with, which have to match for them
+	// to hold
+	Settings cacheSettings
+
+	Entries []cacheEntry
+}
+
+// cacheSettings are the settings that change what looking flags up finds.
+type cacheSettings struct {
+	GitBackend      GitBackend
+	FirstParent     bool
+	DateSource      DateSource
+	AgeMetric       AgeMetric
+	MaxHistoryDepth int
+	HistorySince    string
+	FoldPathCase    bool
+	GitHubHistory   bool
+}
+
+func (r *runner) cacheSettings() cacheSettings {
+	return cacheSettings{
+		GitBackend:      r.cfg.GitBackend,
+		FirstParent:     r.cfg.FirstParent,
+		DateSource:      r.cfg.DateSource,
+		AgeMetric:       r.cfg.AgeMetric,
+		MaxHistoryDepth: r.cfg.MaxHistoryDepth,
+		HistorySince:    r.cfg.HistorySince.Format(time.RFC3339),
+		FoldPathCase:    r.cfg.FoldPathCase,
+		GitHubHistory:   r.cfg.GitHubHistory,
+	}
+}
+
+// cacheEntry is a lookup and what it found. Root is relative to the work
+// tree, so that the cache can be used from another checkout of the repo.
+type cacheEntry struct {
+	Root, Version, File, Search string
+
+	Found        bool
+	At           time.Time
+	BeforeWindow bool
+	Author       string
+	Commit       string
+	Subject      string
+}
+
+// ExportCache writes what has been found in the history since Initialize to
+// filename, for ImportCache to read in later runs, like CI jobs that keep it
+// between runs. Lookups in shallow clones are left out, since a deeper clone
+// can find more.
+func ExportCache(filename string) error {
+	r.intros.mu.Lock()
+	cache := cacheFile{Format: cacheFormat, Settings: r.cacheSettings()}
+	for key, found := range r.intros.intros {
+		intro, ok := found.Get()
+		if intro.shallow || intro.byMtime {
+			continue
+		}
+		root, err := filepath.Rel(r.cfg.WorkTree, key.root)
+		if err != nil {
+			continue
+		}
+		cache.Entries = append(cache.Entries, cacheEntry{
+			Root:         filepath.ToSlash(root),
+			Version:      key.version,
+			File:         key.file,
+			Search:       key.search,
+			Found:        ok,
+			At:           intro.at,
+			BeforeWindow: intro.beforeWindow,
+			Author:       intro.author,
+			Commit:       intro.commit,
+			Subject:      intro.subject,
+		})
+	}
+	r.intros.mu.Unlock()
+	sort.Slice(cache.Entries, func(i, j int) bool {
+		a, b := cache.Entries[i], cache.Entries[j]
+		if a.Root != b.Root {
+			return a.Root < b.Root
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Search != b.Search {
+			return a.Search < b.Search
+		}
+		return a.Version < b.Version
+	})
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return errors.Wrap(err, "create cache file")
+	}
+	defer f.Close()
+	if err := gob.NewEncoder(f).Encode(cache); err != nil {
+		return errors.Wrap(err, "write cache file")
+	}
+	r.l.Debug().Str("file", filename).Int("entries", len(cache.Entries)).Msg("Exported cache")
+	return errors.Wrap(f.Close(), "write cache file")
+}
+
+// ImportCache adds the lookups ExportCache wrote to filename to the ones
+// kept, so that flags they found aren't looked up again. A missing file is
+// an empty cache, like on the first run. A cache written with other history
+// settings is ignored, since what it found may not hold. Initialize must be
+// called first.
+func ImportCache(filename string) error {
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		r.l.Debug().Str("file", filename).Msg("No cache to import")
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "open cache file")
+	}
+	defer f.Close()
+
+	cache := cacheFile{}
+	if err := gob.NewDecoder(f).Decode(&cache); err != nil {
+		return errors.Wrapf(err, "read cache file %s", filename)
+	}
+	if cache.Format != cacheFormat || cache.Settings != r.cacheSettings() {
+		r.l.Warn().
+			Str("file", filename).
+			Msg("The cache was written by another version or with other history settings, ignoring it")
+		return nil
+	}
+
+	for _, e := range cache.Entries {
+		key := introKey{
+			root:    filepath.Join(r.cfg.WorkTree, filepath.FromSlash(e.Root)),
+			version: e.Version,
+			file:    e.File,
+			search:  e.Search,
+		}
+		found := mo.None[introduction]()
+		if e.Found {
+			found = mo.Some(introduction{
+				at:           e.At,
+				beforeWindow: e.BeforeWindow,
+				author:       e.Author,
+				commit:       e.Commit,
+				subject:      e.Subject,
+			})
+		}
+		r.intros.put(key, found)
+	}
+	r.l.Debug().Str("file", filename).Int("entries", len(cache.Entries)).Msg("Imported cache")
+	return nil
+}
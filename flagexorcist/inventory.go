@@ -0,0 +1,137 @@
+package flagexorcist
+
+import (
+	"go/constant"
+	"go/token"
+	"go/types"
+	"sort"
+
+	"github.com/dgunay/flag-exorcist/flagexorcist/rewrite"
+	"golang.org/x/tools/go/packages"
+)
+
+// FlagType is the type of value a flag holds, named as in OpenFeature.
+type FlagType string
+
+const (
+	FlagTypeBoolean FlagType = "boolean"
+	FlagTypeString  FlagType = "string"
+	FlagTypeInteger FlagType = "integer"
+	FlagTypeFloat   FlagType = "float"
+	FlagTypeObject  FlagType = "object"
+)
+
+// InventoryItem is a tracked flag found in the code.
+type InventoryItem struct {
+	Flag FlagID `json:"flag"`
+
+	// The type of the flag's value. Empty if it can't be told, like for keys
+	// that are only ever passed around as strings.
+	Type FlagType `json:"type,omitempty"`
+
+	// The value the flag is declared with, if it is known at compile time.
+	Default constant.Value `json:"-"`
+
+	// Where the flag is declared. Invalid if it isn't declared in the code.
+	Declared token.Position `json:"declared"`
+
+	// How many places the flag is used, and where
+	Usages int   `json:"usages"`
+	Uses   []Use `json:"uses,omitempty"`
+}
+
+// Use is a place a flag is used.
+type Use struct {
+	// Import path of the package the use is in
+	Package  string         `json:"package"`
+	Position token.Position `json:"position"`
+}
+
+// Inventory lists the tracked flags found in pkgs, sorted by flag. The
+// packages must be loaded with syntax and type information, and Initialize
+// must be called first.
+func Inventory(pkgs []*packages.Package) []InventoryItem {
+	items := map[FlagID]*InventoryItem{}
+	seen := map[token.Position]bool{}
+	ids := packagesIDs(r.flags, pkgs)
+	for _, pkg := range pkgs {
+		for _, ref := range r.references(packagePass(pkg), ids) {
+			// Test variants of a package contain the same files again
+			pos := pkg.Fset.Position(ref.pos)
+			if seen[pos] {
+				continue
+			}
+			seen[pos] = true
+
+			item, ok := items[ref.id]
+			if !ok {
+				item = &InventoryItem{Flag: ref.id}
+				items[ref.id] = item
+			}
+			if !ref.declaration {
+				item.Usages++
+				item.Uses = append(item.Uses, Use{Package: pkg.PkgPath, Position: pos})
+				continue
+			}
+			if item.Declared.IsValid() {
+				continue
+			}
+
+			item.Declared = pos
+			// A const holding a key says nothing about the flag's value
+			if ref.id.Kind == FlagKindSymbol {
+				item.Type, item.Default = declaredAs(pkg, ref.pos)
+			}
+		}
+	}
+
+	list := []InventoryItem{}
+	for _, item := range items {
+		list = append(list, *item)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Flag.less(list[j].Flag)
+	})
+	return list
+}
+
+// declaredAs returns the type and value of the flag declared at pos.
+func declaredAs(pkg *packages.Package, pos token.Pos) (FlagType, constant.Value) {
+	var obj types.Object
+	for id, def := range pkg.TypesInfo.Defs {
+		if id.Pos() == pos {
+			obj = def
+			break
+		}
+	}
+	if obj == nil {
+		return "", nil
+	}
+
+	value, _ := rewrite.DeclaredValue(pkg, obj)
+	return flagType(obj.Type()), value
+}
+
+// flagType maps a Go type to the OpenFeature type that holds it.
+func flagType(t types.Type) FlagType {
+	basic, ok := t.Underlying().(*types.Basic)
+	if !ok {
+		switch t.Underlying().(type) {
+		case *types.Struct, *types.Map, *types.Slice:
+			return FlagTypeObject
+		}
+		return ""
+	}
+
+	switch info := basic.Info(); {
+	case info&types.IsBoolean != 0:
+		return FlagTypeBoolean
+	case info&types.IsString != 0:
+		return FlagTypeString
+	case info&types.IsInteger != 0:
+		return FlagTypeInteger
+	case info&types.IsFloat != 0:
+		return FlagTypeFloat
+	}
+	return ""
+}
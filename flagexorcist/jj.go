@@ -0,0 +1,71 @@
+package flagexorcist
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/pkg/errors"
+)
+
+// jjGitDir returns the git store backing the Jujutsu workspace at path, if
+// it is one that isn't colocated with git. Colocated workspaces have a
+// regular .git dir and need nothing special.
+func jjGitDir(path string) (string, bool) {
+	if _, err := os.Stat(filepath.Join(path, ".git")); err == nil {
+		return "", false
+	}
+
+	// Secondary workspaces, made by `jj workspace add`, have a file in place
+	// of the repo dir that points at the main workspace's.
+	dotJJ := filepath.Join(path, ".jj")
+	repoDir := filepath.Join(dotJJ, "repo")
+	info, err := os.Stat(repoDir)
+	if err != nil {
+		return "", false
+	}
+	if !info.IsDir() {
+		target, err := os.ReadFile(repoDir)
+		if err != nil {
+			return "", false
+		}
+		repoDir = resolveFrom(dotJJ, string(target))
+	}
+
+	store := filepath.Join(repoDir, "store")
+	target, err := os.ReadFile(filepath.Join(store, "git_target"))
+	if err != nil {
+		return "", false
+	}
+	return resolveFrom(store, string(target)), true
+}
+
+// resolveFrom resolves a path read from a file in dir.
+func resolveFrom(dir, path string) string {
+	path = strings.TrimSpace(path)
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(dir, path)
+}
+
+// jjWorkingCopy returns the commit of the working copy of the Jujutsu
+// workspace at path. Only colocated workspaces keep git's HEAD up to date, so
+// history is walked from here instead. The working copy isn't snapshotted, so
+// running the analyzer never changes the repo.
+func jjWorkingCopy(path string) (plumbing.Hash, error) {
+	out, err := exec.Command(
+		"jj", "--repository", path, "--ignore-working-copy",
+		"log", "--no-graph", "-r", "@", "-T", "commit_id",
+	).Output()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		stderr := strings.TrimSpace(string(exitErr.Stderr))
+		return plumbing.ZeroHash, errors.Wrapf(err, "jj log: %s", stderr)
+	}
+	if err != nil {
+		return plumbing.ZeroHash, errors.Wrap(err, "jj log")
+	}
+	return plumbing.NewHash(strings.TrimSpace(string(out))), nil
+}
@@ -0,0 +1,175 @@
+// Package launchdarkly is a small client for the parts of the LaunchDarkly
+// REST API that flag-exorcist integrates with.
+package launchdarkly
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const DefaultBaseURL = "https://app.launchdarkly.com"
+
+// DefaultTimeout is how long a request may take before it is given up on, so
+// that an outage of the API doesn't hang the run.
+const DefaultTimeout = 30 * time.Second
+
+type Client struct {
+	// Base URL of the API, e.g. for the federal instance. Defaults to
+	// DefaultBaseURL.
+	BaseURL string
+
+	// Access token used to authenticate requests
+	Token string
+
+	HTTPClient *http.Client
+}
+
+func NewClient(baseURL, token string) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		Token:      token,
+		HTTPClient: &http.Client{Timeout: DefaultTimeout},
+	}
+}
+
+// Flag is a feature flag, with the targeting of the environments it was
+// fetched for.
+type Flag struct {
+	Key        string      `json:"key"`
+	Archived   bool        `json:"archived"`
+	Variations []Variation `json:"variations"`
+
+	Environments map[string]Environment `json:"environments"`
+}
+
+type Variation struct {
+	Value json.RawMessage `json:"value"`
+	Name  string          `json:"name,omitempty"`
+}
+
+// Environment is a flag's targeting in one environment. Variations are
+// referred to by their index in the flag's variations.
+type Environment struct {
+	On            bool           `json:"on"`
+	OffVariation  *int           `json:"offVariation"`
+	Fallthrough   Serve          `json:"fallthrough"`
+	Rules         []Rule         `json:"rules"`
+	Targets       []Target       `json:"targets"`
+	Prerequisites []Prerequisite `json:"prerequisites"`
+}
+
+// Serve is what a rule or the fallthrough serves: either one variation, or a
+// percentage rollout across several.
+type Serve struct {
+	Variation *int     `json:"variation"`
+	Rollout   *Rollout `json:"rollout"`
+}
+
+type Rollout struct {
+	Variations []WeightedVariation `json:"variations"`
+}
+
+type WeightedVariation struct {
+	Variation int `json:"variation"`
+	Weight    int `json:"weight"`
+}
+
+type Rule struct {
+	Serve
+}
+
+type Target struct {
+	Variation int      `json:"variation"`
+	Values    []string `json:"values"`
+}
+
+type Prerequisite struct {
+	Key       string `json:"key"`
+	Variation int    `json:"variation"`
+}
+
+// GetFlag gets the flag with key in project, with its targeting in env.
+func (c *Client) GetFlag(ctx context.Context, project, key, env string) (Flag, error) {
+	var flag Flag
+	path := fmt.Sprintf("/api/v2/flags/%s/%s?%s",
+		url.PathEscape(project), url.PathEscape(key), url.Values{"env": {env}}.Encode(),
+	)
+	err := c.get(ctx, path, &flag)
+	return flag, err
+}
+
+// ServedToAll returns the variation everyone in the environment is served,
+// if the flag is on and serves them all the same one. Flags with
+// prerequisites depend on other flags, so who gets what can't be told from
+// this one alone.
+func (e Environment) ServedToAll() (int, bool) {
+	if !e.On || len(e.Prerequisites) > 0 {
+		return 0, false
+	}
+
+	served := map[int]bool{}
+	serves := []Serve{e.Fallthrough}
+	for _, rule := range e.Rules {
+		serves = append(serves, rule.Serve)
+	}
+	for _, serve := range serves {
+		switch {
+		case serve.Variation != nil:
+			served[*serve.Variation] = true
+		case serve.Rollout != nil:
+			for _, v := range serve.Rollout.Variations {
+				if v.Weight > 0 {
+					served[v.Variation] = true
+				}
+			}
+		}
+	}
+	for _, target := range e.Targets {
+		served[target.Variation] = true
+	}
+
+	if len(served) != 1 {
+		return 0, false
+	}
+	for variation := range served {
+		return variation, true
+	}
+	return 0, false
+}
+
+// get sends a GET request and decodes the response into out.
+func (c *Client) get(ctx context.Context, path string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.Token != "" {
+		// LaunchDarkly takes the bare access token, without a scheme
+		req.Header.Set("Authorization", c.Token)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("GET %s: %s: %s", path, resp.Status, bytes.TrimSpace(msg))
+	}
+	return errors.Wrap(json.NewDecoder(resp.Body).Decode(out), "decode response")
+}
@@ -0,0 +1,62 @@
+package flagexorcist
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/dgunay/flag-exorcist/flagexorcist/launchdarkly"
+	"github.com/pkg/errors"
+)
+
+// launchDarklyState looks up flags in an environment of a LaunchDarkly
+// project.
+type launchDarklyState struct {
+	client      *launchdarkly.Client
+	project     string
+	environment string
+}
+
+func newLaunchDarklyState(cfg Config) launchDarklyState {
+	return launchDarklyState{
+		client:      launchdarkly.NewClient(cfg.LaunchDarklyAPIURL, cfg.LaunchDarklyAPIToken),
+		project:     cfg.LaunchDarklyProject,
+		environment: cfg.LaunchDarklyEnvironment,
+	}
+}
+
+func (s launchDarklyState) Name() string {
+	return "launchdarkly"
+}
+
+func (s launchDarklyState) FlagState(ctx context.Context, key string) (FlagState, error) {
+	flag, err := s.client.GetFlag(ctx, s.project, key, s.environment)
+	if err != nil {
+		return FlagState{}, err
+	}
+	env, ok := flag.Environments[s.environment]
+	if !ok {
+		return FlagState{}, errors.Errorf("flag %s has no environment %s", key, s.environment)
+	}
+
+	state := FlagState{Provider: s.Name(), Archived: flag.Archived, On: env.On}
+	if variation, ok := env.ServedToAll(); ok {
+		state.RolledOut = true
+		state.Serving = fmt.Sprintf("variation %d", variation)
+		if variation < len(flag.Variations) {
+			state.Serving = compactJSON(flag.Variations[variation].Value)
+		}
+	}
+	return state, nil
+}
+
+// compactJSON returns a JSON value with the whitespace taken out, so that it
+// fits in a message.
+func compactJSON(value json.RawMessage) string {
+	b := &bytes.Buffer{}
+	if err := json.Compact(b, value); err != nil {
+		return string(value)
+	}
+	return b.String()
+}
@@ -0,0 +1,65 @@
+package flagexorcist
+
+import (
+	"go/token"
+	"sort"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// Location is a place a flag is declared or used.
+type Location struct {
+	Position    token.Position `json:"position"`
+	Declaration bool           `json:"declaration"`
+	Source      UsageSource    `json:"source"`
+}
+
+// Locate finds every declaration and usage of flag in pkgs, declarations
+// first and then in file order. Consts holding the flag's key, fields tagged
+// with it, and entries of FLAG_MAPS are included when flag is a key. The
+// packages must be loaded with syntax and type information.
+func Locate(pkgs []*packages.Package, flag FlagID) []Location {
+	ids := flagIDs{
+		symbols: map[string][]FlagID{},
+		keys:    map[string]FlagID{},
+		maps:    r.flags.maps,
+		tag:     r.flags.tag,
+	}
+	if flag.Kind == FlagKindKey {
+		ids.keys[flag.Name] = flag
+	} else {
+		ids.symbols[flag.Name] = []FlagID{flag}
+	}
+
+	locs := []Location{}
+	seen := map[token.Position]bool{}
+	for _, pkg := range pkgs {
+		for _, ref := range r.references(packagePass(pkg), ids) {
+			// Test variants of a package contain the same files again, and
+			// registered detectors find every flag
+			pos := pkg.Fset.Position(ref.pos)
+			if seen[pos] || ref.id != flag {
+				continue
+			}
+			seen[pos] = true
+
+			locs = append(locs, Location{
+				Position:    pos,
+				Declaration: ref.declaration,
+				Source:      ref.source(),
+			})
+		}
+	}
+
+	sort.SliceStable(locs, func(i, j int) bool {
+		a, b := locs[i], locs[j]
+		if a.Declaration != b.Declaration {
+			return a.Declaration
+		}
+		if a.Position.Filename != b.Position.Filename {
+			return a.Position.Filename < b.Position.Filename
+		}
+		return a.Position.Offset < b.Position.Offset
+	})
+	return locs
+}
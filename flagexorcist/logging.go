@@ -0,0 +1,53 @@
+package flagexorcist
+
+import (
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// levelWriter only writes the logs at or above a level, so that logs written
+// to several places can each have a level of their own.
+type levelWriter struct {
+	w     io.Writer
+	level zerolog.Level
+}
+
+func (w levelWriter) Write(p []byte) (int, error) {
+	return w.w.Write(p)
+}
+
+func (w levelWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	if level < w.level {
+		// Dropped, but not an error
+		return len(p), nil
+	}
+	return w.w.Write(p)
+}
+
+// newLogger returns the logger to log with: the global one at LogLevel and,
+// if LogFile is set, JSON lines in it at LogFileLevel too. The file is
+// returned to be closed when the logger is replaced.
+func newLogger(cfg Config) (zerolog.Logger, *os.File, error) {
+	level := zerolog.Level(cfg.LogLevel)
+	if cfg.LogFile == "" {
+		return log.Logger.Level(level), nil, nil
+	}
+
+	f, err := os.OpenFile(cfg.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return zerolog.Logger{}, nil, errors.Wrap(err, "open log file")
+	}
+	fileLevel := zerolog.Level(cfg.LogFileLevel)
+	w := zerolog.MultiLevelWriter(
+		levelWriter{w: os.Stderr, level: level},
+		levelWriter{w: f, level: fileLevel},
+	)
+	if fileLevel < level {
+		level = fileLevel
+	}
+	return log.Logger.Output(w).Level(level), f, nil
+}
@@ -0,0 +1,138 @@
+package flagexorcist
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/packages"
+)
+
+// mapKeysFact lists the flag keys declared in the FLAG_MAPS of a package, so
+// that packages importing it can tell its keys apart from other strings.
+type mapKeysFact struct {
+	Keys []string
+}
+
+func (*mapKeysFact) AFact() {}
+
+func (f *mapKeysFact) String() string {
+	return "flag keys " + strings.Join(f.Keys, " ")
+}
+
+// mapEntries finds the map literals that FLAG_MAPS are initialized with, and
+// the keys of their entries, like new-checkout in
+//
+//	var Defaults = map[string]bool{"new-checkout": false}
+//
+// Keys can be string constants too.
+func (ids flagIDs) mapEntries(
+	in *inspector.Inspector, info *types.Info,
+) ([]string, map[*ast.CompositeLit]bool) {
+	keys := []string{}
+	lits := map[*ast.CompositeLit]bool{}
+	if len(ids.maps) == 0 {
+		return keys, lits
+	}
+
+	in.Preorder([]ast.Node{(*ast.ValueSpec)(nil)}, func(node ast.Node) {
+		spec := node.(*ast.ValueSpec)
+		for i, name := range spec.Names {
+			if i >= len(spec.Values) {
+				break
+			}
+			if _, ok := match(ids.maps[name.Name], info.Defs[name]); !ok {
+				continue
+			}
+			lit, ok := astutil.Unparen(spec.Values[i]).(*ast.CompositeLit)
+			if !ok {
+				continue
+			}
+			if m, ok := info.TypeOf(lit).Underlying().(*types.Map); !ok || !isString(m.Key()) {
+				continue
+			}
+
+			lits[lit] = true
+			for _, elt := range lit.Elts {
+				kv, ok := elt.(*ast.KeyValueExpr)
+				if !ok {
+					continue
+				}
+				if value := info.Types[kv.Key].Value; value != nil && value.Kind() == constant.String {
+					keys = append(keys, constant.StringVal(value))
+				}
+			}
+		}
+	})
+	return keys, lits
+}
+
+// withKeys returns ids also tracking keys, unless they already are.
+func (ids flagIDs) withKeys(keys []string) flagIDs {
+	if len(keys) == 0 {
+		return ids
+	}
+	tracked := map[string]FlagID{}
+	for name, id := range ids.keys {
+		tracked[name] = id
+	}
+	for _, key := range keys {
+		if _, ok := tracked[key]; !ok {
+			tracked[key] = FlagID{Kind: FlagKindKey, Name: key}
+		}
+	}
+	ids.keys = tracked
+	return ids
+}
+
+// passIDs returns the flags tracked in the package of pass: the configured
+// ones, and the keys of the FLAG_MAPS in it and every package it imports. The
+// package's own keys are exported for packages that import it.
+func (r *runner) passIDs(pass *analysis.Pass) flagIDs {
+	in := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	keys, _ := r.flags.mapEntries(in, pass.TypesInfo)
+	if len(keys) > 0 {
+		pass.ExportPackageFact(&mapKeysFact{Keys: keys})
+	}
+	for _, fact := range pass.AllPackageFacts() {
+		if f, ok := fact.Fact.(*mapKeysFact); ok && fact.Package != pass.Pkg {
+			keys = append(keys, f.Keys...)
+		}
+	}
+	return r.flags.withKeys(keys)
+}
+
+// packagesIDs returns the flags tracked across pkgs, like passIDs.
+func packagesIDs(ids flagIDs, pkgs []*packages.Package) flagIDs {
+	keys := []string{}
+	for _, pkg := range pkgs {
+		found, _ := ids.mapEntries(inspector.New(pkg.Syntax), pkg.TypesInfo)
+		keys = append(keys, found...)
+	}
+	return ids.withKeys(keys)
+}
+
+// isMapEntry reports whether the innermost node of stack is the key of an
+// entry of one of lits.
+func isMapEntry(stack []ast.Node, lits map[*ast.CompositeLit]bool) bool {
+	if len(stack) < 3 {
+		return false
+	}
+	kv, ok := stack[len(stack)-2].(*ast.KeyValueExpr)
+	if !ok || kv.Key != stack[len(stack)-1] {
+		return false
+	}
+	lit, ok := stack[len(stack)-3].(*ast.CompositeLit)
+	return ok && lits[lit]
+}
+
+// isString reports whether t is a string type.
+func isString(t types.Type) bool {
+	basic, ok := t.Underlying().(*types.Basic)
+	return ok && basic.Info()&types.IsString != 0
+}
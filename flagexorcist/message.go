@@ -0,0 +1,190 @@
+package flagexorcist
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// MessageData is what MESSAGE_TEMPLATE is executed with.
+type MessageData struct {
+	Flag FlagID
+	// The flag's name
+	Symbol   string
+	Category string
+
+	IntroducedAt time.Time
+	AgeDays      int
+	CutoffDays   int
+
+	// Where the finding is reported, relative to the repo root
+	Path string
+	Line int
+
+	Usages int
+	Files  int
+
+	// The first code owner of the flag, or the author who added it if it
+	// has none, and all of them
+	Owner      string
+	CodeOwners []string
+	Author     string
+
+	// The hash and subject line of the commit that added the flag
+	Commit        string
+	CommitSubject string
+
+	// The first ticket the flag is tracked in, and all of them
+	Ticket  string
+	Tickets []string
+
+	// The message that would have been reported without a template
+	Message string
+}
+
+// AgeUnit is the unit ages are shown in.
+type AgeUnit string
+
+const (
+	AgeUnitDays   AgeUnit = "days"
+	AgeUnitWeeks  AgeUnit = "weeks"
+	AgeUnitMonths AgeUnit = "months"
+	AgeUnitYears  AgeUnit = "years"
+	// Days for young flags, months for older ones, and years for ancient
+	// ones
+	AgeUnitAuto AgeUnit = "auto"
+)
+
+func (u *AgeUnit) SetValue(s string) error {
+	switch unit := AgeUnit(s); unit {
+	case AgeUnitDays, AgeUnitWeeks, AgeUnitMonths, AgeUnitYears, AgeUnitAuto:
+		*u = unit
+		return nil
+	}
+	return errors.Errorf("invalid age unit %q, must be days, weeks, months, years or auto", s)
+}
+
+// describeAge says when a flag was introduced, or last changed if that is
+// what ages are measured from, and how long ago that was on runDate, like
+// "introduced 2023-04-01, 412 days ago".
+func (r *runner) describeAge(intro introduction, runDate time.Time) string {
+	event := "introduced"
+	if r.cfg.AgeMetric == AgeMetricLastModified {
+		event = "last changed"
+	}
+	age := r.formatDays(ageDays(intro.at, runDate))
+	if intro.beforeWindow {
+		return fmt.Sprintf("%s before %s, more than %s ago", event, r.formatDate(intro.at), age)
+	}
+	return fmt.Sprintf("%s %s, %s ago", event, r.formatDate(intro.at), age)
+}
+
+func (r *runner) formatDate(t time.Time) string {
+	layout := r.cfg.DateFormat
+	if layout == "" {
+		layout = "2006-01-02"
+	}
+	return t.UTC().Format(layout)
+}
+
+// formatCutoff shows the cutoff in the age unit, or as a duration if it isn't
+// a whole number of days.
+func (r *runner) formatCutoff(flag FlagID) string {
+	cutoff := r.cutoff(flag).approx()
+	if cutoff%(24*time.Hour) != 0 {
+		return cutoff.String()
+	}
+	return r.formatDays(int(cutoff / (24 * time.Hour)))
+}
+
+// describeStaleOn says when a flag introduced at intro will be older than its
+// cutoff, after runDate.
+func (r *runner) describeStaleOn(flag FlagID, intro introduction, runDate time.Time) string {
+	on := r.staleOn(flag, intro)
+	return fmt.Sprintf("will be past its cutoff of %s on %s, in %s",
+		r.formatCutoff(flag), r.formatDate(on), r.formatDays(ageDays(runDate, on)),
+	)
+}
+
+// formatDays shows a number of days in the age unit, rounded down.
+func (r *runner) formatDays(days int) string {
+	unit := r.cfg.AgeUnit
+	if unit == AgeUnitAuto {
+		switch {
+		case days < 60:
+			unit = AgeUnitDays
+		case days < 2*365:
+			unit = AgeUnitMonths
+		default:
+			unit = AgeUnitYears
+		}
+	}
+
+	n, name := days, "day"
+	switch unit {
+	case AgeUnitWeeks:
+		n, name = days/7, "week"
+	case AgeUnitMonths:
+		// Months are 1/12 of a year on average
+		n, name = days*12/365, "month"
+	case AgeUnitYears:
+		n, name = days/365, "year"
+	}
+	if n != 1 {
+		name += "s"
+	}
+	return fmt.Sprintf("%d %s", n, name)
+}
+
+// parseMessageTemplate parses MessageTemplate, which is nil if it is empty.
+func parseMessageTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+	t, err := template.New("message").Option("missingkey=error").Parse(text)
+	return t, errors.Wrap(err, "invalid MESSAGE_TEMPLATE")
+}
+
+// templatedMessage renders the message of f with MessageTemplate, if there is
+// one. If it fails, the built-in message is kept.
+func (r *runner) templatedMessage(f Finding) string {
+	if r.message == nil {
+		return f.Message
+	}
+
+	data := MessageData{
+		Flag:          f.Flag,
+		Symbol:        f.Flag.Name,
+		Category:      f.Category,
+		IntroducedAt:  f.IntroducedAt,
+		AgeDays:       f.AgeDays,
+		CutoffDays:    int(r.cutoff(f.Flag).approx().Hours() / 24),
+		Path:          f.Path,
+		Line:          f.Position.Line,
+		Usages:        f.UsageCount,
+		Files:         f.FileCount,
+		CodeOwners:    f.CodeOwners,
+		Author:        f.Author,
+		Commit:        f.Commit,
+		CommitSubject: f.CommitSubject,
+		Owner:         f.Author,
+		Tickets:       f.Tickets,
+		Message:       f.Message,
+	}
+	if len(f.CodeOwners) > 0 {
+		data.Owner = f.CodeOwners[0]
+	}
+	if len(f.Tickets) > 0 {
+		data.Ticket = f.Tickets[0]
+	}
+
+	b := &strings.Builder{}
+	if err := r.message.Execute(b, data); err != nil {
+		r.l.Warn().Err(err).Msg("Failed to execute MESSAGE_TEMPLATE, using the built-in message")
+		return f.Message
+	}
+	return b.String()
+}
@@ -0,0 +1,54 @@
+package flagexorcist
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// loadOnboarding reads the list of onboarded directories from an onboarding
+// file. Each line names one directory relative to the repo root. Blank lines
+// and lines starting with # are ignored.
+func loadOnboarding(filename string) ([]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, errors.Wrap(err, "open onboarding file")
+	}
+	defer f.Close()
+
+	dirs := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		dirs = append(dirs, path.Clean(strings.TrimSuffix(filepath.ToSlash(line), "/")))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "read onboarding file")
+	}
+
+	return dirs, nil
+}
+
+// enforced reports whether findings in the given file should fail the run.
+// Files outside of the onboarded directories are report-only.
+func (r *runner) enforced(filename string) bool {
+	if r.onboarded == nil {
+		return true
+	}
+
+	rel := r.relPath(filename)
+	for _, dir := range r.onboarded {
+		if dir == "." || r.samePath(rel, dir) ||
+			len(rel) > len(dir) && rel[len(dir)] == '/' && r.samePath(rel[:len(dir)], dir) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+package flagexorcist
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// Where CODEOWNERS files are looked for, relative to the repo root, in the
+// order GitHub looks for them.
+var codeOwnersFiles = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+// ownerRule is a line of a CODEOWNERS file.
+type ownerRule struct {
+	// The pattern as glob segments, and as a directory whose contents it
+	// matches
+	glob, dir []string
+	owners    []string
+}
+
+// annotated adds what is known about a flag beyond its age to a finding: where
+// it is declared, what it is for, the tickets it is tracked in and who owns
+// it.
+func (r *runner) annotated(pass *analysis.Pass, f Finding, intro introduction) Finding {
+	f.Declaration = pass.Fset.Position(intro.declaredAt)
+	if f.Description = r.registry[f.Flag].description; f.Description != "" {
+		f.Message += fmt.Sprintf(" (%s)", f.Description)
+	}
+	f = r.withTickets(pass, f, intro.declaredAt)
+	return r.owned(f, intro, pass.Fset.Position(intro.declaredAt).Filename)
+}
+
+// owned fills in who owns the flag a finding is about: its owners in the flag
+// registry, or else the CODEOWNERS of the file the flag was dated from, and
+// the author, hash and subject line of the commit that added it.
+// If ReportOwners is set, they are named in the message too.
+func (r *runner) owned(f Finding, intro introduction, filename string) Finding {
+	f.Author = intro.author
+	f.Commit = intro.commit
+	f.CommitSubject = intro.subject
+	f.CodeOwners = r.registry[f.Flag].owners
+	if len(f.CodeOwners) == 0 {
+		f.CodeOwners = r.codeOwners(filename)
+	}
+	if !r.cfg.ReportOwners {
+		return f
+	}
+
+	parts := []string{}
+	if len(f.CodeOwners) > 0 {
+		parts = append(parts, "owned by "+strings.Join(f.CodeOwners, " "))
+	}
+	if f.Author != "" {
+		parts = append(parts, "added by "+f.Author+commitRef(f))
+	}
+	if len(parts) > 0 {
+		f.Message += fmt.Sprintf(" [%s]", strings.Join(parts, ", "))
+	}
+	return f
+}
+
+// codeOwners returns the owners of filename according to the CODEOWNERS file
+// of its repo, if there is one. As on GitHub, the last matching rule wins.
+func (r *runner) codeOwners(filename string) []string {
+	root := r.topRoot(filename)
+	segments := strings.Split(r.relTo(root, filename), "/")
+
+	var owners []string
+	for _, rule := range r.ownerRules(root) {
+		if matchGlob(rule.glob, segments) || matchGlob(rule.dir, segments) {
+			owners = rule.owners
+		}
+	}
+	return owners
+}
+
+// ownerRules returns the rules of the CODEOWNERS file of the repo at root,
+// reading it when first needed.
+func (r *runner) ownerRules(root string) []ownerRule {
+	r.ownersMu.Lock()
+	defer r.ownersMu.Unlock()
+	if rules, ok := r.owners[root]; ok {
+		return rules
+	}
+
+	rules := []ownerRule{}
+	for _, name := range codeOwnersFiles {
+		contents, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(name)))
+		if err != nil {
+			continue
+		}
+		rules = parseCodeOwners(contents)
+		break
+	}
+	r.owners[root] = rules
+	return rules
+}
+
+// parseCodeOwners parses the rules of a CODEOWNERS file. Patterns follow
+// .gitignore rules: a pattern is relative to the repo root if it contains a
+// slash other than a trailing one, and matches at any depth otherwise.
+func parseCodeOwners(contents []byte) []ownerRule {
+	rules := []ownerRule{}
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		pattern := strings.TrimSuffix(fields[0], "/")
+		if !strings.Contains(pattern, "/") {
+			pattern = "**/" + pattern
+		}
+		glob := strings.Split(strings.TrimPrefix(pattern, "/"), "/")
+		rules = append(rules, ownerRule{
+			glob:   glob,
+			dir:    append(glob[:len(glob):len(glob)], "**"),
+			owners: fields[1:],
+		})
+	}
+	return rules
+}
+
+// commitRef names the commit that added the flag of a finding, with its
+// subject line, like ` in 1a2b3c4d ("Add checkout flag")`.
+func commitRef(f Finding) string {
+	if f.Commit == "" {
+		return ""
+	}
+	ref := " in " + shortHash(f.Commit)
+	if f.CommitSubject != "" {
+		ref += fmt.Sprintf(" (%q)", f.CommitSubject)
+	}
+	return ref
+}
+
+// shortHash abbreviates a commit hash.
+func shortHash(hash string) string {
+	if len(hash) > 8 {
+		return hash[:8]
+	}
+	return hash
+}
+
+// signature formats a commit author like git does.
+func signature(name, email string) string {
+	if email == "" {
+		return name
+	}
+	return fmt.Sprintf("%s <%s>", name, email)
+}
+
+// firstLine returns the subject line of a commit message.
+func firstLine(message string) string {
+	line, _, _ := strings.Cut(message, "\n")
+	return strings.TrimSpace(line)
+}
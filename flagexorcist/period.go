@@ -0,0 +1,159 @@
+package flagexorcist
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Period is a length of time, like a cutoff. It can be a Go duration like
+// 2160h, a number of days, weeks, months or years like 90d, 2w, 3mo or 1y, a
+// mix of those like 1y6mo, or an ISO 8601 duration like P3M. Months and years
+// are calendar months and years, so a flag with a cutoff of 1y is stale on the
+// anniversary of its introduction, whatever the length of the year. Empty is
+// no time at all.
+type Period string
+
+func (p *Period) SetValue(s string) error {
+	if _, err := parsePeriod(s); err != nil {
+		return err
+	}
+	*p = Period(s)
+	return nil
+}
+
+// span is a parsed Period.
+type span struct {
+	years, months, days int
+	duration            time.Duration
+}
+
+var (
+	// ISO 8601 durations, with the date parts and the time parts after T
+	isoPeriod = regexp.MustCompile(`^P(?:(\d+)Y)?(?:(\d+)M)?(?:(\d+)W)?(?:(\d+)D)?` +
+		`(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+	// A number and its unit, in the friendlier form
+	periodPart = regexp.MustCompile(`^(\d+(?:\.\d+)?)([a-zµμ]+)`)
+)
+
+// parsePeriod parses a Period.
+func parsePeriod(s string) (span, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return span{}, nil
+	}
+	if strings.HasPrefix(strings.ToUpper(s), "P") {
+		return parseISOPeriod(s)
+	}
+
+	// The calendar units are taken out, and the rest is left for
+	// time.ParseDuration
+	sp := span{}
+	rest := ""
+	for remaining := s; remaining != ""; {
+		match := periodPart.FindStringSubmatch(remaining)
+		if match == nil {
+			// Like a bare 0, which time.ParseDuration accepts
+			rest += remaining
+			break
+		}
+		remaining = remaining[len(match[0]):]
+
+		n, err := strconv.Atoi(match[1])
+		unit := match[2]
+		switch unit {
+		case "y", "mo", "w", "d":
+			if err != nil {
+				return span{}, errors.Errorf("invalid period %q, %s must be a whole number", s, match[0])
+			}
+		}
+		switch unit {
+		case "y":
+			sp.years += n
+		case "mo":
+			sp.months += n
+		case "w":
+			sp.days += 7 * n
+		case "d":
+			sp.days += n
+		default:
+			rest += match[0]
+		}
+	}
+	if rest != "" {
+		d, err := time.ParseDuration(rest)
+		if err != nil || d < 0 {
+			return span{}, errors.Errorf(
+				"invalid period %q, must be like 90d, 2w, 3mo, 1y, 2160h or P3M", s,
+			)
+		}
+		sp.duration = d
+	}
+	return sp, nil
+}
+
+// parseISOPeriod parses an ISO 8601 duration, like P1Y2M or PT36H.
+func parseISOPeriod(s string) (span, error) {
+	match := isoPeriod.FindStringSubmatch(strings.ToUpper(s))
+	if match == nil || s == "P" || strings.HasSuffix(strings.ToUpper(s), "T") {
+		return span{}, errors.Errorf("invalid ISO 8601 duration %q, must be like P3M or P1Y2M10D", s)
+	}
+	number := func(i int) int {
+		n, _ := strconv.Atoi(match[i])
+		return n
+	}
+	sp := span{years: number(1), months: number(2), days: 7*number(3) + number(4)}
+	sp.duration = time.Duration(number(5))*time.Hour + time.Duration(number(6))*time.Minute
+	if match[7] != "" {
+		seconds, _ := strconv.ParseFloat(match[7], 64)
+		sp.duration += time.Duration(seconds * float64(time.Second))
+	}
+	return sp, nil
+}
+
+// before returns the time sp before t, counting months and years on the
+// calendar.
+func (sp span) before(t time.Time) time.Time {
+	return t.AddDate(-sp.years, -sp.months, -sp.days).Add(-sp.duration)
+}
+
+// after returns the time sp after t, counting months and years on the
+// calendar.
+func (sp span) after(t time.Time) time.Time {
+	return t.AddDate(sp.years, sp.months, sp.days).Add(sp.duration)
+}
+
+// approx is about how long sp is, counting a year as 365 days and a month as
+// 30, for showing it and anywhere a fixed length is needed.
+func (sp span) approx() time.Duration {
+	days := 365*sp.years + 30*sp.months + sp.days
+	return time.Duration(days)*24*time.Hour + sp.duration
+}
+
+func (sp span) isZero() bool {
+	return sp == span{}
+}
+
+func (sp span) String() string {
+	if sp.isZero() {
+		return "0s"
+	}
+	b := &strings.Builder{}
+	for _, part := range []struct {
+		n    int
+		unit string
+	}{{sp.years, "y"}, {sp.months, "mo"}, {sp.days, "d"}} {
+		if part.n != 0 {
+			fmt.Fprintf(b, "%d%s", part.n, part.unit)
+		}
+	}
+	if sp.duration != 0 {
+		b.WriteString(sp.duration.String())
+	}
+	return b.String()
+}
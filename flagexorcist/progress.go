@@ -0,0 +1,139 @@
+package flagexorcist
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Phases of analyzing a package, for timing.
+const (
+	PhaseOpenRepo = "open repo"
+	PhaseFindRefs = "find references"
+	PhaseDate     = "date flags"
+	PhaseReport   = "report"
+)
+
+var phaseOrder = []string{PhaseOpenRepo, PhaseFindRefs, PhaseDate, PhaseReport}
+
+// Stats is what the analyzer has done since it was initialized.
+type Stats struct {
+	Packages int64
+	// Flags looked up in history
+	Symbols int64
+	// Commits searched while looking flags up
+	Commits int64
+	// Versions of files read while looking flags up, which is most of what
+	// searching commits costs
+	Reads int64
+
+	// Time spent in each phase, summed over all packages. Packages are
+	// analyzed concurrently, so this can add up to more than the wall time.
+	Phases []PhaseTiming
+}
+
+type PhaseTiming struct {
+	Phase    string        `json:"phase"`
+	Duration time.Duration `json:"duration"`
+}
+
+// progress counts what the analyzer has done.
+type progress struct {
+	packages atomic.Int64
+	symbols  atomic.Int64
+	commits  atomic.Int64
+	reads    atomic.Int64
+
+	mu     sync.Mutex
+	phases map[string]time.Duration
+}
+
+// timed adds the time since start to phase, and returns now as the start of
+// the next phase.
+func (p *progress) timed(phase string, start time.Time) time.Time {
+	now := time.Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.phases == nil {
+		p.phases = map[string]time.Duration{}
+	}
+	p.phases[phase] += now.Sub(start)
+	return now
+}
+
+func (p *progress) reset() {
+	p.packages.Store(0)
+	p.symbols.Store(0)
+	p.commits.Store(0)
+	p.reads.Store(0)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.phases = nil
+}
+
+// CurrentStats returns what the analyzer has done so far.
+func CurrentStats() Stats {
+	stats := Stats{
+		Packages: r.progress.packages.Load(),
+		Symbols:  r.progress.symbols.Load(),
+		Commits:  r.progress.commits.Load(),
+		Reads:    r.progress.reads.Load(),
+	}
+
+	r.progress.mu.Lock()
+	defer r.progress.mu.Unlock()
+	for _, phase := range phaseOrder {
+		stats.Phases = append(stats.Phases, PhaseTiming{
+			Phase:    phase,
+			Duration: r.progress.phases[phase],
+		})
+	}
+	return stats
+}
+
+// ReportProgress rewrites a line of progress on w every interval until stop
+// is called.
+func ReportProgress(w io.Writer, interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		shown := false
+		for {
+			select {
+			case <-ticker.C:
+				s := CurrentStats()
+				fmt.Fprintf(w, "\r%d packages analyzed, %d flags dated, %d commits searched",
+					s.Packages, s.Symbols, s.Commits,
+				)
+				shown = true
+			case <-done:
+				if shown {
+					// Clear the line
+					fmt.Fprint(w, "\r\033[K")
+				}
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// WriteSummary writes the totals and time spent in each phase to w.
+func WriteSummary(w io.Writer, elapsed time.Duration) {
+	s := CurrentStats()
+	fmt.Fprintf(w, "%d packages analyzed, %d flags dated, %d commits searched in %v\n",
+		s.Packages, s.Symbols, s.Commits, elapsed.Round(time.Millisecond),
+	)
+	for _, phase := range s.Phases {
+		fmt.Fprintf(w, "  %-16s %v\n", phase.Phase, phase.Duration.Round(time.Millisecond))
+	}
+}
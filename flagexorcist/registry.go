@@ -0,0 +1,124 @@
+package flagexorcist
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// registryFile is a flag registry, in YAML or JSON, like
+//
+//	flags:
+//	  - symbol: example.com/flags.EnableCheckout
+//	    owner: "@checkout-team"
+//	    ticket: CHK-12
+//	    description: The new one-page checkout
+//	    created: 2023-01-05
+//	    expiry: 2023-04-01
+//	  - key: launchdarkly:new-search
+//	    cutoff: 90d
+//
+// Other fields, like created, are ignored.
+type registryFile struct {
+	Flags []struct {
+		// One of a symbol, like in FLAG_SYMBOLS, or a key, like in FLAG_KEYS
+		Symbol string `yaml:"symbol"`
+		Key    string `yaml:"key"`
+
+		// Who owns the flag. Several owners can be separated by spaces.
+		Owner string `yaml:"owner"`
+
+		// Where the flag's rollout is tracked, added to the tickets found in
+		// the comments on its declaration. Several tickets can be separated
+		// by spaces.
+		Ticket string `yaml:"ticket"`
+
+		// What the flag is for, shown with its findings
+		Description string `yaml:"description"`
+
+		// When the flag is due to be removed, as YYYY-MM-DD
+		Expiry string `yaml:"expiry"`
+
+		// How old the flag can be before it is stale, instead of CUTOFF, in
+		// any form a Period can take
+		Cutoff string `yaml:"cutoff"`
+	} `yaml:"flags"`
+}
+
+// registryEntry is what the registry says about a flag.
+type registryEntry struct {
+	owners      []string
+	tickets     []string
+	description string
+	expiry      time.Time
+	cutoff      span
+}
+
+// loadRegistry reads a flag registry file into the symbols and keys it
+// tracks, and what it says about each.
+func loadRegistry(filename string) (symbols, keys []string, _ map[FlagID]registryEntry, _ error) {
+	contents, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "read flag registry")
+	}
+	// JSON is YAML too
+	var file registryFile
+	if err := yaml.Unmarshal(contents, &file); err != nil {
+		return nil, nil, nil, errors.Wrap(err, "parse flag registry")
+	}
+
+	entries := map[FlagID]registryEntry{}
+	for i, f := range file.Flags {
+		var id FlagID
+		switch {
+		case f.Symbol != "" && f.Key != "":
+			return nil, nil, nil, errors.Errorf(
+				"flag registry entry %d has both a symbol and a key", i+1,
+			)
+		case f.Symbol != "":
+			id = SymbolID(f.Symbol)
+			symbols = append(symbols, f.Symbol)
+		case f.Key != "":
+			id = KeyID(f.Key)
+			keys = append(keys, f.Key)
+		default:
+			return nil, nil, nil, errors.Errorf(
+				"flag registry entry %d has neither a symbol nor a key", i+1,
+			)
+		}
+
+		entry := registryEntry{
+			owners:      strings.Fields(f.Owner),
+			tickets:     strings.Fields(f.Ticket),
+			description: strings.TrimSpace(f.Description),
+		}
+		if f.Expiry != "" {
+			if entry.expiry, err = time.Parse("2006-01-02", f.Expiry); err != nil {
+				return nil, nil, nil, errors.Errorf(
+					"invalid expiry %q of flag %v in flag registry, must be YYYY-MM-DD",
+					f.Expiry, id,
+				)
+			}
+		}
+		if f.Cutoff != "" {
+			if entry.cutoff, err = parsePeriod(f.Cutoff); err != nil {
+				return nil, nil, nil, errors.Wrapf(
+					err, "invalid cutoff of flag %v in flag registry", id,
+				)
+			}
+		}
+		entries[id] = entry
+	}
+	return symbols, keys, entries, nil
+}
+
+// cutoff returns how old flag can be before it is stale.
+func (r *runner) cutoff(flag FlagID) span {
+	if entry, ok := r.registry[flag]; ok && !entry.cutoff.isZero() {
+		return entry.cutoff
+	}
+	return r.cutoffSpan
+}
@@ -0,0 +1,140 @@
+package flagexorcist
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/samber/mo"
+)
+
+// FlagState is the state of a flag in the flag system that serves it.
+type FlagState struct {
+	// The flag system the state is from, like launchdarkly
+	Provider string `json:"provider"`
+
+	Archived bool `json:"archived"`
+	On       bool `json:"on"`
+
+	// Whether everyone is served the same value, and that value
+	RolledOut bool   `json:"rolledOut"`
+	Serving   string `json:"serving,omitempty"`
+}
+
+// Done reports whether the flag's rollout is over, so the code gating on it
+// can be removed.
+func (s FlagState) Done() bool {
+	return s.Archived || s.RolledOut
+}
+
+// String describes the state, like "serving true to everyone in
+// launchdarkly".
+func (s FlagState) String() string {
+	switch {
+	case s.Archived:
+		return "archived in " + s.Provider
+	case s.RolledOut:
+		return fmt.Sprintf("serving %s to everyone in %s", s.Serving, s.Provider)
+	case s.On:
+		return "rolling out in " + s.Provider
+	}
+	return "off in " + s.Provider
+}
+
+// FlagStateProvider looks up the state of flags in a flag system, so that
+// flags it says are done rolling out can be reported.
+type FlagStateProvider interface {
+	// Name of the flag system, which is also the namespace of its keys, like
+	// launchdarkly
+	Name() string
+
+	// FlagState returns the state of the flag with key. An error means the
+	// state isn't known, not that the flag doesn't exist.
+	FlagState(ctx context.Context, key string) (FlagState, error)
+}
+
+// remoteProviders returns the flag systems configured to look flags up in,
+// with the built-in ones first.
+func remoteProviders(cfg Config) []FlagStateProvider {
+	providers := []FlagStateProvider{}
+	if cfg.LaunchDarklyAPIToken != "" {
+		providers = append(providers, newLaunchDarklyState(cfg))
+	}
+	if cfg.UnleashAPIURL != "" {
+		providers = append(providers, newUnleashState(cfg))
+	}
+	if cfg.SplitAPIKey != "" {
+		providers = append(providers, newSplitState(cfg))
+	}
+	if cfg.ConfigCatUsername != "" {
+		providers = append(providers, newConfigCatState(cfg))
+	}
+	return append(providers, cfg.FlagStateProviders...)
+}
+
+// remoteStates caches the state of each flag, so that each is looked up once
+// per run no matter how many packages use it.
+type remoteStates struct {
+	mu     sync.Mutex
+	states map[FlagID]*remoteLookup
+}
+
+type remoteLookup struct {
+	once  sync.Once
+	state mo.Option[FlagState]
+}
+
+func (s *remoteStates) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states = map[FlagID]*remoteLookup{}
+}
+
+func (s *remoteStates) lookup(flag FlagID) *remoteLookup {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.states == nil {
+		s.states = map[FlagID]*remoteLookup{}
+	}
+	lookup, ok := s.states[flag]
+	if !ok {
+		lookup = &remoteLookup{}
+		s.states[flag] = lookup
+	}
+	return lookup
+}
+
+// remoteState returns the state of a key in the first flag system it is
+// found in. Keys without a namespace are looked up in every flag system
+// configured, and keys with one only in that system. Lookups that fail are
+// warned about and treated as not found, so an outage doesn't fail the run.
+func (r *runner) remoteState(ctx context.Context, flag FlagID) mo.Option[FlagState] {
+	if flag.Kind != FlagKindKey || len(r.providers) == 0 {
+		return mo.None[FlagState]()
+	}
+
+	lookup := r.remote.lookup(flag)
+	lookup.once.Do(func() {
+		for _, provider := range r.providers {
+			if flag.Namespace != "" && flag.Namespace != provider.Name() {
+				continue
+			}
+			state, err := provider.FlagState(ctx, flag.Name)
+			if err != nil {
+				r.l.Warn().
+					Err(err).
+					Stringer("flag", flag).
+					Str("provider", provider.Name()).
+					Msg("Failed to look up flag state")
+				continue
+			}
+			r.l.Debug().
+				Stringer("flag", flag).
+				Stringer("state", state).
+				Msg("Looked up flag state")
+			lookup.state = mo.Some(state)
+			return
+		}
+	})
+	return lookup.state
+}
@@ -0,0 +1,361 @@
+package flagexorcist
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+	"github.com/go-git/go-git/v5/storage/filesystem/dotgit"
+	"github.com/pkg/errors"
+)
+
+// ShallowPolicy is what to do when the repo is a shallow clone, and so may
+// not have the commits that introduced flags.
+type ShallowPolicy string
+
+const (
+	// Report a diagnostic for flags whose introduction is cut off by the
+	// shallow history.
+	ShallowReport ShallowPolicy = "report"
+	// Fail the run.
+	ShallowFail ShallowPolicy = "fail"
+	// Fetch the rest of the history with `git fetch --unshallow`.
+	ShallowDeepen ShallowPolicy = "deepen"
+)
+
+func (p *ShallowPolicy) SetValue(s string) error {
+	switch policy := ShallowPolicy(s); policy {
+	case ShallowReport, ShallowFail, ShallowDeepen:
+		*p = policy
+		return nil
+	}
+	return errors.Errorf("invalid shallow clone policy %q, must be report, fail or deepen", s)
+}
+
+// gitRepo is an opened git repository.
+type gitRepo struct {
+	*git.Repository
+
+	// Where the repo is checked out, which history paths are relative to
+	root string
+
+	// The commit history is read from. Zero to read it from HEAD.
+	head plumbing.Hash
+
+	// The commits at the boundary of a shallow clone, whose parents are
+	// missing. Empty if the repo has full history.
+	shallow map[plumbing.Hash]bool
+}
+
+// Serializes deepening so concurrent passes don't all fetch at once.
+var deepenMu sync.Mutex
+
+// open opens the git repo checked out at root. The work tree's repo is the
+// one at RepoPath, or at GitDir if it is set. Linked worktrees, made by `git
+// worktree add`, keep only their own HEAD and index in their git dir and share
+// everything else with the main repo through the commondir file, so that is
+// followed too.
+func (r *runner) open(root string) (*git.Repository, error) {
+	gitDir := r.gitDir(root)
+	if gitDir == "" {
+		r.l.Debug().Str("path", r.checkout(root)).Msg("Opening git repo")
+		repo, err := git.PlainOpenWithOptions(r.checkout(root), &git.PlainOpenOptions{
+			EnableDotGitCommonDir: true,
+		})
+		return repo, errors.Wrapf(err, "open git repo at %s", r.checkout(root))
+	}
+
+	// The work tree isn't needed since we only read history
+	r.l.Debug().Str("gitDir", gitDir).Msg("Opening git dir")
+	fs, err := gitDirFilesystem(gitDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "open git dir")
+	}
+	storage := filesystem.NewStorage(fs, cache.NewObjectLRUDefault())
+	repo, err := git.Open(storage, nil)
+	return repo, errors.Wrap(err, "open git dir")
+}
+
+// checkout returns where the repo whose checkout is rooted at root is found.
+// That is RepoPath for the work tree, which may be a parent of the checkout
+// root when GIT_WORK_TREE is set.
+func (r *runner) checkout(root string) string {
+	if root == r.cfg.WorkTree {
+		return r.cfg.RepoPath
+	}
+	return root
+}
+
+// gitDir returns the git dir of the checkout at root, if it isn't where git
+// would look for it: GitDir for the work tree, or the git store of a Jujutsu
+// workspace.
+func (r *runner) gitDir(root string) string {
+	if root == r.cfg.WorkTree && r.cfg.GitDir != "" {
+		return r.cfg.GitDir
+	}
+	if dir, ok := jjGitDir(r.checkout(root)); ok {
+		return dir
+	}
+	return ""
+}
+
+// repoRoot returns the checkout root of the innermost repo containing
+// filename. That is a submodule's checkout if filename is in one, and the top
+// checkout otherwise.
+func (r *runner) repoRoot(filename string) string {
+	top := r.topRoot(filename)
+	for dir := filepath.Dir(filename); ; dir = filepath.Dir(dir) {
+		if rel, ok := r.within(top, dir); !ok || rel == "." {
+			return top
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+	}
+}
+
+// topRoot returns the root of the checkout filename is analyzed in: the work
+// tree, or if no repo was configured, the checkout enclosing filename.
+func (r *runner) topRoot(filename string) string {
+	if !r.detectRoot {
+		return r.cfg.WorkTree
+	}
+
+	dir := filepath.Dir(filename)
+	r.rootsMu.Lock()
+	defer r.rootsMu.Unlock()
+	if root, ok := r.roots[dir]; ok {
+		return root
+	}
+	root := findCheckout(dir)
+	if root == "" {
+		root = r.cfg.WorkTree
+	}
+	r.roots[dir] = root
+	return root
+}
+
+// findCheckout returns the nearest directory at or above dir that is the root
+// of a git, Jujutsu or Mercurial checkout, or "" if there is none. Submodules
+// are skipped, so that they are dated as part of their superproject.
+func findCheckout(dir string) string {
+	for {
+		if isCheckoutRoot(dir) {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+func isCheckoutRoot(dir string) bool {
+	dotGit := filepath.Join(dir, ".git")
+	info, err := os.Stat(dotGit)
+	if err != nil {
+		_, jj := jjGitDir(dir)
+		return jj || isHgRepo(dir)
+	}
+	if info.IsDir() {
+		return true
+	}
+
+	// Linked worktrees and submodules both have a .git file, but a
+	// submodule's points into its superproject's modules dir.
+	target, err := os.ReadFile(dotGit)
+	return err == nil && !strings.Contains(filepath.ToSlash(string(target)), "/modules/")
+}
+
+// gitDirFilesystem returns the filesystem of the git dir at dir, joined with
+// the main repo's git dir if dir belongs to a linked worktree.
+func gitDirFilesystem(dir string) (billy.Filesystem, error) {
+	fs := osfs.New(dir)
+
+	common, err := os.ReadFile(filepath.Join(dir, "commondir"))
+	if os.IsNotExist(err) {
+		return fs, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "read commondir")
+	}
+
+	commonDir := strings.TrimSpace(string(common))
+	if !filepath.IsAbs(commonDir) {
+		commonDir = filepath.Join(dir, commonDir)
+	}
+	return dotgit.NewRepositoryFilesystem(fs, osfs.New(commonDir)), nil
+}
+
+// openRepo opens the git repo checked out at root and applies the shallow
+// clone policy.
+func (r *runner) openRepo(root string) (*gitRepo, error) {
+	repo, err := r.open(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var head plumbing.Hash
+	if _, ok := jjGitDir(r.checkout(root)); ok {
+		if head, err = jjWorkingCopy(r.checkout(root)); err != nil {
+			return nil, err
+		}
+	}
+
+	shallow, err := repo.Storer.Shallow()
+	if err != nil {
+		return nil, errors.Wrap(err, "read shallow commits")
+	}
+	if len(shallow) == 0 {
+		return &gitRepo{Repository: repo, root: root, head: head}, nil
+	}
+
+	switch r.cfg.OnShallow {
+	case ShallowFail:
+		return nil, errors.New(
+			"the git repo is a shallow clone, so flag ages can't be determined; " +
+				"fetch the full history with `git fetch --unshallow`",
+		)
+	case ShallowDeepen:
+		deepened, err := r.deepen(root)
+		if err != nil {
+			return nil, err
+		}
+		if deepened {
+			return r.openRepo(root)
+		}
+		// The fetch left it shallow, like when the remote is shallow too, so
+		// the flags it cuts off are reported like they are by default
+	}
+
+	r.l.Warn().
+		Int("shallowCommits", len(shallow)).
+		Msg("The git repo is a shallow clone, some flags may not be dated")
+	opened := &gitRepo{Repository: repo, root: root, head: head, shallow: map[plumbing.Hash]bool{}}
+	for _, hash := range shallow {
+		opened.shallow[hash] = true
+	}
+	return opened, nil
+}
+
+// deepen fetches the full history of the shallow clone checked out at root,
+// and reports whether it is no longer shallow. The fetch is only tried once
+// per root, so a clone that stays shallow isn't fetched again for every
+// package.
+func (r *runner) deepen(root string) (bool, error) {
+	deepenMu.Lock()
+	defer deepenMu.Unlock()
+
+	// Another pass may have beaten us to it
+	repo, err := r.open(root)
+	if err != nil {
+		return false, err
+	}
+	if shallow, err := repo.Storer.Shallow(); err == nil && len(shallow) == 0 {
+		return true, nil
+	}
+	if r.deepened[root] {
+		return false, nil
+	}
+	r.deepened[root] = true
+
+	args := append(r.gitArgs(root), "fetch", "--unshallow")
+
+	r.l.Info().Strs("args", args).Msg("Fetching full history of shallow clone")
+	out, err := exec.Command("git", args...).CombinedOutput()
+	if err != nil {
+		return false, errors.Wrapf(err, "git fetch --unshallow: %s", strings.TrimSpace(string(out)))
+	}
+
+	if repo, err = r.open(root); err != nil {
+		return false, err
+	}
+	shallow, err := repo.Storer.Shallow()
+	if err != nil {
+		return false, errors.Wrap(err, "read shallow commits")
+	}
+	if len(shallow) > 0 {
+		r.l.Warn().Msg("The git repo is still a shallow clone after fetching its full history")
+	}
+	return len(shallow) == 0, nil
+}
+
+// gitArgs returns the arguments that point the git binary at the repo checked
+// out at root.
+func (r *runner) gitArgs(root string) []string {
+	if dir := r.gitDir(root); dir != "" {
+		return []string{"--git-dir", dir}
+	}
+	return []string{"-C", r.checkout(root)}
+}
+
+// relTo returns the path of filename relative to the checkout at root, as it
+// appears in the checkout's history: slash-separated on every platform.
+// Filenames outside root are returned whole.
+func (r *runner) relTo(root, filename string) string {
+	if rel, ok := r.within(root, filename); ok {
+		return rel
+	}
+	return filepath.ToSlash(filename)
+}
+
+// within returns the slash-separated path of path relative to dir, if path
+// is dir or is inside it.
+func (r *runner) within(dir, path string) (string, bool) {
+	rel, err := filepath.Rel(dir, path)
+	if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return filepath.ToSlash(rel), true
+	}
+	if !r.cfg.FoldPathCase {
+		return "", false
+	}
+
+	// filepath.Rel is case-sensitive, so compare the prefix by hand
+	dir, path = filepath.Clean(dir), filepath.Clean(path)
+	if strings.EqualFold(dir, path) {
+		return ".", true
+	}
+	if len(path) > len(dir) && strings.EqualFold(path[:len(dir)], dir) &&
+		os.IsPathSeparator(path[len(dir)]) {
+		return filepath.ToSlash(path[len(dir)+1:]), true
+	}
+	return "", false
+}
+
+// samePath reports whether two slash-separated paths from history name the
+// same file.
+func (r *runner) samePath(a, b string) bool {
+	if r.cfg.FoldPathCase {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+// headHash returns the commit history is read from.
+func (g *gitRepo) headHash() (plumbing.Hash, error) {
+	if !g.head.IsZero() {
+		return g.head, nil
+	}
+	ref, err := g.Head()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return ref.Hash(), nil
+}
+
+// headRev returns the commit history is read from as a git revision.
+func (g *gitRepo) headRev() string {
+	if !g.head.IsZero() {
+		return g.head.String()
+	}
+	return "HEAD"
+}
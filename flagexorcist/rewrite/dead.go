@@ -0,0 +1,229 @@
+package rewrite
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"sort"
+)
+
+// Branch is code that can never run, assuming a flag always has a value.
+type Branch struct {
+	Pos token.Pos
+	End token.Pos
+
+	// What the code is, like "else branch"
+	Reason string
+}
+
+// DeadBranches returns the code in file that can never run if the flag
+// always has value: the branch of an if statement on it that isn't taken, the
+// rest of a block after one that always returns, and the cases of a switch
+// on it that can never match. Branches inside other dead branches aren't
+// returned separately. They are sorted by position.
+func DeadBranches(
+	file *ast.File, info *types.Info, flag types.Object, value constant.Value,
+) []Branch {
+	d := deadFinder{info: info, flag: flag, value: value}
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.IfStmt:
+			d.ifStmt(n)
+		case *ast.SwitchStmt:
+			d.switchStmt(n)
+		case *ast.BlockStmt:
+			d.block(n.List)
+		case *ast.CaseClause:
+			d.block(n.Body)
+		case *ast.CommClause:
+			d.block(n.Body)
+		}
+		return true
+	})
+
+	sort.SliceStable(d.dead, func(i, j int) bool {
+		if d.dead[i].Pos != d.dead[j].Pos {
+			return d.dead[i].Pos < d.dead[j].Pos
+		}
+		return d.dead[i].End > d.dead[j].End
+	})
+	outer := []Branch{}
+	for _, b := range d.dead {
+		if len(outer) > 0 && b.Pos < outer[len(outer)-1].End {
+			continue
+		}
+		outer = append(outer, b)
+	}
+	return outer
+}
+
+type deadFinder struct {
+	info  *types.Info
+	flag  types.Object
+	value constant.Value
+	dead  []Branch
+}
+
+// fold evaluates expr if it refers to the flag.
+func (d *deadFinder) fold(expr ast.Expr) constant.Value {
+	if !d.mentions(expr) {
+		return nil
+	}
+	return Fold(expr, d.info, d.flag, d.value)
+}
+
+// mentions reports whether the flag is used in node.
+func (d *deadFinder) mentions(node ast.Node) bool {
+	found := false
+	ast.Inspect(node, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && d.info.Uses[id] == d.flag {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+func (d *deadFinder) add(node ast.Node, reason string) {
+	d.dead = append(d.dead, Branch{Pos: node.Pos(), End: node.End(), Reason: reason})
+}
+
+// taken returns the branch of an if statement that is always taken, if its
+// condition is always true or always false. It is nil if there is none, like
+// with no else.
+func (d *deadFinder) taken(n *ast.IfStmt) (ast.Stmt, bool) {
+	cond := d.fold(n.Cond)
+	if cond == nil || cond.Kind() != constant.Bool {
+		return nil, false
+	}
+	if constant.BoolVal(cond) {
+		return n.Body, true
+	}
+	return n.Else, true
+}
+
+func (d *deadFinder) ifStmt(n *ast.IfStmt) {
+	cond := d.fold(n.Cond)
+	if cond == nil || cond.Kind() != constant.Bool {
+		return
+	}
+	if !constant.BoolVal(cond) {
+		d.add(n.Body, "if branch")
+	} else if n.Else != nil {
+		d.add(n.Else, "else branch")
+	}
+}
+
+// block finds the statements of a block that come after an if statement
+// whose branch is always taken and always returns.
+func (d *deadFinder) block(list []ast.Stmt) {
+	for i, s := range list {
+		n, ok := s.(*ast.IfStmt)
+		if !ok || i+1 == len(list) {
+			continue
+		}
+		taken, ok := d.taken(n)
+		if !ok || taken == nil || !d.terminates(taken) {
+			continue
+		}
+		d.dead = append(d.dead, Branch{
+			Pos:    list[i+1].Pos(),
+			End:    list[len(list)-1].End(),
+			Reason: "code after an early return that always happens",
+		})
+		return
+	}
+}
+
+// switchStmt finds the cases of a switch that can never match: a switch on
+// the flag, or one without a tag whose cases are conditions on it. Switches
+// with fallthrough are left alone, since a case that can't match can still
+// be fallen into.
+func (d *deadFinder) switchStmt(n *ast.SwitchStmt) {
+	for _, s := range n.Body.List {
+		body := s.(*ast.CaseClause).Body
+		if len(body) == 0 {
+			continue
+		}
+		if b, ok := body[len(body)-1].(*ast.BranchStmt); ok && b.Tok == token.FALLTHROUGH {
+			return
+		}
+	}
+
+	var match func(expr ast.Expr) constant.Value
+	if n.Tag == nil {
+		match = d.fold
+	} else {
+		tag := d.fold(n.Tag)
+		if tag == nil {
+			return
+		}
+		match = func(expr ast.Expr) constant.Value {
+			v := Fold(expr, d.info, d.flag, d.value)
+			if v == nil || !canCompare(tag, v, token.EQL) {
+				return nil
+			}
+			return constant.MakeBool(constant.Compare(tag, token.EQL, v))
+		}
+	}
+
+	// Once a case always matches, the ones after it and the default can't
+	var def *ast.CaseClause
+	matched := false
+	for _, s := range n.Body.List {
+		clause := s.(*ast.CaseClause)
+		if clause.List == nil {
+			def = clause
+			continue
+		}
+		if matched {
+			d.add(clause, "case after one that always matches")
+			continue
+		}
+
+		never := true
+		for _, expr := range clause.List {
+			v := match(expr)
+			if v == nil || v.Kind() != constant.Bool {
+				never = false
+				continue
+			}
+			if constant.BoolVal(v) {
+				matched, never = true, false
+				break
+			}
+		}
+		if never {
+			d.add(clause, "case that never matches")
+		}
+	}
+	if matched && def != nil {
+		d.add(def, "default case of a switch with a case that always matches")
+	}
+}
+
+// terminates reports whether a branch always ends in a return, panic, break,
+// continue or goto.
+func (d *deadFinder) terminates(s ast.Stmt) bool {
+	switch s := s.(type) {
+	case *ast.BlockStmt:
+		return len(s.List) > 0 && d.terminates(s.List[len(s.List)-1])
+	case *ast.ReturnStmt:
+		return true
+	case *ast.BranchStmt:
+		return s.Tok != token.FALLTHROUGH
+	case *ast.ExprStmt:
+		call, ok := s.X.(*ast.CallExpr)
+		if !ok {
+			return false
+		}
+		id, ok := call.Fun.(*ast.Ident)
+		if !ok {
+			return false
+		}
+		builtin, ok := d.info.Uses[id].(*types.Builtin)
+		return ok && builtin.Name() == "panic"
+	}
+	return false
+}
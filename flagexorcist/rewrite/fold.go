@@ -0,0 +1,86 @@
+package rewrite
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+)
+
+// Fold evaluates expr assuming the flag always has the given value. Returns
+// nil if the expression doesn't fold to a constant.
+func Fold(expr ast.Expr, info *types.Info, flag types.Object, value constant.Value) constant.Value {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		if info.Uses[e] == flag {
+			return value
+		}
+	case *ast.SelectorExpr:
+		if info.Uses[e.Sel] == flag {
+			return value
+		}
+	case *ast.ParenExpr:
+		return Fold(e.X, info, flag, value)
+	case *ast.UnaryExpr:
+		x := Fold(e.X, info, flag, value)
+		if x == nil {
+			return nil
+		}
+		switch {
+		case e.Op == token.NOT && x.Kind() == constant.Bool,
+			e.Op == token.SUB && isNumeric(x):
+			return constant.UnaryOp(e.Op, x, 0)
+		}
+		return nil
+	case *ast.BinaryExpr:
+		x := Fold(e.X, info, flag, value)
+		// && and || can short-circuit without knowing the right hand side
+		if x != nil && x.Kind() == constant.Bool {
+			if e.Op == token.LAND && !constant.BoolVal(x) {
+				return x
+			}
+			if e.Op == token.LOR && constant.BoolVal(x) {
+				return x
+			}
+		}
+		y := Fold(e.Y, info, flag, value)
+		if x == nil || y == nil || !canCompare(x, y, e.Op) {
+			return nil
+		}
+		switch e.Op {
+		case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ:
+			return constant.MakeBool(constant.Compare(x, e.Op, y))
+		case token.LAND, token.LOR:
+			return constant.BinaryOp(x, e.Op, y)
+		}
+		return nil
+	}
+
+	if tv, ok := info.Types[expr]; ok && tv.Value != nil {
+		return tv.Value
+	}
+	return nil
+}
+
+func isNumeric(v constant.Value) bool {
+	switch v.Kind() {
+	case constant.Int, constant.Float, constant.Complex:
+		return true
+	}
+	return false
+}
+
+// canCompare reports whether x and y can be combined with op without
+// go/constant panicking.
+func canCompare(x, y constant.Value, op token.Token) bool {
+	if x.Kind() != y.Kind() && !(isNumeric(x) && isNumeric(y)) {
+		return false
+	}
+	switch x.Kind() {
+	case constant.Bool:
+		return op == token.EQL || op == token.NEQ || op == token.LAND || op == token.LOR
+	case constant.Complex:
+		return op == token.EQL || op == token.NEQ
+	}
+	return op != token.LAND && op != token.LOR
+}
@@ -0,0 +1,451 @@
+// Package rewrite computes the source edits that remove a flag from a package,
+// assuming the flag always has a given value. Conditionals on the flag are
+// constant-folded, dead branches dropped, and remaining usages replaced by the
+// value. Usages that write to the flag or take its address are left alone,
+// along with its declaration.
+package rewrite
+
+import (
+	"bytes"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+)
+
+// Kind describes what an edit does.
+type Kind string
+
+const (
+	// Deletes the declaration of the flag.
+	DeleteDeclaration Kind = "delete_declaration"
+	// Replaces a conditional on the flag with the branch that is always
+	// taken.
+	FoldBranch Kind = "fold_branch"
+	// Replaces a usage that can't be folded with the flag's value.
+	ReplaceUsage Kind = "replace_usage"
+	// Deletes an import that is no longer used once usages are replaced.
+	DeleteImport Kind = "delete_import"
+)
+
+// Edit replaces the source between Pos and End with NewText.
+type Edit struct {
+	Kind    Kind
+	Pos     token.Pos
+	End     token.Pos
+	NewText []byte
+
+	// The code that can never run once the flag is removed, and which this
+	// edit deletes. token.NoPos if there is none.
+	DeadPos token.Pos
+	DeadEnd token.Pos
+
+	Description string
+
+	// For folds, the statement that is kept in place of the if statement, or
+	// nil if nothing is.
+	keep ast.Stmt
+}
+
+// TextEdit converts the edit for use in an analysis.SuggestedFix.
+func (e Edit) TextEdit() analysis.TextEdit {
+	return analysis.TextEdit{Pos: e.Pos, End: e.End, NewText: e.NewText}
+}
+
+// Lookup finds the package-level const or var, or struct field, named name
+// declared in pkg.
+func Lookup(pkg *packages.Package, name string) (types.Object, bool) {
+	for id, obj := range pkg.TypesInfo.Defs {
+		if obj == nil || id.Name != name {
+			continue
+		}
+		switch obj := obj.(type) {
+		case *types.Var:
+			if obj.IsField() || obj.Parent() == pkg.Types.Scope() {
+				return obj, true
+			}
+		case *types.Const:
+			if obj.Parent() == pkg.Types.Scope() {
+				return obj, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// DeclaredValue returns the value the flag is initialized to, if it is known
+// at compile time.
+func DeclaredValue(pkg *packages.Package, flag types.Object) (constant.Value, bool) {
+	if c, ok := flag.(*types.Const); ok {
+		return c.Val(), true
+	}
+
+	for _, file := range pkg.Syntax {
+		path, _ := astutil.PathEnclosingInterval(file, flag.Pos(), flag.Pos())
+		for _, n := range path {
+			spec, ok := n.(*ast.ValueSpec)
+			if !ok {
+				continue
+			}
+			for i, name := range spec.Names {
+				if name.Pos() == flag.Pos() && i < len(spec.Values) {
+					v := pkg.TypesInfo.Types[spec.Values[i]].Value
+					return v, v != nil
+				}
+			}
+		}
+	}
+	return nil, false
+}
+
+// Rewrite returns the edits to pkg that remove flag, assuming it always has
+// value. The edits are sorted by position and don't overlap.
+func Rewrite(pkg *packages.Package, flag types.Object, value constant.Value) ([]Edit, error) {
+	if value == nil {
+		return nil, errors.Errorf("no value to assume for flag %s", flag.Name())
+	}
+
+	// The edits to each file, and whether any usage can't be replaced, in
+	// which case the flag is still needed
+	sources := make([][]byte, len(pkg.Syntax))
+	raw := make([][]Edit, len(pkg.Syntax))
+	kept := false
+	for i, file := range pkg.Syntax {
+		src, err := source(pkg.Fset, file)
+		if err != nil {
+			return nil, err
+		}
+		sources[i] = src
+
+		ast.Inspect(file, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok && pkg.TypesInfo.Uses[id] == flag {
+				if edit, ok := usageEdit(pkg, file, id, flag, value); ok {
+					raw[i] = append(raw[i], edit)
+				} else {
+					kept = true
+				}
+			}
+			return true
+		})
+	}
+
+	edits := []Edit{}
+	for i, file := range pkg.Syntax {
+		if del, ok := DeclarationEdit(file, flag); ok && !kept {
+			raw[i] = append(raw[i], del)
+		}
+		edits = append(edits, nest(pkg.Fset.File(file.Pos()), sources[i], raw[i])...)
+	}
+
+	return edits, nil
+}
+
+// source reads the source of file.
+func source(fset *token.FileSet, file *ast.File) ([]byte, error) {
+	name := fset.File(file.Pos()).Name()
+	src, err := os.ReadFile(name)
+	return src, errors.Wrapf(err, "read %s", name)
+}
+
+// DeclarationEdit deletes the declaration of flag if it is in file. Specs
+// declaring several names at once are left alone.
+func DeclarationEdit(file *ast.File, flag types.Object) (Edit, bool) {
+	if flag.Pos() < file.Pos() || flag.Pos() > file.End() {
+		return Edit{}, false
+	}
+
+	path, _ := astutil.PathEnclosingInterval(file, flag.Pos(), flag.Pos())
+	for i, n := range path {
+		var del ast.Node
+		switch n := n.(type) {
+		case *ast.ValueSpec:
+			if len(n.Names) > 1 {
+				return Edit{}, false
+			}
+			del = n
+			if i+1 < len(path) {
+				if gen, ok := path[i+1].(*ast.GenDecl); ok && len(gen.Specs) == 1 {
+					del = gen
+				}
+			}
+		case *ast.Field:
+			if len(n.Names) > 1 {
+				return Edit{}, false
+			}
+			del = n
+		default:
+			continue
+		}
+
+		return Edit{
+			Kind:        DeleteDeclaration,
+			Pos:         del.Pos(),
+			End:         del.End(),
+			Description: "delete declaration of " + flag.Name(),
+		}, true
+	}
+	return Edit{}, false
+}
+
+// InlineEdit replaces a usage of a flag with value, if it only reads the flag
+// where a literal means the same: as the condition of an if statement, an
+// operand of !, && or ||, or a value assigned or returned. Unlike Rewrite, it
+// leaves the code around the usage alone, for other tools to clean up.
+// Usages qualified by anything but a name, like f().Flag, are left alone,
+// since replacing them would drop the call.
+func InlineEdit(file *ast.File, id *ast.Ident, value constant.Value) (Edit, bool) {
+	path, _ := astutil.PathEnclosingInterval(file, id.Pos(), id.End())
+
+	var usage ast.Expr = id
+	parent := 1
+	if len(path) > 1 {
+		if sel, ok := path[1].(*ast.SelectorExpr); ok && sel.Sel == id {
+			if _, ok := sel.X.(*ast.Ident); !ok {
+				return Edit{}, false
+			}
+			usage, parent = sel, 2
+		}
+	}
+	if parent >= len(path) || !isSimpleRead(path[parent], usage) {
+		return Edit{}, false
+	}
+
+	return Edit{
+		Kind:        ReplaceUsage,
+		Pos:         usage.Pos(),
+		End:         usage.End(),
+		NewText:     []byte(value.ExactString()),
+		Description: "inline the value of " + id.Name,
+	}, true
+}
+
+// isSimpleRead reports whether usage is read in parent somewhere a literal
+// can take its place, for InlineEdit.
+func isSimpleRead(parent ast.Node, usage ast.Expr) bool {
+	contains := func(exprs []ast.Expr) bool {
+		for _, e := range exprs {
+			if e == usage {
+				return true
+			}
+		}
+		return false
+	}
+	switch parent := parent.(type) {
+	case *ast.IfStmt:
+		return parent.Cond == usage
+	case *ast.UnaryExpr:
+		return parent.Op == token.NOT
+	case *ast.BinaryExpr:
+		return parent.Op == token.LAND || parent.Op == token.LOR
+	case *ast.AssignStmt:
+		return contains(parent.Rhs)
+	case *ast.ValueSpec:
+		return contains(parent.Values)
+	case *ast.ReturnStmt:
+		return contains(parent.Results)
+	}
+	return false
+}
+
+// ImportEdit deletes the import of the package that name refers to from
+// file, like when the last usage of it has been replaced.
+func ImportEdit(file *ast.File, name *types.PkgName) (Edit, bool) {
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.IMPORT {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			imp := spec.(*ast.ImportSpec)
+			if imp.Path.Value != strconv.Quote(name.Imported().Path()) {
+				continue
+			}
+			if imp.Name != nil && imp.Name.Name != name.Name() {
+				continue
+			}
+			var del ast.Node = imp
+			if len(gen.Specs) == 1 {
+				del = gen
+			}
+			return Edit{
+				Kind:        DeleteImport,
+				Pos:         del.Pos(),
+				End:         del.End(),
+				Description: "delete the import of " + name.Imported().Path(),
+			}, true
+		}
+	}
+	return Edit{}, false
+}
+
+// usageEdit works out what to do with a single usage of the flag. It returns
+// false if the usage can't be replaced by a value, because it writes to the
+// flag, takes its address or is the key of a struct literal.
+func usageEdit(
+	pkg *packages.Package, file *ast.File, id *ast.Ident, flag types.Object, value constant.Value,
+) (Edit, bool) {
+	path, _ := astutil.PathEnclosingInterval(file, id.Pos(), id.End())
+
+	// The usage itself, including any qualifier like pkg.Flag or cfg.Flag
+	var usage ast.Expr = id
+	if len(path) > 1 {
+		if sel, ok := path[1].(*ast.SelectorExpr); ok && sel.Sel == id {
+			usage = sel
+		}
+	}
+	parent := 1
+	if usage != id {
+		parent = 2
+	}
+	if parent < len(path) && !isRead(path[parent], usage, flag) {
+		return Edit{}, false
+	}
+
+	// Climb out of the expression the flag is part of.
+	var expr ast.Expr = id
+	i := 1
+	for ; i < len(path) && isFoldable(path[i], expr); i++ {
+		expr = path[i].(ast.Expr)
+	}
+
+	replace := Edit{
+		Kind:        ReplaceUsage,
+		Pos:         usage.Pos(),
+		End:         usage.End(),
+		NewText:     []byte(value.ExactString()),
+		Description: "replace usage with the flag's value",
+	}
+	if i >= len(path) {
+		return replace, true
+	}
+
+	ifStmt, ok := path[i].(*ast.IfStmt)
+	if !ok || ifStmt.Cond != expr || ifStmt.Init != nil {
+		return replace, true
+	}
+
+	cond := Fold(expr, pkg.TypesInfo, flag, value)
+	if cond == nil || cond.Kind() != constant.Bool {
+		return replace, true
+	}
+
+	fold := Edit{Kind: FoldBranch, Pos: ifStmt.Pos(), End: ifStmt.End()}
+	if constant.BoolVal(cond) {
+		fold.Description = "condition is always true; keep the if branch"
+		fold.keep = ifStmt.Body
+		if ifStmt.Else != nil {
+			fold.DeadPos, fold.DeadEnd = ifStmt.Else.Pos(), ifStmt.Else.End()
+		}
+		return fold, true
+	}
+
+	fold.Description = "condition is always false; keep the else branch, if any"
+	fold.keep = ifStmt.Else
+	fold.DeadPos, fold.DeadEnd = ifStmt.Body.Pos(), ifStmt.Body.End()
+	return fold, true
+}
+
+// isRead reports whether usage only reads the flag in parent, rather than
+// writing to it, taking its address or naming it as a struct literal's key.
+func isRead(parent ast.Node, usage ast.Expr, flag types.Object) bool {
+	switch parent := parent.(type) {
+	case *ast.AssignStmt:
+		for _, lhs := range parent.Lhs {
+			if lhs == usage {
+				return false
+			}
+		}
+	case *ast.IncDecStmt:
+		return parent.X != usage
+	case *ast.RangeStmt:
+		return parent.Key != usage && parent.Value != usage
+	case *ast.UnaryExpr:
+		return parent.Op != token.AND
+	case *ast.KeyValueExpr:
+		// Keys of map literals are values, but a field used as a key names
+		// the field of a struct literal
+		if v, ok := flag.(*types.Var); ok && v.IsField() {
+			return parent.Key != usage
+		}
+	}
+	return true
+}
+
+// nest resolves overlapping edits. Edits inside code deleted by another edit
+// are dropped, and edits inside code kept by a fold are applied to the fold's
+// NewText.
+func nest(tf *token.File, src []byte, raw []Edit) []Edit {
+	sort.SliceStable(raw, func(i, j int) bool {
+		if raw[i].Pos != raw[j].Pos {
+			return raw[i].Pos < raw[j].Pos
+		}
+		return raw[i].End > raw[j].End
+	})
+
+	top := []Edit{}
+	for i := 0; i < len(raw); {
+		e := raw[i]
+		j := i + 1
+		for j < len(raw) && raw[j].Pos < e.End {
+			j++
+		}
+
+		if e.Kind == FoldBranch {
+			e.NewText = keptText(tf, src, e, raw[i+1:j])
+		}
+		top = append(top, e)
+		i = j
+	}
+	return top
+}
+
+// keptText renders the code a fold keeps, with the edits inside it applied.
+func keptText(tf *token.File, src []byte, fold Edit, inner []Edit) []byte {
+	if fold.keep == nil {
+		return []byte{}
+	}
+
+	pos, end := fold.keep.Pos(), fold.keep.End()
+	if _, ok := fold.keep.(*ast.BlockStmt); ok {
+		// Drop the braces
+		pos, end = pos+1, end-1
+	}
+
+	within := []Edit{}
+	for _, e := range inner {
+		if e.Pos >= pos && e.End <= end {
+			within = append(within, e)
+		}
+	}
+
+	text := []byte{}
+	last := pos
+	for _, e := range nest(tf, src, within) {
+		text = append(text, src[tf.Offset(last):tf.Offset(e.Pos)]...)
+		text = append(text, e.NewText...)
+		last = e.End
+	}
+	text = append(text, src[tf.Offset(last):tf.Offset(end)]...)
+
+	return bytes.TrimSpace(text)
+}
+
+// isFoldable reports whether parent is an expression Fold can see through to
+// reach child.
+func isFoldable(parent ast.Node, child ast.Expr) bool {
+	switch parent := parent.(type) {
+	case *ast.SelectorExpr:
+		return parent.Sel == child
+	case *ast.ParenExpr, *ast.UnaryExpr, *ast.BinaryExpr:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,248 @@
+package rewrite_test
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dgunay/flag-exorcist/flagexorcist/rewrite"
+	"golang.org/x/tools/go/packages"
+)
+
+// load loads a package from the testdata GOPATH.
+func load(t *testing.T, path string) *packages.Package {
+	t.Helper()
+	testdata, err := filepath.Abs(filepath.Join("..", "..", "testdata"))
+	if err != nil {
+		t.Fatalf("Failed to get testdata path: %s", err)
+	}
+
+	pkgs, err := packages.Load(&packages.Config{
+		Mode: packages.LoadAllSyntax,
+		Dir:  testdata,
+		Env:  append(os.Environ(), "GOPATH="+testdata, "GO111MODULE=off", "GOPROXY=off"),
+	}, path)
+	if err != nil {
+		t.Fatalf("Failed to load packages: %s", err)
+	}
+	return pkgs[0]
+}
+
+// apply applies edits to the only file of pkg, and returns it formatted.
+func apply(t *testing.T, pkg *packages.Package, edits []rewrite.Edit) []byte {
+	t.Helper()
+	tf := pkg.Fset.File(pkg.Syntax[0].Pos())
+	src, err := os.ReadFile(tf.Name())
+	if err != nil {
+		t.Fatalf("Failed to read source: %s", err)
+	}
+
+	got := []byte{}
+	last := 0
+	for _, e := range edits {
+		got = append(got, src[last:tf.Offset(e.Pos)]...)
+		got = append(got, e.NewText...)
+		last = tf.Offset(e.End)
+	}
+	got = append(got, src[last:]...)
+
+	got, err = format.Source(got)
+	if err != nil {
+		t.Fatalf("Rewritten source doesn't parse: %s\n%s", err, got)
+	}
+	return got
+}
+
+func TestRewrite(t *testing.T) {
+	t.Parallel()
+
+	pkg := load(t, "simulate")
+
+	flag, ok := rewrite.Lookup(pkg, "EnableCheckout")
+	if !ok {
+		t.Fatalf("Flag not found")
+	}
+	value, ok := rewrite.DeclaredValue(pkg, flag)
+	if !ok {
+		t.Fatalf("Flag value not found")
+	}
+
+	edits, err := rewrite.Rewrite(pkg, flag, value)
+	if err != nil {
+		t.Fatalf("Rewrite failed: %s", err)
+	}
+
+	got := apply(t, pkg, edits)
+	want := `package simulate
+
+import "fmt"
+
+func checkout() {
+	fmt.Println("new checkout")
+
+	fmt.Println(true)
+}
+`
+	if string(got) != want {
+		t.Errorf("Unexpected rewrite:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRewriteWrites(t *testing.T) {
+	t.Parallel()
+
+	pkg := load(t, "writes")
+
+	// Only the reads are replaced, and the flag is kept for the rest
+	for flag, read := range map[string]string{
+		"EnableAssigned":  "EnableAssigned = false\n\treturn true\n",
+		"EnableAddressed": "func Address() *bool {\n\treturn nil\n",
+		"EnableKeyed":     "func Literal(c Config) Config {\n\treturn c\n",
+	} {
+		obj, ok := rewrite.Lookup(pkg, flag)
+		if !ok {
+			t.Fatalf("Flag %s not found", flag)
+		}
+		edits, err := rewrite.Rewrite(pkg, obj, constant.MakeBool(true))
+		if err != nil {
+			t.Fatalf("Rewrite of %s failed: %s", flag, err)
+		}
+		got := apply(t, pkg, edits)
+
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, "writes.go", got, 0)
+		if err != nil {
+			t.Fatalf("Rewrite of %s doesn't parse: %s", flag, err)
+		}
+		if _, err := (&types.Config{}).Check("writes", fset, []*ast.File{file}, nil); err != nil {
+			t.Errorf("Rewrite of %s doesn't compile: %s\n%s", flag, err, got)
+		}
+		if !strings.Contains(string(got), read) {
+			t.Errorf("Rewrite of %s didn't replace its read:\n%s", flag, got)
+		}
+	}
+}
+
+func TestInlineEdit(t *testing.T) {
+	t.Parallel()
+
+	pkg := load(t, "simulate")
+	flag, ok := rewrite.Lookup(pkg, "EnableCheckout")
+	if !ok {
+		t.Fatalf("Flag not found")
+	}
+
+	// Only the conditions are inlined, and the call is left alone
+	edits := []rewrite.Edit{}
+	ast.Inspect(pkg.Syntax[0], func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && pkg.TypesInfo.Uses[id] == flag {
+			if edit, ok := rewrite.InlineEdit(pkg.Syntax[0], id, constant.MakeBool(true)); ok {
+				edits = append(edits, edit)
+			}
+		}
+		return true
+	})
+
+	got := apply(t, pkg, edits)
+	want := `package simulate
+
+import "fmt"
+
+const EnableCheckout = true
+
+func checkout() {
+	if true {
+		fmt.Println("new checkout")
+	} else {
+		fmt.Println("old checkout")
+	}
+
+	if !true {
+		fmt.Println("old checkout")
+	}
+
+	fmt.Println(EnableCheckout)
+}
+`
+	if string(got) != want {
+		t.Errorf("Unexpected inlining:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestImportEdit(t *testing.T) {
+	t.Parallel()
+
+	flags := types.NewPkgName(token.NoPos, nil, "flags", types.NewPackage("example.com/flags", "flags"))
+	tests := []struct {
+		name, src, want string
+	}{
+		{
+			name: "only import",
+			src:  "package a\n\nimport \"example.com/flags\"\n",
+			want: "package a\n",
+		},
+		{
+			name: "grouped",
+			src:  "package a\n\nimport (\n\t\"fmt\"\n\t\"example.com/flags\"\n)\n",
+			want: "package a\n\nimport (\n\t\"fmt\"\n)\n",
+		},
+		{
+			name: "renamed",
+			src:  "package a\n\nimport f \"example.com/flags\"\n",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			fset := token.NewFileSet()
+			file, err := parser.ParseFile(fset, "a.go", tt.src, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			edit, ok := rewrite.ImportEdit(file, flags)
+			if !ok {
+				if tt.want != "" {
+					t.Fatalf("ImportEdit() found no import, want one")
+				}
+				return
+			}
+			tf := fset.File(file.Pos())
+			got, err := format.Source([]byte(tt.src[:tf.Offset(edit.Pos)] + tt.src[tf.Offset(edit.End):]))
+			if err != nil {
+				t.Fatalf("Edited source doesn't parse: %s", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("ImportEdit() leaves %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeadBranches(t *testing.T) {
+	t.Parallel()
+
+	pkg := load(t, "simulate")
+	flag, ok := rewrite.Lookup(pkg, "EnableCheckout")
+	if !ok {
+		t.Fatalf("Flag not found")
+	}
+
+	tf := pkg.Fset.File(pkg.Syntax[0].Pos())
+	lines := [][2]int{}
+	for _, b := range rewrite.DeadBranches(pkg.Syntax[0], pkg.TypesInfo, flag, constant.MakeBool(true)) {
+		lines = append(lines, [2]int{tf.Line(b.Pos), tf.Line(b.End)})
+	}
+	if want := [][2]int{{10, 12}, {14, 16}}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("DeadBranches() covers lines %v, want %v", lines, want)
+	}
+}
@@ -0,0 +1,141 @@
+package flagexorcist
+
+import (
+	"go/constant"
+	"go/token"
+	"go/types"
+	"sort"
+
+	"github.com/dgunay/flag-exorcist/flagexorcist/rewrite"
+	"github.com/pkg/errors"
+	"golang.org/x/tools/go/packages"
+)
+
+// ChangeKind describes the kind of edit an automated removal would make.
+type ChangeKind string
+
+const (
+	// The declaration of the flag would be deleted.
+	ChangeDeleteDeclaration ChangeKind = "delete_declaration"
+	// A conditional on the flag would be replaced by the branch that is
+	// always taken.
+	ChangeFoldBranch ChangeKind = "fold_branch"
+	// A branch that can never run once the flag is removed.
+	ChangeDeadCode ChangeKind = "dead_code"
+	// A usage that can't be folded and would be replaced by the flag's value.
+	ChangeReplaceUsage ChangeKind = "replace_usage"
+)
+
+// LineRange is an inclusive range of lines in a file.
+type LineRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// SimulatedChange is a single edit that removal of a flag would make.
+type SimulatedChange struct {
+	Kind   ChangeKind `json:"kind"`
+	Lines  LineRange  `json:"lines"`
+	Detail string     `json:"detail"`
+}
+
+// SimulatedFile groups the changes made to one file.
+type SimulatedFile struct {
+	Path    string            `json:"path"`
+	Changes []SimulatedChange `json:"changes"`
+}
+
+// Simulation is a machine-readable description of exactly what an automated
+// removal of a flag would change. No diffs are generated.
+type Simulation struct {
+	Flag string `json:"flag"`
+
+	// The value the flag is assumed to have once removed. Empty if it could
+	// not be determined from the declaration.
+	AssumedValue string `json:"assumedValue,omitempty"`
+
+	Files []SimulatedFile `json:"files"`
+}
+
+// Simulate works out what removing the flag named by symbol from pkgs would
+// change, assuming the flag always has value. If value is nil, the value the
+// flag is declared with is used. The packages must be loaded with syntax and
+// type information.
+func Simulate(pkgs []*packages.Package, symbol string, value constant.Value) (*Simulation, error) {
+	var flag types.Object
+	for _, pkg := range pkgs {
+		obj, ok := rewrite.Lookup(pkg, symbol)
+		if !ok {
+			continue
+		}
+		flag = obj
+		if value == nil {
+			value, _ = rewrite.DeclaredValue(pkg, obj)
+		}
+		break
+	}
+	if flag == nil {
+		return nil, errors.Errorf("flag %q is not declared in the loaded packages", symbol)
+	}
+	if value == nil {
+		return nil, errors.Errorf(
+			"can't tell what value flag %q has from its declaration, so a value to assume is needed",
+			symbol,
+		)
+	}
+
+	sim := &Simulation{Flag: symbol, AssumedValue: value.ExactString()}
+	changes := map[string][]SimulatedChange{}
+	for _, pkg := range pkgs {
+		edits, err := rewrite.Rewrite(pkg, flag, value)
+		if err != nil {
+			return nil, err
+		}
+
+		lines := func(pos, end token.Pos) LineRange {
+			return LineRange{
+				Start: pkg.Fset.Position(pos).Line,
+				End:   pkg.Fset.Position(end).Line,
+			}
+		}
+		for _, e := range edits {
+			path := pkg.Fset.Position(e.Pos).Filename
+			changes[path] = append(changes[path], SimulatedChange{
+				Kind:   changeKinds[e.Kind],
+				Lines:  lines(e.Pos, e.End),
+				Detail: e.Description,
+			})
+			if e.DeadPos.IsValid() {
+				changes[path] = append(changes[path], SimulatedChange{
+					Kind:   ChangeDeadCode,
+					Lines:  lines(e.DeadPos, e.DeadEnd),
+					Detail: "branch can never run",
+				})
+			}
+		}
+	}
+
+	for path, cs := range changes {
+		sort.SliceStable(cs, func(i, j int) bool {
+			if cs[i].Lines.Start != cs[j].Lines.Start {
+				return cs[i].Lines.Start < cs[j].Lines.Start
+			}
+			if cs[i].Lines.End != cs[j].Lines.End {
+				return cs[i].Lines.End > cs[j].Lines.End
+			}
+			return cs[i].Kind < cs[j].Kind
+		})
+		sim.Files = append(sim.Files, SimulatedFile{Path: path, Changes: cs})
+	}
+	sort.Slice(sim.Files, func(i, j int) bool {
+		return sim.Files[i].Path < sim.Files[j].Path
+	})
+
+	return sim, nil
+}
+
+var changeKinds = map[rewrite.Kind]ChangeKind{
+	rewrite.DeleteDeclaration: ChangeDeleteDeclaration,
+	rewrite.FoldBranch:        ChangeFoldBranch,
+	rewrite.ReplaceUsage:      ChangeReplaceUsage,
+}
@@ -0,0 +1,58 @@
+package flagexorcist_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/dgunay/flag-exorcist/flagexorcist"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestSimulate(t *testing.T) {
+	t.Parallel()
+
+	workDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get wd: %s", err)
+	}
+
+	testdata := filepath.Join(filepath.Dir(workDir), "testdata")
+	pkgs, err := packages.Load(&packages.Config{
+		Mode: packages.LoadAllSyntax,
+		Dir:  testdata,
+		Env:  append(os.Environ(), "GOPATH="+testdata, "GO111MODULE=off", "GOPROXY=off"),
+	}, "simulate")
+	if err != nil {
+		t.Fatalf("Failed to load packages: %s", err)
+	}
+
+	sim, err := flagexorcist.Simulate(pkgs, "EnableCheckout", nil)
+	if err != nil {
+		t.Fatalf("Simulate failed: %s", err)
+	}
+
+	if sim.AssumedValue != "true" {
+		t.Errorf("Expected assumed value true, got %q", sim.AssumedValue)
+	}
+	if len(sim.Files) != 1 {
+		t.Fatalf("Expected changes in 1 file, got %d", len(sim.Files))
+	}
+
+	want := []flagexorcist.SimulatedChange{
+		{Kind: flagexorcist.ChangeDeleteDeclaration, Lines: flagexorcist.LineRange{Start: 5, End: 5}},
+		{Kind: flagexorcist.ChangeFoldBranch, Lines: flagexorcist.LineRange{Start: 8, End: 12}},
+		{Kind: flagexorcist.ChangeDeadCode, Lines: flagexorcist.LineRange{Start: 10, End: 12}},
+		{Kind: flagexorcist.ChangeDeadCode, Lines: flagexorcist.LineRange{Start: 14, End: 16}},
+		{Kind: flagexorcist.ChangeFoldBranch, Lines: flagexorcist.LineRange{Start: 14, End: 16}},
+		{Kind: flagexorcist.ChangeReplaceUsage, Lines: flagexorcist.LineRange{Start: 18, End: 18}},
+	}
+	got := sim.Files[0].Changes
+	for i := range got {
+		got[i].Detail = ""
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected changes:\ngot:  %+v\nwant: %+v", got, want)
+	}
+}
@@ -0,0 +1,106 @@
+package flagexorcist
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// snoozeFile acknowledges findings about flags until a date, in YAML or JSON,
+// like
+//
+//	snoozed:
+//	  - symbol: EnableX
+//	    until: 2025-06-01
+//	    reason: waiting on partner migration
+//
+// Unlike nolint comments, snoozes run out, and the flag is reported again.
+type snoozeFile struct {
+	Snoozed []struct {
+		// One of a symbol, like in FLAG_SYMBOLS, or a key, like in FLAG_KEYS
+		Symbol string `yaml:"symbol"`
+		Key    string `yaml:"key"`
+
+		// When the snooze runs out, as YYYY-MM-DD
+		Until string `yaml:"until"`
+
+		// Why the flag can't be removed yet
+		Reason string `yaml:"reason"`
+	} `yaml:"snoozed"`
+}
+
+// Snooze acknowledges the findings about a flag until a date.
+type Snooze struct {
+	Until  time.Time `json:"until"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+func (s Snooze) String() string {
+	if s.Reason == "" {
+		return fmt.Sprintf("snoozed until %s", s.Until.UTC().Format("2006-01-02"))
+	}
+	return fmt.Sprintf("snoozed until %s: %s", s.Until.UTC().Format("2006-01-02"), s.Reason)
+}
+
+// loadSnoozes reads a snooze file.
+func loadSnoozes(filename string) (map[FlagID]Snooze, error) {
+	contents, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrap(err, "read snooze file")
+	}
+	// JSON is YAML too
+	var file snoozeFile
+	if err := yaml.Unmarshal(contents, &file); err != nil {
+		return nil, errors.Wrap(err, "parse snooze file")
+	}
+
+	snoozes := map[FlagID]Snooze{}
+	for i, s := range file.Snoozed {
+		var id FlagID
+		switch {
+		case s.Symbol != "" && s.Key != "":
+			return nil, errors.Errorf("snooze %d has both a symbol and a key", i+1)
+		case s.Symbol != "":
+			id = SymbolID(s.Symbol)
+		case s.Key != "":
+			id = KeyID(s.Key)
+		default:
+			return nil, errors.Errorf("snooze %d has neither a symbol nor a key", i+1)
+		}
+
+		until, err := time.Parse("2006-01-02", s.Until)
+		if err != nil {
+			return nil, errors.Errorf(
+				"invalid until %q of snooze of flag %v, must be YYYY-MM-DD", s.Until, id,
+			)
+		}
+		snoozes[id] = Snooze{Until: until, Reason: s.Reason}
+	}
+	return snoozes, nil
+}
+
+// snoozed returns the snooze of flag, if it hasn't run out by runDate.
+func (r *runner) snoozed(flag FlagID, runDate time.Time) (*Snooze, bool) {
+	s, ok := r.snoozes[flag]
+	if !ok || !runDate.Before(s.Until) {
+		return nil, false
+	}
+	return &s, true
+}
+
+// snoozeNote notes that the snooze of flag ran out by runDate, if it did, so
+// that whoever snoozed it knows why it is back.
+func (r *runner) snoozeNote(flag FlagID, runDate time.Time) string {
+	s, ok := r.snoozes[flag]
+	if !ok || runDate.Before(s.Until) {
+		return ""
+	}
+	note := fmt.Sprintf(" (was snoozed until %s", r.formatDate(s.Until))
+	if s.Reason != "" {
+		note += ": " + s.Reason
+	}
+	return note + ")"
+}
@@ -0,0 +1,143 @@
+// Package split is a small client for the parts of the Split.io admin API
+// that flag-exorcist integrates with.
+package split
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const DefaultBaseURL = "https://api.split.io"
+
+// DefaultTimeout is how long a request may take before it is given up on, so
+// that an outage of the API doesn't hang the run.
+const DefaultTimeout = 30 * time.Second
+
+type Client struct {
+	// Base URL of the API. Defaults to DefaultBaseURL.
+	BaseURL string
+
+	// Admin API key used to authenticate requests
+	Token string
+
+	HTTPClient *http.Client
+}
+
+func NewClient(baseURL, token string) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		Token:      token,
+		HTTPClient: &http.Client{Timeout: DefaultTimeout},
+	}
+}
+
+// Definition is a feature flag's targeting in one environment.
+type Definition struct {
+	Name string `json:"name"`
+
+	// Killed flags serve the default treatment to everyone
+	Killed           bool        `json:"killed"`
+	DefaultTreatment string      `json:"defaultTreatment"`
+	Treatments       []Treatment `json:"treatments"`
+	Rules            []Rule      `json:"rules"`
+	DefaultRule      []Bucket    `json:"defaultRule"`
+}
+
+// Treatment is one of the values a flag serves, and the keys and segments
+// targeted to it individually.
+type Treatment struct {
+	Name     string   `json:"name"`
+	Keys     []string `json:"keys"`
+	Segments []string `json:"segments"`
+}
+
+type Rule struct {
+	Buckets []Bucket `json:"buckets"`
+}
+
+// Bucket serves a treatment to size percent of the traffic a rule matches.
+type Bucket struct {
+	Treatment string `json:"treatment"`
+	Size      int    `json:"size"`
+}
+
+// GetDefinition gets the targeting of the flag named name in environment,
+// which is a name or id, of workspace.
+func (c *Client) GetDefinition(
+	ctx context.Context, workspace, name, environment string,
+) (Definition, error) {
+	var def Definition
+	path := fmt.Sprintf("/internal/api/v2/splits/ws/%s/%s/environments/%s",
+		url.PathEscape(workspace), url.PathEscape(name), url.PathEscape(environment),
+	)
+	err := c.get(ctx, path, &def)
+	return def, err
+}
+
+// ServedToAll returns the treatment everyone is served, if they are all
+// served the same one.
+func (d Definition) ServedToAll() (string, bool) {
+	if d.Killed {
+		return d.DefaultTreatment, true
+	}
+
+	served := map[string]bool{}
+	buckets := append([]Bucket(nil), d.DefaultRule...)
+	for _, rule := range d.Rules {
+		buckets = append(buckets, rule.Buckets...)
+	}
+	for _, bucket := range buckets {
+		if bucket.Size > 0 {
+			served[bucket.Treatment] = true
+		}
+	}
+	for _, treatment := range d.Treatments {
+		if len(treatment.Keys) > 0 || len(treatment.Segments) > 0 {
+			served[treatment.Name] = true
+		}
+	}
+
+	if len(served) != 1 {
+		return "", false
+	}
+	for treatment := range served {
+		return treatment, true
+	}
+	return "", false
+}
+
+// get sends a GET request and decodes the response into out.
+func (c *Client) get(ctx context.Context, path string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("GET %s: %s: %s", path, resp.Status, bytes.TrimSpace(msg))
+	}
+	return errors.Wrap(json.NewDecoder(resp.Body).Decode(out), "decode response")
+}
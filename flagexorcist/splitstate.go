@@ -0,0 +1,39 @@
+package flagexorcist
+
+import (
+	"context"
+
+	"github.com/dgunay/flag-exorcist/flagexorcist/split"
+)
+
+// splitState looks up flags in an environment of a Split workspace.
+type splitState struct {
+	client      *split.Client
+	workspace   string
+	environment string
+}
+
+func newSplitState(cfg Config) splitState {
+	return splitState{
+		client:      split.NewClient(cfg.SplitAPIURL, cfg.SplitAPIKey),
+		workspace:   cfg.SplitWorkspaceID,
+		environment: cfg.SplitEnvironment,
+	}
+}
+
+func (s splitState) Name() string {
+	return "split"
+}
+
+func (s splitState) FlagState(ctx context.Context, key string) (FlagState, error) {
+	def, err := s.client.GetDefinition(ctx, s.workspace, key, s.environment)
+	if err != nil {
+		return FlagState{}, err
+	}
+
+	// Split has no off switch short of killing a flag, which serves the
+	// default treatment to everyone
+	state := FlagState{Provider: s.Name(), On: !def.Killed}
+	state.Serving, state.RolledOut = def.ServedToAll()
+	return state, nil
+}
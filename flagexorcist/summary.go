@@ -0,0 +1,147 @@
+package flagexorcist
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// RunSummary is a small summary of a run, for CI pipelines and bots to post
+// as a comment.
+type RunSummary struct {
+	// How many findings there were, how many fail the run, and how many
+	// there were of each severity, including the ones REPORT_TOP left out
+	Findings   int              `json:"findings"`
+	Failing    int              `json:"failing"`
+	BySeverity map[Severity]int `json:"bySeverity"`
+
+	// How many stale flags REPORT_TOP left out of the findings reported
+	Unreported int `json:"unreported"`
+
+	// How many flags were dated, and how many of them are stale
+	Flags int `json:"flags"`
+	Stale int `json:"stale"`
+
+	// The oldest flag dated, if any was
+	Oldest *DatedFlag `json:"oldest,omitempty"`
+
+	// Every stale flag, and the ones that weren't stale in the previous
+	// summary, if there was one. With no previous summary, none are new.
+	StaleFlags []FlagID `json:"staleFlags"`
+	NewlyStale []FlagID `json:"newlyStale"`
+
+	// How long the run took, and how much work it did
+	Duration time.Duration `json:"duration"`
+	Packages int64         `json:"packages"`
+	Commits  int64         `json:"commits"`
+	Phases   []PhaseTiming `json:"phases"`
+}
+
+// Summarize summarizes a run that found findings and dated flags in
+// elapsed, comparing its stale flags to the ones in previous, which can be
+// nil. unreported are the findings REPORT_TOP left out of findings.
+func Summarize(
+	findings, unreported []Finding, flags []DatedFlag,
+	elapsed time.Duration, previous *RunSummary,
+) RunSummary {
+	stats := CurrentStats()
+	s := RunSummary{
+		BySeverity: map[Severity]int{},
+		Flags:      len(flags),
+		StaleFlags: []FlagID{},
+		NewlyStale: []FlagID{},
+		Duration:   elapsed,
+		Packages:   stats.Packages,
+		Commits:    stats.Commits,
+		Phases:     stats.Phases,
+	}
+	left := map[FlagID]bool{}
+	for _, f := range unreported {
+		left[f.Flag] = true
+	}
+	s.Unreported = len(left)
+	for _, f := range append(append([]Finding(nil), findings...), unreported...) {
+		s.Findings++
+		s.BySeverity[f.Severity]++
+		if f.Fails() {
+			s.Failing++
+		}
+	}
+
+	wasStale := map[FlagID]bool{}
+	if previous != nil {
+		for _, flag := range previous.StaleFlags {
+			wasStale[flag] = true
+		}
+	}
+	for i, flag := range flags {
+		if !flag.Undated && (s.Oldest == nil || flag.IntroducedAt.Before(s.Oldest.IntroducedAt)) {
+			s.Oldest = &flags[i]
+		}
+		if !flag.Stale {
+			continue
+		}
+		s.Stale++
+		s.StaleFlags = append(s.StaleFlags, flag.Flag)
+		if previous != nil && !wasStale[flag.Flag] {
+			s.NewlyStale = append(s.NewlyStale, flag.Flag)
+		}
+	}
+	sort.Slice(s.StaleFlags, func(i, j int) bool { return s.StaleFlags[i].less(s.StaleFlags[j]) })
+	sort.Slice(s.NewlyStale, func(i, j int) bool { return s.NewlyStale[i].less(s.NewlyStale[j]) })
+	return s
+}
+
+// ReadRunSummary reads a summary written by WriteRunSummary. It is nil if the
+// file doesn't exist, like on the first run.
+func ReadRunSummary(filename string) (*RunSummary, error) {
+	contents, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "read run summary")
+	}
+	s := &RunSummary{}
+	if err := json.Unmarshal(contents, s); err != nil {
+		return nil, errors.Wrapf(err, "parse run summary %s", filename)
+	}
+	return s, nil
+}
+
+// WriteRunSummary writes s to filename as JSON.
+func WriteRunSummary(filename string, s RunSummary) error {
+	contents, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "encode run summary")
+	}
+	return errors.Wrap(os.WriteFile(filename, append(contents, '\n'), 0o644), "write run summary")
+}
+
+// writeRunSummary writes the summary of a run Run made to the file named by
+// the --summary-out flag, compared to the one named by --summary-previous.
+func (r *runner) writeRunSummary(findings, unreported []Finding, elapsed time.Duration) error {
+	var previous *RunSummary
+	if summaryPrevious != "" {
+		var err error
+		if previous, err = ReadRunSummary(summaryPrevious); err != nil {
+			return err
+		}
+	}
+	return WriteRunSummary(summaryOut, Summarize(findings, unreported, r.dated.list(), elapsed, previous))
+}
+
+// Set by the --summary-out and --summary-previous flags
+var summaryOut, summaryPrevious string
+
+func init() {
+	Analyzer.Flags.StringVar(&summaryOut, "summary-out", "",
+		"write a summary of the run to this file as JSON",
+	)
+	Analyzer.Flags.StringVar(&summaryPrevious, "summary-previous", "",
+		"the summary of an earlier run, if it exists, to tell which flags are newly stale",
+	)
+}
@@ -0,0 +1,91 @@
+package flagexorcist
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// reportTestOnly reports an old flag whose only usages are tests. It is
+// reported at its declaration, or at its first test usage if it isn't
+// declared in the package, unless that is somewhere ignored. tests is empty
+// if the package was analyzed without its tests, which only mention the flag.
+func (r *runner) reportTestOnly(
+	pass *analysis.Pass, flag FlagID, intro introduction, runDate time.Time, tests []reference,
+) {
+	if intro.shallow || !r.isOld(flag, intro, runDate) {
+		return
+	}
+
+	found := []Usage{}
+	for _, usage := range tests {
+		found = append(found, Usage{
+			Source:   usage.source(),
+			Position: pass.Fset.Position(usage.pos),
+			pos:      usage.pos,
+		})
+	}
+	anchor := intro.declaredAt
+	if definedAt(pass.TypesInfo, anchor) == nil {
+		if len(found) == 0 {
+			return
+		}
+		anchor = found[0].pos
+	}
+	if r.ignored(pass.Fset.Position(anchor).Filename) {
+		return
+	}
+
+	// The message doesn't count usages, so that it is the same whether or not
+	// the tests were analyzed, and the checker prints it once.
+	r.report(pass, anchor, runDate, r.annotated(pass, Finding{
+		Flag:     flag,
+		Category: CategoryTestOnly,
+		Message: fmt.Sprintf(
+			"Flag '%v', %v, is only used in tests, so it is dead and should be removed "+
+				"along with them; cutoff is %v",
+			flag, r.describeAge(intro, runDate), r.formatCutoff(flag),
+		),
+		IntroducedAt: intro.at,
+		AgeDays:      ageDays(intro.at, runDate),
+		Usages:       found,
+	}, intro))
+}
+
+// onlyTests reports whether none of usages are outside of tests.
+func onlyTests(pass *analysis.Pass, usages []reference) bool {
+	for _, usage := range usages {
+		if !isTestFile(pass.Fset.Position(usage.pos).Filename) {
+			return false
+		}
+	}
+	return true
+}
+
+// usedInTests reports whether name appears in the tests of the package, when
+// the pass is of the package without its tests. The pass of the package with
+// its tests reports on those usages itself.
+func usedInTests(pass *analysis.Pass, name string) bool {
+	if len(pass.Files) == 0 {
+		return false
+	}
+	for _, file := range pass.Files {
+		if isTestFile(pass.Fset.Position(file.Pos()).Filename) {
+			return false
+		}
+	}
+
+	dir := filepath.Dir(pass.Fset.Position(pass.Files[0].Pos()).Filename)
+	tests, _ := filepath.Glob(filepath.Join(dir, "*_test.go"))
+	for _, test := range tests {
+		contents, err := os.ReadFile(test)
+		if err == nil && bytes.Contains(contents, []byte(name)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package flagexorcist
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// compileTicketPattern compiles TicketPattern, which is nil if it is empty.
+func compileTicketPattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile(pattern)
+	return re, errors.Wrapf(err, "invalid TICKET_PATTERN %q", pattern)
+}
+
+// withTickets attaches the tickets the flag registry gives the flag, and the
+// ones mentioned in the comments on the flag's declaration at pos, to a
+// finding, so it can be traced back to where the rollout is tracked.
+func (r *runner) withTickets(pass *analysis.Pass, f Finding, pos token.Pos) Finding {
+	seen := map[string]bool{}
+	add := func(ticket string) {
+		if !seen[ticket] {
+			seen[ticket] = true
+			f.Tickets = append(f.Tickets, ticket)
+		}
+	}
+	for _, ticket := range r.registry[f.Flag].tickets {
+		add(ticket)
+	}
+	if r.tickets != nil {
+		for _, group := range declarationComments(pass, pos) {
+			for _, ticket := range r.tickets.FindAllString(group.Text(), -1) {
+				add(ticket)
+			}
+		}
+	}
+	if len(f.Tickets) > 0 {
+		f.Message += fmt.Sprintf(" (tracked in %s)", strings.Join(f.Tickets, ", "))
+	}
+	return f
+}
+
+// declarationComments returns the doc and line comments of the declaration
+// whose name is at pos.
+func declarationComments(pass *analysis.Pass, pos token.Pos) []*ast.CommentGroup {
+	for _, file := range pass.Files {
+		if pos < file.Pos() || pos > file.End() {
+			continue
+		}
+
+		path, _ := astutil.PathEnclosingInterval(file, pos, pos)
+		for i, n := range path {
+			switch n := n.(type) {
+			case *ast.ValueSpec:
+				groups := []*ast.CommentGroup{n.Doc, n.Comment}
+				// A lone spec's doc comment is usually on the decl
+				if i+1 < len(path) {
+					if gen, ok := path[i+1].(*ast.GenDecl); ok && len(gen.Specs) == 1 {
+						groups = append(groups, gen.Doc)
+					}
+				}
+				return nonNil(groups)
+			case *ast.Field:
+				return nonNil([]*ast.CommentGroup{n.Doc, n.Comment})
+			}
+		}
+	}
+	return nil
+}
+
+func nonNil(groups []*ast.CommentGroup) []*ast.CommentGroup {
+	found := []*ast.CommentGroup{}
+	for _, g := range groups {
+		if g != nil {
+			found = append(found, g)
+		}
+	}
+	return found
+}
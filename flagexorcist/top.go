@@ -0,0 +1,58 @@
+package flagexorcist
+
+import "sort"
+
+// topFindings keeps the findings about the n oldest flags past their cutoff,
+// and leaves out the ones about the rest, which are only counted, so that
+// teams with years of flag debt are pointed at the worst of it. Findings that
+// don't fail the run, or aren't about a flag past its cutoff, are all kept.
+func (r *runner) topFindings(list []Finding, n int) (kept, unreported []Finding) {
+	introduced := map[FlagID]Finding{}
+	for _, f := range list {
+		if !f.Fails() || !PastCutoff(f.Category) {
+			continue
+		}
+		if prev, ok := introduced[f.Flag]; !ok || f.IntroducedAt.Before(prev.IntroducedAt) {
+			introduced[f.Flag] = f
+		}
+	}
+	if len(introduced) <= n {
+		return list, nil
+	}
+
+	flags := make([]FlagID, 0, len(introduced))
+	for flag := range introduced {
+		flags = append(flags, flag)
+	}
+	sort.Slice(flags, func(i, j int) bool {
+		a, b := introduced[flags[i]], introduced[flags[j]]
+		if !a.IntroducedAt.Equal(b.IntroducedAt) {
+			return a.IntroducedAt.Before(b.IntroducedAt)
+		}
+		return flags[i].less(flags[j])
+	})
+	top := map[FlagID]bool{}
+	for _, flag := range flags[:n] {
+		top[flag] = true
+	}
+
+	for _, f := range list {
+		if f.Fails() && PastCutoff(f.Category) && !top[f.Flag] {
+			unreported = append(unreported, f)
+		} else {
+			kept = append(kept, f)
+		}
+	}
+	r.l.Warn().
+		Int("reported", n).
+		Int("unreported", len(flags)-n).
+		Msgf("Only the %d oldest stale flags are reported; %d more are not, raise REPORT_TOP to see them",
+			n, len(flags)-n)
+	return kept, unreported
+}
+
+// UnreportedFindings returns the findings about stale flags that REPORT_TOP
+// left out of what the last Run returned, and forgets them.
+func UnreportedFindings() []Finding {
+	return r.unreported.take()
+}
@@ -0,0 +1,66 @@
+package flagexorcist
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/tools/go/analysis"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer traces analysis with whatever OpenTelemetry tracer provider the
+// program installs. Without one, spans cost next to nothing.
+var tracer = otel.Tracer("github.com/dgunay/flag-exorcist/flagexorcist")
+
+// traceContext returns the context that packages are traced under: the one
+// passed to RunContext, if the analyzer is being run that way.
+func (r *runner) traceContext() context.Context {
+	if r.traceCtx != nil {
+		return r.traceCtx
+	}
+	return context.Background()
+}
+
+// phase is a part of analyzing a package that is timed for the progress
+// summary, logged and traced as a span.
+type phase struct {
+	r     *runner
+	pkg   string
+	name  string
+	start time.Time
+	span  trace.Span
+}
+
+func (r *runner) startPhase(
+	ctx context.Context, pass *analysis.Pass, name string, attrs ...attribute.KeyValue,
+) (context.Context, *phase) {
+	ctx, span := tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+	return ctx, &phase{r: r, pkg: pass.Pkg.Path(), name: name, start: time.Now(), span: span}
+}
+
+// end ends the phase, marking it as failed if err is not nil, and returns
+// err.
+func (p *phase) end(err error) error {
+	p.r.progress.timed(p.name, p.start)
+	p.r.l.Debug().
+		Err(err).
+		Str("package", p.pkg).
+		Str("phase", p.name).
+		Dur("duration", time.Since(p.start)).
+		Msg("Phase done")
+	endSpan(p.span, err)
+	return err
+}
+
+// endSpan ends span, marking it as failed if err is not nil.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
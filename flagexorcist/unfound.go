@@ -0,0 +1,142 @@
+package flagexorcist
+
+import (
+	"go/ast"
+	"go/types"
+	"sort"
+	"sync"
+)
+
+// UnfoundSymbol is a configured symbol that never matched an identifier,
+// which usually means it is misspelled.
+type UnfoundSymbol struct {
+	Symbol FlagID
+
+	// The closest identifier that was seen, or the zero FlagID if nothing
+	// was close
+	Suggestion FlagID
+}
+
+// sightings records the identifiers seen across every package analyzed, both
+// bare and qualified with the import path of their package.
+type sightings struct {
+	mu        sync.Mutex
+	idents    map[string]bool
+	qualified map[string]bool
+}
+
+func (s *sightings) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.idents = map[string]bool{}
+	s.qualified = map[string]bool{}
+}
+
+// record notes the identifiers a package declares and uses.
+func (s *sightings) record(info *types.Info) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, objs := range []map[*ast.Ident]types.Object{info.Defs, info.Uses} {
+		for ident, obj := range objs {
+			s.idents[ident.Name] = true
+			if obj != nil && obj.Pkg() != nil {
+				s.qualified[obj.Pkg().Path()+"."+ident.Name] = true
+			}
+		}
+	}
+}
+
+// seen reports whether id matched any identifier.
+func (s *sightings) seen(id FlagID) bool {
+	if id.Namespace == "" {
+		return s.idents[id.Name]
+	}
+	return s.qualified[id.String()]
+}
+
+// UnfoundSymbols returns the configured symbols that haven't matched any
+// identifier since the analyzer was initialized, sorted, each with the
+// closest identifier that was seen.
+func UnfoundSymbols() []UnfoundSymbol {
+	r.sightings.mu.Lock()
+	defer r.sightings.mu.Unlock()
+
+	unfound := []UnfoundSymbol{}
+	for _, ids := range r.flags.symbols {
+		for _, id := range ids {
+			if r.sightings.seen(id) {
+				continue
+			}
+			u := UnfoundSymbol{Symbol: id}
+			if name, ok := closest(id.Name, r.sightings.idents); ok {
+				u.Suggestion = FlagID{Kind: id.Kind, Namespace: id.Namespace, Name: name}
+			}
+			unfound = append(unfound, u)
+		}
+	}
+	sort.Slice(unfound, func(i, j int) bool {
+		return unfound[i].Symbol.less(unfound[j].Symbol)
+	})
+	return unfound
+}
+
+// warnUnfound logs a warning for each configured symbol that was never found.
+func (r *runner) warnUnfound() {
+	for _, u := range UnfoundSymbols() {
+		ev := r.l.Warn().Stringer("symbol", u.Symbol)
+		if u.Suggestion != (FlagID{}) {
+			ev = ev.Stringer("did_you_mean", u.Suggestion)
+		}
+		ev.Msg("Flag symbol never matched any identifier, so it was never checked")
+	}
+}
+
+// closest returns the name in names with the smallest edit distance to name,
+// if any is close enough to plausibly be a typo of it.
+func closest(name string, names map[string]bool) (string, bool) {
+	limit := len(name) / 3
+	if limit < 2 {
+		limit = 2
+	}
+
+	best, bestDist := "", limit+1
+	for candidate := range names {
+		if candidate == name {
+			continue
+		}
+		d := levenshtein(name, candidate)
+		if d < bestDist || d == bestDist && candidate < best {
+			best, bestDist = candidate, d
+		}
+	}
+	return best, best != ""
+}
+
+// levenshtein returns the number of single rune insertions, deletions, and
+// substitutions needed to turn a into b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = prev[j-1] + cost
+			if prev[j]+1 < cur[j] {
+				cur[j] = prev[j] + 1
+			}
+			if cur[j-1]+1 < cur[j] {
+				cur[j] = cur[j-1] + 1
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
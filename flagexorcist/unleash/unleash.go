@@ -0,0 +1,166 @@
+// Package unleash is a small client for the parts of the Unleash admin API
+// that flag-exorcist integrates with.
+package unleash
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNotFound is returned for toggles that don't exist, or have been
+// archived, which the API treats the same.
+var ErrNotFound = errors.New("not found")
+
+// DefaultTimeout is how long a request may take before it is given up on, so
+// that an outage of the server doesn't hang the run.
+const DefaultTimeout = 30 * time.Second
+
+type Client struct {
+	// Base URL of the Unleash server, e.g. https://unleash.example.com
+	BaseURL string
+
+	// Admin API token used to authenticate requests
+	Token string
+
+	HTTPClient *http.Client
+}
+
+func NewClient(baseURL, token string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		Token:      token,
+		HTTPClient: &http.Client{Timeout: DefaultTimeout},
+	}
+}
+
+// Feature is a feature toggle, with its strategies in each environment.
+type Feature struct {
+	Name     string `json:"name"`
+	Archived bool   `json:"archived"`
+
+	Environments []Environment `json:"environments"`
+}
+
+// Environment is a toggle's activation in one environment. The toggle is on
+// for anyone any of its strategies is on for.
+type Environment struct {
+	Name       string     `json:"name"`
+	Enabled    bool       `json:"enabled"`
+	Strategies []Strategy `json:"strategies"`
+}
+
+type Strategy struct {
+	Name        string            `json:"name"`
+	Parameters  map[string]string `json:"parameters"`
+	Constraints []json.RawMessage `json:"constraints"`
+	Segments    []int             `json:"segments"`
+	Variants    []Variant         `json:"variants"`
+	Disabled    bool              `json:"disabled"`
+}
+
+type Variant struct {
+	Name   string `json:"name"`
+	Weight int    `json:"weight"`
+}
+
+// GetFeature gets the toggle named name in project.
+func (c *Client) GetFeature(ctx context.Context, project, name string) (Feature, error) {
+	var feature Feature
+	path := fmt.Sprintf("/api/admin/projects/%s/features/%s",
+		url.PathEscape(project), url.PathEscape(name),
+	)
+	err := c.get(ctx, path, &feature)
+	return feature, err
+}
+
+// ArchivedFeatures lists the names of the archived toggles in project.
+func (c *Client) ArchivedFeatures(ctx context.Context, project string) ([]string, error) {
+	var archive struct {
+		Features []Feature `json:"features"`
+	}
+	err := c.get(ctx, "/api/admin/archive/features/"+url.PathEscape(project), &archive)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, feature := range archive.Features {
+		names = append(names, feature.Name)
+	}
+	return names, nil
+}
+
+// Environment returns the toggle's activation in the named environment.
+func (f Feature) Environment(name string) (Environment, bool) {
+	for _, env := range f.Environments {
+		if env.Name == name {
+			return env, true
+		}
+	}
+	return Environment{}, false
+}
+
+// EnabledForAll reports whether the toggle is on for everyone in the
+// environment, with at most one variant, because one of its strategies
+// applies to everyone.
+func (e Environment) EnabledForAll() bool {
+	if !e.Enabled {
+		return false
+	}
+	for _, s := range e.Strategies {
+		if s.Disabled || len(s.Constraints) > 0 || len(s.Segments) > 0 || len(s.Variants) > 1 {
+			continue
+		}
+		switch s.Name {
+		case "default":
+			return true
+		case "flexibleRollout":
+			if s.Parameters["rollout"] == "100" {
+				return true
+			}
+		case "gradualRolloutRandom", "gradualRolloutUserId", "gradualRolloutSessionId":
+			if percentage, err := strconv.Atoi(s.Parameters["percentage"]); err == nil &&
+				percentage >= 100 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// get sends a GET request and decodes the response into out.
+func (c *Client) get(ctx context.Context, path string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.Token != "" {
+		// Unleash takes the bare API token, without a scheme
+		req.Header.Set("Authorization", c.Token)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return errors.Wrapf(ErrNotFound, "GET %s", path)
+	}
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("GET %s: %s: %s", path, resp.Status, bytes.TrimSpace(msg))
+	}
+	return errors.Wrap(json.NewDecoder(resp.Body).Decode(out), "decode response")
+}
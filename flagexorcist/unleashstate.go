@@ -0,0 +1,77 @@
+package flagexorcist
+
+import (
+	"context"
+	"sync"
+
+	"github.com/dgunay/flag-exorcist/flagexorcist/unleash"
+	"github.com/pkg/errors"
+)
+
+// unleashState looks up toggles in an environment of an Unleash project.
+type unleashState struct {
+	client      *unleash.Client
+	project     string
+	environment string
+
+	// The project's archived toggles, listed the first time a toggle isn't
+	// found
+	archiveOnce sync.Once
+	archived    map[string]bool
+	archiveErr  error
+}
+
+func newUnleashState(cfg Config) *unleashState {
+	return &unleashState{
+		client:      unleash.NewClient(cfg.UnleashAPIURL, cfg.UnleashAPIToken),
+		project:     cfg.UnleashProject,
+		environment: cfg.UnleashEnvironment,
+	}
+}
+
+func (s *unleashState) Name() string {
+	return "unleash"
+}
+
+func (s *unleashState) FlagState(ctx context.Context, key string) (FlagState, error) {
+	feature, err := s.client.GetFeature(ctx, s.project, key)
+	if errors.Is(err, unleash.ErrNotFound) {
+		// Archived toggles are only in the archive
+		archived, archiveErr := s.isArchived(ctx, key)
+		if archiveErr != nil {
+			return FlagState{}, archiveErr
+		}
+		if archived {
+			return FlagState{Provider: s.Name(), Archived: true}, nil
+		}
+	}
+	if err != nil {
+		return FlagState{}, err
+	}
+	env, ok := feature.Environment(s.environment)
+	if !ok {
+		return FlagState{}, errors.Errorf("toggle %s has no environment %s", key, s.environment)
+	}
+
+	state := FlagState{Provider: s.Name(), Archived: feature.Archived, On: env.Enabled}
+	if env.EnabledForAll() {
+		state.RolledOut = true
+		state.Serving = "true"
+	}
+	return state, nil
+}
+
+func (s *unleashState) isArchived(ctx context.Context, key string) (bool, error) {
+	s.archiveOnce.Do(func() {
+		names, err := s.client.ArchivedFeatures(ctx, s.project)
+		if err != nil {
+			s.archiveErr = errors.Wrap(err, "list archived toggles")
+			return
+		}
+		s.archived = map[string]bool{}
+		for _, name := range names {
+			s.archived[name] = true
+		}
+	})
+	return s.archived[key], s.archiveErr
+}
@@ -0,0 +1,94 @@
+package flagexorcist
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"time"
+
+	"github.com/dgunay/flag-exorcist/flagexorcist/rewrite"
+	"golang.org/x/tools/go/analysis"
+)
+
+// reportUnused reports a flag with no usages in the package as dead, if it is
+// old and nothing outside the package could be using it. Whether anything
+// outside the package uses an exported flag is only known once every package
+// importing it has been analyzed, so those are left to aggregateUses.
+func (r *runner) reportUnused(
+	pass *analysis.Pass, flag FlagID, intro introduction, runDate time.Time,
+) {
+	if flag.Kind != FlagKindSymbol || intro.shallow || !r.isOld(flag, intro, runDate) {
+		return
+	}
+	declared := pass.Fset.Position(intro.declaredAt)
+	if r.ignored(declared.Filename) {
+		return
+	}
+
+	obj := definedAt(pass.TypesInfo, intro.declaredAt)
+	if obj == nil {
+		return
+	}
+	if obj.Exported() && pass.Pkg.Name() != "main" {
+		if r.aggregating {
+			r.exported.add(unusedExport{
+				pass: pass, flag: flag, obj: obj, intro: intro, runDate: runDate,
+			})
+		}
+		return
+	}
+	r.reportDead(pass, flag, obj, intro, runDate)
+}
+
+// reportDead reports a flag as no longer used anywhere, with a fix deleting
+// its declaration if nothing in its package refers to it.
+func (r *runner) reportDead(
+	pass *analysis.Pass, flag FlagID, obj types.Object, intro introduction, runDate time.Time,
+) {
+	f := Finding{
+		Flag:     flag,
+		Category: CategoryUnused,
+		Message: fmt.Sprintf(
+			"Flag '%v', %v, is no longer used and is safe to remove; cutoff is %v",
+			flag, r.describeAge(intro, runDate), r.formatCutoff(flag),
+		),
+		IntroducedAt: intro.at,
+		AgeDays:      ageDays(intro.at, runDate),
+	}
+
+	// Usages that were ignored, like ones in tests, still need the
+	// declaration.
+	if !isUsed(pass.TypesInfo, obj) {
+		for _, file := range pass.Files {
+			if del, ok := rewrite.DeclarationEdit(file, obj); ok {
+				f.fixes = []analysis.SuggestedFix{{
+					Message:   del.Description,
+					TextEdits: []analysis.TextEdit{del.TextEdit()},
+				}}
+				break
+			}
+		}
+	}
+
+	r.report(pass, intro.declaredAt, runDate, r.annotated(pass, f, intro))
+}
+
+// definedAt returns the object whose defining identifier is at pos, if any.
+func definedAt(info *types.Info, pos token.Pos) types.Object {
+	for id, obj := range info.Defs {
+		if id.Pos() == pos {
+			return obj
+		}
+	}
+	return nil
+}
+
+// isUsed reports whether obj is used anywhere in the package.
+func isUsed(info *types.Info, obj types.Object) bool {
+	for _, used := range info.Uses {
+		if used == obj {
+			return true
+		}
+	}
+	return false
+}
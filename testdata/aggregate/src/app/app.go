@@ -0,0 +1,15 @@
+package app
+
+import "flags"
+
+func Search() bool {
+	return flags.EnableSearch
+}
+
+func Suggest() bool {
+	return flags.EnableSearch && len("suggest") > 0
+}
+
+func Pay() bool {
+	return flags.EnableCheckout
+}
@@ -0,0 +1,16 @@
+package flags
+
+const (
+	// Only used by app
+	EnableSearch = true
+
+	// Used nowhere
+	EnableLegacy = true
+
+	// Used here and by app
+	EnableCheckout = true
+)
+
+func Checkout() bool {
+	return EnableCheckout // want "EnableCheckout.*is hardcoded"
+}
@@ -0,0 +1,11 @@
+package budget
+
+const (
+	EnableSearch   = true
+	EnableCheckout = false
+	EnableReviews  = true // want "Package 'budget' declares 3 flags, more than its budget of 2; 'EnableReviews' is the newest"
+)
+
+func Features() []bool {
+	return []bool{EnableSearch, EnableCheckout, EnableReviews}
+}
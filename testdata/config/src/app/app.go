@@ -0,0 +1,20 @@
+package app
+
+import "viper"
+
+func Checkout() bool {
+	return viper.GetBool("features.new_checkout") // want `Flag 'features.new_checkout', introduced .*; cutoff is 0 days \(used 2 times across 1 file: 2 key-literal\)`
+}
+
+func CheckoutAgain(v *viper.Viper) bool {
+	return v.GetBool("features.new_checkout")
+}
+
+// Not a feature, and not a lookup function
+func Name() string {
+	return viper.GetString("features.name") + viper.GetString("server.name")
+}
+
+func Port() bool {
+	return viper.GetBool("server.tls")
+}
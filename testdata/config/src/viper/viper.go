@@ -0,0 +1,16 @@
+// Package viper stands in for github.com/spf13/viper.
+package viper
+
+type Viper struct{}
+
+func (*Viper) GetBool(key string) bool {
+	return false
+}
+
+func GetBool(key string) bool {
+	return false
+}
+
+func GetString(key string) string {
+	return ""
+}
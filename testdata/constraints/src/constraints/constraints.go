@@ -0,0 +1,6 @@
+package constraints
+
+// Only used behind the integration tag
+var enableSearch = true
+
+var enableReviews = true // want "Flag 'enableReviews', introduced \\d\\d\\d\\d-\\d\\d-\\d\\d, \\d+ days ago, is no longer used and is safe to remove; cutoff is 0 days"
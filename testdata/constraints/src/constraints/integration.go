@@ -0,0 +1,7 @@
+//go:build integration
+
+package constraints
+
+func Search() bool {
+	return enableSearch
+}
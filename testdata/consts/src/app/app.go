@@ -0,0 +1,15 @@
+package app
+
+import (
+	"config"
+	"keys"
+)
+
+func Checkout() bool {
+	return config.IsEnabled(keys.NewCheckout) || // want `Flag 'new-checkout', introduced .*; cutoff is 0 days \(used 2 times across 1 file: 1 symbol, 1 key-literal\)`
+		config.IsEnabled("new-checkout")
+}
+
+func Search() bool {
+	return config.GetBool(keys.FeatureSearch) // want `Flag 'features.search', introduced .*; cutoff is 0 days`
+}
@@ -0,0 +1,9 @@
+package config
+
+func GetBool(key string) bool {
+	return false
+}
+
+func IsEnabled(key string) bool {
+	return false
+}
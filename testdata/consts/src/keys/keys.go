@@ -0,0 +1,6 @@
+package keys
+
+const (
+	NewCheckout   = "new-checkout"
+	FeatureSearch = "features.search"
+)
@@ -0,0 +1,62 @@
+package dead
+
+import "fmt"
+
+const EnableCheckout = true
+
+func Checkout() {
+	if EnableCheckout { // want "Flag 'EnableCheckout', .*is hardcoded to true"
+		fmt.Println("new checkout")
+	} else { // want "Flag 'EnableCheckout', .*is always true, so this else branch can never run and can be removed"
+		fmt.Println("old checkout")
+	}
+
+	if !EnableCheckout { // want "this if branch can never run"
+		fmt.Println("old checkout")
+	}
+}
+
+func Pay() error {
+	if EnableCheckout {
+		return nil
+	}
+	fmt.Println("old payment") // want "this code after an early return that always happens can never run"
+	return fmt.Errorf("old payment")
+}
+
+func Ship(express bool) {
+	switch {
+	case express:
+		fmt.Println("express")
+	case !EnableCheckout: // want "this case that never matches can never run"
+		fmt.Println("old shipping")
+	case EnableCheckout:
+		fmt.Println("new shipping")
+	case len("never") > 10: // want "this case after one that always matches can never run"
+		fmt.Println("never")
+	default: // want "this default case of a switch with a case that always matches can never run"
+		fmt.Println("unreachable")
+	}
+
+	switch EnableCheckout {
+	case false: // want "this case that never matches can never run"
+		fmt.Println("old")
+	}
+
+	// Unknown, so nothing is dead
+	if express && EnableCheckout {
+		fmt.Println("express checkout")
+	} else {
+		fmt.Println("maybe")
+	}
+}
+
+// Falls through, so left alone
+func Fall() {
+	switch EnableCheckout {
+	case true:
+		fallthrough
+	case false:
+		fmt.Println("both")
+	}
+}
@@ -0,0 +1,15 @@
+package detector
+
+// Client is a flag system the analyzer doesn't know about
+type Client struct{}
+
+func (Client) Toggle(name string) bool { return false }
+
+func Checkout(c Client) bool {
+	return c.Toggle("new-checkout") // want "Flag 'new-checkout', introduced \\d\\d\\d\\d-\\d\\d-\\d\\d, \\d+ days ago; cutoff is 0 days"
+}
+
+// Not a toggle
+func Name() string {
+	return "new-search"
+}
@@ -0,0 +1,17 @@
+package app
+
+import "os"
+
+func Checkout() bool {
+	return os.Getenv("FEATURE_NEW_CHECKOUT") == "true" // want `Flag 'FEATURE_NEW_CHECKOUT', introduced .*; cutoff is 0 days \(used 2 times across 1 file: 2 env\)`
+}
+
+func CheckoutAgain() bool {
+	_, ok := os.LookupEnv("FEATURE_NEW_CHECKOUT")
+	return ok
+}
+
+// Not a feature
+func Home() string {
+	return os.Getenv("HOME")
+}
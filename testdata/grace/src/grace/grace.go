@@ -0,0 +1,24 @@
+package grace
+
+const (
+	EnableSearch  = true
+	EnableReviews = true
+
+	// flagexorcist:expires 2099-10-01
+	EnableWishlist = true
+)
+
+// Expired less than a month ago, so only warned about
+func Search() bool {
+	return EnableSearch
+}
+
+// Expired over a month ago
+func Reviews() bool {
+	return EnableReviews // want `Flag 'EnableReviews', introduced .*, expired 2099-11-01 according to the flag registry and its grace period ended 2099-12-01, but is still in the code`
+}
+
+// Expired by its annotation, which isn't in the registry
+func Wishlist() bool {
+	return EnableWishlist // want `Flag 'EnableWishlist', introduced .*, expired 2099-10-01 according to its annotation and its grace period ended 2099-10-31, but is still in the code`
+}
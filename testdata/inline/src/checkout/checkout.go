@@ -0,0 +1,34 @@
+package checkout
+
+import "fmt"
+
+const (
+	EnableCheckout = false
+	CheckoutName   = "new"
+)
+
+// Not the type a literal would be, so it is left alone
+type Mode bool
+
+const EnableSearch Mode = true
+
+type Flags struct {
+	NewReviews bool `flag:"new-reviews,default=true"`
+}
+
+func Checkout(f Flags) string {
+	if EnableCheckout { // want "Flag 'EnableCheckout', introduced .*; cutoff is 0 days \\(used 3 times across 1 file: 3 symbol\\)"
+		fmt.Println("new checkout")
+	}
+	enabled := !EnableCheckout && f.NewReviews // want "Flag 'new-reviews', introduced .*; cutoff is 0 days"
+
+	// Passed to a call, so it is left alone
+	fmt.Println(EnableCheckout, enabled)
+
+	return CheckoutName // want "Flag 'CheckoutName', introduced .*; cutoff is 0 days"
+}
+
+func Search() bool {
+	mode := EnableSearch // want "Flag 'EnableSearch', introduced .*; cutoff is 0 days"
+	return bool(mode)
+}
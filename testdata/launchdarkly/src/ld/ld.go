@@ -0,0 +1,30 @@
+package ld
+
+type Client struct{}
+
+func (Client) BoolVariation(key string, fallback bool) bool {
+	return fallback
+}
+
+var client Client
+
+func Checkout() bool {
+	return client.BoolVariation("checkout-v2", false) // want "Flag 'checkout-v2', introduced .*, is serving true to everyone in launchdarkly, so its rollout is complete and the code gating on it can be removed"
+}
+
+func Search() bool {
+	return client.BoolVariation("search-v2", false) // want "Flag 'launchdarkly:search-v2', introduced .*, is archived in launchdarkly, so its rollout is complete"
+}
+
+// Still rolling out, or not in LaunchDarkly at all
+func Reviews() bool {
+	return client.BoolVariation("reviews-v2", false)
+}
+
+func Missing() bool {
+	return client.BoolVariation("missing", false)
+}
+
+func Elsewhere() bool {
+	return client.BoolVariation("elsewhere", false)
+}
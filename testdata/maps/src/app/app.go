@@ -0,0 +1,15 @@
+package app
+
+import "flags"
+
+func Checkout() bool {
+	return flags.Enabled("new-checkout") // want "Flag 'new-checkout', introduced .*; cutoff is 0 days"
+}
+
+func Search() bool {
+	return flags.Enabled("new-search") // want "Flag 'new-search', introduced .*; cutoff is 0 days"
+}
+
+func Other() bool {
+	return flags.Enabled("not-a-flag")
+}
@@ -0,0 +1,16 @@
+package flags // want package:"flag keys new-checkout new-search unused-flag"
+
+const KeySearch = "new-search"
+
+var Defaults = map[string]bool{
+	"new-checkout": false,
+	KeySearch:      true,
+	"unused-flag":  false,
+}
+
+// Not one of FLAG_MAPS
+var other = map[string]bool{"not-a-flag": true}
+
+func Enabled(key string) bool {
+	return Defaults[key] || other[key]
+}
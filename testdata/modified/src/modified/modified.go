@@ -0,0 +1,10 @@
+package main
+
+import "os"
+
+var MyFlag = os.Getenv("MY_FLAG") != ""
+
+func main() {
+	if MyFlag { // want `Flag 'MyFlag', last changed \d{4}-\d\d-\d\d, \d+ days ago; cutoff is 0 days`
+	}
+}
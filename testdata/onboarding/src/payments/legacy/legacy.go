@@ -0,0 +1,8 @@
+// Package legacy is nested in an onboarded directory, so it is onboarded too.
+package legacy
+
+var EnableLegacy = true
+
+func Pay() bool {
+	return EnableLegacy // want `Flag 'EnableLegacy', introduced .*`
+}
@@ -0,0 +1,7 @@
+package payments
+
+var EnablePayments = true
+
+func Pay() bool {
+	return EnablePayments // want `Flag 'EnablePayments', introduced .*`
+}
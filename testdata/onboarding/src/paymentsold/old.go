@@ -0,0 +1,9 @@
+// Package paymentsold only starts with the name of an onboarded directory, so
+// its findings are report-only.
+package paymentsold
+
+var EnableOld = true
+
+func Pay() bool {
+	return EnableOld
+}
@@ -0,0 +1,22 @@
+package vendors
+
+func enabled(key string) bool {
+	return false
+}
+
+func Checkout() bool {
+	return enabled("checkout-v2") // want "Flag 'split:checkout-v2', introduced .*, is serving on to everyone in split, so its rollout is complete"
+}
+
+func Search() bool {
+	return enabled("search-v2") // want "Flag 'configcat:search-v2', introduced .*, is serving true to everyone in configcat, so its rollout is complete"
+}
+
+func Reviews() bool {
+	return enabled("reviews-v2") // want "Flag 'custom:reviews-v2', introduced .*, is archived in custom, so its rollout is complete"
+}
+
+// Only served to some
+func Wishlist() bool {
+	return enabled("wishlist") || enabled("ramping")
+}
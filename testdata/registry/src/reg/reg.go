@@ -0,0 +1,23 @@
+package reg
+
+const (
+	EnableSearch  = true
+	EnableReviews = true
+)
+
+func enabled(key string) bool {
+	return false
+}
+
+func Search() bool {
+	return EnableSearch // want `Flag 'EnableSearch', introduced .*, expired 2001-03-01 according to the flag registry but is still in the code \[owned by @search-team, added by .*\]`
+}
+
+func Checkout() bool {
+	return enabled("new-checkout") // want `Flag 'new-checkout', introduced .*; cutoff is 1 day \(The one-page checkout\) \(tracked in CHK-12, CHK-15\) \[owned by @checkout-team @payments, added by .*\]`
+}
+
+// Young, and not expired
+func Reviews() bool {
+	return EnableReviews
+}
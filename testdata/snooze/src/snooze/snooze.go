@@ -0,0 +1,16 @@
+package snooze
+
+const (
+	EnableSearch  = true
+	EnableReviews = true
+)
+
+// Snoozed until after the run date
+func Search() bool {
+	return EnableSearch
+}
+
+// Snoozed until before the run date
+func Reviews() bool {
+	return EnableReviews // want `Flag 'EnableReviews', introduced .*; cutoff is 0 days \(was snoozed until 2099-06-01: blocked on the mobile release\)`
+}
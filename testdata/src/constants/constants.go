@@ -1,8 +1,9 @@
 package main
 
+// Rollout tracked in PROJ-1234
 const MyFlag = "myflag"
 
 func main() {
-	if MyFlag != "myflag" { // want "Flag 'MyFlag', added on \\d\\d\\d\\d-\\d\\d-\\d\\d, is more than \\d days old"
+	if MyFlag != "myflag" { // want "Flag 'MyFlag', introduced \\d\\d\\d\\d-\\d\\d-\\d\\d, \\d+ days ago; cutoff is 0 days \\(tracked in PROJ-1234\\)"
 	}
 }
@@ -0,0 +1,8 @@
+package mocks
+
+// Matched by EXCLUDE_PATHS, so nothing is reported here
+const MyFlag = true
+
+func Enabled() bool {
+	return MyFlag
+}
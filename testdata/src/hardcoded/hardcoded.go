@@ -0,0 +1,12 @@
+package main
+
+import "fmt"
+
+// Never assigned again, so it is always false
+var MyFlag = false
+
+func main() {
+	if MyFlag { // want "Flag 'MyFlag', introduced \\d\\d\\d\\d-\\d\\d-\\d\\d, \\d+ days ago, is hardcoded to false, so its rollout is complete and the code it turns off is dead; cutoff is 0 days"
+		fmt.Println("new behavior")
+	}
+}
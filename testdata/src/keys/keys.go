@@ -0,0 +1,14 @@
+package main
+
+const NewCheckout = "new-checkout"
+
+func isEnabled(key string) bool { return key != "" }
+
+func main() {
+	if isEnabled(NewCheckout) { // want "Flag 'new-checkout', introduced \\d\\d\\d\\d-\\d\\d-\\d\\d, \\d+ days ago; cutoff is 0 days \\(used 2 times across 1 file: 1 symbol, 1 key-literal\\)"
+	}
+	if isEnabled("new-checkout") {
+	}
+	if isEnabled("other-key") {
+	}
+}
@@ -0,0 +1,10 @@
+package main
+
+import "testing"
+
+// Ignored with IGNORE_TESTS
+func TestCheckout(t *testing.T) {
+	if NewCheckout == "" {
+		t.Fatal("no key")
+	}
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// Set from the environment, so it isn't hardcoded
+var MyFlag = false
+
+func init() {
+	MyFlag = os.Getenv("MY_FLAG") == "on" // want "Flag 'MyFlag', introduced \\d\\d\\d\\d-\\d\\d-\\d\\d, \\d+ days ago; cutoff is 0 days \\(used 2 times across 1 file: 2 symbol\\)"
+}
+
+func main() {
+	if MyFlag {
+		fmt.Println("new behavior")
+	}
+}
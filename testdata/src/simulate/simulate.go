@@ -0,0 +1,19 @@
+package simulate
+
+import "fmt"
+
+const EnableCheckout = true
+
+func checkout() {
+	if EnableCheckout {
+		fmt.Println("new checkout")
+	} else {
+		fmt.Println("old checkout")
+	}
+
+	if !EnableCheckout {
+		fmt.Println("old checkout")
+	}
+
+	fmt.Println(EnableCheckout)
+}
@@ -0,0 +1,7 @@
+package main
+
+import "os"
+
+var MyFlag = os.Getenv("MY_FLAG") == "on" // want "Flag 'MyFlag', introduced \\d\\d\\d\\d-\\d\\d-\\d\\d, \\d+ days ago, is only used in tests, so it is dead and should be removed along with them; cutoff is 0 days"
+
+func main() {}
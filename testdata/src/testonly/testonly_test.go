@@ -0,0 +1,10 @@
+package main
+
+import "testing"
+
+// The only thing keeping the flag around
+func TestFlag(t *testing.T) {
+	if MyFlag {
+		t.Log("flag on")
+	}
+}
@@ -0,0 +1,9 @@
+package main
+
+import "fmt"
+
+const MyFlag = true // want "Flag 'MyFlag', introduced \\d\\d\\d\\d-\\d\\d-\\d\\d, \\d+ days ago, is no longer used and is safe to remove; cutoff is 0 days"
+
+func main() {
+	fmt.Println("the flag is gone")
+}
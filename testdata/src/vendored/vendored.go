@@ -0,0 +1,8 @@
+package main
+
+import "flags"
+
+func main() {
+	if flags.Enabled() {
+	}
+}
@@ -0,0 +1,28 @@
+package writes
+
+var EnableAssigned = true
+
+var EnableAddressed = true
+
+type Config struct {
+	EnableKeyed bool
+}
+
+func Assign() bool {
+	EnableAssigned = false
+	return EnableAssigned
+}
+
+func Address() *bool {
+	if EnableAddressed {
+		return nil
+	}
+	return &EnableAddressed
+}
+
+func Literal(c Config) Config {
+	if c.EnableKeyed {
+		return c
+	}
+	return Config{EnableKeyed: true}
+}
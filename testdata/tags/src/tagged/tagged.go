@@ -0,0 +1,24 @@
+package tagged
+
+type Flags struct {
+	NewCheckout bool `flag:"new-checkout,default=false" json:"newCheckout"`
+	NewSearch   bool `flag:"new-search"`
+}
+
+type Config struct {
+	Flags
+	Name string `json:"name"`
+}
+
+func Defaults() Flags {
+	return Flags{NewCheckout: false} // want "Flag 'new-checkout', introduced .*; cutoff is 0 days \\(used 3 times across 1 file: 3 symbol\\)"
+}
+
+func Checkout(cfg *Config) bool {
+	return cfg.NewCheckout || cfg.Flags.NewCheckout
+}
+
+// Not a tracked key
+func Search(cfg Config) bool {
+	return cfg.NewSearch
+}
@@ -0,0 +1,11 @@
+package main
+
+import "os"
+
+// Rollout tracked in PROJ-99
+var MyFlag = os.Getenv("MY_FLAG") != ""
+
+func main() {
+	if MyFlag { // want `MyFlag \(symbol\) is \d+ days old, past the 0 day cutoff; remove it \(PROJ-99\)`
+	}
+}
@@ -0,0 +1,8 @@
+package main
+
+const MyFlag = true
+
+func main() {
+	if MyFlag {
+	}
+}
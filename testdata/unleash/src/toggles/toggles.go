@@ -0,0 +1,26 @@
+package toggles
+
+type Client struct{}
+
+func (Client) IsEnabled(name string) bool {
+	return false
+}
+
+var client Client
+
+func Checkout() bool {
+	return client.IsEnabled("checkout-v2") // want "Flag 'checkout-v2', introduced .*, is serving true to everyone in unleash, so its rollout is complete"
+}
+
+func Search() bool {
+	return client.IsEnabled("search-v2") // want "Flag 'unleash:search-v2', introduced .*, is archived in unleash, so its rollout is complete"
+}
+
+// Only enabled for some, or off
+func Reviews() bool {
+	return client.IsEnabled("reviews-v2")
+}
+
+func Wishlist() bool {
+	return client.IsEnabled("wishlist")
+}